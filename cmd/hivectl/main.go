@@ -0,0 +1,290 @@
+// Command hivectl drives a hive server's admin API from the command line, ex:
+//
+//	hivectl -target https://hive.example.com project create crowd project.json
+//	hivectl tasks import crowd tasks.json
+//	hivectl assets import crowd pages.csv
+//	hivectl task disable crowd categorize
+//	hivectl export -o crowd.tar.gz crowd
+//
+// The admin API key is read from -adminKey or HIVE_ADMIN_KEY.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nytlabs/hive/hive"
+)
+
+const usage = `usage: hivectl [-target url] [-adminKey key] command [args]
+
+commands:
+  project create {project_id} [project.json]  create or update a project, from a file of its fields
+  tasks import {project_id} {tasks.json}       create or update tasks, from {"Tasks": [...]} or a bare list
+  assets import [-batch n] [-dedup=false] {project_id} {file}
+                                               import assets from .json, .ndjson or .csv, by the file's extension
+  task enable {project_id} {task}              make a task available
+  task disable {project_id} {task}             make a task unavailable
+  task complete {project_id} {task}            verify the task's assets that meet its completion criteria
+  export [-task name] [-format json|tar.gz] [-o file] {project_id}
+                                               back up a project, or with -task export its submitted data as CSV
+`
+
+// client sends requests to a hive server's admin API.
+type client struct {
+	target   string
+	adminKey string
+	http     *http.Client
+}
+
+// do sends body to path with the given content type and returns the response body, or an error for anything but a 200.
+func (c *client) do(method string, path string, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.target, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.adminKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
+
+// sendJson sends body as JSON, returning the response body.
+func (c *client) sendJson(method string, path string, body interface{}) ([]byte, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.do(method, path, "application/json", bytes.NewReader(reqBody))
+}
+
+func main() {
+	target := flag.String("target", envDefault("HIVE_TARGET", "http://localhost:8080"), "url of the hive server, or HIVE_TARGET")
+	adminKey := flag.String("adminKey", os.Getenv("HIVE_ADMIN_KEY"), "API key for the admin endpoints, if the server requires one")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	c := &client{target: *target, adminKey: *adminKey, http: &http.Client{Timeout: 10 * time.Minute}}
+	out, err := run(c, flag.Args())
+	if err == errUsage {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "hivectl:", err)
+		os.Exit(1)
+	}
+	printResponse(out)
+}
+
+// errUsage means the command line didn't name a command hivectl knows, or left out its arguments.
+var errUsage = errors.New("usage")
+
+// run carries out the command in args, returning what to print.
+func run(c *client, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errUsage
+	}
+	switch args[0] {
+	case "project":
+		if len(args) < 3 || args[1] != "create" {
+			return nil, errUsage
+		}
+		return createProject(c, args[2], args[3:])
+	case "tasks":
+		if len(args) != 4 || args[1] != "import" {
+			return nil, errUsage
+		}
+		return importTasks(c, args[2], args[3])
+	case "assets":
+		if len(args) < 2 || args[1] != "import" {
+			return nil, errUsage
+		}
+		return importAssets(c, args[2:])
+	case "task":
+		if len(args) != 4 {
+			return nil, errUsage
+		}
+		switch args[1] {
+		case "enable", "disable", "complete":
+			return c.do("GET", fmt.Sprintf("/admin/projects/%s/tasks/%s/%s", url.PathEscape(args[2]), url.PathEscape(args[3]), args[1]), "", nil)
+		}
+		return nil, errUsage
+	case "export":
+		return export(c, args[1:])
+	}
+	return nil, errUsage
+}
+
+// createProject creates or updates a project, with the fields in the file named in args if there is one.
+func createProject(c *client, projectId string, args []string) ([]byte, error) {
+	var project hive.Project
+	if len(args) > 0 {
+		projectJson, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(projectJson, &project)
+		if err != nil {
+			return nil, fmt.Errorf("%s isn't a project: %v", args[0], err)
+		}
+	}
+	project.Id = projectId
+	return c.sendJson("POST", "/admin/projects/"+url.PathEscape(projectId), project)
+}
+
+// importTasks creates or updates the tasks in a file, given as {"Tasks": [...]} or as a bare list.
+func importTasks(c *client, projectId string, path string) ([]byte, error) {
+	tasksJson, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tasks struct {
+		Tasks []hive.Task
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(tasksJson), []byte("[")) {
+		err = json.Unmarshal(tasksJson, &tasks.Tasks)
+	} else {
+		err = json.Unmarshal(tasksJson, &tasks)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s isn't a list of tasks: %v", path, err)
+	}
+	if len(tasks.Tasks) == 0 {
+		return nil, fmt.Errorf("%s doesn't have any tasks", path)
+	}
+	return c.sendJson("POST", "/admin/projects/"+url.PathEscape(projectId)+"/tasks", tasks)
+}
+
+// importAssets sends a file of assets to the import that reads its format: .csv, .ndjson (or .jsonl), or .json,
+// which can be {"Assets": [...]} or a bare list.
+func importAssets(c *client, args []string) ([]byte, error) {
+	flags := flag.NewFlagSet("assets import", flag.ContinueOnError)
+	batch := flags.Int("batch", 0, "for .csv and .ndjson files, how many assets the server stores per bulk request")
+	dedup := flags.Bool("dedup", true, "update assets whose url is already in the project instead of creating more")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 2 {
+		return nil, errUsage
+	}
+	projectId, path := flags.Arg(0), flags.Arg(1)
+
+	query := url.Values{}
+	if *batch > 0 {
+		query.Set("batch", strconv.Itoa(*batch))
+	}
+	if !*dedup {
+		query.Set("dedup", "false")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	assetsPath := "/admin/projects/" + url.PathEscape(projectId) + "/assets"
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return c.do("POST", assetsPath+".csv?"+query.Encode(), "text/csv", file)
+	case ".ndjson", ".jsonl":
+		return c.do("POST", assetsPath+"?"+query.Encode(), "application/x-ndjson", file)
+	case ".json":
+		assetsJson, err := ioutil.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(assetsJson), []byte("[")) {
+			assetsJson = append(append([]byte(`{"Assets": `), assetsJson...), '}')
+		}
+		return c.do("POST", assetsPath+"?"+query.Encode(), "application/json", bytes.NewReader(assetsJson))
+	}
+	return nil, fmt.Errorf("%s should be a .json, .ndjson or .csv file", path)
+}
+
+// export downloads a project's backup, or with -task its submitted data for that task as CSV, to -o or stdout.
+func export(c *client, args []string) ([]byte, error) {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	task := flags.String("task", "", "export the submitted data for this task as CSV, instead of a backup")
+	format := flags.String("format", "", "json or tar.gz, for backups; defaults to tar.gz when -o ends in .tar.gz")
+	output := flags.String("o", "", "file to write to, instead of stdout")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 1 {
+		return nil, errUsage
+	}
+	projectId := flags.Arg(0)
+
+	path := "/admin/projects/" + url.PathEscape(projectId) + "/export"
+	if *task != "" {
+		path += ".csv?task=" + url.QueryEscape(*task)
+	} else {
+		if *format == "" && strings.HasSuffix(*output, ".tar.gz") {
+			*format = "tar.gz"
+		}
+		if *format != "" {
+			path += "?format=" + url.QueryEscape(*format)
+		}
+	}
+
+	exported, err := c.do("GET", path, "", nil)
+	if err != nil || *output == "" {
+		return exported, err
+	}
+	err = ioutil.WriteFile(*output, exported, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(`{"Exported": %q, "Bytes": %d}`, *output, len(exported))), nil
+}
+
+// printResponse writes a response to stdout, indenting it if it's JSON.
+func printResponse(out []byte) {
+	var indented bytes.Buffer
+	if json.Indent(&indented, out, "", "  ") == nil {
+		indented.WriteByte('\n')
+		out = indented.Bytes()
+	}
+	os.Stdout.Write(out)
+}
+
+// envDefault returns the environment variable name, or defaultVal when it isn't set.
+func envDefault(name string, defaultVal string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultVal
+}
@@ -0,0 +1,476 @@
+// Command hiveswagger writes a Swagger 2.0 document for hive's API from the annotations on its handlers, ex:
+//
+//	// @Title AdminAssetHandler
+//	// @Description retrieves a single project asset defined by an id
+//	// @Param   asset_id        path   string     true        "Retrieve asset with given ID only"
+//	// @Success 200 {object}  Asset
+//	// @Resource /assets
+//	// @Router /admin/projects/{project_id}/assets/{asset_id} [get]
+//
+// Response and body types named in the annotations are described from the package's struct declarations, following
+// their json tags. It's run by go generate in the hive package, which embeds the result:
+//
+//	hiveswagger -dir hive -o hive/swagger.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// schema is a JSON schema, as Swagger 2.0 uses them for bodies and responses.
+type schema map[string]interface{}
+
+type info struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Version     string   `json:"version"`
+	Contact     *contact `json:"contact,omitempty"`
+	License     *license `json:"license,omitempty"`
+}
+
+type contact struct {
+	Email string `json:"email"`
+}
+
+type license struct {
+	Name string `json:"name"`
+	Url  string `json:"url,omitempty"`
+}
+
+type tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Type        string `json:"type,omitempty"`
+	Schema      schema `json:"schema,omitempty"`
+}
+
+type response struct {
+	Description string `json:"description"`
+	Schema      schema `json:"schema,omitempty"`
+}
+
+type operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Consumes    []string              `json:"consumes,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type document struct {
+	Swagger             string                          `json:"swagger"`
+	Info                info                            `json:"info"`
+	BasePath            string                          `json:"basePath"`
+	Produces            []string                        `json:"produces"`
+	Tags                []tag                           `json:"tags,omitempty"`
+	SecurityDefinitions map[string]schema               `json:"securityDefinitions"`
+	Paths               map[string]map[string]operation `json:"paths"`
+	Definitions         map[string]schema               `json:"definitions"`
+}
+
+var (
+	annotationPattern = regexp.MustCompile(`^@(\w+)\s*(.*)$`)
+	paramPattern      = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+(true|false)\s*(?:"(.*)")?$`)
+	responsePattern   = regexp.MustCompile(`^(\d{3})\s+\{(\w+)\}\s+(\S+)\s*(.*)$`)
+	routerPattern     = regexp.MustCompile(`^(\S+)\s+\[(\w+)\]$`)
+	subApiPattern     = regexp.MustCompile(`^(.*?)\s*\[/(\S+)\]$`)
+)
+
+// primitiveTypes are the Swagger types for parameter and field types that aren't declared in the package.
+var primitiveTypes = map[string]schema{
+	"string":  {"type": "string"},
+	"bool":    {"type": "boolean"},
+	"boolean": {"type": "boolean"},
+	"int":     {"type": "integer"},
+	"int32":   {"type": "integer", "format": "int32"},
+	"int64":   {"type": "integer", "format": "int64"},
+	"uint":    {"type": "integer"},
+	"uint32":  {"type": "integer", "format": "int32"},
+	"uint64":  {"type": "integer", "format": "int64"},
+	"byte":    {"type": "integer"},
+	"float32": {"type": "number", "format": "float"},
+	"float64": {"type": "number", "format": "double"},
+	"file":    {"type": "file"},
+	// wrapError's {"error": "..."}
+	"error": {"type": "object", "properties": map[string]interface{}{"error": schema{"type": "string"}}},
+}
+
+// generator collects the operations and the types they need from a package.
+type generator struct {
+	types    map[string]*ast.TypeSpec
+	doc      document
+	problems []string
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package whose handlers are annotated")
+	output := flag.String("o", "", "file to write the document to, instead of stdout")
+	flag.Parse()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, *dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		fail(err)
+	}
+	if len(pkgs) != 1 {
+		fail(fmt.Errorf("%s should hold exactly one package, found %d", *dir, len(pkgs)))
+	}
+
+	g := &generator{
+		types: make(map[string]*ast.TypeSpec),
+		doc: document{
+			Swagger:  "2.0",
+			BasePath: "/",
+			Produces: []string{"application/json"},
+			SecurityDefinitions: map[string]schema{
+				"adminKey": {"type": "apiKey", "in": "header", "name": "Authorization", "description": "An admin API key, as \"Bearer {key}\""},
+			},
+			Paths:       make(map[string]map[string]operation),
+			Definitions: make(map[string]schema),
+		},
+	}
+	var files []*ast.File
+	var names []string
+	for _, pkg := range pkgs {
+		for name := range pkg.Files {
+			names = append(names, name)
+		}
+		// files in name order, so the document doesn't change between runs
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, pkg.Files[name])
+		}
+	}
+	for _, file := range files {
+		g.collectTypes(file)
+	}
+	for _, file := range files {
+		g.readFile(fset, file)
+	}
+	if len(g.problems) > 0 {
+		fail(fmt.Errorf("bad annotations:\n\t%s", strings.Join(g.problems, "\n\t")))
+	}
+
+	docJson, err := json.MarshalIndent(g.doc, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	docJson = append(docJson, '\n')
+	if *output == "" {
+		os.Stdout.Write(docJson)
+		return
+	}
+	err = ioutil.WriteFile(*output, docJson, 0644)
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "hiveswagger:", err)
+	os.Exit(1)
+}
+
+// collectTypes records the package-level types declared in a file.
+func (g *generator) collectTypes(file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			g.types[typeSpec.Name.Name] = typeSpec
+		}
+	}
+}
+
+// readFile adds the API's description from the comment holding @APIVersion, and an operation for each annotated
+// function.
+func (g *generator) readFile(fset *token.FileSet, file *ast.File) {
+	for _, group := range file.Comments {
+		if strings.Contains(group.Text(), "@APIVersion") || strings.Contains(group.Text(), "@SubApi") {
+			g.readApiInfo(annotations(group))
+		}
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Doc == nil || !strings.Contains(fn.Doc.Text(), "@Router") {
+			continue
+		}
+		pos := fset.Position(fn.Pos())
+		g.readOperation(fmt.Sprintf("%s:%d", pos.Filename, pos.Line), annotations(fn.Doc))
+	}
+}
+
+// annotations returns a comment's @ lines as name and value pairs, in order.
+func annotations(group *ast.CommentGroup) [][2]string {
+	var found [][2]string
+	for _, line := range strings.Split(group.Text(), "\n") {
+		match := annotationPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match != nil {
+			found = append(found, [2]string{match[1], strings.TrimSpace(match[2])})
+		}
+	}
+	return found
+}
+
+func (g *generator) readApiInfo(lines [][2]string) {
+	for _, line := range lines {
+		switch line[0] {
+		case "APIVersion":
+			g.doc.Info.Version = line[1]
+		case "Title":
+			g.doc.Info.Title = line[1]
+		case "Description":
+			g.doc.Info.Description = line[1]
+		case "Contact":
+			g.doc.Info.Contact = &contact{Email: line[1]}
+		case "License":
+			if g.doc.Info.License == nil {
+				g.doc.Info.License = &license{}
+			}
+			g.doc.Info.License.Name = line[1]
+		case "LicenseUrl":
+			if g.doc.Info.License == nil {
+				g.doc.Info.License = &license{}
+			}
+			g.doc.Info.License.Url = line[1]
+		case "SubApi":
+			if match := subApiPattern.FindStringSubmatch(line[1]); match != nil {
+				g.doc.Tags = append(g.doc.Tags, tag{Name: match[2], Description: match[1]})
+			}
+		}
+	}
+}
+
+// readOperation adds the operation a handler's annotations describe, under each path it's routed from.
+func (g *generator) readOperation(where string, lines [][2]string) {
+	op := operation{Responses: make(map[string]response)}
+	var routes [][2]string
+	for _, line := range lines {
+		value := line[1]
+		switch line[0] {
+		case "Title":
+			op.OperationId = value
+		case "Description":
+			op.Summary = value
+		case "Accept":
+			switch value {
+			case "json":
+				op.Consumes = append(op.Consumes, "application/json")
+			default:
+				op.Consumes = append(op.Consumes, value)
+			}
+		case "Param":
+			match := paramPattern.FindStringSubmatch(value)
+			if match == nil {
+				g.problem(where, "@Param %s", value)
+				continue
+			}
+			description := strings.Replace(match[5], `\"`, `"`, -1)
+			op.Parameters = append(op.Parameters, g.parameter(match[1], match[2], match[3], match[4] == "true", description))
+		case "Success", "Failure":
+			match := responsePattern.FindStringSubmatch(value)
+			if match == nil {
+				g.problem(where, "@%s %s", line[0], value)
+				continue
+			}
+			description := strings.TrimSpace(match[4])
+			if description == "" {
+				description = match[3]
+			}
+			op.Responses[match[1]] = response{Description: description, Schema: g.typeSchema(match[3])}
+		case "Resource":
+			op.Tags = append(op.Tags, strings.TrimPrefix(value, "/"))
+		case "Router":
+			match := routerPattern.FindStringSubmatch(value)
+			if match == nil {
+				g.problem(where, "@Router %s", value)
+				continue
+			}
+			routes = append(routes, [2]string{match[1], strings.ToLower(match[2])})
+		}
+	}
+
+	for _, route := range routes {
+		path, method := route[0], route[1]
+		if strings.HasPrefix(path, "/admin") {
+			op.Security = []map[string][]string{{"adminKey": {}}}
+		}
+		if g.doc.Paths[path] == nil {
+			g.doc.Paths[path] = make(map[string]operation)
+		}
+		if _, ok := g.doc.Paths[path][method]; ok {
+			g.problem(where, "%s %s is annotated on more than one handler", method, path)
+		}
+		g.doc.Paths[path][method] = op
+	}
+}
+
+func (g *generator) problem(where string, format string, args ...interface{}) {
+	g.problems = append(g.problems, where+": "+fmt.Sprintf(format, args...))
+}
+
+// parameter describes a request parameter. Body parameters get a schema, everything else a simple type.
+func (g *generator) parameter(name string, in string, typeName string, required bool, description string) parameter {
+	param := parameter{Name: name, In: in, Description: description, Required: required || in == "path"}
+	if in == "body" {
+		param.Schema = g.typeSchema(typeName)
+		return param
+	}
+	if primitive, ok := primitiveTypes[typeName]; ok {
+		param.Type = primitive["type"].(string)
+	} else {
+		param.Type = "string"
+	}
+	return param
+}
+
+// typeSchema describes a type named in an annotation, adding definitions for the package's types it uses.
+func (g *generator) typeSchema(name string) schema {
+	if primitive, ok := primitiveTypes[name]; ok {
+		return primitive
+	}
+	return g.exprSchema(&ast.Ident{Name: name})
+}
+
+// exprSchema describes a type expression the way encoding/json would marshal it.
+func (g *generator) exprSchema(expr ast.Expr) schema {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if primitive, ok := primitiveTypes[t.Name]; ok && t.Name != "file" && t.Name != "error" {
+			return primitive
+		}
+		spec, ok := g.types[t.Name]
+		if !ok {
+			return schema{}
+		}
+		if _, isStruct := spec.Type.(*ast.StructType); !isStruct {
+			// named lists, maps and strings marshal the same as what they're made of
+			return g.exprSchema(spec.Type)
+		}
+		if _, ok := g.doc.Definitions[t.Name]; !ok {
+			// reserved first, so types that refer to themselves end
+			g.doc.Definitions[t.Name] = schema{}
+			g.doc.Definitions[t.Name] = g.structSchema(spec.Type.(*ast.StructType))
+		}
+		return schema{"$ref": "#/definitions/" + t.Name}
+	case *ast.StarExpr:
+		return g.exprSchema(t.X)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return schema{"type": "string", "format": "byte"}
+		}
+		return schema{"type": "array", "items": g.exprSchema(t.Elt)}
+	case *ast.MapType:
+		return schema{"type": "object", "additionalProperties": g.exprSchema(t.Value)}
+	case *ast.StructType:
+		return g.structSchema(t)
+	case *ast.SelectorExpr:
+		switch fmt.Sprintf("%s.%s", t.X, t.Sel.Name) {
+		case "time.Time":
+			return schema{"type": "string", "format": "date-time"}
+		case "time.Duration":
+			return schema{"type": "integer", "format": "int64", "description": "nanoseconds"}
+		}
+	}
+	// interface{}, json.RawMessage and anything else that can hold any JSON
+	return schema{}
+}
+
+// structSchema describes a struct's exported fields, named by their json tags, with the fields of embedded structs
+// promoted as encoding/json does.
+func (g *generator) structSchema(st *ast.StructType) schema {
+	properties := make(map[string]interface{})
+	for _, field := range st.Fields.List {
+		jsonName := ""
+		if field.Tag != nil {
+			tags := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+			jsonName = strings.Split(tags.Get("json"), ",")[0]
+		}
+		if jsonName == "-" {
+			continue
+		}
+		switch field.Type.(type) {
+		case *ast.FuncType, *ast.ChanType:
+			continue
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			embedded := field.Type
+			if star, ok := embedded.(*ast.StarExpr); ok {
+				embedded = star.X
+			}
+			ident, ok := embedded.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			if spec, ok := g.types[ident.Name]; ok && jsonName == "" {
+				if embeddedStruct, ok := spec.Type.(*ast.StructType); ok {
+					for name, property := range g.structSchema(embeddedStruct)["properties"].(map[string]interface{}) {
+						if _, ok := properties[name]; !ok {
+							properties[name] = property
+						}
+					}
+					continue
+				}
+			}
+			names = []*ast.Ident{ident}
+		}
+
+		for _, name := range names {
+			if !name.IsExported() && jsonName == "" {
+				continue
+			}
+			property := g.exprSchema(field.Type)
+			if comment := strings.TrimSpace(field.Comment.Text()); comment != "" {
+				// a $ref can't carry a description of its own, so it's wrapped
+				if _, isRef := property["$ref"]; isRef {
+					property = schema{"allOf": []schema{property}}
+				} else {
+					property = copySchema(property)
+				}
+				property["description"] = comment
+			}
+			propertyName := name.Name
+			if jsonName != "" {
+				propertyName = jsonName
+			}
+			properties[propertyName] = property
+		}
+	}
+	return schema{"type": "object", "properties": properties}
+}
+
+// copySchema copies the top level of a schema, so a field's description doesn't end up on a shared primitive.
+func copySchema(s schema) schema {
+	copied := make(schema, len(s))
+	for key, value := range s {
+		copied[key] = value
+	}
+	return copied
+}
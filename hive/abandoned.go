@@ -0,0 +1,128 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// abandonedAsset is an asset users keep being handed and letting their lease run out on, often a sign it's
+// unreadable or too hard.
+type abandonedAsset struct {
+	Asset     Asset
+	Abandoned int // assignments on it that expired
+}
+
+type abandonedResponse struct {
+	Abandoned int              // expired assignments, across all assets
+	Assets    []abandonedAsset // assets abandoned at least min times, most first
+}
+
+type abandonedAgg struct {
+	Assets struct {
+		Buckets []struct {
+			Key   string `json:"key"`
+			Count int    `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"assets"`
+}
+
+// FindAbandonedAssets returns the size assets in the current project with the most expired assignments, leaving out
+// those with fewer than min. taskId narrows it to one task, unless it's empty.
+func (s *Server) FindAbandonedAssets(taskId string, min int, size int) (abandoned abandonedResponse, err error) {
+	musts := []string{
+		fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId),
+		`{ "term": { "State": "expired" } }`,
+	}
+	if taskId != "" {
+		musts = append(musts, fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskId))
+	}
+
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": { "must": [ %s ] }
+				}
+			}
+		},
+		"size": 0,
+		"aggs": {
+			"assets": {
+				"terms": { "field": "Asset.Id", "size": %d, "min_doc_count": %d, "order": { "_count": "desc" } }
+			}
+		}
+	}`, strings.Join(musts, ", "), size, min)
+
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return
+	}
+	var agg abandonedAgg
+	err = json.Unmarshal(results.Aggregations, &agg)
+	if err != nil {
+		return
+	}
+
+	abandoned.Abandoned = results.Hits.Total
+	abandoned.Assets = []abandonedAsset{}
+	for _, bucket := range agg.Assets.Buckets {
+		asset, err := s.FindAsset(bucket.Key)
+		if err != nil || asset == nil {
+			// the asset may have been deleted since
+			continue
+		}
+		abandoned.Assets = append(abandoned.Assets, abandonedAsset{
+			Asset:     *asset,
+			Abandoned: bucket.Count,
+		})
+	}
+	return
+}
+
+// @Title AdminAbandonedAssetsHandler
+// @Description returns the assets whose assignments expire most often, to spot ones users give up on
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task        query   string     false        "If specified, only counts expired assignments for this task"
+// @Param   min        query   int     false        "Leaves out assets abandoned fewer times than this, defaults to 2"
+// @Param   size        query   int     false        "How many assets to return, defaults to 10"
+// @Success 200 {object}  abandonedResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/abandoned [get]
+func (s *Server) AdminAbandonedAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	taskId := queryParams.Get("task")
+	if taskId != "" && !strings.HasPrefix(taskId, s.ActiveProjectId+"-") {
+		taskId = s.ActiveProjectId + "-" + taskId
+	}
+	min, err := strconv.Atoi(defaultQuery(queryParams, "min", "2"))
+	if err != nil || min < 1 {
+		min = 2
+	}
+	size, err := strconv.Atoi(defaultQuery(queryParams, "size", "10"))
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	abandoned, err := s.FindAbandonedAssets(taskId, min, size)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	abandonedJson, err := json.Marshal(abandoned)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, abandonedJson)
+}
@@ -0,0 +1,319 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Announcement is a message shown to a project's contributors in-app, ex: planned downtime or a change to the rules.
+// It is active between Starts and Ends; leaving either unset makes that side of the window open-ended.
+type Announcement struct {
+	Id       string    // guid, auto-generated
+	Project  string    // announcements are scoped to projects
+	Message  string    // required, the text to display
+	Severity string    // "info", "warning" or "critical"; defaults to "info"
+	Starts   time.Time // optional, when the announcement starts showing
+	Ends     time.Time // optional, when the announcement stops showing
+	Created  time.Time
+}
+
+type announcementResponse struct {
+	Announcement Announcement
+}
+type announcementsResponse struct {
+	Announcements []Announcement
+	Meta          meta
+}
+
+// announcedAssignment adds the project's active announcement, if any, alongside an assignment's own fields.
+type announcedAssignment struct {
+	*Assignment
+	Announcement *Announcement `json:",omitempty"`
+}
+
+// announcementSeverities ranks severities so the most important active announcement wins.
+var announcementSeverities = map[string]int{
+	"info":     0,
+	"warning":  1,
+	"critical": 2,
+}
+
+// IsActive reports whether the announcement should be shown at the given time.
+func (a Announcement) IsActive(now time.Time) bool {
+	if !a.Starts.IsZero() && now.Before(a.Starts) {
+		return false
+	}
+	if !a.Ends.IsZero() && now.After(a.Ends) {
+		return false
+	}
+	return true
+}
+
+// FindAnnouncement looks up an announcement by id.
+func (s *Server) FindAnnouncement(id string) (announcement *Announcement, err error) {
+	err = s.Store.Get("announcements", id, &announcement)
+	if err != nil {
+		return nil, err
+	}
+	if announcement.Project != s.ActiveProjectId {
+		return nil, notFound("Sorry, there isn't an announcement with that id in this project.")
+	}
+	return announcement, nil
+}
+
+// FindAnnouncements returns announcements in the current project, newest first, along with pagination meta information.
+func (s *Server) FindAnnouncements(p Params) (announcements []Announcement, m meta, err error) {
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"term": { "Project": "%s" }
+				}
+			}
+		},
+		"from": %s,
+		"size": %s,
+		"sort": [ { "Created": { "order" : "desc" } } ]
+	}`, s.ActiveProjectId, p.From, p.Size)
+	results, err := s.Store.Search("announcements", searchJson)
+	if err != nil {
+		return
+	}
+
+	m.Total = results.Hits.Total
+	m.From, _ = strconv.Atoi(p.From)
+	m.Size, _ = strconv.Atoi(p.Size)
+
+	for _, hit := range results.Hits.Hits {
+		var announcement Announcement
+		err = json.Unmarshal(*hit.Source, &announcement)
+		if err != nil {
+			return
+		}
+		announcements = append(announcements, announcement)
+	}
+	if len(announcements) <= 0 {
+		announcements = make([]Announcement, 0)
+	}
+	return
+}
+
+// ActiveAnnouncement returns the current project's most severe active announcement, preferring the newest on ties.
+// It returns nil when nothing is active.
+func (s *Server) ActiveAnnouncement() (*Announcement, error) {
+	announcements, _, err := s.FindAnnouncements(Params{From: "0", Size: "100"})
+	if err != nil {
+		return nil, err
+	}
+
+	var active *Announcement
+	now := time.Now().UTC()
+	for i, announcement := range announcements {
+		if !announcement.IsActive(now) {
+			continue
+		}
+		if active == nil || announcementSeverities[announcement.Severity] > announcementSeverities[active.Severity] {
+			active = &announcements[i]
+		}
+	}
+	return active, nil
+}
+
+// SaveAnnouncement creates an announcement from the request body, or updates it when id is given.
+func (s *Server) SaveAnnouncement(id string, requestBody io.Reader) (*Announcement, error) {
+	body, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	var announcement Announcement
+	err = json.Unmarshal(body, &announcement)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := &ValidationError{}
+	if announcement.Message == "" {
+		invalid.add("Message", "is required")
+	}
+	if announcement.Severity == "" {
+		announcement.Severity = "info"
+	}
+	if _, ok := announcementSeverities[announcement.Severity]; !ok {
+		invalid.add("Severity", "has to be one of info, warning or critical, not %q", announcement.Severity)
+	}
+	if !announcement.Starts.IsZero() && !announcement.Ends.IsZero() && announcement.Ends.Before(announcement.Starts) {
+		invalid.add("Ends", "can't be before Starts")
+	}
+	err = invalid.orNil()
+	if err != nil {
+		return nil, err
+	}
+	announcement.Project = s.ActiveProjectId
+
+	if id != "" {
+		existing, err := s.FindAnnouncement(id)
+		if err != nil {
+			return nil, err
+		}
+		announcement.Id = existing.Id
+		announcement.Created = existing.Created
+	} else {
+		announcement.Created = time.Now().UTC()
+
+		// store in elasticsearch, which will generate a unique id
+		resultId, err := s.Store.Put("announcements", "", announcement)
+		if err != nil {
+			return nil, err
+		}
+		announcement.Id = resultId
+	}
+
+	_, err = s.Store.Put("announcements", announcement.Id, announcement)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// @Title AdminAnnouncementsHandler
+// @Description returns a paginated list of a project's announcements, newest first
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   from        query   int     false        "If specified, will return a set of announcements starting with from number"
+// @Param   size        query   int     false        "If specified, will return a total number of announcements specified as size"
+// @Success 200 {object}  announcementsResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/announcements [get]
+func (s *Server) AdminAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	p := Params{
+		From: defaultQuery(queryParams, "from", "0"),
+		Size: defaultQuery(queryParams, "size", "10"),
+	}
+
+	announcements, m, err := s.FindAnnouncements(p)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcementsJson, err := json.Marshal(announcementsResponse{
+		Announcements: announcements,
+		Meta:          m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, announcementsJson)
+}
+
+// @Title AdminAnnouncementHandler
+// @Description returns a single announcement by ID
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   announcement_id     path    string     true        "Announcement ID"
+// @Success 200 {object}  announcementResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/announcements/{announcement_id} [get]
+func (s *Server) AdminAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	announcement, err := s.FindAnnouncement(vars["announcement_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcementJson, err := json.Marshal(announcementResponse{
+		Announcement: *announcement,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, announcementJson)
+}
+
+// @Title AdminCreateAnnouncementHandler
+// @Description creates an announcement, or updates one when an announcement ID is given
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   announcement_id     path    string     false        "Announcement ID, when updating"
+// @Param   announcement        body   string     true        "JSON-formatted announcement, ex: {\"Message\": \"Down for maintenance at 5pm\", \"Severity\": \"warning\", \"Ends\": \"2015-03-02T18:00:00Z\"}"
+// @Success 200 {object}  announcementResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/announcements/{announcement_id} [post]
+func (s *Server) AdminCreateAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	announcement, err := s.SaveAnnouncement(vars["announcement_id"], r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcementJson, err := json.Marshal(announcementResponse{
+		Announcement: *announcement,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, announcementJson)
+}
+
+// @Title AdminDeleteAnnouncementHandler
+// @Description deletes an announcement
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   announcement_id     path    string     true        "Announcement ID"
+// @Success 200 {object}  announcementResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/announcements/{announcement_id} [delete]
+func (s *Server) AdminDeleteAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	announcement, err := s.FindAnnouncement(vars["announcement_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.Store.Delete("announcements", announcement.Id)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcementJson, err := json.Marshal(announcementResponse{
+		Announcement: *announcement,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, announcementJson)
+}
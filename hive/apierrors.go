@@ -0,0 +1,116 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StatusError is an error that says how it should be answered: with which HTTP status, and which code, for
+// clients to tell errors apart without matching their messages.
+type StatusError struct {
+	Status  int
+	Code    string // ex: "not_found", see errorCode
+	Message string
+}
+
+func (err *StatusError) Error() string {
+	return err.Message
+}
+
+// unauthorized is a 401 for a request that has to be made as a user, but has no session.
+func unauthorized(format string, args ...interface{}) error {
+	return &StatusError{Status: 401, Code: "unauthorized", Message: fmt.Sprintf(format, args...)}
+}
+
+// notFound is a 404 for a document that isn't there, or isn't in the current project.
+func notFound(format string, args ...interface{}) error {
+	return &StatusError{Status: 404, Code: "not_found", Message: fmt.Sprintf(format, args...)}
+}
+
+// conflict is a 409 for something that can't be created because it already exists.
+func conflict(format string, args ...interface{}) error {
+	return &StatusError{Status: 409, Code: "conflict", Message: fmt.Sprintf(format, args...)}
+}
+
+// invalidState is a 422 for a change the document's current state doesn't allow, ex: reviewing an assignment twice.
+func invalidState(format string, args ...interface{}) error {
+	return &StatusError{Status: 422, Code: "invalid_state", Message: fmt.Sprintf(format, args...)}
+}
+
+// tooManyRequests is a 429 for a client, or on behalf of an address, that's asked for something too often lately.
+func tooManyRequests(format string, args ...interface{}) error {
+	return &StatusError{Status: 429, Code: "too_many_requests", Message: fmt.Sprintf(format, args...)}
+}
+
+// statusCodes are the codes of errors without one of their own, by the status they're answered with.
+var statusCodes = map[int]string{
+	400: "invalid_request",
+	401: "unauthorized",
+	403: "forbidden",
+	404: "not_found",
+	405: "method_not_allowed",
+	409: "conflict",
+	422: "invalid_state",
+	429: "too_many_requests",
+}
+
+// errorResponse is how errors are answered. Code is stable, unlike Error, so clients can match on it.
+type errorResponse struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code"`
+	Fields []FieldError `json:"fields,omitempty"` // what's wrong with each field of the request body, for "invalid_request"
+}
+
+// errorStatus is the status to answer err with: 400 for a body that isn't valid, 404 for a document that isn't
+// there, 409 for one that already is or changed underneath the request, 422 for a change its state doesn't allow,
+// 403 for the limits on handing out work, and 500 for anything else.
+func errorStatus(err error) int {
+	switch err := err.(type) {
+	case *StatusError:
+		return err.Status
+	case *ValidationError, *json.SyntaxError, *json.UnmarshalTypeError:
+		return 400
+	}
+	switch err {
+	case ErrNotFound:
+		return 404
+	case ErrConflict:
+		return 409
+	case ErrTooManyUnfinished, ErrTaskLimitReached:
+		return 403
+	}
+	return 500
+}
+
+// errorCode is err's code, or "" when it doesn't have one and gets the code of the status it's answered with.
+func errorCode(err error) string {
+	switch err := err.(type) {
+	case *StatusError:
+		return err.Code
+	case *ValidationError, *json.SyntaxError, *json.UnmarshalTypeError:
+		return "invalid_request"
+	}
+	switch err {
+	case ErrNotFound:
+		return "not_found"
+	case ErrConflict:
+		return "conflict"
+	case ErrTooManyUnfinished:
+		return "too_many_unfinished"
+	case ErrTaskLimitReached:
+		return "task_limit_reached"
+	case ErrConsentRequired:
+		return "consent_required"
+	case ErrAdminKeyRequired:
+		return "admin_key_required"
+	case ErrAdminKeyInvalid:
+		return "admin_key_invalid"
+	}
+	return ""
+}
+
+// wrapFailure answers err with errorStatus, see wrapError.
+func (s *Server) wrapFailure(w http.ResponseWriter, r *http.Request, err error) {
+	s.wrapResponse(w, r, errorStatus(err), s.wrapError(err))
+}
@@ -0,0 +1,61 @@
+package hive
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrAdminKeyRequired and ErrAdminKeyInvalid are returned by admin endpoints when AdminKeys are set.
+var ErrAdminKeyRequired = errors.New("Sorry, admin endpoints need an API key: send it as Authorization: Bearer {key}.")
+var ErrAdminKeyInvalid = errors.New("Sorry, that API key isn't valid for admin endpoints.")
+
+// adminKey returns the API key sent with a request, ex: "Authorization: Bearer 0123abcd".
+func adminKey(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(authorization[len("Bearer "):])
+	}
+	return ""
+}
+
+// validAdminKey reports whether key is one of the server's AdminKeys, comparing in constant time.
+func (s *Server) validAdminKey(key string) bool {
+	valid := false
+	for _, adminKey := range s.AdminKeys {
+		if adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// requireAdminKey is middleware that turns away admin requests without a valid API key,
+// responding 401 when none was sent and 403 when it's wrong. CORS preflight requests and the dashboard's
+// static files are let through, as are requests from users signed in to the project they're for as a reviewer
+// or above, whose role requireRole checks once the request is routed.
+func (s *Server) requireAdminKey(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || isAdminUiPath(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		key := adminKey(r)
+		if key == "" {
+			if user := s.adminSessionUser(r); user != nil {
+				h.ServeHTTP(w, withAdminUser(r, user))
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hive"`)
+			s.wrapResponse(w, r, 401, s.wrapError(ErrAdminKeyRequired))
+			return
+		}
+		if !s.validAdminKey(key) {
+			s.wrapResponse(w, r, 403, s.wrapError(ErrAdminKeyInvalid))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
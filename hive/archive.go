@@ -0,0 +1,164 @@
+package hive
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// FindLiveTasks returns every task in the current project that hasn't been archived.
+// Archived tasks no longer count towards asset verification or narrow other tasks' AssignmentCriteria.
+func (s *Server) FindLiveTasks() (tasks []Task, err error) {
+	err = s.forEachProjectDoc("tasks", func(source json.RawMessage) error {
+		var task Task
+		err := json.Unmarshal(source, &task)
+		if err != nil {
+			return err
+		}
+		if task.CurrentState != "archived" {
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	return
+}
+
+// archivedTaskNames returns the names of the current project's archived tasks.
+func (s *Server) archivedTaskNames() (map[string]bool, error) {
+	archived := make(map[string]bool)
+	err := s.forEachProjectDoc("tasks", func(source json.RawMessage) error {
+		var task Task
+		err := json.Unmarshal(source, &task)
+		if err != nil {
+			return err
+		}
+		if task.CurrentState == "archived" {
+			archived[task.Name] = true
+		}
+		return nil
+	})
+	return archived, err
+}
+
+// liveCriteria returns the task with any AssignmentCriteria rules on archived tasks left out.
+func (s *Server) liveCriteria(task Task) (Task, error) {
+	archived, err := s.archivedTaskNames()
+	if err != nil || len(archived) == 0 {
+		return task, err
+	}
+
+	submittedData := make(map[string]interface{})
+	for taskName, rule := range task.AssignmentCriteria.SubmittedData {
+		if !archived[taskName] {
+			submittedData[taskName] = rule
+		}
+	}
+	task.AssignmentCriteria.SubmittedData = submittedData
+	return task, nil
+}
+
+// DeleteTask removes a task from the current project, along with any AssignmentCriteria rules other tasks have on it.
+// Its assignments, and the data they submitted to assets, are kept.
+func (s *Server) DeleteTask(taskId string) (*Task, error) {
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.forEachProjectDoc("tasks", func(source json.RawMessage) error {
+		var other Task
+		err := json.Unmarshal(source, &other)
+		if err != nil {
+			return err
+		}
+		if _, ok := other.AssignmentCriteria.SubmittedData[task.Name]; !ok || other.Id == task.Id {
+			return nil
+		}
+		delete(other.AssignmentCriteria.SubmittedData, task.Name)
+		_, err = s.Store.Put("tasks", other.Id, other)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Store.Delete("tasks", task.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// @Title AdminDeleteTaskHandler
+// @Description deletes a task, dropping it from other tasks' assignment criteria; archive a task instead to keep it on record
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id        path   string     true        "Task ID"
+// @Success 200 {object}  taskResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id} [delete]
+func (s *Server) AdminDeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+
+	task, err := s.DeleteTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	taskJson, err := json.Marshal(taskResponse{
+		Task: *task,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, taskJson)
+}
+
+// @Title ArchiveTaskHandler
+// @Description retires a task: it stops being assigned, counting towards asset verification and narrowing other tasks' assignment criteria
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id        path   string     true        "Task ID"
+// @Success 200 {object}  taskResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/archive [get]
+func (s *Server) ArchiveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+
+	task, err := s.UpdateTaskState(taskId, "archived")
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	taskJson, err := json.Marshal(taskResponse{
+		Task: *task,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, taskJson)
+}
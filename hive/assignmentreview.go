@@ -0,0 +1,297 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type assignmentReviewQueueResponse struct {
+	Assignments []Assignment
+	Meta        meta
+}
+
+// reviewQueueMusts returns the filters for finished assignments in the current project, optionally for one task.
+func (s *Server) reviewQueueMusts(taskId string) []string {
+	musts := []string{
+		fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId),
+		`{ "term": { "State": "finished" } }`,
+	}
+	if taskId != "" {
+		musts = append(musts, fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskId))
+	}
+	return musts
+}
+
+// SampleReviewQueue returns up to size finished assignments that haven't been reviewed yet, picked at random,
+// and how many are waiting in all.
+func (s *Server) SampleReviewQueue(taskId string, size int) (assignments []Assignment, m meta, err error) {
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"function_score": {
+				"query": {
+					"filtered": {
+						"filter": {
+							"bool": {
+								"must": [ %s ],
+								"must_not": [ { "exists": { "field": "Review" } } ]
+							}
+						}
+					}
+				},
+				"random_score": { "seed": %d },
+				"boost_mode": "replace"
+			}
+		},
+		"from": 0,
+		"size": %d
+	}`, strings.Join(s.reviewQueueMusts(taskId), ", "), time.Now().UnixNano(), size)
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return
+	}
+
+	m.Total = results.Hits.Total
+	m.Size = size
+	assignments = []Assignment{}
+	for _, hit := range results.Hits.Hits {
+		var assignment Assignment
+		err = json.Unmarshal(*hit.Source, &assignment)
+		if err != nil {
+			return
+		}
+		assignments = append(assignments, assignment)
+	}
+	return
+}
+
+// DisputedReviewQueue returns the finished assignments that haven't been reviewed yet on assets where the task's
+// answers disagree, grouped by asset, oldest first within each, with pagination meta information.
+func (s *Server) DisputedReviewQueue(taskId string, from int, size int) (assignments []Assignment, m meta, err error) {
+	// the answers so far for each task and asset, reviewed or not
+	answers := make(map[string][]Assignment)
+	filters := []string{`{ "terms": { "State": [ "finished", "verified" ] } }`}
+	if taskId != "" {
+		filters = append(filters, fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskId))
+	}
+	err = s.forEachMatchingDoc("assignments", filters, func(source json.RawMessage) error {
+		var assignment Assignment
+		err := json.Unmarshal(source, &assignment)
+		if err != nil {
+			return err
+		}
+		key := assignment.Task + "HIVE" + assignment.Asset.Id
+		answers[key] = append(answers[key], assignment)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var disputed []Assignment
+	for _, group := range answers {
+		agreed := true
+		for _, assignment := range group[1:] {
+			if !sameAnswer(group[0].SubmittedData, assignment.SubmittedData) {
+				agreed = false
+				break
+			}
+		}
+		if agreed {
+			continue
+		}
+		for _, assignment := range group {
+			if assignment.State == "finished" && assignment.Review == "" {
+				disputed = append(disputed, assignment)
+			}
+		}
+	}
+	sort.Slice(disputed, func(i, j int) bool {
+		if disputed[i].Asset.Id != disputed[j].Asset.Id {
+			return disputed[i].Asset.Id < disputed[j].Asset.Id
+		}
+		if disputed[i].Task != disputed[j].Task {
+			return disputed[i].Task < disputed[j].Task
+		}
+		return disputed[i].Updated.Before(disputed[j].Updated)
+	})
+
+	m = meta{Total: len(disputed), From: from, Size: size}
+	assignments = []Assignment{}
+	if from < len(disputed) {
+		end := from + size
+		if end > len(disputed) {
+			end = len(disputed)
+		}
+		assignments = disputed[from:end]
+	}
+	return
+}
+
+// ReviewAssignment accepts or rejects a finished assignment. Rejected assignments move to the "rejected" state so
+// they no longer count towards consensus, and either way the verdict is tallied in the user's Quality.
+func (s *Server) ReviewAssignment(assignmentId string, accepted bool, reviewer string) (*Assignment, error) {
+	// a second reviewer at the same time sees the first's verdict, rather than counting the assignment again
+	var assignment Assignment
+	err := s.updateDoc("assignments", assignmentId, &assignment, func(found bool) error {
+		if !found || assignment.Project != s.ActiveProjectId {
+			return notFound("Sorry, there isn't an assignment with that id in this project.")
+		}
+		if assignment.Review != "" {
+			return invalidState("Sorry, this assignment was already %s.", assignment.Review)
+		}
+		if assignment.State != "finished" {
+			return invalidState("Sorry, only finished assignments can be reviewed, this one is %s.", assignment.State)
+		}
+
+		assignment.Review = "accepted"
+		if !accepted {
+			assignment.Review = "rejected"
+			assignment.State = "rejected"
+		}
+		assignment.Reviewer = reviewer
+		assignment.Updated = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !accepted {
+		_, err = s.updateAsset(assignment.Asset.Id, func(asset *Asset) error {
+			asset.Counts["finished"] -= 1
+			asset.Counts["rejected"] += 1
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = s.updateUser(assignment.User, func(user *User) error {
+		user.Quality.Reviewed++
+		if !accepted {
+			user.Quality.Rejected++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// @Title AdminReviewQueueHandler
+// @Description returns finished assignments no one has reviewed yet, a random sample or those on assets whose answers disagree
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task        query   string     false        "If specified, will scope the queue to this task"
+// @Param   disagreement        query   bool     false        "If true, returns assignments on assets whose answers disagree, grouped by asset, instead of a random sample"
+// @Param   from        query   int     false        "With disagreement, will return a set of assignments starting with from number"
+// @Param   size        query   int     false        "How many assignments to return, defaults to 10"
+// @Success 200 {object}  assignmentReviewQueueResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assignments
+// @Router /admin/projects/{project_id}/review [get]
+func (s *Server) AdminReviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	taskId := queryParams.Get("task")
+	if taskId != "" && !strings.HasPrefix(taskId, s.ActiveProjectId+"-") {
+		taskId = s.ActiveProjectId + "-" + taskId
+	}
+	from, err := strconv.Atoi(defaultQuery(queryParams, "from", "0"))
+	if err != nil || from < 0 {
+		from = 0
+	}
+	size, err := strconv.Atoi(defaultQuery(queryParams, "size", "10"))
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	var assignments []Assignment
+	var m meta
+	if queryParams.Get("disagreement") == "true" {
+		assignments, m, err = s.DisputedReviewQueue(taskId, from, size)
+	} else {
+		assignments, m, err = s.SampleReviewQueue(taskId, size)
+	}
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	queueJson, err := json.Marshal(assignmentReviewQueueResponse{
+		Assignments: assignments,
+		Meta:        m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, queueJson)
+}
+
+// @Title AdminReviewAssignmentHandler
+// @Description accepts or rejects a finished assignment; rejected ones no longer count towards consensus and count against the user's Quality
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   assignment_id     path    string     true        "Assignment ID"
+// @Param   review        body   string     true        "JSON-formatted verdict, ex: {\"Accepted\": false, \"Reviewer\": \"editor@example.com\"}"
+// @Success 200 {object}  assignmentResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assignments
+// @Router /admin/projects/{project_id}/assignments/{assignment_id}/review [post]
+func (s *Server) AdminReviewAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var reviewData struct {
+		Accepted *bool
+		Reviewer string
+	}
+	err = json.Unmarshal(body, &reviewData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if reviewData.Accepted == nil {
+		s.wrapFailure(w, r, errors.New("Sorry, say whether the assignment is accepted, ex: {\"Accepted\": true}."))
+		return
+	}
+
+	assignment, err := s.ReviewAssignment(vars["assignment_id"], *reviewData.Accepted, reviewData.Reviewer)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assignmentJson, err := json.Marshal(assignmentResponse{
+		Assignment: *assignment,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assignmentJson)
+}
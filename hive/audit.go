@@ -0,0 +1,365 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxSampleSize caps how many assets a single random sample can return.
+const maxSampleSize = 500
+
+// auditItem is a verified asset along with the consensus answer and the assignments that produced it.
+type auditItem struct {
+	Asset         Asset
+	SubmittedData interface{} // the verified answer for the audited task
+	Assignments   []Assignment
+}
+
+type auditSampleResponse struct {
+	Task   string
+	Sample []auditItem
+	Meta   meta
+}
+
+// RandomAssets returns up to n assets in the current project matching all the given filters, in random order.
+func (s *Server) RandomAssets(filters []string, n int) (assets []Asset, total int, err error) {
+	musts := append([]string{fmt.Sprintf(`{ "query": { "match": { "Project": "%s" } } }`, s.ActiveProjectId)}, filters...)
+
+	searchQuery := `{
+		"query": {
+			"function_score": {
+				"query": {
+					"filtered": {
+						"filter": {
+							"bool": {
+								"must": [%s]
+							}
+						}
+					}
+				},
+				"random_score": { "seed": %d },
+				"boost_mode": "replace"
+			}
+		},
+		"from": 0,
+		"size": %d
+	}`
+	searchJson := fmt.Sprintf(searchQuery, strings.Join(musts, ", "), time.Now().UnixNano(), n)
+	results, err := s.Store.Search("assets", searchJson)
+	if err != nil {
+		return
+	}
+
+	total = results.Hits.Total
+	for _, hit := range results.Hits.Hits {
+		var asset Asset
+		err = json.Unmarshal(*hit.Source, &asset)
+		if err != nil {
+			return
+		}
+		assets = append(assets, asset)
+	}
+	return
+}
+
+// FindAssetAssignments returns an asset's assignments for a task, limited to the given states.
+func (s *Server) FindAssetAssignments(taskId string, assetId string, states ...string) (assignments []Assignment, err error) {
+	musts := []string{
+		fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskId),
+		fmt.Sprintf(`{ "term": { "Asset.Id": "%s" } }`, assetId),
+	}
+	if len(states) > 0 {
+		musts = append(musts, fmt.Sprintf(`{ "terms": { "State": [ "%s" ] } }`, strings.Join(states, `", "`)))
+	}
+	assignmentQuery := fmt.Sprintf(`{ "query": { "bool": { "must": [ %s ] } } }`, strings.Join(musts, ", "))
+
+	count, err := s.Store.Count("assignments", assignmentQuery)
+	if err != nil {
+		return
+	}
+	if count == 0 {
+		return
+	}
+
+	searchJson := fmt.Sprintf(`{ "query": { "bool": { "must": [ %s ] } }, "from": 0, "size": %d }`, strings.Join(musts, ", "), count)
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return
+	}
+	for _, hit := range results.Hits.Hits {
+		var assignment Assignment
+		err = json.Unmarshal(*hit.Source, &assignment)
+		if err != nil {
+			return
+		}
+		assignments = append(assignments, assignment)
+	}
+	return
+}
+
+// AuditSample returns a random sample of assets verified for a task, each with its consensus answer and contributing assignments.
+func (s *Server) AuditSample(task Task, n int) (sample []auditItem, total int, err error) {
+	verifiedFilter := fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, task.Name)
+	assets, total, err := s.RandomAssets([]string{verifiedFilter}, n)
+	if err != nil {
+		return
+	}
+
+	sample = make([]auditItem, 0)
+	for _, asset := range assets {
+		assignments, err := s.FindAssetAssignments(task.Id, asset.Id, "verified")
+		if err != nil {
+			return sample, total, err
+		}
+		if assignments == nil {
+			assignments = make([]Assignment, 0)
+		}
+		sample = append(sample, auditItem{
+			Asset:         asset,
+			SubmittedData: asset.SubmittedData[task.Name],
+			Assignments:   assignments,
+		})
+	}
+	return sample, total, nil
+}
+
+// fieldConfusion tallies crowd answers against known answers for one field of a task's submitted data.
+// Matrix is keyed by known answer, then crowd answer.
+type fieldConfusion struct {
+	Matrix  map[string]map[string]int
+	Correct int
+	Total   int
+}
+
+type confusionResponse struct {
+	Task       string
+	GoldAssets int                       // gold standard assets with a known answer for this task
+	Answers    int                       // finished assignments compared against the known answers
+	Fields     map[string]fieldConfusion // per-field confusion matrices
+}
+
+// confusionLabel turns a submitted value into a matrix label.
+func confusionLabel(value interface{}, ok bool) string {
+	if !ok || value == nil {
+		return "(missing)"
+	}
+	return fmt.Sprint(value)
+}
+
+// ConfusionMatrix compares crowd answers for a task against the known answers on gold standard assets, field by field.
+func (s *Server) ConfusionMatrix(task Task) (resp confusionResponse, err error) {
+	resp.Task = task.Id
+	resp.Fields = make(map[string]fieldConfusion)
+
+	goldAssets, err := s.FindGoldAssets()
+	if err != nil {
+		return
+	}
+
+	for _, asset := range goldAssets {
+		known, ok := asset.GoldData[task.Name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resp.GoldAssets++
+
+		assignments, err := s.FindAssetAssignments(task.Id, asset.Id, "finished", "verified", "rejected")
+		if err != nil {
+			return resp, err
+		}
+		for _, assignment := range assignments {
+			resp.Answers++
+			for field, knownValue := range known {
+				confusion, ok := resp.Fields[field]
+				if !ok {
+					confusion = fieldConfusion{Matrix: make(map[string]map[string]int)}
+				}
+
+				knownLabel := confusionLabel(knownValue, true)
+				crowdValue, answered := assignment.SubmittedData[field]
+				crowdLabel := confusionLabel(crowdValue, answered)
+
+				if confusion.Matrix[knownLabel] == nil {
+					confusion.Matrix[knownLabel] = make(map[string]int)
+				}
+				confusion.Matrix[knownLabel][crowdLabel]++
+				confusion.Total++
+				if knownLabel == crowdLabel {
+					confusion.Correct++
+				}
+				resp.Fields[field] = confusion
+			}
+		}
+	}
+	return resp, nil
+}
+
+// @Title AdminConfusionMatrixHandler
+// @Description compares crowd answers for a task against gold standard answers, returning a confusion matrix per field
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id     path    string     true        "Task ID"
+// @Success 200 {object}  confusionResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/confusion [get]
+func (s *Server) AdminConfusionMatrixHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	resp, err := s.ConfusionMatrix(*task)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	confusionJson, err := json.Marshal(resp)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, confusionJson)
+}
+
+// sampleSize reads the 'n' query parameter, defaulting to 50 and capped at maxSampleSize.
+func sampleSize(r *http.Request) int {
+	n, err := strconv.Atoi(defaultQuery(r.URL.Query(), "n", "50"))
+	if err != nil || n <= 0 {
+		n = 50
+	}
+	if n > maxSampleSize {
+		n = maxSampleSize
+	}
+	return n
+}
+
+// @Title AdminAuditSampleHandler
+// @Description returns a random sample of assets verified for a task with their consensus data and contributing assignments
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id     path    string     true        "Task ID"
+// @Param   n        query   int     false        "Sample size, defaults to 50 (max 500)"
+// @Success 200 {object}  auditSampleResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/audit-sample [get]
+func (s *Server) AdminAuditSampleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	n := sampleSize(r)
+	sample, total, err := s.AuditSample(*task, n)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	sampleJson, err := json.Marshal(auditSampleResponse{
+		Task:   task.Id,
+		Sample: sample,
+		Meta: meta{
+			Total: total,
+			From:  0,
+			Size:  n,
+		},
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, sampleJson)
+}
+
+type assetSampleResponse struct {
+	Sample []Asset
+	Meta   meta
+}
+
+// AssetSample returns a random sample of n assets in the current project, and how many it was drawn from.
+// With state "completed" only verified assets are drawn, or with a task, only assets verified for it.
+func (s *Server) AssetSample(state string, taskName string, n int) (assets []Asset, total int, err error) {
+	var filters []string
+	switch state {
+	case "":
+	case "completed":
+		if taskName != "" {
+			filters = append(filters, fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, taskName))
+		} else {
+			filters = append(filters, `{ "term": { "Verified": true } }`)
+		}
+	default:
+		return nil, 0, fmt.Errorf("Sorry, assets can only be sampled from all of them or with state=completed, not %q.", state)
+	}
+
+	assets, total, err = s.RandomAssets(filters, n)
+	if assets == nil {
+		assets = make([]Asset, 0)
+	}
+	return
+}
+
+// @Title AdminAssetSampleHandler
+// @Description returns a random sample of the project's assets, ex: verified ones to audit output quality
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   n        query   int     false        "Sample size, defaults to 50 (max 500)"
+// @Param   state        query   string     false        "If completed, only samples verified assets"
+// @Param   task        query   string     false        "With state=completed, only samples assets verified for this task"
+// @Success 200 {object}  assetSampleResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/sample [get]
+func (s *Server) AdminAssetSampleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	taskName := strings.TrimPrefix(queryParams.Get("task"), s.ActiveProjectId+"-")
+
+	n := sampleSize(r)
+	sample, total, err := s.AssetSample(queryParams.Get("state"), taskName, n)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	sampleJson, err := json.Marshal(assetSampleResponse{
+		Sample: sample,
+		Meta: meta{
+			Total: total,
+			From:  0,
+			Size:  n,
+		},
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, sampleJson)
+}
@@ -0,0 +1,178 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// backfillPageSize is how many documents a backfill reads from elasticsearch at a time.
+const backfillPageSize = 500
+
+type backfillResponse struct {
+	Task   string
+	Assets int // assets that were given a placeholder for the task's submitted data
+	Users  int // users that were given a count for the task
+}
+
+// forEachProjectDoc pages through every document of docType in the current project, ordered by id, calling fn with each one's source.
+func (s *Server) forEachProjectDoc(docType string, fn func(source json.RawMessage) error) error {
+	return s.forEachMatchingDoc(docType, nil, fn)
+}
+
+// forEachMatchingDoc is forEachProjectDoc for only the documents matching every filter.
+func (s *Server) forEachMatchingDoc(docType string, filters []string, fn func(source json.RawMessage) error) error {
+	return s.forEachSortedDoc(docType, filters, `{ "Id": { "order": "asc" } }`, fn)
+}
+
+// forEachSortedDoc is forEachMatchingDoc in the order of sorts, ex: `{ "Asset.Id": { "order": "asc" } }`.
+// Id is always the last sort, so documents that tie come in the same order on every page.
+func (s *Server) forEachSortedDoc(docType string, filters []string, sorts string, fn func(source json.RawMessage) error) error {
+	if !strings.Contains(sorts, `"Id"`) {
+		sorts += `, { "Id": { "order": "asc" } }`
+	}
+	musts := append([]string{fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId)}, filters...)
+	if scroller, ok := s.Store.(Scroller); ok {
+		searchJson := fmt.Sprintf(`{
+			"query": {
+				"filtered": {
+					"filter": {
+						"bool": { "must": [ %s ] }
+					}
+				}
+			},
+			"size": %d,
+			"sort": [ %s ]
+		}`, strings.Join(musts, ", "), backfillPageSize, sorts)
+		return scroller.Scroll(docType, searchJson, func(hit SearchHit) error {
+			return fn(*hit.Source)
+		})
+	}
+
+	// other stores are paged through, which elasticsearch stops at 10,000 documents
+	for from := 0; ; from += backfillPageSize {
+		searchJson := fmt.Sprintf(`{
+			"query": {
+				"filtered": {
+					"filter": {
+						"bool": { "must": [ %s ] }
+					}
+				}
+			},
+			"from": %d,
+			"size": %d,
+			"sort": [ %s ]
+		}`, strings.Join(musts, ", "), from, backfillPageSize, sorts)
+
+		results, err := s.Store.Search(docType, searchJson)
+		if err != nil {
+			return err
+		}
+		for _, hit := range results.Hits.Hits {
+			err = fn(*hit.Source)
+			if err != nil {
+				return err
+			}
+		}
+		if len(results.Hits.Hits) < backfillPageSize {
+			return nil
+		}
+	}
+}
+
+// BackfillTask gives assets imported before a task existed the empty SubmittedData entry importAssets adds for it,
+// and users a zero count for it, so criteria and tallies treat old and new documents alike.
+func (s *Server) BackfillTask(task Task) (backfill backfillResponse, err error) {
+	backfill.Task = task.Id
+
+	err = s.forEachProjectDoc("assets", func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		if err != nil {
+			return err
+		}
+		if _, ok := asset.SubmittedData[task.Name]; ok {
+			return nil
+		}
+		if asset.SubmittedData == nil {
+			asset.SubmittedData = SubmittedData{}
+		}
+		asset.SubmittedData[task.Name] = nil
+		_, err = s.Store.Put("assets", asset.Id, asset)
+		if err != nil {
+			return err
+		}
+		backfill.Assets++
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = s.forEachProjectDoc("users", func(source json.RawMessage) error {
+		var user User
+		err := json.Unmarshal(source, &user)
+		if err != nil {
+			return err
+		}
+		if _, ok := user.Counts[task.Id]; ok {
+			return nil
+		}
+		if user.Counts == nil {
+			user.Counts = Counts{}
+		}
+		user.Counts[task.Id] = 0
+		_, err = s.Store.Put("users", user.Id, user)
+		if err != nil {
+			return err
+		}
+		backfill.Users++
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = s.Store.Refresh()
+	return
+}
+
+// @Title AdminBackfillTaskHandler
+// @Description adds a task's placeholders to assets and users created before the task, which happens automatically for new tasks
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id     path    string     true        "Task ID"
+// @Success 200 {object}  backfillResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/backfill [post]
+func (s *Server) AdminBackfillTaskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	backfill, err := s.BackfillTask(*task)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	backfillJson, err := json.Marshal(backfill)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, backfillJson)
+}
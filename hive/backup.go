@@ -0,0 +1,169 @@
+package hive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// backupDocTypes are the project's documents included in a backup, besides the project itself.
+var backupDocTypes = []string{"tasks", "assets", "users", "assignments"}
+
+// writeBackupJson writes the current project and all its documents as one JSON object,
+// ex: {"Project": {...}, "Tasks": [...], "Assets": [...], "Users": [...], "Assignments": [...]}.
+// Documents are written as they're read, so backups of any size stream.
+func (s *Server) writeBackupJson(w io.Writer, project json.RawMessage) error {
+	_, err := fmt.Fprintf(w, `{"Project":%s`, project)
+	if err != nil {
+		return err
+	}
+
+	for _, docType := range backupDocTypes {
+		_, err = fmt.Fprintf(w, `,"%s":[`, strings.Title(docType))
+		if err != nil {
+			return err
+		}
+		first := true
+		err = s.forEachProjectDoc(docType, func(source json.RawMessage) error {
+			if !first {
+				_, err := w.Write([]byte(","))
+				if err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err := w.Write(source)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte("]"))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write([]byte("}\n"))
+	return err
+}
+
+// writeBackupTar writes the current project as a gzipped tar of project.json and one newline-delimited JSON file
+// per document type, ex: assets.ndjson. Each file is spooled to disk first, since tar needs its size up front.
+func (s *Server) writeBackupTar(w io.Writer, project json.RawMessage) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	err := tw.WriteHeader(&tar.Header{Name: "project.json", Mode: 0644, Size: int64(len(project) + 1), ModTime: now})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(project)
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write([]byte("\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, docType := range backupDocTypes {
+		err = s.writeBackupFile(tw, docType, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeBackupFile adds one document type to a backup tar as {docType}.ndjson.
+func (s *Server) writeBackupFile(tw *tar.Writer, docType string, modTime time.Time) error {
+	spool, err := ioutil.TempFile("", "hive-backup-"+docType)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	var size int64
+	err = s.forEachProjectDoc(docType, func(source json.RawMessage) error {
+		n, err := fmt.Fprintf(spool, "%s\n", source)
+		size += int64(n)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = spool.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{Name: docType + ".ndjson", Mode: 0644, Size: size, ModTime: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, spool)
+	return err
+}
+
+// @Title AdminExportProjectHandler
+// @Description downloads a backup of a project with its tasks, assets, users and assignments
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   format        query   string     false        "json (the default) for a single JSON file, or tar.gz for a tarball of newline-delimited JSON files"
+// @Success 200 {object}  string
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/export [get]
+func (s *Server) AdminExportProjectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	var project json.RawMessage
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	format := defaultQuery(r.URL.Query(), "format", "json")
+	if format != "json" && format != "tar.gz" {
+		s.wrapFailure(w, r, fmt.Errorf("Sorry, %q isn't a backup format. Use json or tar.gz.", format))
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", s.ActiveProjectId, time.Now().UTC().Format("20060102"), format)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "application/gzip")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(200)
+
+	// once the backup is streaming the status can't change, so a failure part way through cuts the file short
+	if format == "json" {
+		err = s.writeBackupJson(w, project)
+	} else {
+		err = s.writeBackupTar(w, project)
+	}
+	if err != nil {
+		s.logError("failed backing up project", err, nil)
+	}
+}
@@ -0,0 +1,327 @@
+package hive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxUploadSize caps how much of a multipart upload is accepted, in bytes.
+const maxUploadSize = 32 << 20
+
+// emptyPayloadHash is the SHA-256 of an empty request body, for signing GET requests to S3.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signedUrlTTL is how long the links handed out for private assets keep working.
+const signedUrlTTL = 30 * time.Minute
+
+// BlobStore keeps uploaded files, ex: attachments on assignment submissions.
+type BlobStore interface {
+	// Put stores body under key, replacing anything already there, and returns a url it can be fetched from.
+	Put(key string, contentType string, body io.Reader) (url string, err error)
+}
+
+// BlobSigner is implemented by blob stores that can hand out temporary links to private files.
+type BlobSigner interface {
+	// SignUrl returns a link to the stored file at rawUrl that stops working after expires.
+	SignUrl(rawUrl string, expires time.Duration) (string, error)
+}
+
+// BlobReader is implemented by blob stores that can read back what they've stored, ex: cached thumbnails.
+type BlobReader interface {
+	// Get opens the file stored under key, or returns an error satisfying os.IsNotExist when there isn't one.
+	Get(key string) (io.ReadCloser, error)
+}
+
+// DiskBlobStore is a BlobStore that writes files under a local directory.
+// Hive serves the directory itself at /blobs/.
+type DiskBlobStore struct {
+	Dir     string // where files are written
+	BaseUrl string // public url of this hive server, used to build file urls
+}
+
+// Put writes body to Dir/key.
+func (d *DiskBlobStore) Put(key string, contentType string, body io.Reader) (string, error) {
+	filePath := filepath.Join(d.Dir, filepath.FromSlash(path.Clean("/"+key)))
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(d.BaseUrl, "/") + "/blobs/" + awsEscape(key, false), nil
+}
+
+// Get opens Dir/key.
+func (d *DiskBlobStore) Get(key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.Dir, filepath.FromSlash(path.Clean("/"+key))))
+}
+
+// S3BlobStore is a BlobStore backed by an Amazon S3 bucket.
+// Requests are signed with AWS signature version 4.
+type S3BlobStore struct {
+	Bucket    string
+	Region    string // ex: "us-east-1"
+	AccessKey string
+	SecretKey string
+	Endpoint  string // optional, ex: "http://localhost:9000" for S3-compatible storage; uses path-style urls
+}
+
+// objectUrl returns the url of key in the bucket.
+func (b *S3BlobStore) objectUrl(key string) *url.URL {
+	if b.Endpoint != "" {
+		u, err := url.Parse(strings.TrimRight(b.Endpoint, "/"))
+		if err == nil {
+			u.Path = "/" + b.Bucket + "/" + key
+			u.RawPath = "/" + b.Bucket + "/" + awsEscape(key, false)
+			return u
+		}
+	}
+	return &url.URL{
+		Scheme:  "https",
+		Host:    fmt.Sprintf("%s.s3.%s.amazonaws.com", b.Bucket, b.Region),
+		Path:    "/" + key,
+		RawPath: "/" + awsEscape(key, false),
+	}
+}
+
+// Put uploads body to the bucket.
+func (b *S3BlobStore) Put(key string, contentType string, body io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	objectUrl := b.objectUrl(key)
+
+	req, err := http.NewRequest("PUT", objectUrl.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	payloadHash := sha256.Sum256(data)
+	b.sign(req, hex.EncodeToString(payloadHash[:]), time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("Failed storing %s in S3: %s %s", key, resp.Status, respBody)
+	}
+	return objectUrl.String(), nil
+}
+
+// Get downloads key from the bucket.
+func (b *S3BlobStore) Get(key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", b.objectUrl(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	b.sign(req, emptyPayloadHash, time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == 404 || resp.StatusCode == 403 {
+		// without list permission S3 answers 403 for missing keys
+		resp.Body.Close()
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode != 200 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("Failed reading %s from S3: %s %s", key, resp.Status, respBody)
+	}
+	return resp.Body, nil
+}
+
+// SignUrl returns a presigned GET url for an object in a private bucket.
+// rawUrl is either an s3://bucket/key url or one of this store's object urls.
+func (b *S3BlobStore) SignUrl(rawUrl string, expires time.Duration) (string, error) {
+	store := *b
+	var key string
+
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	bucketUrl := b.objectUrl("").String()
+	if parsed.Scheme == "s3" {
+		store.Bucket = parsed.Host
+		key = strings.TrimPrefix(parsed.Path, "/")
+	} else if strings.HasPrefix(rawUrl, bucketUrl) {
+		key = strings.TrimPrefix(parsed.Path, b.objectUrl("").Path)
+	} else {
+		return "", fmt.Errorf("Sorry, %s isn't in S3 storage, so it can't be signed.", rawUrl)
+	}
+	if key == "" {
+		return "", fmt.Errorf("Sorry, %s doesn't name an S3 object.", rawUrl)
+	}
+	return store.presign(key, expires, time.Now().UTC()), nil
+}
+
+// presign builds a url for key that carries its own AWS signature version 4 query string.
+func (b *S3BlobStore) presign(key string, expires time.Duration, now time.Time) string {
+	objectUrl := b.objectUrl(key)
+	date := now.Format("20060102")
+	credential := b.AccessKey + "/" + strings.Join([]string{date, b.Region, "s3", "aws4_request"}, "/")
+
+	// query parameters must be in sorted order for the canonical request
+	query := strings.Join([]string{
+		"X-Amz-Algorithm=AWS4-HMAC-SHA256",
+		"X-Amz-Credential=" + awsEscape(credential, true),
+		"X-Amz-Date=" + now.Format("20060102T150405Z"),
+		"X-Amz-Expires=" + strconv.Itoa(int(expires.Seconds())),
+		"X-Amz-SignedHeaders=host",
+	}, "&")
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		objectUrl.EscapedPath(),
+		query,
+		"host:" + objectUrl.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	_, signature := awsSignature(b.SecretKey, b.Region, "s3", canonicalRequest, now)
+	objectUrl.RawQuery = query + "&X-Amz-Signature=" + signature
+	return objectUrl.String()
+}
+
+// sign adds an AWS signature version 4 Authorization header to req.
+func (b *S3BlobStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headerValues := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		signedHeaders = append(signedHeaders, "content-type")
+		headerValues["content-type"] = contentType
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders string
+	for _, name := range signedHeaders {
+		canonicalHeaders += name + ":" + strings.TrimSpace(headerValues[name]) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope, signature := awsSignature(b.SecretKey, b.Region, "s3", canonicalRequest, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// awsSignature returns the AWS signature version 4 credential scope and signature for a canonical request to service.
+func awsSignature(secretKey string, region string, service string, canonicalRequest string, now time.Time) (scope string, signature string) {
+	date := now.Format("20060102")
+	scope = strings.Join([]string{date, region, service, "aws4_request"}, "/")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent-encodes s the way AWS signatures expect, leaving slashes alone unless encodeSlash is set.
+func awsEscape(s string, encodeSlash bool) string {
+	var escaped bytes.Buffer
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// blobKey builds a storage key from path segments, keeping only the base name of any user-supplied file name.
+func blobKey(segments ...string) (string, error) {
+	clean := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		segment = path.Base(strings.Replace(segment, "\\", "/", -1))
+		if segment == "." || segment == "/" || segment == ".." {
+			return "", errors.New("Sorry, that file name can't be stored.")
+		}
+		clean = append(clean, segment)
+	}
+	return strings.Join(clean, "/"), nil
+}
+
+// signAssetUrl swaps a private asset's stored Url for a short-lived signed link, so the raw location is never exposed.
+func (s *Server) signAssetUrl(asset *Asset) error {
+	if !asset.Private {
+		return nil
+	}
+	signer, ok := s.Blobs.(BlobSigner)
+	if !ok {
+		return errors.New("Sorry, private assets can't be shown without S3 storage configured.")
+	}
+	signedUrl, err := signer.SignUrl(asset.Url, signedUrlTTL)
+	if err != nil {
+		return err
+	}
+	asset.Url = signedUrl
+	return nil
+}
@@ -0,0 +1,176 @@
+package hive
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinSize is the smallest JSON response worth compressing, in bytes. Smaller ones fit in a packet anyway.
+const compressMinSize = 1024
+
+// brotliLevel trades brotli's compression for speed, since responses are compressed as they're sent. Level 4
+// still beats gzip's default on JSON, in about the same time.
+const brotliLevel = 4
+
+// acceptedEncoding returns the encoding to compress a response with, from a request's Accept-Encoding header:
+// "br" when brotli is accepted, "gzip" when gzip is, or "" to send it as it is.
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				quality, _ = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			}
+		}
+		accepted[name] = quality > 0
+	}
+	for _, encoding := range []string{"br", "gzip"} {
+		if ok, listed := accepted[encoding]; listed {
+			if ok {
+				return encoding
+			}
+		} else if accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressedResponse holds back the start of a JSON response until it's seen enough of it to know it's worth
+// compressing, then sends the rest through the encoder. Other responses pass straight through.
+type compressedResponse struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	pending  []byte
+	started  bool
+	encoder  io.WriteCloser
+}
+
+// compressible reports whether the response is JSON, and not encoded already.
+func (cr *compressedResponse) compressible() bool {
+	if cr.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(cr.Header().Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func (cr *compressedResponse) WriteHeader(status int) {
+	if cr.status != 0 {
+		return
+	}
+	cr.status = status
+	// there's no body to compress, or it isn't JSON, so it may as well be sent now
+	if status == http.StatusNoContent || status == http.StatusNotModified || !cr.compressible() {
+		cr.start()
+	}
+}
+
+func (cr *compressedResponse) Write(b []byte) (int, error) {
+	if cr.status == 0 {
+		cr.WriteHeader(200)
+	}
+	if cr.started {
+		if cr.encoder != nil {
+			return cr.encoder.Write(b)
+		}
+		return cr.ResponseWriter.Write(b)
+	}
+	cr.pending = append(cr.pending, b...)
+	if len(cr.pending) >= compressMinSize {
+		err := cr.start()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start sends the headers, compressed when the response is JSON and big enough, and whatever was held back.
+func (cr *compressedResponse) start() error {
+	cr.started = true
+	if cr.compressible() {
+		cr.Header().Add("Vary", "Accept-Encoding")
+		if len(cr.pending) >= compressMinSize {
+			cr.Header().Del("Content-Length")
+			cr.Header().Set("Content-Encoding", cr.encoding)
+			if cr.encoding == "br" {
+				cr.encoder = brotli.NewWriterLevel(cr.ResponseWriter, brotliLevel)
+			} else {
+				cr.encoder = gzip.NewWriter(cr.ResponseWriter)
+			}
+		}
+	}
+	if cr.status != 0 {
+		cr.ResponseWriter.WriteHeader(cr.status)
+	}
+
+	pending := cr.pending
+	cr.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	var err error
+	if cr.encoder != nil {
+		_, err = cr.encoder.Write(pending)
+	} else {
+		_, err = cr.ResponseWriter.Write(pending)
+	}
+	return err
+}
+
+// Flush sends what's been written so far, compressed or not, for streaming responses.
+func (cr *compressedResponse) Flush() {
+	if !cr.started {
+		cr.start()
+	}
+	if flusher, ok := cr.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish sends a response that stayed too small to compress, or ends the compressed stream.
+func (cr *compressedResponse) finish() error {
+	if !cr.started && (cr.status != 0 || len(cr.pending) > 0) {
+		err := cr.start()
+		if err != nil {
+			return err
+		}
+	}
+	if cr.encoder != nil {
+		return cr.encoder.Close()
+	}
+	return nil
+}
+
+// compress gzips JSON responses of compressMinSize or more, or compresses them with brotli when the client accepts
+// it, ex: asset and assignment lists with their SubmittedData, which run to megabytes.
+func (s *Server) compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cr := &compressedResponse{ResponseWriter: w, encoding: encoding}
+		h.ServeHTTP(cr, r)
+		err := cr.finish()
+		if err != nil {
+			s.logError("failed compressing response", err, logFields{"path": r.URL.Path, "encoding": encoding})
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package hive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0, gzip", "gzip"},
+		{"gzip;q=0", ""},
+		{"GZIP", "gzip"},
+		{"*", "br"},
+		{"br;q=0, *", "gzip"},
+		{"deflate", ""},
+		{"gzip;q=0.5, br;q=1.0", "br"},
+	}
+	for _, test := range tests {
+		if got := acceptedEncoding(test.header); got != test.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	big := `{"Assets": ["` + strings.Repeat("a", compressMinSize) + `"]}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		encoding    string // Content-Encoding the response should have
+	}{
+		{"big JSON", "application/json", big, "gzip"},
+		{"big JSON with a charset", "application/json; charset=utf-8", big, "gzip"},
+		{"small JSON", "application/json", `{"Assets": []}`, ""},
+		{"big CSV", "text/csv", strings.Repeat("a,b\n", compressMinSize), ""},
+	}
+	s := &Server{}
+	for _, test := range tests {
+		h := s.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", test.contentType)
+			w.WriteHeader(200)
+			w.Write([]byte(test.body))
+		}))
+		r := httptest.NewRequest("GET", "/projects/crowd/assets", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", test.name, got, test.encoding)
+			continue
+		}
+		body := w.Body.Bytes()
+		if test.encoding == "gzip" {
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+			body, _ = ioutil.ReadAll(reader)
+		}
+		if string(body) != test.body {
+			t.Errorf("%s: body = %q, want %q", test.name, body, test.body)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package hive
+
+import (
+	"io/ioutil"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is everything a hive server can be set up with, read from a YAML file by LoadConfig and
+// overridden by environment variables with ApplyEnv. Every setting is optional, ex:
+//
+//	port: "8080"
+//	esHosts: [es1.example.com, es2.example.com]
+//	index: hive
+//	secret: change-me
+//	adminKeys: [key-for-newsroom, key-for-scripts]
+//	corsOrigins: [https://crowd.example.com]
+//	webhooks:
+//	  crowd: {url: "https://cms.example.com/hive", secret: change-me-too}
+type Config struct {
+	Port            string        `yaml:"port"`            // defaults to "8080"
+	EsHosts         []string      `yaml:"esHosts"`         // elasticsearch hosts, defaults to ["localhost"]
+	EsPort          string        `yaml:"esPort"`          // defaults to "9200"
+	Index           string        `yaml:"index"`           // elasticsearch index name, defaults to "hive"
+	EsVersion       string        `yaml:"esVersion"`       // elasticsearch release, ex: "7" or "7.10.2"; asked of the cluster when empty
+	BaseUrl         string        `yaml:"baseUrl"`         // public url of this server, defaults to "http://localhost:8080"
+	Secret          string        `yaml:"secret"`          // signs login links and sessions
+	AdminKeys       []string      `yaml:"adminKeys"`       // API keys required by admin endpoints
+	CorsOrigins     []string      `yaml:"corsOrigins"`     // sites allowed to call hive from the browser with cookies, see corsPolicy
+	TrustedProxies  []string      `yaml:"trustedProxies"`  // addresses or CIDR ranges of proxies whose X-Forwarded-For is believed
+	SmtpAddr        string        `yaml:"smtpAddr"`        // smtp server (host:port) for sending login emails
+	SmtpUsername    string        `yaml:"smtpUsername"`    // optional, for smtp servers that need a login
+	SmtpPassword    string        `yaml:"smtpPassword"`    // optional, with SmtpUsername
+	MailFrom        string        `yaml:"mailFrom"`        // address login emails are sent from
+	BlobDir         string        `yaml:"blobDir"`         // directory to store uploaded files in
+	S3Bucket        string        `yaml:"s3Bucket"`        // s3 bucket to store uploaded files in, instead of blobDir
+	S3Region        string        `yaml:"s3Region"`        // defaults to "us-east-1"
+	S3Endpoint      string        `yaml:"s3Endpoint"`      // for s3-compatible storage
+	AwsAccessKey    string        `yaml:"awsAccessKey"`    // credentials for the s3 bucket
+	AwsSecretKey    string        `yaml:"awsSecretKey"`    // credentials for the s3 bucket
+	MturkEndpoint   string        `yaml:"mturkEndpoint"`   // Mechanical Turk's requester API, defaults to production; see MturkClient
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"` // ex: "30s", the default
+	// ReconcileInterval is how often stored counts are checked against assignments, ex: "1h", the default; "0s" never
+	ReconcileInterval time.Duration `yaml:"reconcileInterval"`
+
+	// Webhooks are used by projects that haven't set one up through /admin/projects/{project_id}/webhook, by project id
+	Webhooks map[string]Webhook `yaml:"webhooks"`
+}
+
+// DefaultConfig returns the settings hive uses when nothing else is given.
+func DefaultConfig() Config {
+	return Config{
+		Port:              "8080",
+		EsHosts:           []string{"localhost"},
+		EsPort:            "9200",
+		Index:             "hive",
+		BaseUrl:           "http://localhost:8080",
+		S3Region:          "us-east-1",
+		MturkEndpoint:     mturkProductionEndpoint,
+		ShutdownTimeout:   defaultShutdownTimeout,
+		ReconcileInterval: defaultReconcileInterval,
+	}
+}
+
+// LoadConfig reads a YAML config file over the defaults.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	configYaml, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	err = yaml.UnmarshalStrict(configYaml, &config)
+	return config, err
+}
+
+// SplitList splits a comma-separated setting, dropping blanks, ex: "a, b," is ["a", "b"].
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ApplyEnv overrides settings with any environment variables set for them. Lists are comma-separated.
+// Most are named after the setting, ex: HIVE_PORT, HIVE_ES_HOSTS, HIVE_CORS_ORIGINS, HIVE_TRUSTED_PROXIES.
+// Some keep the names they had before config files: ELASTICSEARCH_DOMAIN, ELASTICSEARCH_PORT, HIVE_SECRET,
+// SMTP_USERNAME, SMTP_PASSWORD, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and S3_ENDPOINT.
+func (c *Config) ApplyEnv() error {
+	// when a setting has two names, the later one wins
+	settings := []struct {
+		name    string
+		setting *string
+	}{
+		{"HIVE_PORT", &c.Port},
+		{"ELASTICSEARCH_PORT", &c.EsPort},
+		{"HIVE_ES_PORT", &c.EsPort},
+		{"HIVE_INDEX", &c.Index},
+		{"HIVE_ES_VERSION", &c.EsVersion},
+		{"HIVE_BASE_URL", &c.BaseUrl},
+		{"HIVE_SECRET", &c.Secret},
+		{"HIVE_SMTP_ADDR", &c.SmtpAddr},
+		{"SMTP_USERNAME", &c.SmtpUsername},
+		{"SMTP_PASSWORD", &c.SmtpPassword},
+		{"HIVE_MAIL_FROM", &c.MailFrom},
+		{"HIVE_BLOB_DIR", &c.BlobDir},
+		{"HIVE_S3_BUCKET", &c.S3Bucket},
+		{"HIVE_S3_REGION", &c.S3Region},
+		{"S3_ENDPOINT", &c.S3Endpoint},
+		{"AWS_ACCESS_KEY_ID", &c.AwsAccessKey},
+		{"AWS_SECRET_ACCESS_KEY", &c.AwsSecretKey},
+		{"HIVE_MTURK_ENDPOINT", &c.MturkEndpoint},
+	}
+	for _, env := range settings {
+		if value := os.Getenv(env.name); value != "" {
+			*env.setting = value
+		}
+	}
+
+	lists := []struct {
+		name    string
+		setting *[]string
+	}{
+		{"ELASTICSEARCH_DOMAIN", &c.EsHosts},
+		{"HIVE_ES_HOSTS", &c.EsHosts},
+		{"HIVE_ADMIN_KEYS", &c.AdminKeys},
+		{"HIVE_CORS_ORIGINS", &c.CorsOrigins},
+		{"HIVE_TRUSTED_PROXIES", &c.TrustedProxies},
+	}
+	for _, env := range lists {
+		if value := os.Getenv(env.name); value != "" {
+			*env.setting = SplitList(value)
+		}
+	}
+
+	if value := os.Getenv("HIVE_SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		c.ShutdownTimeout = timeout
+	}
+	if value := os.Getenv("HIVE_RECONCILE_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		c.ReconcileInterval = interval
+	}
+	return nil
+}
+
+// Configure sets the server up from config: where it stores data, how it sends mail and keeps uploads,
+// and everything else. The config is kept as s.Config.
+func (s *Server) Configure(config Config) {
+	s.Config = config
+	s.Port = config.Port
+	s.SecretKey = config.Secret
+	s.AdminKeys = config.AdminKeys
+	s.BaseUrl = config.BaseUrl
+	s.ShutdownTimeout = config.ShutdownTimeout
+	s.ReconcileInterval = config.ReconcileInterval
+
+	hosts := config.EsHosts
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	store, err := NewElasticsearchStore(hosts, config.EsPort, config.Index)
+	if err != nil {
+		logJson("error", "couldn't connect to elasticsearch", logFields{"error": err.Error()})
+		store = &ElasticsearchStore{Index: config.Index}
+	}
+	if config.EsVersion != "" {
+		version, err := esMajorVersion(config.EsVersion)
+		if err != nil {
+			logJson("warn", "asking elasticsearch for its version instead", logFields{"error": err.Error()})
+		}
+		store.Version = version
+	}
+	s.Store = store
+
+	// passwordless login needs a signing secret and somewhere to send mail
+	s.Mailer = nil
+	if config.SmtpAddr != "" {
+		mailer := &SMTPMailer{Addr: config.SmtpAddr, From: config.MailFrom}
+		if config.SmtpUsername != "" {
+			host := strings.Split(config.SmtpAddr, ":")[0]
+			mailer.Auth = smtp.PlainAuth("", config.SmtpUsername, config.SmtpPassword, host)
+		}
+		s.Mailer = mailer
+	}
+
+	// file uploads go to s3 when a bucket is configured, otherwise to local disk
+	s.Blobs = nil
+	if config.S3Bucket != "" {
+		s.Blobs = &S3BlobStore{
+			Bucket:    config.S3Bucket,
+			Region:    config.S3Region,
+			AccessKey: config.AwsAccessKey,
+			SecretKey: config.AwsSecretKey,
+			Endpoint:  config.S3Endpoint,
+		}
+	} else if config.BlobDir != "" {
+		s.Blobs = &DiskBlobStore{Dir: config.BlobDir, BaseUrl: config.BaseUrl}
+	}
+
+	// overflow work can be paid for on mechanical turk with the same aws credentials
+	s.Mturk = nil
+	if config.AwsAccessKey != "" && config.AwsSecretKey != "" {
+		s.Mturk = &MturkClient{
+			Endpoint:  config.MturkEndpoint,
+			AccessKey: config.AwsAccessKey,
+			SecretKey: config.AwsSecretKey,
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package hive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// trustedProxy reports whether ip is one of the configured TrustedProxies, listed as addresses or CIDR ranges.
+func (s *Server) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range s.Config.TrustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(parsed) {
+				return true
+			}
+		} else if proxyIp := net.ParseIP(proxy); proxyIp != nil && proxyIp.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIp returns the IP address a request came from. X-Forwarded-For is only believed when the request came
+// through one of the TrustedProxies, since anyone can send it, and then only as far back as the last address
+// a trusted proxy added: that's the first one the client couldn't have made up.
+func (s *Server) clientIp(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !s.trustedProxy(ip) {
+		return ip
+	}
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !s.trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// requestSource returns a hash identifying the client a request came from, by IP address and user agent, see
+// clientIp. It's stored on submitted assignments so CompletionCriteria.DistinctSources can tell sock puppets apart
+// without keeping IPs.
+func (s *Server) requestSource(r *http.Request) string {
+	ip := s.clientIp(r)
+	hash := sha256.Sum256([]byte(s.ActiveProjectId + "|" + ip + "|" + r.UserAgent()))
+	return hex.EncodeToString(hash[:16])
+}
+
+// contributorKeys identifies who made an assignment, as far as the completion criteria care.
+func contributorKeys(assignment Assignment, criteria CompletionCriteria) []string {
+	var keys []string
+	if criteria.DistinctUsers {
+		keys = append(keys, "user:"+assignment.User)
+	}
+	if criteria.DistinctSources && assignment.Source != "" {
+		keys = append(keys, "source:"+assignment.Source)
+	}
+	return keys
+}
+
+// countMatchingAnswers groups assignments by their submitted data, counting matching answers once per distinct
+// user and/or source when the completion criteria ask for it. Answers match when same says so, comparing each
+// with the first answer of its group, which is the group's Value. Fields with a rule in criteria.Fields are
+// compared by it instead, see matchAnswers. Each counted assignment adds its user's weight, when there are weights.
+func countMatchingAnswers(assignments []Assignment, criteria CompletionCriteria, weights map[string]float64, same func(a, b SubmittedData) bool) []SubmittedDataTracker {
+	var trackers []SubmittedDataTracker
+	var seen []map[string]bool
+
+	for _, assignment := range assignments {
+		i := -1
+		for j, tracker := range trackers {
+			if matchAnswers(criteria, tracker.Value, assignment.SubmittedData, same) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			trackers = append(trackers, SubmittedDataTracker{Value: assignment.SubmittedData})
+			seen = append(seen, make(map[string]bool))
+			i = len(trackers) - 1
+		}
+
+		// the same answer from someone already counted doesn't add to the consensus
+		keys := contributorKeys(assignment, criteria)
+		counted := false
+		for _, key := range keys {
+			if seen[i][key] {
+				counted = true
+			}
+		}
+		if counted {
+			continue
+		}
+		for _, key := range keys {
+			seen[i][key] = true
+		}
+		trackers[i].Count++
+		trackers[i].Weight += weights[assignment.User]
+	}
+	return trackers
+}
+
+// FieldMatchRule loosens how one field of an answer is compared, ex: {"tolerance": 0.05} for a price
+// or {"levenshtein": 2} for a transcribed title.
+type FieldMatchRule struct {
+	Tolerance   float64 // numbers match when they're at most this far apart
+	Levenshtein int     // strings match when at most this many single character edits apart
+}
+
+// matches reports whether two values of the field agree under the rule.
+func (rule FieldMatchRule) matches(a interface{}, b interface{}) bool {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return math.Abs(av-bv) <= rule.Tolerance
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return levenshtein(av, bv) <= rule.Levenshtein
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// levenshtein returns how many single character insertions, deletions or substitutions turn a into b.
+func levenshtein(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(br)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// matchAnswers compares two answers, using criteria's rule for each field that has one and same for the rest.
+func matchAnswers(criteria CompletionCriteria, a SubmittedData, b SubmittedData, same func(a, b SubmittedData) bool) bool {
+	if len(criteria.Fields) == 0 {
+		return same(a, b)
+	}
+	for field, rule := range criteria.Fields {
+		av, aok := a[field]
+		bv, bok := b[field]
+		if aok != bok || (aok && !rule.matches(av, bv)) {
+			return false
+		}
+	}
+	return same(withoutFields(a, criteria.Fields), withoutFields(b, criteria.Fields))
+}
+
+// withoutFields returns a copy of an answer leaving out the fields with rules.
+func withoutFields(answer SubmittedData, fields map[string]FieldMatchRule) SubmittedData {
+	rest := SubmittedData{}
+	for field, value := range answer {
+		if _, ok := fields[field]; !ok {
+			rest[field] = value
+		}
+	}
+	return rest
+}
+
+// ConsensusStrategy decides which answers agree when CompleteTask counts a task's finished assignments on an asset.
+// Strategies are chosen by name with Task.MatchingStrategy, see RegisterConsensusStrategy.
+type ConsensusStrategy interface {
+	// Tally groups the assignments' answers into candidate answers, counting the assignments behind each,
+	// once per distinct user and/or source when the task's CompletionCriteria ask for it. With weights, by user id,
+	// each answer's Weight is the summed weight of the assignments counted for it.
+	Tally(task Task, assignments []Assignment, weights map[string]float64) []SubmittedDataTracker
+}
+
+// consensusStrategies are the strategies tasks can choose from, by name.
+var consensusStrategies = map[string]ConsensusStrategy{
+	"exact":      exactStrategy{},
+	"normalized": normalizedStrategy{},
+	"majority":   majorityStrategy{},
+	"tolerance":  toleranceStrategy{},
+}
+
+// RegisterConsensusStrategy makes a strategy available to tasks under name, ex: for Go programs embedding hive
+// that compare answers their own way. Register strategies before the server starts serving.
+func RegisterConsensusStrategy(name string, strategy ConsensusStrategy) {
+	consensusStrategies[name] = strategy
+}
+
+// taskConsensusStrategy returns the task's MatchingStrategy, "exact" when it doesn't have one.
+func taskConsensusStrategy(task Task) (ConsensusStrategy, error) {
+	name := task.MatchingStrategy
+	if name == "" {
+		name = "exact"
+	}
+	strategy, ok := consensusStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("Sorry, %q isn't a matching strategy. Use exact, normalized, majority or tolerance.", name)
+	}
+	return strategy, nil
+}
+
+// exactStrategy only matches identical answers. It's the default.
+type exactStrategy struct{}
+
+func (exactStrategy) Tally(task Task, assignments []Assignment, weights map[string]float64) []SubmittedDataTracker {
+	return countMatchingAnswers(assignments, task.CompletionCriteria, weights, func(a, b SubmittedData) bool {
+		return reflect.DeepEqual(a, b)
+	})
+}
+
+// normalizedStrategy matches answers whose strings only differ in case or whitespace, ex: "Yes" and "yes ".
+type normalizedStrategy struct{}
+
+func (normalizedStrategy) Tally(task Task, assignments []Assignment, weights map[string]float64) []SubmittedDataTracker {
+	return countMatchingAnswers(assignments, task.CompletionCriteria, weights, func(a, b SubmittedData) bool {
+		return reflect.DeepEqual(normalizeAnswer(map[string]interface{}(a)), normalizeAnswer(map[string]interface{}(b)))
+	})
+}
+
+// normalizeAnswer lowercases every string in an answer and collapses its whitespace.
+func normalizeAnswer(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.ToLower(strings.Join(strings.Fields(v), " "))
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeAnswer(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeAnswer(item)
+		}
+		return normalized
+	}
+	return value
+}
+
+// toleranceStrategy matches answers whose numbers are within the task's MatchingTolerance of each other,
+// ex: crop coordinates a few pixels apart. Everything else has to be identical.
+type toleranceStrategy struct{}
+
+func (toleranceStrategy) Tally(task Task, assignments []Assignment, weights map[string]float64) []SubmittedDataTracker {
+	return countMatchingAnswers(assignments, task.CompletionCriteria, weights, func(a, b SubmittedData) bool {
+		return withinTolerance(map[string]interface{}(a), map[string]interface{}(b), task.MatchingTolerance)
+	})
+}
+
+// withinTolerance compares two answers, allowing numbers to differ by up to tolerance.
+func withinTolerance(a interface{}, b interface{}, tolerance float64) bool {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		return ok && math.Abs(av-bv) <= tolerance
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, item := range av {
+			other, ok := bv[key]
+			if !ok || !withinTolerance(item, other, tolerance) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !withinTolerance(av[i], bv[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// majorityStrategy builds the answer field by field, taking each field's most common value. The answer is only as
+// well supported as its least agreed on field, so its count and weight are the smallest of the fields' majorities.
+// With weights, each field's majority is its heaviest value rather than its most common.
+type majorityStrategy struct{}
+
+func (majorityStrategy) Tally(task Task, assignments []Assignment, weights map[string]float64) []SubmittedDataTracker {
+	fields := make(map[string]bool)
+	for _, assignment := range assignments {
+		for field := range assignment.SubmittedData {
+			fields[field] = true
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	consensus := SubmittedDataTracker{Value: SubmittedData{}}
+	first := true
+	for field := range fields {
+		// count each field's values as if they were whole answers
+		var fieldAssignments []Assignment
+		for _, assignment := range assignments {
+			if value, ok := assignment.SubmittedData[field]; ok {
+				assignment.SubmittedData = SubmittedData{field: value}
+				fieldAssignments = append(fieldAssignments, assignment)
+			}
+		}
+		var majority SubmittedDataTracker
+		for _, tracker := range (exactStrategy{}).Tally(task, fieldAssignments, weights) {
+			if (weights == nil && tracker.Count > majority.Count) || (weights != nil && tracker.Weight > majority.Weight) {
+				majority = tracker
+			}
+		}
+		consensus.Value[field] = majority.Value[field]
+		if consensus.Count == 0 || majority.Count < consensus.Count {
+			consensus.Count = majority.Count
+		}
+		if first || majority.Weight < consensus.Weight {
+			consensus.Weight = majority.Weight
+		}
+		first = false
+	}
+	return []SubmittedDataTracker{consensus}
+}
+
+// defaultTrustWeight is what a user's answers weigh with CompletionCriteria.WeightByTrust before they have a Trust score.
+const defaultTrustWeight = 0.5
+
+// trustWeights returns the weight of each user behind the assignments: their Trust score, or defaultTrustWeight
+// until they have one.
+func (s *Server) trustWeights(assignments []Assignment) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, assignment := range assignments {
+		if _, ok := weights[assignment.User]; ok {
+			continue
+		}
+		weights[assignment.User] = defaultTrustWeight
+		user, err := s.FindUser(assignment.User)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil && user.Trust != nil && user.Trust.Compared > 0 {
+			weights[assignment.User] = user.Trust.Score
+		}
+	}
+	return weights, nil
+}
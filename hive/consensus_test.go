@@ -0,0 +1,230 @@
+package hive
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "kitten", 0},
+		{"kitten", "sitten", 1},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"Fine furs", "Fine furs at", 3},
+		{"café", "cafe", 1}, // runes, not bytes
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := levenshtein(test.b, test.a); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestFieldMatchRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FieldMatchRule
+		a, b interface{}
+		want bool
+	}{
+		{"equal numbers", FieldMatchRule{}, 1.5, 1.5, true},
+		{"numbers within tolerance", FieldMatchRule{Tolerance: 0.05}, 9.99, 10.03, true},
+		{"numbers at tolerance", FieldMatchRule{Tolerance: 0.5}, 1.0, 1.5, true},
+		{"numbers beyond tolerance", FieldMatchRule{Tolerance: 0.05}, 9.99, 10.1, false},
+		{"numbers without tolerance", FieldMatchRule{}, 1.0, 1.01, false},
+		{"strings within edits", FieldMatchRule{Levenshtein: 2}, "Daily News", "Dialy News", true},
+		{"strings beyond edits", FieldMatchRule{Levenshtein: 1}, "Daily News", "Dialy Nws", false},
+		{"strings without edits", FieldMatchRule{}, "yes", "Yes", false},
+		{"number and string", FieldMatchRule{Tolerance: 1, Levenshtein: 1}, 1.0, "1", false},
+		{"other values compared exactly", FieldMatchRule{Tolerance: 1}, []interface{}{1.0}, []interface{}{1.0}, true},
+		{"other values that differ", FieldMatchRule{Tolerance: 1}, []interface{}{1.0}, []interface{}{2.0}, false},
+	}
+	for _, test := range tests {
+		if got := test.rule.matches(test.a, test.b); got != test.want {
+			t.Errorf("%s: matches(%v, %v) = %v, want %v", test.name, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestWithinTolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      interface{}
+		tolerance float64
+		want      bool
+	}{
+		{"numbers within", 10.0, 12.0, 2, true},
+		{"numbers beyond", 10.0, 12.5, 2, false},
+		{"nested crop", map[string]interface{}{"x": 10.0, "y": 20.0}, map[string]interface{}{"x": 12.0, "y": 19.0}, 3, true},
+		{"nested crop beyond", map[string]interface{}{"x": 10.0, "y": 20.0}, map[string]interface{}{"x": 14.0, "y": 19.0}, 3, false},
+		{"missing key", map[string]interface{}{"x": 10.0, "y": 20.0}, map[string]interface{}{"x": 10.0, "z": 20.0}, 3, false},
+		{"extra key", map[string]interface{}{"x": 10.0}, map[string]interface{}{"x": 10.0, "y": 20.0}, 3, false},
+		{"lists", []interface{}{1.0, 2.0}, []interface{}{1.5, 2.5}, 1, true},
+		{"lists of other lengths", []interface{}{1.0, 2.0}, []interface{}{1.0}, 1, false},
+		{"strings stay exact", map[string]interface{}{"label": "ad"}, map[string]interface{}{"label": "Ad"}, 10, false},
+		{"number and string", 1.0, "1", 10, false},
+	}
+	for _, test := range tests {
+		if got := withinTolerance(test.a, test.b, test.tolerance); got != test.want {
+			t.Errorf("%s: withinTolerance(%v, %v, %v) = %v, want %v", test.name, test.a, test.b, test.tolerance, got, test.want)
+		}
+	}
+}
+
+func TestMatchAnswers(t *testing.T) {
+	priced := CompletionCriteria{Fields: map[string]FieldMatchRule{"price": {Tolerance: 0.05}}}
+	tests := []struct {
+		name     string
+		criteria CompletionCriteria
+		a, b     SubmittedData
+		want     bool
+	}{
+		{"no rules, same", CompletionCriteria{}, SubmittedData{"price": 1.0}, SubmittedData{"price": 1.0}, true},
+		{"no rules, different", CompletionCriteria{}, SubmittedData{"price": 1.0}, SubmittedData{"price": 1.01}, false},
+		{"rule loosens its field", priced, SubmittedData{"price": 1.0, "item": "hat"}, SubmittedData{"price": 1.04, "item": "hat"}, true},
+		{"rest still compared", priced, SubmittedData{"price": 1.0, "item": "hat"}, SubmittedData{"price": 1.0, "item": "cap"}, false},
+		{"field missing from one", priced, SubmittedData{"price": 1.0}, SubmittedData{"item": "hat"}, false},
+		{"field missing from both", priced, SubmittedData{"item": "hat"}, SubmittedData{"item": "hat"}, true},
+	}
+	for _, test := range tests {
+		if got := matchAnswers(test.criteria, test.a, test.b, exactSame); got != test.want {
+			t.Errorf("%s: matchAnswers(%v, %v) = %v, want %v", test.name, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// exactSame compares the rest of an answer the way exactStrategy does.
+func exactSame(a, b SubmittedData) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func TestStrategyTally(t *testing.T) {
+	answer := func(user string, source string, data SubmittedData) Assignment {
+		return Assignment{User: user, Source: source, SubmittedData: data}
+	}
+	tests := []struct {
+		name        string
+		task        Task
+		assignments []Assignment
+		want        []int // counts of each answer, in the order they were first given
+	}{
+		{
+			"exact",
+			Task{},
+			[]Assignment{
+				answer("a", "", SubmittedData{"label": "ad"}),
+				answer("b", "", SubmittedData{"label": "Ad "}),
+				answer("c", "", SubmittedData{"label": "ad"}),
+			},
+			[]int{2, 1},
+		},
+		{
+			"normalized",
+			Task{MatchingStrategy: "normalized"},
+			[]Assignment{
+				answer("a", "", SubmittedData{"label": "ad"}),
+				answer("b", "", SubmittedData{"label": "Ad "}),
+			},
+			[]int{2},
+		},
+		{
+			"tolerance",
+			Task{MatchingStrategy: "tolerance", MatchingTolerance: 5},
+			[]Assignment{
+				answer("a", "", SubmittedData{"x": 100.0}),
+				answer("b", "", SubmittedData{"x": 104.0}),
+				answer("c", "", SubmittedData{"x": 110.0}),
+			},
+			[]int{2, 1},
+		},
+		{
+			"distinct users",
+			Task{CompletionCriteria: CompletionCriteria{DistinctUsers: true}},
+			[]Assignment{
+				answer("a", "", SubmittedData{"label": "ad"}),
+				answer("a", "", SubmittedData{"label": "ad"}),
+				answer("b", "", SubmittedData{"label": "ad"}),
+			},
+			[]int{2},
+		},
+		{
+			"distinct sources",
+			Task{CompletionCriteria: CompletionCriteria{DistinctSources: true}},
+			[]Assignment{
+				answer("a", "laptop", SubmittedData{"label": "ad"}),
+				answer("b", "laptop", SubmittedData{"label": "ad"}),
+				answer("c", "phone", SubmittedData{"label": "ad"}),
+			},
+			[]int{2},
+		},
+		{
+			"levenshtein field",
+			Task{CompletionCriteria: CompletionCriteria{Fields: map[string]FieldMatchRule{"title": {Levenshtein: 2}}}},
+			[]Assignment{
+				answer("a", "", SubmittedData{"title": "Fine furs", "page": 2.0}),
+				answer("b", "", SubmittedData{"title": "Fine fur", "page": 2.0}),
+				answer("c", "", SubmittedData{"title": "Fine furs", "page": 3.0}),
+			},
+			[]int{2, 1},
+		},
+	}
+	for _, test := range tests {
+		strategy, err := taskConsensusStrategy(test.task)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		trackers := strategy.Tally(test.task, test.assignments, nil)
+		var got []int
+		for _, tracker := range trackers {
+			got = append(got, tracker.Count)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: counts = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTaskConsensusStrategyUnknown(t *testing.T) {
+	_, err := taskConsensusStrategy(Task{MatchingStrategy: "fuzzy"})
+	if err == nil {
+		t.Error("taskConsensusStrategy(fuzzy) succeeded, want an error")
+	}
+}
+
+func TestClientIp(t *testing.T) {
+	s := &Server{Config: Config{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"forwarded by an untrusted client", "203.0.113.5:4000", "198.51.100.7", "203.0.113.5"},
+		{"forwarded by a trusted proxy", "10.1.2.3:4000", "198.51.100.7", "198.51.100.7"},
+		{"forwarded through trusted proxies", "10.1.2.3:4000", "198.51.100.7, 192.0.2.1", "198.51.100.7"},
+		{"made up hops before the client", "10.1.2.3:4000", "1.1.1.1, 2.2.2.2, 198.51.100.7", "198.51.100.7"},
+		{"trusted proxy without the header", "10.1.2.3:4000", "", "10.1.2.3"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/projects/crowd/tasks/find/assignments", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := s.clientIp(r); got != test.want {
+			t.Errorf("%s: clientIp = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
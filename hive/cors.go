@@ -0,0 +1,116 @@
+package hive
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// corsMethods are the methods a preflight can ask about; the ones a path's routes accept are allowed.
+var corsMethods = []string{"GET", "PUT", "POST", "PATCH", "DELETE"}
+
+const (
+	corsAllowedHeaders = "Content-Type, Authorization, X-Requested-With, If-None-Match"
+	corsExposedHeaders = "ETag, X-Request-Id"
+	corsMaxAge         = "600" // seconds browsers can reuse a preflight's answer
+)
+
+// corsPolicy says how a request from origin may be answered: with the origin echoed back and cookies allowed, when
+// the server's CorsOrigins or the project's list it; with "*" and no cookies, when one of them lists "*" or neither
+// lists anything; or not at all. Echoing any origin along with cookies would let any site act as a signed in user.
+func (s *Server) corsPolicy(origin string, projectId string) (allowOrigin string, credentials bool) {
+	allowed := s.Config.CorsOrigins
+	if projectId != "" {
+		var project *Project
+		if s.Store.Get("projects", projectId, &project) == nil && project != nil {
+			allowed = append(append([]string{}, allowed...), project.CorsOrigins...)
+		}
+	}
+	if len(allowed) == 0 {
+		return "*", false
+	}
+	anySite := false
+	for _, site := range allowed {
+		if site == "*" {
+			anySite = true
+		} else if strings.EqualFold(strings.TrimSuffix(site, "/"), origin) {
+			return origin, true
+		}
+	}
+	if anySite {
+		return "*", false
+	}
+	return "", false
+}
+
+// sameOrigin reports whether origin is the site hive is being called on, which needs no CORS headers.
+func sameOrigin(r *http.Request, origin string) bool {
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// cors answers preflights and adds CORS headers to every response for requests from other sites, see corsPolicy.
+// Preflights are answered here rather than by the routes, which mostly only accept their own methods, so they get
+// the methods the path really accepts without passing through authentication meant for the request itself.
+func (s *Server) cors(router *mux.Router, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || sameOrigin(r, origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		preflight := r.Method == "OPTIONS" && requestedMethod != ""
+
+		// a route is matched as the request that'll follow, ex: the POST a preflight asks about
+		var methods []string
+		projectId := ""
+		for _, method := range corsMethods {
+			probe := *r
+			probe.Method = method
+			var match mux.RouteMatch
+			if !router.Match(&probe, &match) || match.Route == nil {
+				continue
+			}
+			methods = append(methods, method)
+			if projectId == "" {
+				projectId = match.Vars["project_id"]
+			}
+		}
+
+		allowOrigin, credentials := s.corsPolicy(origin, projectId)
+		if allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+		if !preflight {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		switch {
+		case allowOrigin == "":
+			s.wrapResponse(w, r, 403, s.wrapError(fmt.Errorf("Sorry, %s isn't allowed to call hive from the browser.", origin)))
+		case len(methods) == 0:
+			s.wrapFailure(w, r, notFound("Sorry, there isn't an endpoint at %s.", r.URL.Path))
+		case !containsString(methods, strings.ToUpper(requestedMethod)):
+			w.Header().Set("Allow", strings.Join(append(methods, "OPTIONS"), ", "))
+			s.wrapResponse(w, r, 405, s.wrapError(fmt.Errorf("Sorry, %s only accepts %s.", r.URL.Path, strings.Join(methods, ", "))))
+		default:
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(append(methods, "OPTIONS"), ", "))
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
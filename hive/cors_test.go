@@ -0,0 +1,52 @@
+package hive
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsPolicy(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         []string
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+	}{
+		{"nothing listed", nil, "https://crowd.example.com", "*", false},
+		{"listed", []string{"https://crowd.example.com"}, "https://crowd.example.com", "https://crowd.example.com", true},
+		{"listed with a slash", []string{"https://crowd.example.com/"}, "https://crowd.example.com", "https://crowd.example.com", true},
+		{"listed in other case", []string{"https://Crowd.Example.com"}, "https://crowd.example.com", "https://crowd.example.com", true},
+		{"not listed", []string{"https://crowd.example.com"}, "https://evil.example.com", "", false},
+		{"other scheme", []string{"https://crowd.example.com"}, "http://crowd.example.com", "", false},
+		{"any site", []string{"*"}, "https://evil.example.com", "*", false},
+		{"any site, and a listed one", []string{"*", "https://crowd.example.com"}, "https://crowd.example.com", "https://crowd.example.com", true},
+		{"any site, and an unlisted one", []string{"*", "https://crowd.example.com"}, "https://evil.example.com", "*", false},
+	}
+	for _, test := range tests {
+		s := &Server{Config: Config{CorsOrigins: test.origins}}
+		gotOrigin, gotCredentials := s.corsPolicy(test.origin, "")
+		if gotOrigin != test.wantOrigin || gotCredentials != test.wantCredentials {
+			t.Errorf("%s: corsPolicy(%q) = %q, %v, want %q, %v", test.name, test.origin, gotOrigin, gotCredentials, test.wantOrigin, test.wantCredentials)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://hive.example.com", true},
+		{"http://hive.example.com", true},
+		{"https://crowd.example.com", false},
+		{"https://hive.example.com:8443", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "https://hive.example.com/projects/crowd", nil)
+		if got := sameOrigin(r, test.origin); got != test.want {
+			t.Errorf("sameOrigin(%q) = %v, want %v", test.origin, got, test.want)
+		}
+	}
+}
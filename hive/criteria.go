@@ -0,0 +1,200 @@
+package hive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type criteriaPreviewResponse struct {
+	Problems []string // what's wrong with the criteria; assets are only matched when there are none
+	Matching int      // how many assets the criteria currently allow, before excluding what each user has already done
+	Sample   []Asset  // a random sample of those assets
+}
+
+// criteriaMusts builds the elasticsearch filters that select assets matching a task's AssignmentCriteria.
+func criteriaMusts(task Task) []string {
+	musts := []string{}
+	for taskName, ruleI := range task.AssignmentCriteria.SubmittedData {
+		rule, _ := ruleI.(map[string]interface{})
+
+		// an empty rule means assets should have no data submitted for this task
+		if len(rule) == 0 {
+			tmpl := `{
+				"missing": {
+					"field": "SubmittedData.%s"
+				}
+			}`
+
+			musts = append(musts, fmt.Sprintf(tmpl, task.Name))
+
+			// assets must have data submitted that exactly matches the rule
+		} else {
+			for fieldName, fieldValue := range rule {
+				tmpl := `{
+					"query": {
+						"match": {
+							"SubmittedData.%s.%s": "%s"
+						}
+					}
+				}`
+				musts = append(musts, fmt.Sprintf(tmpl, taskName, fieldName, fieldValue))
+			}
+		}
+	}
+	return musts
+}
+
+// CriteriaProblems lists what's wrong with assignment criteria, ex: a rule for a task name that doesn't exist in the project.
+// Criteria like that are accepted when a task is saved but quietly leave it with no assets to assign.
+func (s *Server) CriteriaProblems(criteria AssignmentCriteria) ([]string, error) {
+	p := Params{
+		From:    "0",
+		Size:    "100",
+		SortBy:  "Name",
+		SortDir: "asc",
+	}
+	tasks, _, err := s.FindTasks(p)
+	if err != nil {
+		return nil, err
+	}
+	taskNames := make(map[string]bool)
+	for _, task := range tasks {
+		taskNames[task.Name] = true
+	}
+
+	// sort rule names so problems are reported in a stable order
+	ruleNames := make([]string, 0, len(criteria.SubmittedData))
+	for taskName := range criteria.SubmittedData {
+		ruleNames = append(ruleNames, taskName)
+	}
+	sort.Strings(ruleNames)
+
+	problems := []string{}
+	for _, taskName := range ruleNames {
+		if !taskNames[taskName] {
+			problems = append(problems, fmt.Sprintf("There's no task named %q in this project.", taskName))
+		}
+
+		ruleI := criteria.SubmittedData[taskName]
+		if ruleI == nil {
+			problems = append(problems, fmt.Sprintf("The rule for %q should be an object, ex: {} for assets with no data for that task yet.", taskName))
+			continue
+		}
+		rule, ok := ruleI.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("The rule for %q should be an object of field names and values, not %s.", taskName, jsonKind(ruleI)))
+			continue
+		}
+		for fieldName, fieldValue := range rule {
+			if _, ok := fieldValue.(string); !ok {
+				problems = append(problems, fmt.Sprintf("The value for %s.%s should be a string, not %s.", taskName, fieldName, jsonKind(fieldValue)))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// jsonKind names the kind of a decoded json value for error messages.
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// PreviewCriteria checks assignment criteria for a task and, when they're sound, counts and samples the assets they match.
+func (s *Server) PreviewCriteria(task Task, n int) (preview criteriaPreviewResponse, err error) {
+	preview.Problems, err = s.CriteriaProblems(task.AssignmentCriteria)
+	if err != nil || len(preview.Problems) > 0 {
+		return
+	}
+
+	task, err = s.liveCriteria(task)
+	if err != nil {
+		return
+	}
+
+	workflowMusts, err := s.workflowMusts(task)
+	if err != nil {
+		return
+	}
+
+	// excluded assets are out of circulation
+	filters := append(criteriaMusts(task), workflowMusts...)
+	filters = append(filters, `{ "not": { "term": { "Excluded": true } } }`)
+	preview.Sample, preview.Matching, err = s.RandomAssets(filters, n)
+	if preview.Sample == nil {
+		preview.Sample = []Asset{}
+	}
+	return
+}
+
+// @Title AdminCriteriaPreviewHandler
+// @Description checks assignment criteria and returns how many assets they currently match, with a random sample
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id     path    string     true        "Task ID"
+// @Param   criteria        body   string     false        "JSON-formatted AssignmentCriteria to try, ex: {\"SubmittedData\": {\"find\": {\"match\": \"yes\"}}}; defaults to the task's own"
+// @Param   n        query   int     false        "Sample size, defaults to 50 (max 500)"
+// @Success 200 {object}  criteriaPreviewResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/criteria/preview [post]
+func (s *Server) AdminCriteriaPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		task.AssignmentCriteria = AssignmentCriteria{}
+		err = json.Unmarshal(body, &task.AssignmentCriteria)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+	}
+
+	preview, err := s.PreviewCriteria(*task, sampleSize(r))
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	previewJson, err := json.Marshal(preview)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, previewJson)
+}
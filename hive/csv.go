@@ -0,0 +1,167 @@
+package hive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// wantsCsv reports whether the request asked for a spreadsheet instead of json, with ?format=csv.
+func wantsCsv(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "csv"
+}
+
+// wrapCsv writes rows as a CSV file download named filename.
+func (s *Server) wrapCsv(w http.ResponseWriter, r *http.Request, filename string, rows [][]string) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	err := writer.WriteAll(rows)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(200)
+	w.Write(buf.Bytes())
+}
+
+// userStatsCsv returns a header row followed by one row of counts and accuracy per user.
+func (s *Server) userStatsCsv(users []User) ([][]string, error) {
+	rows := [][]string{{"Id", "Name", "Email", "ExternalId", "Assignments", "Finished", "Verified", "Skipped", "VerifiedAssets", "Favorites", "GoldAnswered", "GoldCorrect", "Accuracy", "Rank"}}
+	for _, user := range users {
+		stats, err := s.CalculateUserStats(user, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, []string{
+			user.Id,
+			user.Name,
+			user.Email,
+			user.ExternalId,
+			strconv.Itoa(user.Counts["Assignments"]),
+			strconv.Itoa(stats.Finished),
+			strconv.Itoa(stats.Verified),
+			strconv.Itoa(stats.Skipped),
+			strconv.Itoa(user.Counts["VerifiedAssets"]),
+			strconv.Itoa(user.Counts["Favorites"]),
+			strconv.Itoa(stats.GoldAnswered),
+			strconv.Itoa(stats.GoldCorrect),
+			strconv.FormatFloat(stats.Accuracy, 'f', 4, 64),
+			strconv.Itoa(stats.Rank),
+		})
+	}
+	return rows, nil
+}
+
+// ImportAssetsCsv imports assets from a spreadsheet with a header row. The url column is required, name, language
+// and dedupKey columns fill in those fields, and every other column becomes a Metadata key. Cells are kept as strings and empty ones are left out.
+func (s *Server) ImportAssetsCsv(body io.Reader, batchSize int, dedup bool) (assetStreamResponse, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return assetStreamResponse{}, errors.New("Sorry, the CSV is empty.")
+	}
+	if err != nil {
+		return assetStreamResponse{}, err
+	}
+
+	urlColumn := -1
+	for i, column := range header {
+		// spreadsheets saved as UTF-8 often start with a byte order mark
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		header[i] = column
+		if strings.EqualFold(column, "url") {
+			urlColumn = i
+		}
+	}
+	if urlColumn == -1 {
+		return assetStreamResponse{}, errors.New("Sorry, the CSV needs a url column.")
+	}
+
+	row := 1
+	return s.importAssetStream(batchSize, dedup, func() (*Asset, error) {
+		record, err := reader.Read()
+		row++
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d couldn't be read: %v", row, err)
+		}
+
+		asset := Asset{Metadata: make(map[string]interface{})}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" || i >= len(header) {
+				continue
+			}
+			switch {
+			case i == urlColumn:
+				asset.Url = value
+			case strings.EqualFold(header[i], "name"):
+				asset.Name = value
+			case strings.EqualFold(header[i], "language"):
+				asset.Language = value
+			case strings.EqualFold(header[i], "dedupKey"):
+				asset.DedupKey = value
+			default:
+				asset.Metadata[header[i]] = value
+			}
+		}
+		if asset.Url == "" {
+			return nil, fmt.Errorf("row %d has no url", row)
+		}
+		return &asset, nil
+	})
+}
+
+// @Title AdminImportAssetsCsvHandler
+// @Description imports assets from a CSV with a url column; other columns become metadata
+// @Accept  text/csv
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   assets        body   string     true        "CSV with a header row, ex: url,name,issue,page"
+// @Param   batch        query   int     false        "How many assets to store per bulk request, defaults to 500 (max 5000)"
+// @Param   dedup        query   bool     false        "Unless false, rows with the Url of an asset already in the project update it instead of creating another"
+// @Param   import        query   string     false        "The id of an import that stopped partway, to resume it: send the same assets and those it already got through are skipped"
+// @Success 200 {object}  assetStreamResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets.csv [post]
+func (s *Server) AdminImportAssetsCsvHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	scoped, err := s.startImport(r.URL.Query().Get("import"), "csv")
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s = scoped
+	imported, err := s.ImportAssetsCsv(r.Body, importBatchSize(r), dedupImports(r))
+	err = s.finishImport(err)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	importedJson, err := json.Marshal(imported)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, importedJson)
+}
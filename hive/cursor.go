@@ -0,0 +1,42 @@
+package hive
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Deep pages are slow to reach with from and size, since elasticsearch has to gather every document before them.
+// Lists sorted by Id can be walked with a cursor instead: each full page's Meta.Cursor holds the last Id on it,
+// and passing it back as cursor= picks up right after it, however deep that is.
+
+// cursorFilter returns a filter for the documents after p's Cursor, or an empty string when there isn't one.
+// Paging by cursor always goes in Id order from the cursor, so it sets p's sort and From to match.
+func cursorFilter(p *Params) (string, error) {
+	if p.Cursor == "" {
+		return "", nil
+	}
+	id, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil || len(id) == 0 {
+		return "", &ValidationError{Fields: []FieldError{{Field: "cursor", Error: "isn't one hive gave out, start again without it"}}}
+	}
+	idJson, err := json.Marshal(string(id))
+	if err != nil {
+		return "", err
+	}
+	p.SortBy = "Id"
+	p.SortDir = "asc"
+	p.From = "0"
+	return fmt.Sprintf(`{ "range": { "Id": { "gt": %s } } }`, idJson), nil
+}
+
+// nextCursor returns the cursor for the page after hits, or an empty string when there can't be one:
+// hits weren't in Id order, or they didn't fill the page.
+func nextCursor(p Params, hits []SearchHit) string {
+	size, _ := strconv.Atoi(p.Size)
+	if p.SortBy != "Id" || p.SortDir == "desc" || len(hits) == 0 || len(hits) < size {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(hits[len(hits)-1].Id))
+}
@@ -0,0 +1,118 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// dedupImports reads the dedup query param: imports match assets to ones already in the project by Url
+// unless it's false.
+func dedupImports(r *http.Request) bool {
+	return defaultQuery(r.URL.Query(), "dedup", "true") != "false"
+}
+
+// findAssetByUrl returns the current project's asset with exactly this url, or nil when there isn't one.
+// Urls are indexed as text, so the search finds candidates and the exact comparison is made here.
+func (s *Server) findAssetByUrl(url string) (*Asset, error) {
+	urlJson, err := json.Marshal(url)
+	if err != nil {
+		return nil, err
+	}
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"query": { "match_phrase": { "Url": %s } },
+				"filter": { "term": { "Project": "%s" } }
+			}
+		},
+		"size": 20
+	}`, urlJson, s.ActiveProjectId)
+	results, err := s.Store.Search("assets", searchJson)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range results.Hits.Hits {
+		var asset Asset
+		err = json.Unmarshal(*hit.Source, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if asset.Url == url {
+			return &asset, nil
+		}
+	}
+	return nil, nil
+}
+
+// importedAsset returns the asset an import should update instead of creating another with the same url: one
+// earlier in the same import, or one already in the project. It returns nil when the url is new.
+func (s *Server) importedAsset(imp *assetImport, url string) (*Asset, error) {
+	if id, ok := imp.urlIds[url]; ok {
+		return s.FindAsset(id)
+	}
+	return s.findAssetByUrl(url)
+}
+
+// stableAssetId returns the id an imported asset asks for: its own Id, or one derived from its DedupKey, or ""
+// when it leaves the id to hive.
+func stableAssetId(projectId string, asset Asset) string {
+	if asset.Id != "" || asset.DedupKey == "" {
+		return asset.Id
+	}
+	return hashAssetId(projectId, []byte("dedupKey\x00"+asset.DedupKey))
+}
+
+// storedAsset returns the current project's asset with id, or nil when there isn't one. Ids are shared by every
+// project, so an id another project's asset already has is an error.
+func (s *Server) storedAsset(id string) (*Asset, error) {
+	exists, err := s.Store.Exists("assets", id)
+	if err != nil || !exists {
+		return nil, err
+	}
+	asset, err := s.FindAsset(id)
+	if err != nil {
+		return nil, err
+	}
+	if asset.Project != s.ActiveProjectId {
+		return nil, conflict("Sorry, the asset id %q is already used in another project.", id)
+	}
+	return asset, nil
+}
+
+// mergeImportedAsset lays the fields given in an import over a stored asset. What contributors have done with it,
+// its answers, counts and verification, is kept.
+func mergeImportedAsset(stored Asset, imported Asset) Asset {
+	// assets matched by id can move, ex: when a CMS changes an article's url
+	if imported.Url != "" {
+		stored.Url = imported.Url
+	}
+	if imported.DedupKey != "" {
+		stored.DedupKey = imported.DedupKey
+	}
+	if imported.Name != "" {
+		stored.Name = imported.Name
+	}
+	if imported.Language != "" {
+		stored.Language = normalizeLanguage(imported.Language)
+	}
+	if imported.Priority != 0 {
+		stored.Priority = imported.Priority
+	}
+	if imported.Private {
+		stored.Private = true
+	}
+	if len(imported.Metadata) > 0 && stored.Metadata == nil {
+		stored.Metadata = make(map[string]interface{})
+	}
+	for key, value := range imported.Metadata {
+		stored.Metadata[key] = value
+	}
+	if len(imported.GoldData) > 0 && stored.GoldData == nil {
+		stored.GoldData = SubmittedData{}
+	}
+	for taskName, answer := range imported.GoldData {
+		stored.GoldData[taskName] = answer
+	}
+	return stored
+}
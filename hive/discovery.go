@@ -0,0 +1,109 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// publicProject is the slice of a project shown on public listings, ex: a "projects" landing page.
+type publicProject struct {
+	Id          string
+	Name        string
+	Description string
+	Progress    int    // percentage (0-100) of non-excluded assets that are verified
+	HeroAsset   *Asset `json:",omitempty"` // the project's featured asset, if it has one
+}
+
+type publicProjectsResponse struct {
+	Projects []publicProject
+	Meta     meta
+}
+
+// FindPublicProjects returns active projects, ordered by id, with their progress and hero asset.
+func (s *Server) FindPublicProjects(p Params) (projects []publicProject, m meta, err error) {
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"term": { "Active": true }
+				}
+			}
+		},
+		"from": %s,
+		"size": %s,
+		"sort": [ { "Id": { "order" : "asc" } } ]
+	}`, p.From, p.Size)
+	results, err := s.Store.Search("projects", searchJson)
+	if err != nil {
+		return
+	}
+
+	m.Total = results.Hits.Total
+	m.From, _ = strconv.Atoi(p.From)
+	m.Size, _ = strconv.Atoi(p.Size)
+
+	projects = make([]publicProject, 0)
+	for _, hit := range results.Hits.Hits {
+		var project Project
+		err = json.Unmarshal(*hit.Source, &project)
+		if err != nil {
+			return
+		}
+		// tallies are scoped to a project, so each listed project is counted in its own scope
+		scoped := s.forProject(project.Id)
+
+		listed := publicProject{
+			Id:          project.Id,
+			Name:        project.Name,
+			Description: project.Description,
+		}
+		assetCount, _ := scoped.Count("assets")
+		_, _, listed.Progress, _ = scoped.CountAssetProgress(assetCount)
+
+		if project.HeroAsset != "" {
+			hero, err := scoped.FindAsset(project.HeroAsset)
+			if err == nil && hero != nil && scoped.signAssetUrl(hero) == nil {
+				// gold answers stay private to admins
+				hero.GoldData = nil
+				listed.HeroAsset = hero
+			}
+		}
+		projects = append(projects, listed)
+	}
+	return
+}
+
+// @Title ProjectsHandler
+// @Description returns a paginated list of active projects with their progress and hero asset, for public landing pages
+// @Accept  json
+// @Param   from        query   int     false        "If specified, will return a set of projects starting with from number"
+// @Param   size        query   int     false        "If specified, will return a total number of projects specified as size"
+// @Success 200 {object}  publicProjectsResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /projects [get]
+func (s *Server) ProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	p := Params{
+		From: defaultQuery(queryParams, "from", "0"),
+		Size: defaultQuery(queryParams, "size", "10"),
+	}
+
+	projects, m, err := s.FindPublicProjects(p)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	projectsJson, err := json.Marshal(publicProjectsResponse{
+		Projects: projects,
+		Meta:     m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, projectsJson)
+}
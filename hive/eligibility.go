@@ -0,0 +1,236 @@
+package hive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// eligibilityStep is one filter applied when picking an asset to assign, with how many assets are left after it.
+type eligibilityStep struct {
+	Step      string          // what the filter does, ex: "matches assignment criteria"
+	Filter    json.RawMessage // the elasticsearch filter itself
+	Remaining int             // assets passing this filter and every one before it
+}
+
+// eligibilityResponse explains whether a user can get a new assignment for a task and, if not, why.
+type eligibilityResponse struct {
+	User       string
+	Task       string
+	TaskState  string
+	Eligible   bool
+	Reasons    []string          // why the user can't be assigned anything, empty when Eligible
+	Unfinished *Assignment       `json:",omitempty"` // the user's unfinished assignment, which they'd be given again
+	Funnel     []eligibilityStep // each filter in the order it narrows the project's assets
+	Languages  int               // of the remaining assets, how many are in one of the user's languages and would be preferred
+}
+
+// countFilteredAssets returns how many assets pass every must filter and no must_not filter.
+func (s *Server) countFilteredAssets(musts []string, mustNots []string) (int, error) {
+	query := fmt.Sprintf(`{"query":{"filtered":{"filter":{"bool":{"must":[%s],"must_not":[%s]}}}}}`, strings.Join(musts, ", "), strings.Join(mustNots, ", "))
+
+	count, err := s.Store.Count("assets", query)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// compactFilter squeezes the whitespace out of a filter for display, falling back to the filter as written.
+func compactFilter(filter string) json.RawMessage {
+	var buf bytes.Buffer
+	err := json.Compact(&buf, []byte(filter))
+	if err != nil {
+		return json.RawMessage(filter)
+	}
+	return json.RawMessage(buf.Bytes())
+}
+
+// ExplainEligibility walks through the checks CreateAssignment and FindAssignmentAsset make for a user and task,
+// counting the assets left after each filter.
+func (s *Server) ExplainEligibility(task Task, user User) (explain eligibilityResponse, err error) {
+	explain.User = user.Id
+	explain.Task = task.Id
+	explain.TaskState = task.CurrentState
+	explain.Reasons = []string{}
+	explain.Funnel = []eligibilityStep{}
+
+	if task.CurrentState != "available" {
+		explain.Reasons = append(explain.Reasons, fmt.Sprintf("The task is %s, not available.", task.CurrentState))
+	}
+
+	problems, err := s.CriteriaProblems(task.AssignmentCriteria)
+	if err != nil {
+		return
+	}
+	for _, problem := range problems {
+		explain.Reasons = append(explain.Reasons, "Assignment criteria: "+problem)
+	}
+
+	assignments, err := s.FindUserAssignments(user.Id)
+	if err != nil {
+		return
+	}
+	for _, assignment := range assignments {
+		if assignment.Task == task.Id && assignment.State == "unfinished" {
+			unfinished := assignment
+			explain.Unfinished = &unfinished
+			break
+		}
+	}
+
+	if explain.Unfinished == nil {
+		err = s.checkUnfinishedLimit(user.Id, "")
+		if err == ErrTooManyUnfinished {
+			explain.Reasons = append(explain.Reasons, "The user already holds the project's limit of unfinished assignments.")
+			err = nil
+		} else if err != nil {
+			return
+		}
+		err = s.checkTaskLimit(task, user.Id)
+		if err == ErrTaskLimitReached {
+			explain.Reasons = append(explain.Reasons, fmt.Sprintf("The user has already finished the task's limit of %d assignments.", task.MaxAssignmentsPerUser))
+			err = nil
+		} else if err != nil {
+			return
+		}
+	}
+
+	// the same filters FindAssignmentAsset builds, one at a time
+	musts := []string{}
+	mustNots := []string{}
+	addStep := func(step string, filter string, mustNot bool) error {
+		if mustNot {
+			mustNots = append(mustNots, filter)
+		} else {
+			musts = append(musts, filter)
+		}
+		remaining, err := s.countFilteredAssets(musts, mustNots)
+		if err != nil {
+			return err
+		}
+		explain.Funnel = append(explain.Funnel, eligibilityStep{
+			Step:      step,
+			Filter:    compactFilter(filter),
+			Remaining: remaining,
+		})
+		return nil
+	}
+
+	err = addStep("in this project", fmt.Sprintf(`{ "query": { "match": { "Project": "%s" } } }`, s.ActiveProjectId), false)
+	if err != nil {
+		return
+	}
+	err = addStep("not excluded", `{ "term": { "Excluded": true } }`, true)
+	if err != nil {
+		return
+	}
+	if len(problems) == 0 {
+		task, err = s.liveCriteria(task)
+		if err != nil {
+			return
+		}
+		for _, filter := range criteriaMusts(task) {
+			err = addStep("matches assignment criteria", filter, false)
+			if err != nil {
+				return
+			}
+		}
+		var workflowMusts []string
+		workflowMusts, err = s.workflowMusts(task)
+		if err != nil {
+			return
+		}
+		for _, filter := range workflowMusts {
+			err = addStep("verified for the task before this one", filter, false)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	assetIds, err := s.assignedAssetIds(task, user)
+	if err != nil {
+		return
+	}
+	if len(assetIds) > 0 {
+		assetIdString := "\"" + strings.Join(assetIds, "\",\"") + "\""
+		err = addStep("not already assigned to this user", fmt.Sprintf(`{ "query": { "terms": { "Id": [ %s ] } } }`, assetIdString), true)
+		if err != nil {
+			return
+		}
+	}
+
+	remaining := explain.Funnel[len(explain.Funnel)-1].Remaining
+	if len(user.Languages) > 0 && remaining > 0 {
+		languageString := "\"" + strings.Join(user.Languages, "\",\"") + "\""
+		languageMusts := append([]string{fmt.Sprintf(`{ "terms": { "Language": [ %s ] } }`, languageString)}, musts...)
+		explain.Languages, err = s.countFilteredAssets(languageMusts, mustNots)
+		if err != nil {
+			return
+		}
+	}
+
+	if explain.Unfinished == nil && remaining == 0 {
+		for _, step := range explain.Funnel {
+			if step.Remaining == 0 {
+				explain.Reasons = append(explain.Reasons, fmt.Sprintf("No assets are left after the %q filter.", step.Step))
+				break
+			}
+		}
+	}
+	explain.Eligible = len(explain.Reasons) == 0
+	return
+}
+
+// @Title AdminEligibilityHandler
+// @Description explains whether a user can be given an assignment for a task, with the number of assets left after each filter
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id     path    string     true        "Task ID"
+// @Param   user_id     path    string     true        "User ID"
+// @Success 200 {object}  eligibilityResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/eligibility/{user_id} [get]
+func (s *Server) AdminEligibilityHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	user, err := s.FindUser(vars["user_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if user == nil {
+		s.wrapFailure(w, r, notFound("Sorry, there isn't a user with id %s.", vars["user_id"]))
+		return
+	}
+
+	explain, err := s.ExplainEligibility(*task, *user)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	explainJson, err := json.Marshal(explain)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, explainJson)
+}
@@ -0,0 +1,85 @@
+package hive
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// apiVersions are the paths every endpoint is served under, oldest first, besides the legacy unprefixed paths that
+// alias /v1. Breaking changes ship under a new version so frontends can move over when they're ready.
+var apiVersions = []string{"/v1", "/v2"}
+
+// envelopeVersion is the first version that wraps every response in an envelope, see envelopeJson.
+const envelopeVersion = "/v2"
+
+// trimApiVersion returns path without its version prefix, ex: /admin/projects/crowd for /v2/admin/projects/crowd.
+func trimApiVersion(path string) string {
+	for _, version := range apiVersions {
+		if path == version || strings.HasPrefix(path, version+"/") {
+			return strings.TrimPrefix(path, version)
+		}
+	}
+	return path
+}
+
+// usesEnvelopes reports whether a request is answered with envelopes, rather than the shapes /v1 has always had.
+func usesEnvelopes(r *http.Request) bool {
+	return r.URL.Path == envelopeVersion || strings.HasPrefix(r.URL.Path, envelopeVersion+"/")
+}
+
+// envelope is how /v2 answers every request: what was asked for in data, paging in meta, and what went wrong, if
+// anything, in errors. Payloads can gain fields inside data without clients having to guess where to look.
+type envelope struct {
+	Data   json.RawMessage `json:"data"`
+	Meta   json.RawMessage `json:"meta,omitempty"`
+	Errors []envelopeError `json:"errors,omitempty"`
+}
+
+type envelopeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"` // the field of the request body at fault, for "invalid_request"
+}
+
+// envelopeJson wraps a response body the way /v1 sends it in an envelope. Errors become one entry of errors, or
+// one per field at fault; anything else goes in data, less its Meta, which becomes meta. Bodies that aren't JSON
+// objects, ex: the empty body of a 304, are left as they are.
+func envelopeJson(statusCode int, data []byte) []byte {
+	var fields map[string]json.RawMessage
+	if len(data) == 0 || json.Unmarshal(data, &fields) != nil {
+		return data
+	}
+
+	var wrapped envelope
+	if statusCode >= 400 {
+		var response errorResponse
+		json.Unmarshal(data, &response)
+		wrapped.Data = json.RawMessage("null")
+		for _, field := range response.Fields {
+			wrapped.Errors = append(wrapped.Errors, envelopeError{Code: response.Code, Message: field.Error, Field: field.Field})
+		}
+		if len(wrapped.Errors) == 0 {
+			wrapped.Errors = []envelopeError{{Code: response.Code, Message: response.Error}}
+		}
+	} else {
+		wrapped.Meta = fields["Meta"]
+		delete(fields, "Meta")
+		wrapped.Data, _ = json.Marshal(fields)
+	}
+
+	enveloped, err := json.Marshal(wrapped)
+	if err != nil {
+		return data
+	}
+	return enveloped
+}
+
+// marshalUser encodes a user for a response. /v1 sends users bare, unlike every other document, and keeps doing so
+// for the clients that expect it; from /v2 on they're a userResponse, so data holds a User like it holds a Project.
+func marshalUser(r *http.Request, user *User) ([]byte, error) {
+	if usesEnvelopes(r) && user != nil {
+		return json.Marshal(userResponse{User: *user})
+	}
+	return json.Marshal(user)
+}
@@ -0,0 +1,98 @@
+package hive
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEnvelopeJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       string
+	}{
+		{
+			"document",
+			200,
+			`{"Project": {"Id": "crowd"}}`,
+			`{"data": {"Project": {"Id": "crowd"}}}`,
+		},
+		{
+			"list with paging",
+			200,
+			`{"Assets": [], "Meta": {"Total": 0, "From": 0, "Size": 10}}`,
+			`{"data": {"Assets": []}, "meta": {"Total": 0, "From": 0, "Size": 10}}`,
+		},
+		{
+			"error",
+			404,
+			`{"error": "Sorry, there isn't an asset with that id.", "code": "not_found"}`,
+			`{"data": null, "errors": [{"code": "not_found", "message": "Sorry, there isn't an asset with that id."}]}`,
+		},
+		{
+			"error for each field",
+			400,
+			`{"error": "Sorry, the request isn't valid.", "code": "invalid_request", "fields": [{"Field": "Name", "Error": "is required"}, {"Field": "Url", "Error": "isn't a url"}]}`,
+			`{"data": null, "errors": [{"code": "invalid_request", "message": "is required", "field": "Name"}, {"code": "invalid_request", "message": "isn't a url", "field": "Url"}]}`,
+		},
+	}
+	for _, test := range tests {
+		got := envelopeJson(test.statusCode, []byte(test.body))
+		var gotValue, wantValue interface{}
+		if err := json.Unmarshal(got, &gotValue); err != nil {
+			t.Errorf("%s: envelopeJson returned %s, which isn't JSON: %v", test.name, got, err)
+			continue
+		}
+		json.Unmarshal([]byte(test.want), &wantValue)
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Errorf("%s: envelopeJson = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEnvelopeJsonLeavesOtherBodies(t *testing.T) {
+	for _, body := range []string{"", `["a", "b"]`, `"sent"`, "Id,Name\n1,Page 1\n"} {
+		if got := envelopeJson(200, []byte(body)); string(got) != body {
+			t.Errorf("envelopeJson(%q) = %q, want it unchanged", body, got)
+		}
+	}
+}
+
+func TestTrimApiVersion(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"/v1/projects/crowd", "/projects/crowd"},
+		{"/v2/admin/projects/crowd", "/admin/projects/crowd"},
+		{"/v2", ""},
+		{"/projects/crowd", "/projects/crowd"},
+		{"/v20/projects", "/v20/projects"},
+	}
+	for _, test := range tests {
+		if got := trimApiVersion(test.path); got != test.want {
+			t.Errorf("trimApiVersion(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestUsesEnvelopes(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/v2/projects/crowd", true},
+		{"/v2", true},
+		{"/v1/projects/crowd", false},
+		{"/projects/crowd", false},
+		{"/v20/projects/crowd", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		if got := usesEnvelopes(r); got != test.want {
+			t.Errorf("usesEnvelopes(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
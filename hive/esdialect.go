@@ -0,0 +1,328 @@
+package hive
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+)
+
+// esDialect rewrites the Elasticsearch request bodies hive builds, which are written for Elasticsearch 1.x, for the
+// release a store is talking to.
+type esDialect interface {
+	// Search rewrites a search body, ex: `{"query": {...}, "aggs": {...}, "sort": [...]}`.
+	Search(body string) (string, error)
+	// Count rewrites a count body, ex: `{"query": {...}}`.
+	Count(body string) (string, error)
+	// Mapping rewrites a mapping for docType, ex: `{"assets": {"properties": {...}}}`.
+	Mapping(docType string, mapping string) (string, error)
+}
+
+// dialectFor picks the dialect for an Elasticsearch major version.
+func dialectFor(version int) esDialect {
+	switch {
+	case version >= 7:
+		return es7Dialect{}
+	case version == 6:
+		return es6Dialect{}
+	case version == 5:
+		return es5Dialect{}
+	}
+	// 2.x still understands filtered queries and the missing and not filters, and hive no longer uses facets
+	return es1Dialect{}
+}
+
+// es1Dialect is for Elasticsearch 1.x and 2.x, which take hive's bodies as they are.
+type es1Dialect struct{}
+
+func (es1Dialect) Search(body string) (string, error) { return body, nil }
+
+func (es1Dialect) Count(body string) (string, error) { return body, nil }
+
+func (es1Dialect) Mapping(docType string, mapping string) (string, error) { return mapping, nil }
+
+// es5Dialect is for Elasticsearch 5.x: filtered queries become bool queries with a filter, the missing and
+// not filters become must_not clauses, strings are mapped as keyword or text, and unlimited terms aggregations
+// ask for as many buckets as there can be.
+type es5Dialect struct{}
+
+func (d es5Dialect) Search(body string) (string, error) {
+	return rewriteJson(body, d.body)
+}
+
+func (d es5Dialect) Count(body string) (string, error) {
+	return rewriteJson(body, d.body)
+}
+
+func (d es5Dialect) Mapping(docType string, mapping string) (string, error) {
+	return rewriteJson(mapping, func(m map[string]interface{}) {
+		if typeMapping, ok := m[docType].(map[string]interface{}); ok {
+			rewriteMapping(typeMapping)
+		}
+	})
+}
+
+// body rewrites the parts of a search or count body that hold queries.
+func (d es5Dialect) body(body map[string]interface{}) {
+	if query, ok := body["query"]; ok {
+		body["query"] = d.query(query)
+	}
+	// 1.x's top-level filter is post_filter since 2.x
+	if filter, ok := body["filter"]; ok {
+		body["post_filter"] = d.query(filter)
+		delete(body, "filter")
+	}
+	for _, name := range []string{"aggs", "aggregations"} {
+		if aggs, ok := body[name].(map[string]interface{}); ok {
+			d.aggs(aggs)
+		}
+	}
+	d.sort(body["sort"])
+}
+
+// query rewrites a query or filter clause, and any clauses inside it.
+func (d es5Dialect) query(clause interface{}) interface{} {
+	c, ok := clause.(map[string]interface{})
+	if !ok {
+		return clause
+	}
+	if filtered, ok := c["filtered"].(map[string]interface{}); ok {
+		rewritten := make(map[string]interface{})
+		if query, ok := filtered["query"]; ok {
+			rewritten["must"] = []interface{}{d.query(query)}
+		}
+		if filter, ok := filtered["filter"]; ok {
+			rewritten["filter"] = []interface{}{d.query(filter)}
+		}
+		return map[string]interface{}{"bool": rewritten}
+	}
+	if missing, ok := c["missing"].(map[string]interface{}); ok {
+		return mustNot(map[string]interface{}{"exists": map[string]interface{}{"field": missing["field"]}})
+	}
+	if not, ok := c["not"].(map[string]interface{}); ok {
+		// {"not": {"filter": {...}}} and {"not": {...}} are both 1.x
+		if filter, ok := not["filter"]; ok && len(not) == 1 {
+			return mustNot(d.query(filter))
+		}
+		return mustNot(d.query(not))
+	}
+	// a query wrapped to be used as a filter, which is just a query since 2.x
+	if query, ok := c["query"]; ok && len(c) == 1 {
+		return d.query(query)
+	}
+	if fquery, ok := c["fquery"].(map[string]interface{}); ok {
+		return d.query(fquery["query"])
+	}
+
+	if b, ok := c["bool"].(map[string]interface{}); ok {
+		for _, occur := range []string{"must", "must_not", "should", "filter"} {
+			switch clauses := b[occur].(type) {
+			case []interface{}:
+				for i := range clauses {
+					clauses[i] = d.query(clauses[i])
+				}
+			case map[string]interface{}:
+				b[occur] = d.query(clauses)
+			}
+		}
+	}
+	// these took either a query or a filter in 1.x, and since 5.x constant_score only takes a filter and the
+	// others only a query
+	if constantScore, ok := c["constant_score"].(map[string]interface{}); ok {
+		if query, ok := constantScore["query"]; ok {
+			constantScore["filter"] = query
+			delete(constantScore, "query")
+		}
+		constantScore["filter"] = d.query(constantScore["filter"])
+	}
+	for _, name := range []string{"function_score", "nested"} {
+		wrapper, ok := c[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if filter, ok := wrapper["filter"]; ok {
+			wrapper["query"] = filter
+			delete(wrapper, "filter")
+		}
+		if query, ok := wrapper["query"]; ok {
+			wrapper["query"] = d.query(query)
+		}
+		if functions, ok := wrapper["functions"].([]interface{}); ok {
+			for _, function := range functions {
+				if f, ok := function.(map[string]interface{}); ok && f["filter"] != nil {
+					f["filter"] = d.query(f["filter"])
+				}
+			}
+		}
+	}
+	return c
+}
+
+// aggs rewrites aggregations, and those nested in them.
+func (d es5Dialect) aggs(aggs map[string]interface{}) {
+	for _, agg := range aggs {
+		a, ok := agg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if filter, ok := a["filter"]; ok {
+			a["filter"] = d.query(filter)
+		}
+		// a size of 0 meant every term in 1.x, and isn't allowed since 5.x
+		if terms, ok := a["terms"].(map[string]interface{}); ok {
+			if size, ok := terms["size"].(json.Number); ok && size.String() == "0" {
+				terms["size"] = math.MaxInt32
+			}
+		}
+		for _, name := range []string{"aggs", "aggregations"} {
+			if nested, ok := a[name].(map[string]interface{}); ok {
+				d.aggs(nested)
+			}
+		}
+	}
+}
+
+// sort swaps ignore_unmapped, gone since 5.x, for unmapped_type, which sorts documents as if the field were missing.
+func (d es5Dialect) sort(sort interface{}) {
+	clauses, ok := sort.([]interface{})
+	if !ok {
+		clauses = []interface{}{sort}
+	}
+	for _, clause := range clauses {
+		fields, ok := clause.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, options := range fields {
+			o, ok := options.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if ignore, ok := o["ignore_unmapped"]; ok {
+				if ignore == true {
+					o["unmapped_type"] = "long"
+				}
+				delete(o, "ignore_unmapped")
+			}
+		}
+	}
+}
+
+// es6Dialect is for Elasticsearch 6.x: as es5Dialect, with mappings that don't name a document type, since each
+// type is kept in an index of its own.
+type es6Dialect struct {
+	es5Dialect
+}
+
+func (d es6Dialect) Mapping(docType string, mapping string) (string, error) {
+	return rewriteJson(mapping, func(m map[string]interface{}) {
+		typeMapping, ok := m[docType].(map[string]interface{})
+		if !ok {
+			return
+		}
+		rewriteMapping(typeMapping)
+		delete(m, docType)
+		for key, value := range typeMapping {
+			m[key] = value
+		}
+	})
+}
+
+// es7Dialect is for Elasticsearch 7.x and OpenSearch: as es6Dialect, with searches counting every matching
+// document rather than stopping at 10,000, and random scores seeded from each document's sequence number.
+type es7Dialect struct {
+	es6Dialect
+}
+
+func (d es7Dialect) Search(body string) (string, error) {
+	return rewriteJson(body, func(b map[string]interface{}) {
+		d.body(b)
+		d.randomScore(b["query"])
+		b["track_total_hits"] = true
+	})
+}
+
+func (d es7Dialect) Count(body string) (string, error) {
+	return rewriteJson(body, func(b map[string]interface{}) {
+		d.body(b)
+		d.randomScore(b["query"])
+	})
+}
+
+// randomScore gives seeded random_score functions the field 7.x wants them to be seeded from.
+func (d es7Dialect) randomScore(query interface{}) {
+	q, ok := query.(map[string]interface{})
+	if !ok {
+		return
+	}
+	functionScore, ok := q["function_score"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	scores := []interface{}{functionScore["random_score"]}
+	if functions, ok := functionScore["functions"].([]interface{}); ok {
+		for _, function := range functions {
+			if f, ok := function.(map[string]interface{}); ok {
+				scores = append(scores, f["random_score"])
+			}
+		}
+	}
+	for _, score := range scores {
+		if s, ok := score.(map[string]interface{}); ok && s["seed"] != nil && s["field"] == nil {
+			s["field"] = "_seq_no"
+		}
+	}
+}
+
+// rewriteMapping maps 1.x's not_analyzed strings as keyword and other strings as text, and drops the analyzed
+// settings other types no longer take, in a type's mapping and the objects inside it.
+func rewriteMapping(mapping map[string]interface{}) {
+	if fieldType, ok := mapping["type"].(string); ok {
+		index, hasIndex := mapping["index"].(string)
+		if fieldType == "string" {
+			mapping["type"] = "text"
+			if index == "not_analyzed" {
+				mapping["type"] = "keyword"
+			}
+		}
+		if hasIndex {
+			delete(mapping, "index")
+			if index == "no" {
+				mapping["index"] = false
+			}
+		}
+	}
+	for _, name := range []string{"properties", "fields"} {
+		fields, ok := mapping[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if f, ok := field.(map[string]interface{}); ok {
+				rewriteMapping(f)
+			}
+		}
+	}
+}
+
+// mustNot is a bool query excluding what clause matches.
+func mustNot(clause interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{clause}}}
+}
+
+// rewriteJson decodes a JSON object, lets rewrite change it in place, and encodes it again. Numbers are kept as
+// they were written, ex: seeds too big for a float64.
+func rewriteJson(body string, rewrite func(map[string]interface{})) (string, error) {
+	var decoded map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewBufferString(body))
+	decoder.UseNumber()
+	err := decoder.Decode(&decoded)
+	if err != nil || decoded == nil {
+		return body, err
+	}
+	rewrite(decoded)
+	rewritten, err := json.Marshal(decoded)
+	if err != nil {
+		return "", err
+	}
+	return string(rewritten), nil
+}
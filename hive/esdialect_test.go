@@ -0,0 +1,146 @@
+package hive
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// sameJson reports whether two JSON documents are equal, regardless of key order and spacing.
+func sameJson(t *testing.T, got string, want string) bool {
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+		t.Errorf("%s isn't JSON: %v", got, err)
+		return false
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("%s isn't JSON: %v", want, err)
+	}
+	return reflect.DeepEqual(gotValue, wantValue)
+}
+
+func TestDialectSearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		body    string
+		want    string
+	}{
+		{
+			"1.x as it is",
+			1,
+			`{"query": {"filtered": {"filter": {"missing": {"field": "Task"}}}}}`,
+			`{"query": {"filtered": {"filter": {"missing": {"field": "Task"}}}}}`,
+		},
+		{
+			"filtered query",
+			5,
+			`{"query": {"filtered": {"query": {"match_all": {}}, "filter": {"term": {"Project": "crowd"}}}}}`,
+			`{"query": {"bool": {"must": [{"match_all": {}}], "filter": [{"term": {"Project": "crowd"}}]}}}`,
+		},
+		{
+			"missing and not filters",
+			5,
+			`{"query": {"filtered": {"filter": {"bool": {"must": [{"missing": {"field": "Task"}}, {"not": {"filter": {"term": {"State": "skipped"}}}}]}}}}}`,
+			`{"query": {"bool": {"filter": [{"bool": {"must": [
+				{"bool": {"must_not": [{"exists": {"field": "Task"}}]}},
+				{"bool": {"must_not": [{"term": {"State": "skipped"}}]}}
+			]}}]}}}`,
+		},
+		{
+			"query used as a filter",
+			6,
+			`{"query": {"filtered": {"filter": {"bool": {"must": [{"query": {"match": {"Project": "crowd"}}}]}}}}}`,
+			`{"query": {"bool": {"filter": [{"bool": {"must": [{"match": {"Project": "crowd"}}]}}]}}}`,
+		},
+		{
+			"top-level filter",
+			5,
+			`{"filter": {"term": {"Verified": true}}}`,
+			`{"post_filter": {"term": {"Verified": true}}}`,
+		},
+		{
+			"every term",
+			5,
+			`{"aggs": {"States": {"terms": {"field": "State", "size": 0}}}}`,
+			`{"aggs": {"States": {"terms": {"field": "State", "size": 2147483647}}}}`,
+		},
+		{
+			"unmapped sort",
+			5,
+			`{"sort": [{"Priority": {"order": "desc", "ignore_unmapped": true}}]}`,
+			`{"sort": [{"Priority": {"order": "desc", "unmapped_type": "long"}}]}`,
+		},
+		{
+			"every hit counted",
+			7,
+			`{"query": {"match_all": {}}}`,
+			`{"query": {"match_all": {}}, "track_total_hits": true}`,
+		},
+		{
+			"seeded random score",
+			7,
+			`{"query": {"function_score": {"query": {"match_all": {}}, "random_score": {"seed": 12345678901234567890}}}}`,
+			`{"query": {"function_score": {"query": {"match_all": {}}, "random_score": {"seed": 12345678901234567890, "field": "_seq_no"}}}, "track_total_hits": true}`,
+		},
+	}
+	for _, test := range tests {
+		got, err := dialectFor(test.version).Search(test.body)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !sameJson(t, got, test.want) {
+			t.Errorf("%s: Search = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDialectCount(t *testing.T) {
+	got, err := dialectFor(7).Count(`{"query": {"filtered": {"filter": {"term": {"Project": "crowd"}}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// counts don't take track_total_hits
+	want := `{"query": {"bool": {"filter": [{"term": {"Project": "crowd"}}]}}}`
+	if !sameJson(t, got, want) {
+		t.Errorf("Count = %s, want %s", got, want)
+	}
+}
+
+func TestDialectMapping(t *testing.T) {
+	mapping := `{"assets": {"properties": {
+		"Id": {"type": "string", "index": "not_analyzed"},
+		"Name": {"type": "string"},
+		"Url": {"type": "string", "index": "no"},
+		"Counts": {"type": "object", "properties": {"finished": {"type": "long"}}}
+	}}}`
+	tests := []struct {
+		version int
+		want    string
+	}{
+		{1, mapping},
+		{5, `{"assets": {"properties": {
+			"Id": {"type": "keyword"},
+			"Name": {"type": "text"},
+			"Url": {"type": "text", "index": false},
+			"Counts": {"type": "object", "properties": {"finished": {"type": "long"}}}
+		}}}`},
+		{6, `{"properties": {
+			"Id": {"type": "keyword"},
+			"Name": {"type": "text"},
+			"Url": {"type": "text", "index": false},
+			"Counts": {"type": "object", "properties": {"finished": {"type": "long"}}}
+		}}`},
+	}
+	for _, test := range tests {
+		got, err := dialectFor(test.version).Mapping("assets", mapping)
+		if err != nil {
+			t.Errorf("%d.x: %v", test.version, err)
+			continue
+		}
+		if !sameJson(t, got, test.want) {
+			t.Errorf("%d.x: Mapping = %s, want %s", test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package hive
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// responseETag is the ETag of a response body. It's weak since compress changes the bytes actually sent, and
+// only the body they decode to is compared.
+func responseETag(data []byte) string {
+	return fmt.Sprintf(`W/"%x"`, sha1.Sum(data))
+}
+
+// etagMatches reports whether an If-None-Match header names etag, comparing them weakly as RFC 7232 asks.
+func etagMatches(header string, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapCachedResponse is wrapResponse for a 200 front-ends poll, ex: a project's progress. The response gets an
+// ETag of its body, and a request whose If-None-Match has it already is answered with a 304 and no body.
+// Cache-Control asks browsers to check back every time, so they send If-None-Match themselves.
+func (s *Server) wrapCachedResponse(w http.ResponseWriter, r *http.Request, data []byte) {
+	etag := responseETag(data)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		s.wrapResponse(w, r, http.StatusNotModified, nil)
+		return
+	}
+	s.wrapResponse(w, r, 200, data)
+}
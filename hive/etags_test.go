@@ -0,0 +1,42 @@
+package hive
+
+import (
+	"testing"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := responseETag([]byte(`{"Project": {"Id": "crowd"}}`))
+	strong := etag[len("W/"):]
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"no header", "", false},
+		{"same", etag, true},
+		{"strong form of the same", strong, true},
+		{"any", "*", true},
+		{"any, padded", " * ", true},
+		{"one of several", `W/"0123", ` + etag + `, "4567"`, true},
+		{"none of several", `W/"0123", "4567"`, false},
+		{"other body", responseETag([]byte(`{"Project": {"Id": "other"}}`)), false},
+	}
+	for _, test := range tests {
+		if got := etagMatches(test.header, etag); got != test.want {
+			t.Errorf("%s: etagMatches(%q, %q) = %v, want %v", test.name, test.header, etag, got, test.want)
+		}
+	}
+}
+
+func TestResponseETag(t *testing.T) {
+	body := []byte(`{"Assets": []}`)
+	if responseETag(body) != responseETag(append([]byte{}, body...)) {
+		t.Error("responseETag differs for the same body")
+	}
+	if responseETag(body) == responseETag([]byte(`{"Assets": [{}]}`)) {
+		t.Error("responseETag is the same for different bodies")
+	}
+	if etag := responseETag(body); etag[:3] != `W/"` || etag[len(etag)-1] != '"' {
+		t.Errorf("responseETag = %s, want a weak, quoted ETag", etag)
+	}
+}
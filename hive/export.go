@@ -0,0 +1,168 @@
+package hive
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// flattenData spreads nested submitted data into dotted column names, ex: {"location": {"city": "NYC"}}
+// becomes "location.city". Lists and other values are kept whole.
+func flattenData(prefix string, value interface{}, columns map[string]interface{}) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		columns[prefix] = value
+		return
+	}
+	for name, field := range fields {
+		flattenData(prefix+"."+name, field, columns)
+	}
+}
+
+// csvValue formats a decoded json value for a spreadsheet cell, writing lists and objects as json.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool, float64:
+		return fmt.Sprint(v)
+	default:
+		valueJson, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(valueJson)
+	}
+}
+
+// exportRow is an asset flattened into named cells.
+func exportRow(asset Asset, taskName string) map[string]interface{} {
+	cells := map[string]interface{}{
+		"Id":   asset.Id,
+		"Url":  asset.Url,
+		"Name": asset.Name,
+	}
+	for key, value := range asset.Metadata {
+		flattenData("Metadata."+key, value, cells)
+	}
+	flattenData(taskName, asset.SubmittedData[taskName], cells)
+	return cells
+}
+
+// ExportTaskData writes a CSV of every verified, non-excluded asset in the current project with its data for a task,
+// one row per asset. Columns are the asset's Id, Url and Name, its Metadata keys and the task's data fields.
+// Assets are read twice, once to find every column and once to write the rows, so exports of any size stream.
+func (s *Server) ExportTaskData(w *csv.Writer, taskName string) error {
+	filters := []string{
+		fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, taskName),
+		`{ "not": { "term": { "Excluded": true } } }`,
+	}
+
+	columnSet := make(map[string]bool)
+	err := s.forEachMatchingDoc("assets", filters, func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		if err != nil {
+			return err
+		}
+		for column := range exportRow(asset, taskName) {
+			columnSet[column] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the asset's own fields first, then metadata and task data in alphabetical order
+	columns := []string{"Id", "Url", "Name"}
+	var dataColumns []string
+	for column := range columnSet {
+		if column != "Id" && column != "Url" && column != "Name" {
+			dataColumns = append(dataColumns, column)
+		}
+	}
+	sort.Strings(dataColumns)
+	columns = append(columns, dataColumns...)
+
+	err = w.Write(columns)
+	if err != nil {
+		return err
+	}
+
+	rows := 0
+	err = s.forEachMatchingDoc("assets", filters, func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		if err != nil {
+			return err
+		}
+		cells := exportRow(asset, taskName)
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = csvValue(cells[column])
+		}
+		err = w.Write(record)
+		if err != nil {
+			return err
+		}
+
+		// send rows along as they're written rather than holding the whole file
+		rows++
+		if rows%backfillPageSize == 0 {
+			w.Flush()
+		}
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// @Title AdminExportHandler
+// @Description downloads a CSV of verified assets with their metadata and submitted data for a task, one row per asset
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task        query   string     true        "Name of the task whose submitted data is exported"
+// @Success 200 {object}  string
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/export.csv [get]
+func (s *Server) AdminExportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskName := defaultQuery(r.URL.Query(), "task", "")
+	if taskName == "" {
+		s.wrapFailure(w, r, errors.New("Sorry, say which task's data to export, ex: ?task=categorize."))
+		return
+	}
+	exists, err := s.Store.Exists("tasks", s.ActiveProjectId+"-"+taskName)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if !exists {
+		s.wrapFailure(w, r, notFound("Sorry, there's no task named %q in this project.", taskName))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, s.ActiveProjectId, taskName))
+	w.WriteHeader(200)
+
+	// once rows are streaming the status can't change, so a failure part way through cuts the file short
+	err = s.ExportTaskData(csv.NewWriter(w), taskName)
+	if err != nil {
+		s.logError("failed exporting task data", err, logFields{"task": taskName})
+	}
+}
@@ -0,0 +1,229 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Flag is a contributor's report that something is wrong with an asset (ex: a broken image, offensive content,
+// the wrong item). Each user can flag an asset once; flagging it again updates their reason.
+type Flag struct {
+	Id      string // guid composed of ids from project + asset + user
+	Project string // the project
+	Asset   string // the flagged asset's id
+	User    string // the user who flagged it
+	Reason  string // required, why the asset was flagged (ex: "broken image", "offensive content", "wrong item")
+	Created time.Time
+}
+
+type flagResponse struct {
+	Flag     Flag
+	Excluded bool // whether the asset is now excluded from assignment
+}
+type flagsResponse struct {
+	Flags []Flag
+	Meta  meta
+}
+
+// FlagAsset records a user's report about an asset. Once an asset collects the project's FlagThreshold
+// flags it is excluded from assignment automatically.
+func (s *Server) FlagAsset(assetId string, userId string, reason string) (*Flag, *Asset, error) {
+	if reason == "" {
+		return nil, nil, errors.New("Sorry, a reason is required to flag an asset.")
+	}
+
+	asset, err := s.FindAsset(assetId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if asset.Project != s.ActiveProjectId {
+		return nil, nil, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+
+	flag := Flag{
+		Id:      strings.Join([]string{s.ActiveProjectId, asset.Id, userId}, "HIVE"),
+		Project: s.ActiveProjectId,
+		Asset:   asset.Id,
+		User:    userId,
+		Reason:  reason,
+		Created: time.Now().UTC(),
+	}
+	alreadyFlagged, err := s.Store.Exists("flags", flag.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = s.Store.Put("flags", flag.Id, flag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if alreadyFlagged {
+		return &flag, asset, nil
+	}
+
+	project, err := s.FindProject(s.ActiveProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+	asset, err = s.updateAsset(asset.Id, func(asset *Asset) error {
+		asset.Counts["Flags"] += 1
+		if project.FlagThreshold > 0 && asset.Counts["Flags"] >= project.FlagThreshold {
+			asset.Excluded = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &flag, asset, nil
+}
+
+// FindFlags returns flags in the current project, newest first, optionally scoped to an asset, along with pagination meta information.
+func (s *Server) FindFlags(assetId string, p Params) (flags []Flag, m meta, err error) {
+	err = s.Store.Refresh()
+	if err != nil {
+		return
+	}
+
+	musts := []string{}
+	musts = append(musts, fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId))
+	if assetId != "" {
+		musts = append(musts, fmt.Sprintf(`{ "term": { "Asset": "%s" } }`, assetId))
+	}
+
+	searchQuery := `{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [%s ]
+					}
+				}
+			}
+		},
+		"from": %s,
+		"size": %s,
+		"sort": [ { "Created": { "order" : "desc" } } ]
+	}`
+	searchJson := fmt.Sprintf(searchQuery, strings.Join(musts, ", "), p.From, p.Size)
+	results, err := s.Store.Search("flags", searchJson)
+	if err != nil {
+		return
+	}
+
+	m.Total = results.Hits.Total
+	m.From, _ = strconv.Atoi(p.From)
+	m.Size, _ = strconv.Atoi(p.Size)
+
+	for _, hit := range results.Hits.Hits {
+		var flag Flag
+		err = json.Unmarshal(*hit.Source, &flag)
+		if err != nil {
+			return
+		}
+		flags = append(flags, flag)
+	}
+	if len(flags) <= 0 {
+		flags = make([]Flag, 0)
+	}
+	return
+}
+
+// @Title FlagAssetHandler
+// @Description reports a problem with an asset on behalf of the current user
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id     path    string     true        "Asset ID"
+// @Param   flag        body   string     true        "JSON-formatted reason, ex: {\"Reason\": \"broken image\"}"
+// @Success 200 {object}  flagResponse
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /projects/{project_id}/assets/{asset_id}/flag [post]
+func (s *Server) FlagAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	// find the user
+	userId := s.SessionUserId(r)
+	user, _ := s.FindUser(userId)
+	if user == nil {
+		s.wrapFailure(w, r, unauthorized("Sorry, flagging assets requires a valid user."))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var flagData struct {
+		Reason string
+	}
+	err = json.Unmarshal(body, &flagData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	flag, asset, err := s.FlagAsset(vars["asset_id"], user.Id, strings.TrimSpace(flagData.Reason))
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	flagJson, err := json.Marshal(flagResponse{
+		Flag:     *flag,
+		Excluded: asset.Excluded,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, flagJson)
+}
+
+// @Title AdminFlagsHandler
+// @Description returns a paginated list of contributor reports about assets, newest first
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset        query   string     false        "If specified, will scope flags to this asset"
+// @Param   from        query   int     false        "If specified, will return a set of flags starting with from number"
+// @Param   size        query   int     false        "If specified, will return a total number of flags specified as size"
+// @Success 200 {object}  flagsResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/flags [get]
+func (s *Server) AdminFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	p := Params{
+		From: defaultQuery(queryParams, "from", "0"),
+		Size: defaultQuery(queryParams, "size", "10"),
+	}
+
+	flags, m, err := s.FindFlags(queryParams.Get("asset"), p)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	flagsJson, err := json.Marshal(flagsResponse{
+		Flags: flags,
+		Meta:  m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, flagsJson)
+}
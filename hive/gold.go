@@ -0,0 +1,164 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Quality is how a user's finished assignments on gold standard assets compare with the known-correct answers.
+// It's kept up to date as they submit, and recalculated for everyone by ScoreGold.
+type Quality struct {
+	GoldAnswered int     // finished assignments on gold standard assets
+	GoldCorrect  int     // of those, how many matched the known-correct answer
+	Accuracy     float64 // GoldCorrect / GoldAnswered, or 0 with no gold answers yet
+	Reviewed     int     // finished assignments a reviewer has accepted or rejected, see ReviewAssignment
+	Rejected     int     // of those, how many were rejected
+}
+
+// grade adds one gold answer to the tally.
+func (q *Quality) grade(correct bool) {
+	q.GoldAnswered++
+	if correct {
+		q.GoldCorrect++
+	}
+	q.Accuracy = float64(q.GoldCorrect) / float64(q.GoldAnswered)
+}
+
+type goldScoreResponse struct {
+	Users        int // users whose Quality was recalculated
+	GoldAnswered int // finished assignments on gold standard assets, across all users
+	GoldCorrect  int
+}
+
+// goldFilters returns the asset filters with a draw for the task's GoldPercent added: either only assets with a
+// gold answer for the task, or only assets without one. musts and mustNots are left as they are.
+func goldFilters(task Task, musts []string, mustNots []string) ([]string, []string) {
+	goldFilter := fmt.Sprintf(`{ "exists": { "field": "GoldData.%s" } }`, task.Name)
+	if rand.Intn(100) < task.GoldPercent {
+		return append(append([]string{}, musts...), goldFilter), mustNots
+	}
+	return musts, append(append([]string{}, mustNots...), goldFilter)
+}
+
+// gradeGold adds a finished assignment to the user's Quality when its asset has a gold answer for the task.
+// asset should be the stored asset, since assignments carry theirs without GoldData.
+func (s *Server) gradeGold(user *User, assignment Assignment, asset Asset) error {
+	if len(asset.GoldData) == 0 {
+		return nil
+	}
+	task, err := s.FindTask(assignment.Task)
+	if err != nil {
+		return err
+	}
+	graded, correct := matchesGold(assignment, map[string]Asset{asset.Id: asset}, map[string]string{task.Id: task.Name})
+	if graded {
+		user.Quality.grade(correct)
+	}
+	return nil
+}
+
+// ScoreGold recalculates every user's Quality in the current project from their finished assignments,
+// ex: after gold answers are added to assets that were already handed out.
+func (s *Server) ScoreGold() (score goldScoreResponse, err error) {
+	goldAssets, err := s.FindGoldAssets()
+	if err != nil {
+		return
+	}
+	tasks, err := s.FindLiveTasks()
+	if err != nil {
+		return
+	}
+	taskNames := make(map[string]string)
+	for _, task := range tasks {
+		taskNames[task.Id] = task.Name
+	}
+
+	qualities := make(map[string]*Quality)
+	filters := []string{`{ "term": { "State": "finished" } }`}
+	err = s.forEachMatchingDoc("assignments", filters, func(source json.RawMessage) error {
+		var assignment Assignment
+		err := json.Unmarshal(source, &assignment)
+		if err != nil {
+			return err
+		}
+		graded, correct := matchesGold(assignment, goldAssets, taskNames)
+		if !graded {
+			return nil
+		}
+		quality := qualities[assignment.User]
+		if quality == nil {
+			quality = &Quality{}
+			qualities[assignment.User] = quality
+		}
+		quality.grade(correct)
+		score.GoldAnswered++
+		if correct {
+			score.GoldCorrect++
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = s.forEachProjectDoc("users", func(source json.RawMessage) error {
+		var user User
+		err := json.Unmarshal(source, &user)
+		if err != nil {
+			return err
+		}
+		_, err = s.updateUser(user.Id, func(user *User) error {
+			// reviews aren't recalculated, so carry them over
+			reviewed, rejected := user.Quality.Reviewed, user.Quality.Rejected
+			user.Quality = Quality{}
+			if quality := qualities[user.Id]; quality != nil {
+				user.Quality = *quality
+			}
+			user.Quality.Reviewed, user.Quality.Rejected = reviewed, rejected
+			return nil
+		})
+		if err == ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		score.Users++
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	err = s.Store.Refresh()
+	return
+}
+
+// @Title AdminScoreGoldHandler
+// @Description recalculates every user's Quality, their accuracy on gold standard assets, from their finished assignments
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Success 200 {object}  goldScoreResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/projects/{project_id}/gold/score [post]
+func (s *Server) AdminScoreGoldHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	score, err := s.ScoreGold()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	scoreJson, err := json.Marshal(score)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, scoreJson)
+}
@@ -0,0 +1,76 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readyTimeout is how long ReadyzHandler waits on the store before calling the instance unready.
+const readyTimeout = 5 * time.Second
+
+type healthResponse struct {
+	Status string // "ok", "ready" or "unavailable"
+	Error  string `json:",omitempty"` // why the instance isn't ready
+}
+
+// checkReady reports whether the store can be reached and has been set up, giving up after readyTimeout.
+func (s *Server) checkReady() error {
+	if s.Store == nil {
+		return errors.New("Sorry, hive has no store to keep data in.")
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		exists, err := s.Store.IndexExists()
+		if err == nil && !exists {
+			err = errors.New("Sorry, the store hasn't been set up yet, see /admin/setup.")
+		}
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(readyTimeout):
+		return errors.New("Sorry, the store didn't respond in time.")
+	}
+}
+
+// @Title HealthzHandler
+// @Description reports that the hive process is up, without checking anything it depends on
+// @Success 200 {object}  healthResponse
+// @Resource /health
+// @Router /healthz [get]
+func (s *Server) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	healthJson, err := json.Marshal(healthResponse{Status: "ok"})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, healthJson)
+}
+
+// @Title ReadyzHandler
+// @Description reports whether this instance can serve requests: its store is reachable and set up
+// @Success 200 {object}  healthResponse
+// @Failure 503 {object}  healthResponse
+// @Resource /health
+// @Router /readyz [get]
+func (s *Server) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	status := 200
+	ready := healthResponse{Status: "ready"}
+	err := s.checkReady()
+	if err != nil {
+		status = 503
+		ready = healthResponse{Status: "unavailable", Error: err.Error()}
+	}
+
+	readyJson, err := json.Marshal(ready)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, status, readyJson)
+}
@@ -15,31 +15,48 @@
 package hive
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
-	"math/rand"
 	"net/http"
 	"net/url"
-	"reflect"
+	"os"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
-
-	elastigo "github.com/jacqui/elastigo/lib"
 )
 
 // Server runs the http service for hive's api
 // It also stores some commonly accessed global settings
 type Server struct {
 	Port            string
-	Index           string
-	EsConn          elastigo.Conn
-	ActiveProjectId string
+	Store           Store         // where projects, tasks, assets, users and assignments are kept, ex: an ElasticsearchStore
+	ActiveProjectId string        // the project a request is scoped to, only ever set on a request's own copy (see forProject)
+	SecretKey       string        // signs login links; passwordless login is disabled without it
+	AdminKeys       []string      // API keys for the admin endpoints; they're open to anyone when there are none
+	BaseUrl         string        // public url of this server, used to build links in emails
+	Mailer          Mailer        // sends passwordless login emails
+	Blobs           BlobStore     // stores uploaded files; uploads are disabled without it
+	Mturk           *MturkClient  // publishes overflow assets as Mechanical Turk HITs; disabled without it
+	ShutdownTimeout time.Duration // how long Run waits for in-flight requests once it's told to stop, 30 seconds by default
+	// ReconcileInterval is how often Run repairs stored counts that have drifted from the assignments behind them,
+	// see ReconcileCounts. Never when it's 0.
+	ReconcileInterval time.Duration
+	Config            Config // the settings the server was set up with, see Configure
+
+	middleware      []Middleware // wraps every request, see Use
+	adminMiddleware []Middleware // wraps admin requests, see UseAdmin
+	activeImport    *ImportJob   // the import a request is recording its progress in, only ever set on a request's own copy (see startImport)
 }
 
 // NewServer returns an instance of a Hive webserver that can be run (see main.go)
@@ -47,17 +64,27 @@ func NewServer() *Server {
 	return &Server{}
 }
 
+// forProject returns a copy of the server scoped to a project. Handlers scope their own copy,
+// so concurrent requests never see or change each other's project and the shared Server stays read-only.
+func (s *Server) forProject(projectId string) *Server {
+	scoped := *s
+	scoped.ActiveProjectId = projectId
+	return &scoped
+}
+
 // API metadata related to pagination
 type meta struct {
-	Total int
-	From  int
-	Size  int
+	Total  int
+	From   int
+	Size   int
+	Cursor string `json:",omitempty"` // when sorted by Id, pass back as cursor= for the next page, see cursorFilter
 }
 
 // Counts are a map of category to total number of favorited assets, assignments overall, assignments by task.
 // Examples:
-//   User.Counts["Favorites"] = 4
-//	 User.Counts["Assignments"] = 40
+//
+//	User.Counts["Favorites"] = 4
+//	User.Counts["Assignments"] = 40
 type Counts map[string]int
 
 // SubmittedData is a map of task names to freeform json, used on Assignments and Assets
@@ -80,7 +107,19 @@ type Project struct {
 	TaskCount       int    // calculated tally of tasks
 	UserCount       int    // calculated tally of users
 	AssignmentCount Counts // calculated tally of assignments by state (finished, skipped, etc.)
+	VerifiedCount   int    // calculated tally of verified assets, not counting excluded ones
+	ExcludedCount   int    // calculated tally of assets excluded from assignment
+	Progress        int    // calculated percentage (0-100) of non-excluded assets that are verified
 	MetaProperties  []MetaProperty
+	Active          bool             // optional, active projects are listed publicly at GET /projects
+	HeroAsset       string           // optional, id of an asset to feature on public project listings
+	Session         SessionSettings  // optional, how the session cookie holding the current user id is named and scoped
+	ConsentVersion  string           // optional, the terms of service version users must accept before submitting assignments
+	FlagThreshold   int              // optional, assets are excluded from assignment automatically once flagged by this many users
+	HashAssetIds    bool             // optional, derive asset ids from a hash of their url (or uploaded content) so re-imports are idempotent
+	Onboarding      []OnboardingStep // optional, ordered tutorial steps for new contributors
+	MaxUnfinished   int              // optional, how many unfinished assignments a user can hold at once across all tasks
+	CorsOrigins     []string         // optional, sites allowed to call this project from the browser, on top of the server's corsOrigins
 }
 
 // userFavorites are a map of asset IDs to asset records favorited by users.
@@ -92,15 +131,22 @@ type userFavorites map[string]Asset
 // They are scoped to a project, so the same person can have multiple records, one per project.
 // Which fields are required is up to you - Hive will create a user with only an ID, to keep the barrier of entry
 type User struct {
-	Id             string // guid for the user in this project
-	Name           string // person's name, could be a first + last, just first, a username, etc
-	Email          string // email address is required
-	Project        string // users are scoped to projects, the same person would have multiple user records across multiple projects
-	ExternalId     string // you can optionally use some kind of external id to look up the user (ex: nytimes user id)
-	Counts         Counts // calculation of favorites and assignments (total + by task) counts
-	Favorites      userFavorites
-	NewFavorites   userFavorites
-	VerifiedAssets []string // list of verified asset ids that the user has contributed to
+	Id                  string // guid for the user in this project
+	Name                string // person's name, could be a first + last, just first, a username, etc
+	Email               string // email address is required
+	Project             string // users are scoped to projects, the same person would have multiple user records across multiple projects
+	ExternalId          string // you can optionally use some kind of external id to look up the user (ex: nytimes user id)
+	Counts              Counts // calculation of favorites and assignments (total + by task) counts
+	Favorites           userFavorites
+	NewFavorites        userFavorites
+	VerifiedAssets      []string // list of verified asset ids that the user has contributed to
+	Languages           []string // optional, languages the user prefers to work in (ex: "en", "es"), matched against Asset.Language
+	ConsentVersion      string   // the terms of service version this user has accepted, if any
+	OnboardingSteps     []string // ids of the project onboarding steps this user has completed
+	Role                string   // what the user can do in the project's admin: "owner", "admin", "reviewer" or "contributor", the default
+	Quality             Quality  // how the user's answers on gold standard assets compare with the known-correct ones, and how reviewers judged them
+	Trust               *Trust   `json:",omitempty"` // how often the user's answers agree with verified answers, see ScoreTrust
+	HideFromLeaderboard bool     // the user has asked to be left off the project's leaderboard
 }
 
 // Assignments are the work users have to do for a given task and asset.
@@ -111,25 +157,43 @@ type Assignment struct {
 	Project       string        // the project
 	Task          string        // the task
 	Asset         Asset         // most importantly, what the user is completing a task on
-	State         string        // assignments start out "unfinished" but can be "skipped" or "finished"
+	State         string        // assignments start out "unfinished" but can be "skipped" or "finished", or "expired" once their task's lease runs out
 	SubmittedData SubmittedData // data the user submits when finishing the assignment
+	Created       time.Time     // when the assignment was handed out
+	Updated       time.Time     // when the assignment was last submitted, skipped or changed
+	Draft         SubmittedData // autosaved work in progress, cleared once the assignment is submitted or skipped
+	DraftSaved    time.Time     // when the draft was last autosaved
+	Source        string        // hash of the IP address and user agent the assignment was last submitted from
+	SkipReason    string        `json:",omitempty"` // why a "skipped" assignment was skipped, one of skipReasons, ex: "broken_image"
+	Review        string        `json:",omitempty"` // "accepted" or "rejected" once a reviewer has checked the finished assignment, see ReviewAssignment
+	Reviewer      string        `json:",omitempty"` // optional, who reviewed it
 }
 
 // Assets are what get assigned to users and can be images, pdfs, etc. All require a URL and are scoped to a project.
 type Asset struct {
-	Id            string                 // guid for the asset
+	Id            string                 // guid for the asset; optional at import, where a stable id makes re-imports update the asset
+	DedupKey      string                 `json:",omitempty"` // optional, a stable key from the source, ex: a CMS id, that the asset's id is derived from at import
+	ImportId      string                 `json:",omitempty"` // the import that created the asset, see ImportJob
 	Project       string                 // assets are scoped to projects, the same asset in many projects would have multiple records
 	Url           string                 // required, should be a direct link to the thing you want crowdsourced
 	Name          string                 // optional, a displayable name
 	Metadata      map[string]interface{} // optional, any additional info (ex: a newspaper issue date and page number)
+	Language      string                 // optional, language of the asset's content (ex: "en", "es"), set at import
 	SubmittedData SubmittedData          // this is filled in once crowdsourcing success happens
+	GoldData      SubmittedData          // optional, known-correct answers by task name; marks this as a gold standard asset
+	Prelabel      SubmittedData          // machine suggestions by task name, fetched from the task's PrelabelUrl at import
 	Favorited     bool
 	Verified      bool
-	Counts        Counts // calculation of favorites and assignments (total + by task) counts
+	Excluded      bool      // excluded assets are kept but never assigned, and don't count towards project progress
+	Priority      int       // optional, eligible assets with higher priorities are assigned first, ex: for time-sensitive material
+	Private       bool      // private assets live in a private S3 bucket; contributors only ever see short-lived signed urls
+	Counts        Counts    // calculation of favorites and assignments (total + by task) counts
+	Created       time.Time // when the asset was imported
 }
 
 type projectResponse struct {
-	Project Project
+	Project      Project
+	Announcement *Announcement `json:",omitempty"` // the project's active announcement, on public project responses
 }
 type projectsResponse struct {
 	Projects []Project
@@ -151,6 +215,19 @@ type assetsResponse struct {
 	Assets []Asset
 	Meta   meta
 }
+type assetImportResponse struct {
+	Assets   []Asset
+	Meta     meta
+	Imported int // new assets stored
+	Updated  int // assets matched by Url or id to one already in the project, and updated in place
+	Existing int // assets left as they were because their hashed id had already been imported
+	Failed   int // assets elasticsearch refused to store
+	// Results says what happened to each asset that was stored or already there, in the order they were stored
+	Results []assetImportResult
+	// Failures says why assets weren't stored, for the first 100 of them
+	Failures []BulkItemError `json:",omitempty"`
+	Import   string          // the import's id, to resume or roll it back, see ImportJob
+}
 
 type taskResponse struct {
 	Task Task
@@ -169,7 +246,8 @@ type usersResponse struct {
 }
 
 type assignmentResponse struct {
-	Assignment Assignment
+	Assignment   Assignment
+	Announcement *Announcement `json:",omitempty"`
 }
 type assignmentsResponse struct {
 	Assignments []Assignment
@@ -182,22 +260,22 @@ AssignmentCriteria determines when assets become eligible for assignment per tas
 At a minimum, you should specify that the asset has not been verified for the next task by indicating that task name with empty data.
 Here is an example criteria for a task "Categorize" that makes any asset not yet categorized eligible:
 
-{
-	"Categorize": {
-		"SubmittedData": {}
+	{
+		"Categorize": {
+			"SubmittedData": {}
+		}
 	}
-}
 
 You can also set what data should have been submitted in another task before an asset is eligible for this one.
 Example: a task "Categorize" that relies on assets completing a 'Find' task with specific data submitted:
 
-{
-	"Find": {
-		"SubmittedData": {
-			"category": "advertisement"
+	{
+		"Find": {
+			"SubmittedData": {
+				"category": "advertisement"
+			}
 		}
 	}
-}
 */
 type AssignmentCriteria struct {
 	SubmittedData map[string]interface{}
@@ -207,88 +285,98 @@ type AssignmentCriteria struct {
 // Set a minimum number of assignments along with a minimum number of matching assignments.
 // All assignments must be finished to be counted here.
 type CompletionCriteria struct {
-	Total    int // minimum finished assigments
-	Matching int // minimum assignments with the same answer
+	Total           int     // minimum finished assigments
+	Matching        int     // minimum assignments with the same answer
+	DistinctUsers   bool    // optional, matching answers only count once per user
+	DistinctSources bool    // optional, matching answers only count once per client (IP address and browser), to discount sock puppets
+	WeightByTrust   bool    // optional, weigh each answer by its user's Trust score, so answers need MatchingWeight instead of Matching
+	MatchingWeight  float64 // with WeightByTrust, the summed weight of matching answers an asset needs, ex: 2.5
+
+	// Fields loosens how fields of the answer are compared, by field name, ex: {"price": {"tolerance": 0.05}}
+	Fields map[string]FieldMatchRule `json:",omitempty"`
 }
 
 // Tasks are individual actions to do on an asset. A project can have one or more tasks.
 // Criteria for assignment and verification of assets is stored on a task.
 type Task struct {
-	Id                 string             // guid, auto-generated
-	Project            string             // tasks are scoped to projects
-	Name               string             // a short sluggable name usable in urls (ex: find, transcribe, crop)
-	Description        string             // a displayable title, description, instructions
-	CurrentState       string             // is this task available, hidden, waiting or closed?
-	AssignmentCriteria AssignmentCriteria // the criteria used when assigning valid assets for this task
-	CompletionCriteria CompletionCriteria // the criteria used to mark an asset as 'completed' for this task
-}
-
-// FacetTerm maps Elasticsearch term + count from a faceted query.
-type facetTerm struct {
-	Term  string
-	Count int
-}
-
-// FacetTerms is an array of faceted terms (term + count) along with the total.
-type facetTerms struct {
-	Terms []facetTerm
-	Total int
-}
-
-// facetWrapper rolls up FacetTerms/FacetTerm to map the full faceted query results from Elasticsearch
-// Note: Hive always uses the name 'Value' in faceted queries to fit into this struct.
-type facetWrapper struct {
-	Value facetTerms
+	Id                    string             // guid, auto-generated
+	Project               string             // tasks are scoped to projects
+	Name                  string             // a short sluggable name usable in urls (ex: find, transcribe, crop)
+	Description           string             // a displayable title, description, instructions
+	CurrentState          string             // is this task available, hidden, waiting, closed or archived?
+	AssignmentCriteria    AssignmentCriteria // the criteria used when assigning valid assets for this task
+	CompletionCriteria    CompletionCriteria // the criteria used to mark an asset as 'completed' for this task
+	ReviewPercent         int                // optional, percentage (0-100) of assets verified for this task queued for spot-check review
+	PrelabelUrl           string             // optional, prediction endpoint POST'd each imported asset; its response is stored in Asset.Prelabel
+	MaxAssignmentsPerUser int                // optional, how many assignments for this task one user can finish, so no one dominates its consensus
+	GoldPercent           int                // optional, percentage (0-100) of new assignments given on gold standard assets, to measure accuracy
+	NextTask              string             // optional, name of the task assets move on to once they're verified for this one, see /admin/projects/{project_id}/workflow
+	MatchingStrategy      string             // optional, how answers are compared when completing the task: "exact", the default, "normalized", "majority" or "tolerance"
+	MatchingTolerance     float64            // optional, how far apart numbers in answers can be and still match, with the "tolerance" strategy
+	LeaseMinutes          int                // optional, how long an unfinished assignment is held before it expires and its asset is handed out again
+	Mturk                 *MturkSettings     `json:",omitempty"` // optional, how the task's assets are published as paid Mechanical Turk HITs
 }
 
-type userBucket struct {
-	Id    string `json:"key"`
+// termBucket maps an Elasticsearch term + count from a terms aggregation.
+type termBucket struct {
+	Term  string `json:"key"`
 	Count int    `json:"doc_count"`
 }
 
-type userBuckets struct {
-	Buckets []userBucket `json:"buckets"`
-}
-type assetBucket struct {
-	Id    string      `json:"key"`
-	Count int         `json:"doc_count"`
-	Users userBuckets `json:"users"`
+// stateAgg maps the results of a terms aggregation of assignments by their State.
+// Note: Hive always uses the name 'States' in these aggregations to fit into this struct.
+type stateAgg struct {
+	States struct {
+		Buckets []termBucket `json:"buckets"`
+	}
 }
 
-type assetBuckets struct {
-	Buckets []assetBucket `json:"buckets"`
-}
+// ErrTooManyUnfinished is returned when a user asks for new work while holding the project's limit of unfinished assignments.
+var ErrTooManyUnfinished = errors.New("Too many unfinished assignments: please finish or skip the assignments you already have before starting more.")
 
-type assetAgg struct {
-	Assets assetBuckets `json:"assets"`
-}
+// ErrTaskLimitReached is returned when a user asks for new work on a task they've already finished its MaxAssignmentsPerUser for.
+var ErrTaskLimitReached = errors.New("Task limit reached: you've finished as many assignments for this task as one person can. Thanks for your help!")
 
-// wrapError is a convenience function to consistently format errors in json responses
+// ErrConsentRequired is returned when a user submits work before accepting the project's current terms of service.
+var ErrConsentRequired = errors.New("Consent required: please accept the current terms of service before submitting assignments.")
+
+// wrapError is a convenience function to consistently format errors in json responses, see errorResponse.
 func (s *Server) wrapError(err error) (formattedError []byte) {
-	formattedError = []byte(fmt.Sprintf(`{"error":"%s"}`, err.Error()))
-	log.Println(string(formattedError))
+	response := errorResponse{Error: err.Error(), Code: errorCode(err)}
+	if invalid, ok := err.(*ValidationError); ok {
+		response.Fields = invalid.Fields
+	}
+	formattedError, _ = json.Marshal(response)
 	return formattedError
 }
 
 // wrapResponse is a convenience function to consistently format responses with the right headers
 func (s *Server) wrapResponse(w http.ResponseWriter, r *http.Request, statusCode int, data []byte) {
-
-	w.Header().Set("Content-Type", "application/json")
-
-	origin := r.Header.Get("Origin")
-	if origin == "" {
-		origin = r.Host
+	// errors without a code of their own get the status's, ex: "forbidden"
+	var response errorResponse
+	if statusCode >= 400 && json.Unmarshal(data, &response) == nil && response.Error != "" && response.Code == "" {
+		response.Code = statusCodes[statusCode]
+		if response.Code == "" {
+			response.Code = "internal_error"
+		}
+		data, _ = json.Marshal(response)
 	}
-	if origin != "" {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
+	if usesEnvelopes(r) {
+		data = envelopeJson(statusCode, data)
 	}
 
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	w.Write(data)
-	// log.Println(string(data))
+
+	// errors are logged with the request, see logRequests
+	if entry, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok && statusCode >= 400 {
+		if response.Error != "" {
+			entry.Error = response.Error
+		} else {
+			entry.Error = string(data)
+		}
+	}
 }
 
 func defaultQuery(q url.Values, name string, defaultVal string) (val string) {
@@ -311,29 +399,23 @@ func defaultQuery(q url.Values, name string, defaultVal string) (val string) {
 func (s *Server) AdminAssetHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
 	assetId := vars["asset_id"]
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	asset, err := s.FindAsset(assetId)
 	if err != nil {
-		log.Println("failed finding asset", assetId, "because:", err)
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	assetWithCounts, err := s.CalculateAssetCounts(*asset)
-	if err != nil {
-		log.Println(err)
-	}
-
-	// format the json response
+	// counts are kept up to date as assignments are written, see AdminRecountAssetHandler for when they drift
 	resp := assetResponse{
-		Asset: assetWithCounts,
+		Asset: *asset,
 	}
 
 	assetJson, err := json.Marshal(resp)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assetJson)
@@ -344,30 +426,71 @@ func (s *Server) AdminAssetHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   assets        body   string     true        "JSON-formatted array of assets, each requires a URL at minimum"
-// @Success 200 {object}  assetsResponse
+// @Param   batch        query   int     false        "For newline-delimited JSON imports (Content-Type application/x-ndjson or format=ndjson), how many assets to store per bulk request, defaults to 500 (max 5000)"
+// @Param   dedup        query   bool     false        "Unless false, assets with the Url of one already in the project update it instead of creating another"
+// @Param   import        query   string     false        "The id of an import that stopped partway, to resume it: send the same assets and those it already got through are skipped"
+// @Success 200 {object}  assetImportResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assets
 // @Router /admin/projects/{project_id}/assets [post]
 func (s *Server) AdminCreateAssetsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
+
+	source := "json"
+	if wantsNdjson(r) {
+		source = "ndjson"
+	}
+	scoped, err := s.startImport(r.URL.Query().Get("import"), source)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s = scoped
+
+	// large imports are streamed one asset per line and reported as totals
+	if wantsNdjson(r) {
+		imported, err := s.StreamAssets(r.Body, importBatchSize(r), dedupImports(r))
+		err = s.finishImport(err)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+		importedJson, err := json.Marshal(imported)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+		s.wrapResponse(w, r, 200, importedJson)
+		return
+	}
 
-	assets, err := s.CreateAssets(r.Body)
+	assets, imported, err := s.CreateAssets(r.Body, dedupImports(r))
+	err = s.finishImport(err)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
+
 	m := &meta{
 		Total: len(assets),
 		From:  0,
 		Size:  10,
 	}
-	assetsJson, err := json.Marshal(&assetsResponse{
-		Assets: assets,
-		Meta:   *m,
+	assetsJson, err := json.Marshal(&assetImportResponse{
+		Assets:   assets,
+		Meta:     *m,
+		Imported: imported.Imported,
+		Updated:  imported.Updated,
+		Existing: imported.Existing,
+		Failed:   imported.Failed,
+		Failures: imported.Failures,
+		Results:  imported.Results,
+		Import:   imported.Import,
 	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assetsJson)
@@ -380,13 +503,20 @@ func (s *Server) AdminCreateAssetsHandler(w http.ResponseWriter, r *http.Request
 // @Param   from        query   int     false        "If specified, will return a set of assets starting with from number"
 // @Param   size        query   int     false        "If specified, will return a total number of assets specified as size"
 // @Param   task        query   string     false        "If task is specified, will scope assets to those completed for the task 'task'"
+// @Param   sortBy        query   string     false        "Fields to sort by, each with an optional direction, ex: Metadata.issueDate:desc,Name:asc. Counts work too: finished, verified, skipped, unfinished, assignments, favorites"
+// @Param   sortDir        query   string     false        "asc or desc"
+// @Param   since        query   string     false        "Only assets imported at or after this time or date, ex: 2015-06-01"
+// @Param   until        query   string     false        "Only assets imported before this time, or on or before this date"
+// @Param   meta.{field}        query   string     false        "Only assets whose metadata matches, repeatable, ex: meta.page=4 or meta.issueDate>=1912-01-01"
+// @Param   cursor        query   string     false        "Meta.Cursor from the previous page, to page through the whole list in Id order"
 // @Success 200 {object}  assetsResponse
+// @Failure 400 {object} errorResponse	what's wrong with sortBy, cursor or a meta.{field} filter
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assets
 // @Router /admin/projects/{project_id}/assets [get]
 func (s *Server) AdminAssetsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	var assets []Asset
 	var m meta
@@ -400,12 +530,20 @@ func (s *Server) AdminAssetsHandler(w http.ResponseWriter, r *http.Request) {
 		State:   defaultQuery(queryParams, "state", ""),
 		SortBy:  defaultQuery(queryParams, "sortBy", "Id"),
 		SortDir: defaultQuery(queryParams, "sortDir", "asc"),
+		Since:   defaultQuery(queryParams, "since", ""),
+		Until:   defaultQuery(queryParams, "until", ""),
+		Cursor:  defaultQuery(queryParams, "cursor", ""),
+	}
+	p.Meta, err = ParseMetaConditions(queryParams)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
 	}
 
 	if p.State == "completed" {
 		assets, m, err = s.FindAssetsWithDataForTask(p)
 		if err != nil {
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
 	}
@@ -413,40 +551,105 @@ func (s *Server) AdminAssetsHandler(w http.ResponseWriter, r *http.Request) {
 	if p.State == "" {
 		assets, m, err = s.FindAssets(p)
 		if err != nil {
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
 	}
 
-	var assetsWithCounts []Asset
-	for _, asset := range assets {
-		//assetIds = append(assetIds, asset.Id)
-		// nested aggregation of assignments completed on asset broken down by task then state
-		// assetTmpl := `{ "query": { "bool": { "must": [ { "match_all": {} } ] } }, "aggs": { "assets": { "terms": { "field": "Asset.Id" }, "aggs": { "status_terms": { "terms": { "field": "State" } } } } } }`
-		// aggregation of asset assignments by state
-		// assetTmpl := `{ "query": { "bool": { "must": [ { "match_all": {} } ] } }, "aggs": { "assets": { "terms": { "field": "Asset.Id", "size": 20 }, "aggs": { "status_terms": { "terms": { "field": "State" } } } } } }`
-		assetWithCounts, err := s.CalculateAssetCounts(asset)
-		if err != nil {
-			log.Println(err)
-		}
-		assetsWithCounts = append(assetsWithCounts, assetWithCounts)
-	}
-	//}
-
+	// each asset's stored counts are kept up to date as assignments are written, rather than recounted per page
 	assetsResponse := &assetsResponse{
-		Assets: assetsWithCounts,
+		Assets: assets,
 		Meta:   m,
 	}
 	// format the json response
 	assetsJson, err := json.Marshal(assetsResponse)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assetsJson)
 }
 
+// UpdateAssetExcluded is called from the exclude and include AssetHandlers.
+// Excluded assets stay in the index but are no longer handed out in assignments.
+func (s *Server) UpdateAssetExcluded(assetId string, excluded bool) (asset *Asset, err error) {
+	asset, err = s.updateAsset(assetId, func(asset *Asset) error {
+		if asset.Project != s.ActiveProjectId {
+			return ErrNotFound
+		}
+		asset.Excluded = excluded
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// @Title ExcludeAssetHandler
+// @Description removes an asset from assignment circulation without deleting it (ex: unreadable scans)
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Success 200 {object}  assetResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id}/exclude [get]
+func (s *Server) ExcludeAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	asset, err := s.UpdateAssetExcluded(vars["asset_id"], true)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	assetJson, err := json.Marshal(assetResponse{
+		Asset: *asset,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetJson)
+}
+
+// @Title IncludeAssetHandler
+// @Description puts a previously excluded asset back into assignment circulation
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Success 200 {object}  assetResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id}/include [get]
+func (s *Server) IncludeAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	asset, err := s.UpdateAssetExcluded(vars["asset_id"], false)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	assetJson, err := json.Marshal(assetResponse{
+		Asset: *asset,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetJson)
+}
+
 // UpdateTaskState is called from disable and enable TaskHandlers
 // It sets the current state of a task (available, waiting)
 func (s *Server) UpdateTaskState(taskId string, state string) (task *Task, err error) {
@@ -455,11 +658,11 @@ func (s *Server) UpdateTaskState(taskId string, state string) (task *Task, err e
 		return nil, err
 	}
 	task.CurrentState = state
-	_, err = s.EsConn.Index(s.Index, "tasks", task.Id, nil, task)
+	_, err = s.Store.Put("tasks", task.Id, task)
 	if err != nil {
 		return nil, err
 	}
-	_, err = s.EsConn.Refresh(s.Index)
+	err = s.Store.Refresh()
 	if err != nil {
 		return nil, err
 	}
@@ -477,7 +680,7 @@ func (s *Server) UpdateTaskState(taskId string, state string) (task *Task, err e
 // @Router /admin/projects/{project_id}/tasks/{task_id}/disable [get]
 func (s *Server) DisableTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	taskId := vars["task_id"]
 	taskName := taskId
 	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
@@ -486,7 +689,7 @@ func (s *Server) DisableTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.UpdateTaskState(taskName, "waiting")
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	taskJson, err := json.Marshal(taskResponse{
@@ -494,7 +697,7 @@ func (s *Server) DisableTaskHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, taskJson)
@@ -512,7 +715,7 @@ func (s *Server) DisableTaskHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /admin/projects/{project_id}/tasks/{task_id}/enable [get]
 func (s *Server) EnableTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	taskId := vars["task_id"]
 	taskName := taskId
 	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
@@ -521,7 +724,7 @@ func (s *Server) EnableTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.UpdateTaskState(taskName, "available")
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	taskJson, err := json.Marshal(taskResponse{
@@ -529,13 +732,104 @@ func (s *Server) EnableTaskHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, taskJson)
 	return
 }
 
+// taskStates are the states a task can be put in.
+var taskStates = map[string]bool{"available": true, "hidden": true, "waiting": true, "closed": true, "archived": true}
+
+// UpdateTaskStates sets the current state of several tasks at once, ex: to pause a whole project.
+// Task ids may be given with or without the project prefix. Every id is checked before any task changes.
+func (s *Server) UpdateTaskStates(taskIds []string, state string) (tasks []Task, err error) {
+	invalid := &ValidationError{}
+	if !taskStates[state] {
+		invalid.add("State", "has to be one of available, hidden, waiting, closed or archived, not %q", state)
+	}
+	if len(taskIds) == 0 {
+		invalid.add("Tasks", "is required")
+	}
+	err = invalid.orNil()
+	if err != nil {
+		return nil, err
+	}
+
+	fullIds := make([]string, 0, len(taskIds))
+	for _, taskId := range taskIds {
+		if !strings.HasPrefix(taskId, s.ActiveProjectId) {
+			taskId = s.ActiveProjectId + "-" + taskId
+		}
+		exists, _ := s.Store.Exists("tasks", taskId)
+		if !exists {
+			return nil, notFound("Sorry, there's no task %s in this project.", taskId)
+		}
+		fullIds = append(fullIds, taskId)
+	}
+
+	for _, taskId := range fullIds {
+		task, err := s.UpdateTaskState(taskId, state)
+		if err != nil {
+			return tasks, err
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, nil
+}
+
+// @Title AdminTaskStatesHandler
+// @Description sets the state of several tasks in one call, ex: to pause or resume every task in a project
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   states        body   string     true        "JSON-formatted task ids and target state, ex: {\"Tasks\": [\"find\", \"transcribe\"], \"State\": \"waiting\"}"
+// @Success 200 {object}  tasksResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/state [post]
+func (s *Server) AdminTaskStatesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	var stateData struct {
+		Tasks []string
+		State string
+	}
+	err = json.Unmarshal(body, &stateData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	tasks, err := s.UpdateTaskStates(stateData.Tasks, stateData.State)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	tasksJson, err := json.Marshal(tasksResponse{
+		Tasks: tasks,
+		Meta: meta{
+			Total: len(tasks),
+			From:  0,
+			Size:  len(tasks),
+		},
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, tasksJson)
+}
+
 // @Title AdminTasksHandler
 // @Description returns a paginated tasks in a project
 // @Accept  json
@@ -548,7 +842,7 @@ func (s *Server) EnableTaskHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /admin/projects/{project_id}/tasks [get]
 func (s *Server) AdminTasksHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	queryParams := r.URL.Query()
 	p := Params{
@@ -560,7 +854,7 @@ func (s *Server) AdminTasksHandler(w http.ResponseWriter, r *http.Request) {
 
 	tasks, m, err := s.FindTasks(p)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -572,7 +866,7 @@ func (s *Server) AdminTasksHandler(w http.ResponseWriter, r *http.Request) {
 	tasksJson, err := json.Marshal(tasksResponse)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, tasksJson)
@@ -584,16 +878,17 @@ func (s *Server) AdminTasksHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   tasks        body   string     true        "JSON-formatted array of tasks to add or update"
 // @Success 200 {object}  tasksResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
 // @Failure 500 {object} error	appropriate error message
 // @Resource /tasks
 // @Router /admin/projects/{project_id}/tasks [post]
 func (s *Server) AdminCreateTasksHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	tasks, m, err := s.CreateTasks(r.Body)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	tasksResponse := &tasksResponse{
@@ -602,7 +897,7 @@ func (s *Server) AdminCreateTasksHandler(w http.ResponseWriter, r *http.Request)
 	}
 	tasksJson, err := json.Marshal(tasksResponse)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, tasksJson)
@@ -615,13 +910,15 @@ func (s *Server) AdminCreateTasksHandler(w http.ResponseWriter, r *http.Request)
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   from        query   int     false        "If specified, will return a set of tasks starting with from number"
 // @Param   size        query   int     false        "If specified, will return a total number of tasks specified as size"
+// @Param   If-None-Match        header   string     false        "The ETag of a copy already held, to get a 304 with no body when it hasn't changed"
 // @Success 200 {object}  tasksResponse
+// @Success 304 {object} string	unchanged since the ETag in If-None-Match
 // @Failure 500 {object} error	appropriate error message
 // @Resource /tasks
 // @Router /projects/{project_id}/tasks [get]
 func (s *Server) TasksHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	queryParams := r.URL.Query()
 	p := Params{
@@ -632,7 +929,7 @@ func (s *Server) TasksHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	tasks, m, err := s.FindTasks(p)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -644,10 +941,10 @@ func (s *Server) TasksHandler(w http.ResponseWriter, r *http.Request) {
 	tasksJson, err := json.Marshal(tasksResponse)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	s.wrapResponse(w, r, 200, tasksJson)
+	s.wrapCachedResponse(w, r, tasksJson)
 }
 
 // @Title AdminAssignmentsHandler
@@ -658,13 +955,17 @@ func (s *Server) TasksHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   state        query   string     false        "Assignment state (unfinished, skipped, finished)"
 // @Param   from        query   int     false        "If specified, will return a set of assignments starting with from number"
 // @Param   size        query   int     false        "If specified, will return a total number of assignments specified as size"
+// @Param   since        query   string     false        "Only assignments last updated at or after this time or date, ex: 2015-06-01"
+// @Param   until        query   string     false        "Only assignments last updated before this time, or on or before this date"
+// @Param   cursor        query   string     false        "Meta.Cursor from the previous page, to page through the whole list in Id order"
 // @Success 200 {object}  assignmentsResponse
+// @Failure 400 {object} errorResponse	cursor isn't one hive gave out
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assignments
 // @Router /admin/projects/{project_id}/assignments [get]
 func (s *Server) AdminAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	queryParams := r.URL.Query()
 	p := Params{
@@ -674,11 +975,14 @@ func (s *Server) AdminAssignmentsHandler(w http.ResponseWriter, r *http.Request)
 		State:   defaultQuery(queryParams, "state", ""),
 		SortBy:  defaultQuery(queryParams, "sortBy", "Id"),
 		SortDir: defaultQuery(queryParams, "sortDir", "asc"),
+		Since:   defaultQuery(queryParams, "since", ""),
+		Until:   defaultQuery(queryParams, "until", ""),
+		Cursor:  defaultQuery(queryParams, "cursor", ""),
 	}
 
 	assignments, m, err := s.FindAssignments(p)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -690,7 +994,7 @@ func (s *Server) AdminAssignmentsHandler(w http.ResponseWriter, r *http.Request)
 	assignmentsJson, err := json.Marshal(assignmentsResponse)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assignmentsJson)
@@ -701,44 +1005,24 @@ func (s *Server) AdminAssignmentsHandler(w http.ResponseWriter, r *http.Request)
 // @Accept  json
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   user_id        path   string     true        "User ID"
-// @Success 200 {object}  userResponse
+// @Success 200 {object}  User
 // @Failure 500 {object} error	appropriate error message
 // @Resource /users
 // @Router /admin/projects/{project_id}/users/{user_id} [get]
 func (s *Server) AdminUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
-	_, err := s.EsConn.Refresh(s.Index)
-	if err != nil {
-		return
-	}
 	user, err := s.FindUser(vars["user_id"])
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	if user.Counts["Assignments"] > 0 {
-		var assetIds []string
-		assetQuery := `{ "query": { "query_string": { "default_field": "Verified", "query": "true" } }, "aggs": { "assets": { "terms": { "field": "Id", "size": 0 } } } }`
-		assetResults, _ := s.EsConn.Search(s.Index, "assets", nil, assetQuery)
-		var a assetAgg
-		_ = json.Unmarshal(assetResults.Aggregations, &a)
-
-		for _, b := range a.Assets.Buckets {
-			assetIds = append(assetIds, b.Id)
-		}
-		assetIdString := "\"" + strings.Join(assetIds, "\", \"") + "\""
-		verifyQuery := fmt.Sprintf(`{"query": {"bool": {"must": [{"terms": {"assignments.Asset.Id": [%s]}},{"term": {"assignments.User": "%s" } } ], "must_not": [ { "term": { "assignments.State": "skipped" } }, { "term": { "assignments.State": "unfinished" } } ] } }, "from": 0, "size": %d}`, assetIdString, user.Id, user.Counts["Assignments"])
-		verifyResults, _ := s.EsConn.Search(s.Index, "assignments", nil, verifyQuery)
-		verifiedCount := verifyResults.Hits.Total
-		user.Counts["VerifiedAssets"] = verifiedCount
-		_, _ = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
-	}
-	userJson, err := json.Marshal(user)
+	// stored counts are shown as they are; ReconcileCounts repairs any that drift
+	userJson, err := marshalUser(r, user)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -751,13 +1035,18 @@ func (s *Server) AdminUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   from        query   int     false        "If specified, will return a set of users starting with from number"
 // @Param   size        query   int     false        "If specified, will return a total number of users specified as size"
+// @Param   sortBy        query   string     false        "Fields to sort by, each with an optional direction, ex: trust:asc,Name:asc. Counts work too: assignments, verifiedAssets, favorites, or trust"
+// @Param   sortDir        query   string     false        "asc or desc"
+// @Param   format        query   string     false        "csv to download a spreadsheet with each user's counts and accuracy instead of json"
+// @Param   cursor        query   string     false        "Meta.Cursor from the previous page, to page through the whole list in Id order"
 // @Success 200 {object}  usersResponse
+// @Failure 400 {object} errorResponse	what's wrong with sortBy or cursor
 // @Failure 500 {object} error	appropriate error message
 // @Resource /users
 // @Router /admin/projects/{project_id}/users [get]
 func (s *Server) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	queryParams := r.URL.Query()
 	p := Params{
@@ -768,37 +1057,27 @@ func (s *Server) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
 		SortBy:   defaultQuery(queryParams, "sortBy", "Id"),
 		SortDir:  defaultQuery(queryParams, "sortDir", "asc"),
 		Verified: defaultQuery(queryParams, "verified", ""),
+		Query:    defaultQuery(queryParams, "q", ""),
+		Cursor:   defaultQuery(queryParams, "cursor", ""),
 	}
 
-	_, err := s.EsConn.Refresh(s.Index)
-	if err != nil {
-		return
-	}
 	users, m, err := s.FindUsers(p)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	var assetIds []string
-	assetQuery := `{ "query": { "query_string": { "default_field": "Verified", "query": "true" } }, "aggs": { "assets": { "terms": { "field": "Id", "size": 0 } } } }`
-	assetResults, _ := s.EsConn.Search(s.Index, "assets", nil, assetQuery)
-	var a assetAgg
-	_ = json.Unmarshal(assetResults.Aggregations, &a)
-
-	for _, b := range a.Assets.Buckets {
-		assetIds = append(assetIds, b.Id)
-	}
-	assetIdString := "\"" + strings.Join(assetIds, "\", \"") + "\""
-	for _, user := range users {
-		if user.Counts["Assignments"] > 0 {
-			verifyQuery := fmt.Sprintf(`{"query": {"bool": {"must": [{"terms": {"assignments.Asset.Id": [%s]}},{"term": {"assignments.User": "%s" } } ], "must_not": [ { "term": { "assignments.State": "skipped" } }, { "term": { "assignments.State": "unfinished" } } ] } }, "from": 0, "size": %d}`, assetIdString, user.Id, user.Counts["Assignments"])
-			verifyResults, _ := s.EsConn.Search(s.Index, "assignments", nil, verifyQuery)
-			verifiedCount := verifyResults.Hits.Total
-			user.Counts["VerifiedAssets"] = verifiedCount
-			_, _ = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+	// stored counts are shown as they are; ReconcileCounts repairs any that drift
+	if wantsCsv(r) {
+		rows, err := s.userStatsCsv(users)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
 		}
+		s.wrapCsv(w, r, s.ActiveProjectId+"-users.csv", rows)
+		return
 	}
+
 	// format the json response
 	usersResponse := &usersResponse{
 		Users: users,
@@ -807,12 +1086,84 @@ func (s *Server) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
 	usersJson, err := json.Marshal(usersResponse)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, usersJson)
 }
 
+// @Title AdminMergeUsersHandler
+// @Description merges one user into another, the same as AdminMergeIntoUserHandler with the user to keep given in the body
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   merge        body   string     true        "JSON-formatted user ids, ex: {\"Source\": \"cookie user id\", \"Target\": \"registered user id\"}"
+// @Success 200 {object}  userResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 404 {object} errorResponse	either user isn't in the project
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/projects/{project_id}/users/merge [post]
+func (s *Server) AdminMergeUsersHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	var mergeData struct {
+		Source string
+		Target string
+	}
+	err := json.NewDecoder(r.Body).Decode(&mergeData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.serveMergedUser(w, r, mergeData.Source, mergeData.Target)
+}
+
+// @Title AdminMergeIntoUserHandler
+// @Description merges another user into this one, ex: the anonymous cookie user someone had before signing up
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   user_id        path   string     true        "ID of the user to keep"
+// @Param   merge        body   string     true        "JSON-formatted id of the user to merge and delete, ex: {\"Source\": \"cookie user id\"}"
+// @Success 200 {object}  userResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 404 {object} errorResponse	either user isn't in the project
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/projects/{project_id}/users/{user_id}/merge [post]
+func (s *Server) AdminMergeIntoUserHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	var mergeData struct {
+		Source string
+	}
+	err := json.NewDecoder(r.Body).Decode(&mergeData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.serveMergedUser(w, r, mergeData.Source, vars["user_id"])
+}
+
+// serveMergedUser merges sourceId into targetId and answers with the merged user, for both merge endpoints.
+func (s *Server) serveMergedUser(w http.ResponseWriter, r *http.Request, sourceId string, targetId string) {
+	user, err := s.MergeUsers(sourceId, targetId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	userJson, err := json.Marshal(userResponse{
+		User: *user,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, userJson)
+}
+
 // Creates or updates a project by parsing the JSON body of the request.
 func (s *Server) CreateProject(requestBody io.Reader) (project *Project, err error) {
 	body, err := ioutil.ReadAll(requestBody)
@@ -825,12 +1176,22 @@ func (s *Server) CreateProject(requestBody io.Reader) (project *Project, err err
 		return nil, err
 	}
 
+	if project.Id == "" {
+		project.Id = s.ActiveProjectId
+	}
+	invalid := &ValidationError{}
+	validateProject(invalid, *project, s.ActiveProjectId)
+	err = invalid.orNil()
+	if err != nil {
+		return nil, err
+	}
+
 	// store in elasticsearch
-	_, err = s.EsConn.Index(s.Index, "projects", project.Id, nil, project)
+	_, err = s.Store.Put("projects", project.Id, project)
 	if err != nil {
 		return nil, err
 	}
-	_, err = s.EsConn.Refresh(s.Index)
+	err = s.Store.Refresh()
 	if err != nil {
 		return nil, err
 	}
@@ -849,29 +1210,45 @@ func (s *Server) CreateTask(requestBody io.Reader) (task *Task, err error) {
 	if err != nil {
 		return
 	}
+	invalid := &ValidationError{}
+	validateTask(invalid, "", *task)
+	err = invalid.orNil()
+	if err != nil {
+		return nil, err
+	}
 
 	task.Id = strings.Join([]string{s.ActiveProjectId, strings.ToLower(task.Name)}, "-")
 	if task.AssignmentCriteria.SubmittedData == nil {
 		task.AssignmentCriteria.SubmittedData = make(map[string]interface{})
 	}
-	_, err = s.EsConn.Index(s.Index, "tasks", task.Id, nil, task)
+	exists, _ := s.Store.Exists("tasks", task.Id)
+	_, err = s.Store.Put("tasks", task.Id, task)
 	if err != nil {
 		return
 	}
 
-	_, err = s.EsConn.Refresh(s.Index)
+	err = s.Store.Refresh()
 	if err != nil {
 		return
 	}
 
+	// assets and users from before the task need its placeholders
+	if !exists {
+		_, err = s.BackfillTask(*task)
+		if err != nil {
+			return
+		}
+	}
+
 	return task, nil
 }
 
 // Creates assets in this project by parsing the JSON body of the request.
-func (s *Server) CreateAssets(requestBody io.Reader) (assets []Asset, err error) {
+// With dedup, assets whose Url is already in the project update that asset instead.
+func (s *Server) CreateAssets(requestBody io.Reader, dedup bool) (assets []Asset, imported assetStreamResponse, err error) {
 	body, err := ioutil.ReadAll(requestBody)
 	if err != nil {
-		return assets, err
+		return assets, imported, err
 	}
 
 	var importedJson struct {
@@ -879,19 +1256,58 @@ func (s *Server) CreateAssets(requestBody io.Reader) (assets []Asset, err error)
 	}
 	err = json.Unmarshal(body, &importedJson)
 	if err != nil {
-		return assets, err
+		return assets, imported, err
 	}
 
-	assets, err = s.importAssets(importedJson.Assets)
+	assets, imported, err = s.importAssets(importedJson.Assets, dedup)
 	if err != nil {
-		return assets, err
+		return assets, imported, err
 	}
-	return assets, nil
+	return assets, imported, nil
 
 }
 
 // importAssets is a helper method called by CreateAssets that formats the request body appropriately for saving assets.
-func (s *Server) importAssets(newAssets []Asset) (assets []Asset, err error) {
+// It returns the assets as stored, and how many were new, updated, already there or refused by elasticsearch.
+// Ids are generated here rather than by elasticsearch, so the assets are stored with bulk requests in a single pass.
+func (s *Server) importAssets(newAssets []Asset, dedup bool) (assets []Asset, imported assetStreamResponse, err error) {
+	// the whole import is checked before any of it is stored
+	invalid := &ValidationError{}
+	for i, asset := range newAssets {
+		validateAsset(invalid, fmt.Sprintf("Assets[%d].", i), asset)
+	}
+	err = invalid.orNil()
+	if err != nil {
+		return assets, imported, err
+	}
+
+	i := 0
+	var results []assetImportResult
+	imported, err = s.importAssetBatches(defaultImportBatchSize, dedup, func() (*Asset, error) {
+		if i == len(newAssets) {
+			return nil, nil
+		}
+		i++
+		return &newAssets[i-1], nil
+	}, func(asset Asset, result string) {
+		assets = append(assets, asset)
+		results = append(results, assetImportResult{Id: asset.Id, Url: asset.Url, Result: result})
+	})
+	imported.Results = results
+	return assets, imported, err
+}
+
+// assetImport is what importing assets into the current project needs to know up front.
+type assetImport struct {
+	tasks         []Task
+	submittedData SubmittedData // an empty placeholder for each task
+	hashIds       bool
+	dedup         bool              // match assets to ones already in the project by Url, see prepareAsset
+	urlIds        map[string]string // with dedup, the ids of the assets this import has stored so far, by Url
+}
+
+// newAssetImport looks up the current project's tasks and settings for an import.
+func (s *Server) newAssetImport(dedup bool) (*assetImport, error) {
 	p := Params{
 		From:    "0",
 		Size:    "10",
@@ -900,7 +1316,7 @@ func (s *Server) importAssets(newAssets []Asset) (assets []Asset, err error) {
 	}
 	tasks, _, err := s.FindTasks(p)
 	if err != nil {
-		return assets, err
+		return nil, err
 	}
 
 	submittedData := SubmittedData{}
@@ -909,44 +1325,139 @@ func (s *Server) importAssets(newAssets []Asset) (assets []Asset, err error) {
 		submittedData[task.Name] = nil
 	}
 
-	for _, asset := range newAssets {
-		if len(asset.Url) == 0 {
-			return assets, errors.New("Sorry, all assets must specify a url.")
+	return &assetImport{
+		tasks:         tasks,
+		submittedData: submittedData,
+		hashIds:       s.hashesAssetIds(),
+		dedup:         dedup,
+		urlIds:        make(map[string]string),
+	}, nil
+}
+
+// prepareAsset fills in a new asset's project, placeholders and prelabels ready for storing.
+// When its hashed id has already been imported, the stored asset is returned as existing instead.
+// With dedup, an asset whose Url is already in the project is returned updated with the import's fields.
+func (s *Server) prepareAsset(imp *assetImport, asset Asset) (prepared Asset, existing *Asset, updated bool, err error) {
+	invalid := &ValidationError{}
+	validateAsset(invalid, "", asset)
+	err = invalid.orNil()
+	if err != nil {
+		return asset, nil, false, err
+	}
+
+	asset.Id = stableAssetId(s.ActiveProjectId, asset)
+
+	// hashed ids make re-imports idempotent: an asset that's already here is left as it is
+	if imp.hashIds {
+		if asset.Id == "" {
+			asset.Id = hashAssetId(s.ActiveProjectId, []byte(asset.Url))
 		}
-		asset.Project = s.ActiveProjectId
-		asset.SubmittedData = submittedData
-		asset.Counts = Counts{
-			"Favorites":   0,
-			"Assignments": 0,
-			"finished":    0,
-			"skipped":     0,
-			"unfinished":  0,
+		exists, _ := s.Store.Exists("assets", asset.Id)
+		if exists {
+			existing, err = s.FindAsset(asset.Id)
+			return asset, existing, false, err
 		}
-
-		// store in elasticsearch, which will generate a unique id
-		result, err := s.EsConn.Index(s.Index, "assets", "", nil, asset)
+	} else if asset.Id != "" {
+		// so do stable ids, given or derived from a DedupKey, but the asset is updated with the import's fields
+		stored, err := s.storedAsset(asset.Id)
 		if err != nil {
-			return assets, err
+			return asset, nil, false, err
 		}
-
-		// get the id, store it in the asset source in elasticsearch
-		asset.Id = result.Id
-		_, err = s.EsConn.Index(s.Index, "assets", asset.Id, nil, asset)
+		if stored != nil {
+			return mergeImportedAsset(*stored, asset), nil, true, nil
+		}
+		imp.urlIds[asset.Url] = asset.Id
+	} else if imp.dedup {
+		stored, err := s.importedAsset(imp, asset.Url)
 		if err != nil {
-			return assets, err
+			return asset, nil, false, err
 		}
-
-		if err == nil {
-			assets = append(assets, asset)
+		if stored != nil {
+			return mergeImportedAsset(*stored, asset), nil, true, nil
+		}
+		asset.Id, err = randomId()
+		if err != nil {
+			return asset, nil, false, err
+		}
+		imp.urlIds[asset.Url] = asset.Id
+	} else {
+		asset.Id = ""
+	}
+
+	asset.Project = s.ActiveProjectId
+	asset.Created = time.Now().UTC()
+	asset.Language = normalizeLanguage(asset.Language)
+	asset.SubmittedData = imp.submittedData
+	asset.Counts = Counts{
+		"Favorites":   0,
+		"Assignments": 0,
+		"finished":    0,
+		"skipped":     0,
+		"unfinished":  0,
+	}
+
+	// ask any prediction endpoints for a starting point contributors can work from
+	for _, task := range imp.tasks {
+		if task.PrelabelUrl == "" {
+			continue
+		}
+		prelabel, err := fetchPrelabel(task, asset)
+		if err != nil {
+			s.logError("failed prelabeling asset", err, logFields{"url": asset.Url, "task": task.Name})
+			continue
+		}
+		if asset.Prelabel == nil {
+			asset.Prelabel = SubmittedData{}
 		}
+		asset.Prelabel[task.Name] = prelabel
 	}
+	return asset, nil, false, nil
+}
+
+// hashesAssetIds reports whether the current project derives asset ids from hashes.
+func (s *Server) hashesAssetIds() bool {
+	var project *Project
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	return err == nil && project != nil && project.HashAssetIds
+}
+
+// hashAssetId derives a stable asset id from the project and a fingerprint of the asset, its url or uploaded content.
+// The same asset gets the same id on every import and in every environment.
+func hashAssetId(projectId string, fingerprint []byte) string {
+	h := sha256.New()
+	h.Write([]byte(projectId))
+	h.Write([]byte{0})
+	h.Write(fingerprint)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
 
-	_, err = s.EsConn.Refresh(s.Index)
+// prelabelClient calls external prediction endpoints, with a timeout so a slow model can't stall imports.
+var prelabelClient = &http.Client{Timeout: 10 * time.Second}
+
+// fetchPrelabel POSTs an asset to a task's PrelabelUrl and returns the decoded JSON suggestion.
+func fetchPrelabel(task Task, asset Asset) (prelabel interface{}, err error) {
+	assetJson, err := json.Marshal(asset)
 	if err != nil {
-		return
+		return nil, err
 	}
+	resp, err := prelabelClient.Post(task.PrelabelUrl, "application/json", strings.NewReader(string(assetJson)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-	return assets, nil
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("prediction endpoint responded with %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(body, &prelabel)
+	if err != nil {
+		return nil, err
+	}
+	return prelabel, nil
 }
 
 // CreateTasks reads the request body POST'd to hive's admin to create/update tasks
@@ -974,11 +1485,19 @@ func (s *Server) CreateTasks(requestBody io.Reader) (tasks []Task, m meta, err e
 
 // importTasks is a helper method called by CreateTasks that formats the request body appropriately for saving tasks.
 func (s *Server) importTasks(newTasks []Task) (tasks []Task, m meta, err error) {
+	// the whole import is checked before any of it is stored
+	invalid := &ValidationError{}
+	for i, task := range newTasks {
+		validateTask(invalid, fmt.Sprintf("Tasks[%d].", i), task)
+	}
+	err = invalid.orNil()
+	if err != nil {
+		return
+	}
+
+	var addedTasks []Task
+	batch := make(map[string]interface{})
 	for _, task := range newTasks {
-		if len(task.Name) == 0 {
-			err = errors.New("Sorry, all tasks must specify a name.")
-			return
-		}
 		task.Project = s.ActiveProjectId
 
 		task.Id = strings.Join([]string{s.ActiveProjectId, strings.ToLower(task.Name)}, "-")
@@ -986,18 +1505,34 @@ func (s *Server) importTasks(newTasks []Task) (tasks []Task, m meta, err error)
 			task.AssignmentCriteria.SubmittedData = make(map[string]interface{})
 		}
 
-		// store in elasticsearch, which will generate a unique id
-		_, err := s.EsConn.Index(s.Index, "tasks", task.Id, nil, task)
-		if err != nil {
-			return tasks, m, err
+		exists, _ := s.Store.Exists("tasks", task.Id)
+		if !exists {
+			addedTasks = append(addedTasks, task)
 		}
+		batch[task.Id] = task
 		tasks = append(tasks, task)
 	}
-	_, err = s.EsConn.Refresh(s.Index)
+
+	// stored together, in one bulk request; a task that fails is reported with why
+	if len(batch) > 0 {
+		err = s.Store.PutMany("tasks", batch)
+		if err != nil {
+			return
+		}
+	}
+	err = s.Store.Refresh()
 	if err != nil {
 		return
 	}
 
+	// assets and users from before these tasks need their placeholders
+	for _, task := range addedTasks {
+		_, err = s.BackfillTask(task)
+		if err != nil {
+			return
+		}
+	}
+
 	m.Total = len(tasks)
 	m.From = 0
 	m.Size = len(tasks)
@@ -1006,8 +1541,10 @@ func (s *Server) importTasks(newTasks []Task) (tasks []Task, m meta, err error)
 }
 
 // CompleteTask uses the task's CompletionCriteria to find eligible assets for verification.
+// The task's finished assignments are read once, in order of their asset, so each asset's answers arrive together
+// and no aggregation has to hold every asset at once. Only the assets that reach consensus are kept until they're
+// verified, once the reading is done.
 func (s *Server) CompleteTask(taskId string) ([]Asset, error) {
-	var searchJson string
 	var assets []Asset
 
 	taskName := s.ActiveProjectId + "-" + taskId
@@ -1015,180 +1552,75 @@ func (s *Server) CompleteTask(taskId string) ([]Asset, error) {
 	if err != nil {
 		return assets, err
 	}
-
-	query := `{
-		"aggs": {
-			"assets": {
-				"terms": {
-					"field": "Asset.Id",
-					"size": 50000,
-					"min_doc_count": %d
-				},
-				"aggs": {
-					"users": {
-						"terms": {
-							"field": "User"
-						}
-					}
-				}
-			}
-		},
-		"query": {
-			"filtered": {
-				"filter": {
-					"bool": {
-						"must": [
-						{
-							"query": {
-								"match": {
-									"assignments.Task": "%s"
-								}
-							}
-						},
-						{
-							"query": {
-								"match": {
-									"Project": "%s"
-								}
-							}
-						},
-						{
-							"query": {
-								"match": {
-									"State": "finished"
-								}
-							}
-						}
-						]
-					}
-				}
-			}
-		}
-	}`
-
-	searchJson = fmt.Sprintf(query, task.CompletionCriteria.Total, taskName, s.ActiveProjectId)
-	log.Println(searchJson)
-
-	results, err := s.EsConn.Search(s.Index, "assignments", nil, searchJson)
+	strategy, err := taskConsensusStrategy(*task)
 	if err != nil {
 		return assets, err
 	}
+	// without a weight to reach, answers are counted as usual
+	weighted := task.CompletionCriteria.WeightByTrust && task.CompletionCriteria.MatchingWeight > 0
 
-	log.Println("** Assignments count:", results.Hits.Total)
-	var a assetAgg
-	err = json.Unmarshal(results.Aggregations, &a)
+	var completions []assetCompletion
+	var group []Assignment
+	considered, assignmentCount := 0, 0
+	tallyGroup := func() {
+		if len(group) == 0 {
+			return
+		}
+		considered++
+		if completion, ok := s.assetConsensus(*task, strategy, weighted, group); ok {
+			completions = append(completions, completion)
+		}
+		group = nil
+	}
+	filters := []string{
+		fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskName),
+		`{ "term": { "State": "finished" } }`,
+	}
+	err = s.forEachSortedDoc("assignments", filters, `{ "Asset.Id": { "order": "asc" } }`, func(source json.RawMessage) error {
+		var assignment Assignment
+		err := json.Unmarshal(source, &assignment)
+		if err != nil {
+			s.logError("failed reading assignment", err, nil)
+			return nil
+		}
+		assignmentCount++
+		if len(group) > 0 && group[0].Asset.Id != assignment.Asset.Id {
+			tallyGroup()
+		}
+		group = append(group, assignment)
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return assets, err
 	}
+	tallyGroup()
+	s.logEvent("completing task", logFields{"task": task.Name, "assignments": assignmentCount, "assets": considered, "verifying": len(completions)})
 
-	/*
-		assignments := make(map[string]Assignment)
-
-		for _, hit := range results.Hits.Hits {
-			var assignment Assignment
-			rawMessage := hit.Source
-			err = json.Unmarshal(*rawMessage, &assignment)
-			if err != nil {
-				continue
-			}
-			assignments[assignment.Asset.Id] = assignment
+	verified := make(map[string]interface{})
+	for _, completion := range completions {
+		asset, err := s.CompleteAsset(completion.AssetId, *task, completion.Value)
+		if err != nil {
+			s.logError("failed completing asset", err, logFields{"asset": completion.AssetId, "task": task.Name})
+			continue
 		}
-	*/
-
-	log.Println("** Assets Buckets:", len(a.Assets.Buckets))
-	for _, b := range a.Assets.Buckets {
-		if b.Count >= task.CompletionCriteria.Matching {
-			log.Println("Completing asset", b.Id, "for task", task.Name)
-
-			assignmentQuery := `{
-				"query": {
-					"filtered": {
-						"filter": {
-							"bool": {
-								"must": [
-								{
-									"query": {
-										"match": {
-											"Task": "%s"
-										}
-									}
-								},
-								{
-									"query": {
-										"match": {
-											"Asset.Id": "%s"
-										}
-									}
-								},
-								{
-									"query": {
-										"match": {
-											"Project": "%s"
-										}
-									}
-								},
-								{
-									"query": {
-										"match": {
-											"State": "finished"
-										}
-									}
-								}
-								]
-							}
-						}
-					}
-				}
-			}`
-			assignmentSearchJson := fmt.Sprintf(assignmentQuery, taskName, b.Id, s.ActiveProjectId)
-			log.Println(assignmentSearchJson)
-			assignmentResults, err := s.EsConn.Search(s.Index, "assignments", nil, assignmentSearchJson)
-			if err != nil {
-				log.Println("error searching for matching assignment:", err)
-				return nil, err
-			}
-			log.Println("** Matching assignments count:", assignmentResults.Hits.Total)
-
-			var matchingAssignments []Assignment
-			var sdTrackers []SubmittedDataTracker
-			for _, assignmentHit := range assignmentResults.Hits.Hits {
-				var matchingAssignment Assignment
-				rawMessage := assignmentHit.Source
-				err = json.Unmarshal(*rawMessage, &matchingAssignment)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				sdTrackers = collateSubmittedData(sdTrackers, matchingAssignment.SubmittedData)
-				matchingAssignments = append(matchingAssignments, matchingAssignment)
-			}
-
-			log.Println("sdTrackers:", sdTrackers)
-			for _, tracker := range sdTrackers {
-				if tracker.Count >= task.CompletionCriteria.Matching {
-					log.Println("found", tracker.Count, "matching sds!")
-					asset, err := s.CompleteAsset(b.Id, *task, tracker.Value)
-					if err != nil {
-						log.Println("error completing asset", err)
-						continue
-					}
-					assets = append(assets, *asset)
-					for _, a := range matchingAssignments {
-						a.State = "verified"
-						log.Println("verifying assignment", a.Id)
-						_, err = s.EsConn.Index(s.Index, "assignments", a.Id, nil, a)
-						if err != nil {
-							log.Println("error saving assignment record:", err)
-						}
-					}
-					continue
-				}
-			}
+		assets = append(assets, *asset)
+		_, err = s.QueueReview(*task, *asset)
+		if err != nil {
+			s.logError("failed queueing asset for review", err, logFields{"asset": asset.Id, "task": task.Name})
+		}
+		for _, a := range completion.Assignments {
+			a.State = "verified"
+			verified[a.Id] = a
+		}
+		// the verified assignments are stored a page at a time
+		if len(verified) >= backfillPageSize {
+			s.verifyAssignments(verified)
+			verified = make(map[string]interface{})
 		}
 	}
+	s.verifyAssignments(verified)
 
-	_, err = s.EsConn.Refresh(s.Index)
+	err = s.Store.Refresh()
 	if err != nil {
 		return assets, err
 	}
@@ -1196,37 +1628,79 @@ func (s *Server) CompleteTask(taskId string) ([]Asset, error) {
 	return assets, err
 }
 
+// assetCompletion is an asset whose finished assignments for a task agree, and the answer they agree on.
+type assetCompletion struct {
+	AssetId     string
+	Value       SubmittedData
+	Assignments []Assignment
+}
+
+// assetConsensus tallies one asset's finished assignments for a task, and reports whether they reach its
+// CompletionCriteria, and with what answer.
+func (s *Server) assetConsensus(task Task, strategy ConsensusStrategy, weighted bool, assignments []Assignment) (assetCompletion, bool) {
+	completion := assetCompletion{AssetId: assignments[0].Asset.Id, Assignments: assignments}
+	if len(assignments) < task.CompletionCriteria.Total {
+		return completion, false
+	}
+	// weighted answers can be verified by fewer assignments than Matching
+	if !weighted && len(assignments) < task.CompletionCriteria.Matching {
+		return completion, false
+	}
+
+	var weights map[string]float64
+	if weighted {
+		var err error
+		weights, err = s.trustWeights(assignments)
+		if err != nil {
+			s.logError("failed weighing assignments", err, logFields{"asset": completion.AssetId, "task": task.Name})
+			return completion, false
+		}
+	}
+	for _, tracker := range strategy.Tally(task, assignments, weights) {
+		if (!weighted && tracker.Count >= task.CompletionCriteria.Matching) || (weighted && tracker.Weight >= task.CompletionCriteria.MatchingWeight) {
+			completion.Value = tracker.Value
+			return completion, true
+		}
+	}
+	return completion, false
+}
+
+// verifyAssignments stores assignments marked verified, keyed by id, in one bulk request, logging any that fail.
+func (s *Server) verifyAssignments(assignments map[string]interface{}) {
+	if len(assignments) == 0 {
+		return
+	}
+	err := s.Store.PutMany("assignments", assignments)
+	if bulkErr, ok := err.(*BulkError); ok {
+		for _, item := range bulkErr.Items {
+			s.logError("failed verifying assignment", errors.New(item.Error), logFields{"assignment": item.Id})
+		}
+	} else if err != nil {
+		s.logError("failed verifying assignments", err, logFields{"assignments": len(assignments)})
+	}
+}
+
 type SubmittedDataTracker struct {
-	Value SubmittedData
-	Count int
-}
-
-func collateSubmittedData(sdt []SubmittedDataTracker, item SubmittedData) []SubmittedDataTracker {
-	log.Println("---------------------------------------")
-	log.Println("sdt size:", len(sdt))
-	log.Println("sdt before:", sdt)
-	log.Println("item:", item)
-	foundIt := false
-	for i, tracker := range sdt {
-		if reflect.DeepEqual(tracker.Value, item) {
-			log.Println("found a match")
-			// we've seen this before
-			tracker.Count += 1
-			sdt[i] = tracker
-			log.Println("count is now:", tracker.Count)
-			foundIt = true
-		}
-	}
-	log.Println("sdt after:", sdt)
-	if !foundIt {
-		log.Println("didn't find it")
-		sdt = append(sdt, SubmittedDataTracker{
-			Value: item,
-			Count: 1,
-		})
+	Value  SubmittedData
+	Count  int
+	Weight float64 // with CompletionCriteria.WeightByTrust, the summed trust of the users counted
+}
+
+// normalizeLanguage lowercases and trims a language code so asset and user languages compare exactly.
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.TrimSpace(language))
+}
+
+// normalizeLanguages normalizes a list of language codes, dropping blanks and duplicates.
+func normalizeLanguages(languages []string) []string {
+	var normalized []string
+	for _, language := range languages {
+		language = normalizeLanguage(language)
+		if language != "" {
+			normalized = appendIfMissing(normalized, language)
+		}
 	}
-	log.Println("---------------------------------------")
-	return sdt
+	return normalized
 }
 
 func appendIfMissing(slice []string, item string) []string {
@@ -1240,39 +1714,39 @@ func appendIfMissing(slice []string, item string) []string {
 
 // CompleteAsset is called by CompleteTask to store verified submitted data on assets.
 func (s *Server) CompleteAsset(assetId string, task Task, submittedData map[string]interface{}) (*Asset, error) {
-	asset, err := s.FindAsset(assetId)
+	// archived tasks don't hold assets back from being verified
+	tasks, err := s.FindLiveTasks()
 	if err != nil {
-		return asset, err
-	}
-	if asset == nil {
-		assetError := errors.New("Failed finding an asset with that id.")
-		return asset, assetError
-	}
-	asset.SubmittedData[task.Name] = submittedData
-	p := Params{
-		From:    "0",
-		Size:    "10",
-		SortBy:  "Name",
-		SortDir: "asc",
+		return nil, err
 	}
 
-	tasks, _, err := s.FindTasks(p)
-	if err != nil {
-		return asset, err
-	}
-	assetVerified := true
-	for _, t := range tasks {
-		if asset.SubmittedData[t.Name] == nil {
-			assetVerified = false
+	wasVerified := false
+	asset, err := s.updateAsset(assetId, func(asset *Asset) error {
+		if asset.SubmittedData == nil {
+			asset.SubmittedData = SubmittedData{}
 		}
+		asset.SubmittedData[task.Name] = submittedData
+		wasVerified = asset.Verified
+
+		asset.Verified = true
+		for _, t := range tasks {
+			if asset.SubmittedData[t.Name] == nil {
+				asset.Verified = false
+			}
+		}
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an asset with that id.")
 	}
-	if assetVerified {
-		log.Println("Asset #", asset.Id, "is considered verified!")
-	}
-	asset.Verified = assetVerified
-	_, err = s.EsConn.Index(s.Index, "assets", assetId, nil, asset)
 	if err != nil {
-		return asset, err
+		return nil, err
+	}
+
+	// let the project's webhook know the first time the asset's verified
+	if asset.Verified && !wasVerified {
+		s.logEvent("asset verified", logFields{"asset": asset.Id, "task": task.Name})
+		s.AssetVerified(*asset)
 	}
 	return asset, nil
 }
@@ -1285,7 +1759,7 @@ func (s *Server) CalculateAssetCounts(asset Asset) (Asset, error) {
 				"must": [
 				{
 					"term": {
-						"assignments.Asset.Id": "%s"
+						"Asset.Id": "%s"
 					}
 				}
 				],
@@ -1296,8 +1770,8 @@ func (s *Server) CalculateAssetCounts(asset Asset) (Asset, error) {
 		"from": 0,
 		"size": 10,
 		"sort": [],
-		"facets": {
-			"Value": {
+		"aggs": {
+			"States": {
 				"terms": {
 					"field": "State"
 				}
@@ -1305,45 +1779,28 @@ func (s *Server) CalculateAssetCounts(asset Asset) (Asset, error) {
 		}
 	}`
 	assignmentQuery := fmt.Sprintf(assetTmpl, asset.Id)
-	assignResults, err := s.EsConn.Search(s.Index, "assignments", nil, assignmentQuery)
+	assignResults, err := s.Store.Search("assignments", assignmentQuery)
 	if err != nil {
 		return asset, err
 	}
-	var a facetWrapper
-	err = json.Unmarshal(assignResults.Facets, &a)
+	var a stateAgg
+	err = json.Unmarshal(assignResults.Aggregations, &a)
 	if err != nil {
 		return asset, err
 	}
-
-	if len(asset.Counts) <= 0 {
-		asset.Counts = Counts{
-			"Favorites":   0,
-			"Assignments": 0,
-			"finished":    0,
-			"skipped":     0,
-			"unfinished":  0,
-		}
-	} else {
-		asset.Counts = Counts{
-			"Assignments": 0,
-			"finished":    0,
-			"skipped":     0,
-			"unfinished":  0,
-		}
-	}
-	asset.Counts["Assignments"] = a.Value.Total
-	for _, facetTerm := range a.Value.Terms {
-		asset.Counts[facetTerm.Term] = facetTerm.Count
-	}
-
-	_, err = s.EsConn.Index(s.Index, "assets", asset.Id, nil, asset)
+	updated, err := s.updateAsset(asset.Id, func(asset *Asset) error {
+		asset.Counts = assignmentCounts(asset.Counts, a.States.Buckets)
+		return nil
+	})
 	if err != nil {
 		return asset, err
 	}
-	return asset, nil
+	return *updated, nil
 }
 
-func (s *Server) UpdateAssignment(requestBody io.Reader) (assignment *Assignment, err error) {
+// UpdateAssignment saves an assignment submitted by a user and updates the counts on its asset and user.
+// source identifies the submitting client, see requestSource.
+func (s *Server) UpdateAssignment(requestBody io.Reader, source string) (assignment *Assignment, err error) {
 	body, err := ioutil.ReadAll(requestBody)
 	if err != nil {
 		return nil, err
@@ -1353,11 +1810,49 @@ func (s *Server) UpdateAssignment(requestBody io.Reader) (assignment *Assignment
 	if err != nil {
 		return nil, err
 	}
+	return s.SubmitAssignment(assignment, source)
+}
 
+// SubmitAssignment saves a submitted assignment, updating its asset's and user's counts. Callers acting for a
+// contributor check the assignment is theirs first, see readAssignmentSubmission.
+func (s *Server) SubmitAssignment(assignment *Assignment, source string) (*Assignment, error) {
 	//assignment.State = "finished"
+	assignment.Updated = time.Now().UTC()
+	assignment.Source = source
 
-	asset, _ := s.FindAsset(assignment.Asset.Id)
-	if asset != nil {
+	invalid := &ValidationError{}
+	validateSubmission(invalid, *assignment)
+	err := invalid.orNil()
+	if err != nil {
+		return nil, err
+	}
+
+	if assignment.State != "unfinished" {
+		assignment.Draft = nil
+	}
+	if assignment.State != "skipped" {
+		assignment.SkipReason = ""
+	}
+
+	// move the assignment out of unfinished first, so a repeated or concurrent submission can't count twice
+	submitted := *assignment
+	var stored Assignment
+	err = s.updateDoc("assignments", assignment.Id, &stored, func(found bool) error {
+		if !found || stored.State != "unfinished" {
+			return invalidState("Sorry, only unfinished assignments can be submitted.")
+		}
+		merged := submitted
+		// keep answers saved partway through a multi-step task
+		merged.SubmittedData = mergeSubmittedData(stored.SubmittedData, submitted.SubmittedData)
+		stored = merged
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	assignment = &stored
+
+	asset, err := s.updateAsset(assignment.Asset.Id, func(asset *Asset) error {
 		// Set counts on asset
 		if len(asset.Counts) <= 0 {
 			asset.Counts = Counts{
@@ -1371,43 +1866,51 @@ func (s *Server) UpdateAssignment(requestBody io.Reader) (assignment *Assignment
 
 		asset.Counts[assignment.State] += 1
 		asset.Counts["unfinished"] -= 1
-
-		_, err = s.EsConn.Index(s.Index, "assets", asset.Id, nil, asset)
+		return nil
+	})
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	if asset != nil {
+		// ensure the asset is updated on the assignment record
+		snapshot := *asset
+		snapshot.GoldData = nil
+		state := assignment.State
+		err = s.updateDoc("assignments", assignment.Id, &stored, func(found bool) error {
+			if !found || stored.State != state {
+				return errUnchanged
+			}
+			stored.Asset = snapshot
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
-		// ensure the asset is updated on the assignment record
-		assignment.Asset = *asset
-	}
-
-	_, err = s.EsConn.Index(s.Index, "assignments", assignment.Id, nil, assignment)
-	if err != nil {
-		return nil, err
 	}
 	// refresh the index, attempting to fix "skipped" assignment issue #4
-	_, err = s.EsConn.Refresh(s.Index)
+	err = s.Store.Refresh()
 	if err != nil {
 		return nil, err
 	}
 
+	if assignment.State == "finished" || assignment.State == "skipped" {
+		s.AssignmentChanged("assignment."+assignment.State, *assignment)
+	}
+
 	// add finished assignments to the user's list
 	if assignment.State == "finished" {
-		user, err := s.FindUser(assignment.User)
-		if err != nil {
-			return nil, err
-		}
-		user.Counts["Assignments"]++
-		user.Counts[assignment.Task]++
-
 		p := Params{
 			From:    "0",
 			Size:    "10",
 			SortBy:  "Name",
 			SortDir: "asc",
 		}
+		tasks, _, _ := s.FindTasks(p)
+
+		_, err = s.updateUser(assignment.User, func(user *User) error {
+			user.Counts["Assignments"]++
+			user.Counts[assignment.Task]++
 
-		tasks, _, err := s.FindTasks(p)
-		if err != nil {
 			for _, task := range tasks {
 				// Set any missing task counts to zero
 				_, ok := user.Counts[task.Id]
@@ -1415,9 +1918,13 @@ func (s *Server) UpdateAssignment(requestBody io.Reader) (assignment *Assignment
 					user.Counts[task.Id] = 0
 				}
 			}
-		}
 
-		_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+			// keep the user's accuracy up to date as they answer gold standard assets
+			if asset != nil {
+				return s.gradeGold(user, *assignment, *asset)
+			}
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -1425,6 +1932,69 @@ func (s *Server) UpdateAssignment(requestBody io.Reader) (assignment *Assignment
 	return assignment, nil
 }
 
+// checkUnfinishedLimit returns ErrTooManyUnfinished when the user already holds the project's MaxUnfinished unfinished assignments.
+// The assignment with id except, if any, isn't counted since it would be handed out again rather than added.
+func (s *Server) checkUnfinishedLimit(userId string, except string) error {
+	var project *Project
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	if err != nil {
+		return err
+	}
+	if project == nil || project.MaxUnfinished <= 0 {
+		return nil
+	}
+
+	unfinishedQuery := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "term": { "User": "%s" } }, { "term": { "Project": "%s" } }, { "term": { "State": "unfinished" } } ], "must_not": [ { "term": { "Id": "%s" } } ] } } } } }`, userId, s.ActiveProjectId, except)
+
+	count, err := s.Store.Count("assignments", unfinishedQuery)
+	if err != nil {
+		return err
+	}
+	if count >= project.MaxUnfinished {
+		return ErrTooManyUnfinished
+	}
+	return nil
+}
+
+// checkTaskLimit returns ErrTaskLimitReached when the user has already finished the task's MaxAssignmentsPerUser assignments.
+// Finished assignments that went on to verify their asset count too.
+func (s *Server) checkTaskLimit(task Task, userId string) error {
+	if task.MaxAssignmentsPerUser <= 0 {
+		return nil
+	}
+	finishedQuery := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "term": { "User": "%s" } }, { "term": { "Project": "%s" } }, { "term": { "Task": "%s" } }, { "terms": { "State": [ "finished", "verified" ] } } ] } } } } }`, userId, s.ActiveProjectId, task.Id)
+
+	count, err := s.Store.Count("assignments", finishedQuery)
+	if err != nil {
+		return err
+	}
+	if count >= task.MaxAssignmentsPerUser {
+		return ErrTaskLimitReached
+	}
+	return nil
+}
+
+// countNewAssignment counts an assignment just handed out on its asset, see updateAsset.
+func countNewAssignment(asset *Asset) error {
+	// Set counts on asset
+	if len(asset.Counts) <= 0 {
+		asset.Counts = Counts{
+			"Favorites":   0,
+			"Assignments": 0,
+			"finished":    0,
+			"skipped":     0,
+			"unfinished":  0,
+		}
+	}
+
+	// Since this asset is being assigned now, update the total assignments count
+	asset.Counts["Assignments"] += 1
+
+	// And update the unfinished count, since it's a new assignment
+	asset.Counts["unfinished"] += 1
+	return nil
+}
+
 // CreateAssetAssignment is called by the AssignAssetHandler to generate a new assignment for a particular asset, task and user
 func (s *Server) CreateAssetAssignment(taskId string, userId string, assetId string) (assignment *Assignment, err error) {
 	user, _ := s.FindUser(userId)
@@ -1439,28 +2009,35 @@ func (s *Server) CreateAssetAssignment(taskId string, userId string, assetId str
 
 	asset, err := s.FindAsset(assetId)
 	if asset == nil {
-		assetError := errors.New("Failed finding an asset with that id.")
+		assetError := notFound("Sorry, there isn't an asset with that id.")
 		return nil, assetError
 	}
+	if asset.Excluded {
+		return nil, invalidState("Sorry, this asset has been excluded from assignment.")
+	}
 
-	// Set counts on asset
-	if len(asset.Counts) <= 0 {
-		asset.Counts = Counts{
-			"Favorites":   0,
-			"Assignments": 0,
-			"finished":    0,
-			"skipped":     0,
-			"unfinished":  0,
-		}
+	assignmentId := strings.Join([]string{s.ActiveProjectId, taskId, assetId, userId}, "HIVE")
+	err = s.checkUnfinishedLimit(userId, assignmentId)
+	if err != nil {
+		return nil, err
 	}
-	asset.Counts["Assignments"] += 1
-	asset.Counts["unfinished"] += 1
-	_, err = s.EsConn.Index(s.Index, "assets", asset.Id, nil, asset)
+	task, err := s.FindTask(taskId)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+	err = s.checkTaskLimit(*task, userId)
+	if err != nil {
+		return nil, err
 	}
 
-	assignmentId := strings.Join([]string{s.ActiveProjectId, taskId, assetId, userId}, "HIVE")
+	counted, err := s.updateAsset(asset.Id, countNewAssignment)
+	if err != nil {
+		s.logError("failed counting assignment on asset", err, logFields{"asset": asset.Id})
+	} else {
+		asset = counted
+	}
+
+	now := time.Now().UTC()
 	assignment = &Assignment{
 		Id:      assignmentId,
 		User:    userId,
@@ -1468,12 +2045,17 @@ func (s *Server) CreateAssetAssignment(taskId string, userId string, assetId str
 		Task:    taskId,
 		Asset:   *asset,
 		State:   "unfinished",
+		Created: now,
+		Updated: now,
 	}
+	// gold answers stay private to admins
+	assignment.Asset.GoldData = nil
 
-	_, err = s.EsConn.Index(s.Index, "assignments", assignment.Id, nil, assignment)
+	_, err = s.Store.Put("assignments", assignment.Id, assignment)
 	if err != nil {
 		return nil, err
 	}
+	s.AssignmentChanged("assignment.created", *assignment)
 	return assignment, nil
 }
 
@@ -1497,8 +2079,7 @@ func (s *Server) CreateAssignment(taskId string, userId string) (assignment *Ass
 	}
 
 	if task.CurrentState != "available" {
-		taskError := errors.New("Invalid task")
-		return nil, taskError
+		return nil, invalidState("Sorry, this task isn't available, it's %s.", task.CurrentState)
 	}
 
 	searchQuery := `{
@@ -1507,22 +2088,22 @@ func (s *Server) CreateAssignment(taskId string, userId string) (assignment *Ass
       "must": [
         {
           "term": {
-            "assignments.Project": "%s"
+            "Project": "%s"
           }
         },
         {
           "term": {
-            "assignments.Task": "%s"
+            "Task": "%s"
           }
         },
         {
           "term": {
-            "assignments.User": "%s"
+            "User": "%s"
           }
         },
         {
           "term": {
-            "assignments.State": "unfinished"
+            "State": "unfinished"
           }
         }
       ]
@@ -1532,7 +2113,7 @@ func (s *Server) CreateAssignment(taskId string, userId string) (assignment *Ass
 
 	searchJson := fmt.Sprintf(searchQuery, s.ActiveProjectId, taskId, userId)
 
-	results, err := s.EsConn.Search(s.Index, "assignments", nil, searchJson)
+	results, err := s.Store.Search("assignments", searchJson)
 	if err != nil {
 		return nil, err
 	}
@@ -1547,34 +2128,28 @@ func (s *Server) CreateAssignment(taskId string, userId string) (assignment *Ass
 
 		// create a new assignment
 	} else {
-		assignmentAsset, err := s.FindAssignmentAsset(*task, *user)
+		err = s.checkUnfinishedLimit(userId, "")
 		if err != nil {
 			return nil, err
 		}
-
-		// Set counts on asset
-		if len(assignmentAsset.Counts) <= 0 {
-			assignmentAsset.Counts = Counts{
-				"Favorites":   0,
-				"Assignments": 0,
-				"finished":    0,
-				"skipped":     0,
-				"unfinished":  0,
-			}
+		err = s.checkTaskLimit(*task, userId)
+		if err != nil {
+			return nil, err
 		}
 
-		// Since this asset is being assigned now, update the total assignments count
-		assignmentAsset.Counts["Assignments"] += 1
-
-		// And update the unfinished count, since it's a new assignment
-		assignmentAsset.Counts["unfinished"] += 1
+		assignmentAsset, err := s.FindAssignmentAsset(*task, *user)
+		if err != nil {
+			return nil, err
+		}
 
-		_, err = s.EsConn.Index(s.Index, "assets", assignmentAsset.Id, nil, assignmentAsset)
+		counted, err := s.updateAsset(assignmentAsset.Id, countNewAssignment)
 		if err != nil {
 			return nil, err
 		}
+		assignmentAsset = *counted
 
 		assignmentId := strings.Join([]string{s.ActiveProjectId, taskId, assignmentAsset.Id, user.Id}, "HIVE")
+		now := time.Now().UTC()
 		assignment = &Assignment{
 			Id:      assignmentId,
 			User:    userId,
@@ -1582,12 +2157,17 @@ func (s *Server) CreateAssignment(taskId string, userId string) (assignment *Ass
 			Task:    taskId,
 			Asset:   assignmentAsset,
 			State:   "unfinished",
+			Created: now,
+			Updated: now,
 		}
+		// gold answers stay private to admins
+		assignment.Asset.GoldData = nil
 
-		_, err = s.EsConn.Index(s.Index, "assignments", assignment.Id, nil, assignment)
+		_, err = s.Store.Put("assignments", assignment.Id, assignment)
 		if err != nil {
 			return nil, err
 		}
+		s.AssignmentChanged("assignment.created", *assignment)
 		return assignment, nil
 	}
 }
@@ -1595,22 +2175,16 @@ func (s *Server) CreateAssignment(taskId string, userId string) (assignment *Ass
 // Count composes a simple elasticsearch query scoping results to the current project, returning a total of 'countWhat'
 // This method is used to tally number of tasks and assets for instance.
 func (s *Server) Count(countWhat string) (count int, err error) {
-	var args map[string]interface{}
-
 	projectQuery := fmt.Sprintf(`{ "query": { "term" : {"Project": "%s" } } }`, s.ActiveProjectId)
-	countResponse, err := s.EsConn.Count(s.Index, countWhat, args, projectQuery)
-	if err != nil {
-		return
-	}
-	count = countResponse.Count
-	return
+	return s.Store.Count(countWhat, projectQuery)
 }
 
 // CountAssignments returns a map of assignment states to totals for each scoped to the current project.
 func (s *Server) CountAssignments() (assignmentCount map[string]int, err error) {
 	projectQuery := fmt.Sprintf(`{
-		"facets": {
-			"Value": {
+		"size": 0,
+		"aggs": {
+			"States": {
 				"terms": {
 					"field": "State"
 				}
@@ -1634,27 +2208,51 @@ func (s *Server) CountAssignments() (assignmentCount map[string]int, err error)
 			}
 		}
 	}`, s.ActiveProjectId)
-	results, err := s.EsConn.Search(s.Index, "assignments", nil, projectQuery)
+	results, err := s.Store.Search("assignments", projectQuery)
 	if err != nil {
 		return
 	}
-	var a facetWrapper
-	err = json.Unmarshal(results.Facets, &a)
+	var a stateAgg
+	err = json.Unmarshal(results.Aggregations, &a)
 	if err != nil {
 		return nil, err
 	}
 
 	assignmentCount = make(map[string]int)
-	for _, t := range a.Value.Terms {
-		assignmentCount[strings.Title(t.Term)] = t.Count
+	for _, bucket := range a.States.Buckets {
+		assignmentCount[strings.Title(bucket.Term)] = bucket.Count
 	}
-	assignmentCount["Total"] = a.Value.Total
+	assignmentCount["Total"] = results.Hits.Total
 	return assignmentCount, nil
 }
 
+// CountAssetProgress tallies verified and excluded assets in the current project, and the percentage of
+// non-excluded assets that are verified.
+func (s *Server) CountAssetProgress(assetCount int) (verified int, excluded int, progress int, err error) {
+	excludedQuery := fmt.Sprintf(`{ "query": { "bool": { "must": [ { "term": { "Project": "%s" } }, { "term": { "Excluded": true } } ] } } }`, s.ActiveProjectId)
+	excluded, err = s.Store.Count("assets", excludedQuery)
+	if err != nil {
+		return
+	}
+
+	verifiedQuery := fmt.Sprintf(`{ "query": { "bool": { "must": [ { "term": { "Project": "%s" } }, { "term": { "Verified": true } } ], "must_not": [ { "term": { "Excluded": true } } ] } } }`, s.ActiveProjectId)
+	verified, err = s.Store.Count("assets", verifiedQuery)
+	if err != nil {
+		return
+	}
+
+	if assetCount-excluded > 0 {
+		progress = verified * 100 / (assetCount - excluded)
+	}
+	return
+}
+
 // FindProject looks up a project by id, tallying counts of assets, users, tasks and assignments.
 func (s *Server) FindProject(id string) (project *Project, err error) {
-	err = s.EsConn.GetSource(s.Index, "projects", id, nil, &project)
+	err = s.Store.Get("projects", id, &project)
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't a project with the id %q.", id)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1663,14 +2261,15 @@ func (s *Server) FindProject(id string) (project *Project, err error) {
 	project.TaskCount, _ = s.Count("tasks")
 
 	project.AssignmentCount, _ = s.CountAssignments()
+	project.VerifiedCount, project.ExcludedCount, project.Progress, _ = s.CountAssetProgress(project.AssetCount)
 
 	return project, nil
 }
 
 // FindProjects returns all projects, tallying counts of assets, users, tasks and assignments for each.
 func (s *Server) FindProjects(p Params) (projects []Project, m meta, err error) {
-	query := elastigo.Search(s.Index).Type("projects").From(p.From).Size(p.Size)
-	results, err := query.Result(&s.EsConn)
+	searchJson := fmt.Sprintf(`{ "from": %s, "size": %s }`, p.From, p.Size)
+	results, err := s.Store.Search("projects", searchJson)
 
 	if err != nil {
 		return
@@ -1693,10 +2292,13 @@ func (s *Server) FindProjects(p Params) (projects []Project, m meta, err error)
 			if err != nil {
 				return
 			}
-			project.AssetCount, _ = s.Count("assets")
-			project.UserCount, _ = s.Count("users")
-			project.TaskCount, _ = s.Count("tasks")
-			project.AssignmentCount, _ = s.CountAssignments()
+			// counted in the listed project, not whichever one s is scoped to
+			scoped := s.forProject(project.Id)
+			project.AssetCount, _ = scoped.Count("assets")
+			project.UserCount, _ = scoped.Count("users")
+			project.TaskCount, _ = scoped.Count("tasks")
+			project.AssignmentCount, _ = scoped.CountAssignments()
+			project.VerifiedCount, project.ExcludedCount, project.Progress, _ = scoped.CountAssetProgress(project.AssetCount)
 
 			projects = append(projects, project)
 		}
@@ -1716,11 +2318,10 @@ func (s *Server) FindUser(id string) (user *User, err error) {
 		return user, nil
 	}
 
-	err = s.EsConn.GetSource(s.Index, "users", id, nil, &user)
+	err = s.Store.Get("users", id, &user)
 
 	if err != nil {
-		var args map[string]interface{}
-		userExists, _ := s.EsConn.ExistsBool(s.Index, "users", id, args)
+		userExists, _ := s.Store.Exists("users", id)
 		if !userExists {
 			return nil, nil
 		}
@@ -1748,7 +2349,10 @@ func (s *Server) FindUser(id string) (user *User, err error) {
 
 // FindTask looks up a task by id
 func (s *Server) FindTask(id string) (task *Task, err error) {
-	err = s.EsConn.GetSource(s.Index, "tasks", id, nil, &task)
+	err = s.Store.Get("tasks", id, &task)
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't a task with the id %q.", id)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1757,19 +2361,7 @@ func (s *Server) FindTask(id string) (task *Task, err error) {
 
 // FindTasks returns an array of tasks for the current project
 func (s *Server) FindTasks(p Params) (tasks []Task, m meta, err error) {
-	query := elastigo.Search(s.Index).Type("tasks").Filter(
-		elastigo.Filter().Terms("Project", s.ActiveProjectId),
-	).From(p.From).Size(p.Size)
-	if p.SortDir == "desc" {
-		query = query.Sort(
-			elastigo.Sort(p.SortBy).Desc(),
-		)
-	} else {
-		query = query.Sort(
-			elastigo.Sort(p.SortBy).Asc(),
-		)
-	}
-	results, err := query.Result(&s.EsConn)
+	results, err := s.findProjectDocs("tasks", "", p, nil)
 
 	if err != nil {
 		tasks = make([]Task, 0)
@@ -1791,20 +2383,21 @@ func (s *Server) FindTasks(p Params) (tasks []Task, m meta, err error) {
 // FindUsers returns an array of users in the current project, along with pagination meta information
 // 'from' and 'size' parameters determine the offset and limit passed to the database.
 func (s *Server) FindUsers(p Params) (users []User, m meta, err error) {
-	query := elastigo.Search(s.Index).Type("users").Filter(
-		elastigo.Filter().Terms("Project", s.ActiveProjectId),
-	).From(p.From).Size(p.Size)
-	if p.SortDir == "desc" {
-		query = query.Sort(
-			elastigo.Sort(p.SortBy).Desc(),
-		)
-	} else {
-		query = query.Sort(
-			elastigo.Sort(p.SortBy).Asc(),
-		)
+	var filters []string
+	cursor, err := cursorFilter(&p)
+	if err != nil {
+		return
+	}
+	if cursor != "" {
+		filters = append(filters, cursor)
 	}
 
-	results, err := query.Result(&s.EsConn)
+	var results *SearchResult
+	if p.Query != "" {
+		results, err = s.searchUsers(p, filters...)
+	} else {
+		results, err = s.findProjectDocs("users", "", p, userCountSorts, filters...)
+	}
 
 	if err != nil {
 		users = make([]User, 0)
@@ -1816,6 +2409,7 @@ func (s *Server) FindUsers(p Params) (users []User, m meta, err error) {
 	m.Total = resultCount
 	m.From, _ = strconv.Atoi(p.From)
 	m.Size, _ = strconv.Atoi(p.Size)
+	m.Cursor = nextCursor(p, results.Hits.Hits)
 
 	taskParams := Params{
 		From:    "0",
@@ -1824,7 +2418,7 @@ func (s *Server) FindUsers(p Params) (users []User, m meta, err error) {
 		SortDir: "asc",
 	}
 
-	tasks, m, err := s.FindTasks(taskParams)
+	tasks, _, err := s.FindTasks(taskParams)
 	for _, hit := range results.Hits.Hits {
 		var user User
 		rawMessage := hit.Source
@@ -1846,9 +2440,158 @@ func (s *Server) FindUsers(p Params) (users []User, m meta, err error) {
 	return
 }
 
+// searchUsers finds users in the current project whose Name or Email starts with p.Query, or whose ExternalId or Id matches it.
+// Any filters narrow it further.
+func (s *Server) searchUsers(p Params, filters ...string) (*SearchResult, error) {
+	queryJson, err := json.Marshal(p.Query)
+	if err != nil {
+		return nil, err
+	}
+	sorts, err := s.sortJson(p, userCountSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"bool": {
+				"must": [ %s ],
+				"should": [
+					{ "match_phrase_prefix": { "Name": %s } },
+					{ "match_phrase_prefix": { "Email": %s } },
+					{ "prefix": { "ExternalId": %s } },
+					{ "term": { "Id": %s } }
+				],
+				"minimum_should_match": 1
+			}
+		},
+		"from": %s,
+		"size": %s,
+		"sort": [ %s ]
+	}`, strings.Join(append([]string{fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId)}, filters...), ", "), queryJson, queryJson, queryJson, queryJson, p.From, p.Size, sorts)
+
+	return s.Store.Search("users", searchJson)
+}
+
+// assetCountSorts and userCountSorts map sortBy values for calculated counts onto the stored Counts fields behind them.
+var assetCountSorts = map[string]string{
+	"assignments": "Counts.Assignments",
+	"favorites":   "Counts.Favorites",
+	"finished":    "Counts.finished",
+	"skipped":     "Counts.skipped",
+	"unfinished":  "Counts.unfinished",
+	"verified":    "Counts.verified",
+}
+var userCountSorts = map[string]string{
+	"assignments":    "Counts.Assignments",
+	"favorites":      "Counts.Favorites",
+	"verifiedassets": "Counts.VerifiedAssets",
+	"trust":          "Trust.Score",
+}
+
+// sortFieldPattern is what a field in SortBy has to look like before it's put in a query, ex: Name or Metadata.issueDate.
+var sortFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)*$`)
+
+// sortJson returns the elasticsearch sort clauses for p, translating calculated counts into their stored fields.
+// SortBy can list several fields, each with its own direction, ex: "Metadata.issueDate:desc,Name:asc"; fields without
+// one go in p's SortDir. Metadata fields must be among the project's MetaProperties. Documents without a count or
+// metadata field sort last either way.
+func (s *Server) sortJson(p Params, countSorts map[string]string) (string, error) {
+	defaultDir := "asc"
+	if p.SortDir == "desc" {
+		defaultDir = "desc"
+	}
+
+	var metaProperties map[string]bool
+	var clauses []string
+	for _, spec := range strings.Split(p.SortBy, ",") {
+		field, sortDir := strings.TrimSpace(spec), defaultDir
+		if i := strings.LastIndex(field, ":"); i >= 0 {
+			field, sortDir = field[:i], strings.ToLower(field[i+1:])
+			if sortDir != "asc" && sortDir != "desc" {
+				invalid := &ValidationError{}
+				invalid.add("sortBy", "has to sort by asc or desc, not %q", spec)
+				return "", invalid
+			}
+		}
+
+		if countField, ok := countSorts[strings.ToLower(field)]; ok {
+			clauses = append(clauses, fmt.Sprintf(`{ "%s": { "order": "%s", "missing": "_last", "ignore_unmapped": true } }`, countField, sortDir))
+			continue
+		}
+		if !sortFieldPattern.MatchString(field) {
+			invalid := &ValidationError{}
+			invalid.add("sortBy", "can't sort by %q", field)
+			return "", invalid
+		}
+		if strings.HasPrefix(field, "Metadata.") {
+			if metaProperties == nil {
+				var project *Project
+				err := s.Store.Get("projects", s.ActiveProjectId, &project)
+				if err != nil {
+					return "", err
+				}
+				metaProperties = make(map[string]bool)
+				for _, metaProp := range project.MetaProperties {
+					metaProperties[metaProp.Name] = true
+				}
+			}
+			if !metaProperties[strings.TrimPrefix(field, "Metadata.")] {
+				invalid := &ValidationError{}
+				invalid.add("sortBy", "can only sort by the project's MetaProperties, not %q", strings.TrimPrefix(field, "Metadata."))
+				return "", invalid
+			}
+			clauses = append(clauses, fmt.Sprintf(`{ "%s": { "order": "%s", "missing": "_last", "ignore_unmapped": true } }`, field, sortDir))
+			continue
+		}
+		clauses = append(clauses, fmt.Sprintf(`{ "%s": { "order": "%s" } }`, field, sortDir))
+	}
+	return strings.Join(clauses, ", "), nil
+}
+
+// findProjectDocs pages through the current project's documents of docType, which may be ordered by one of their calculated counts.
+// When dateField is given, p's Since and Until bound it, and any extraFilters narrow it further.
+func (s *Server) findProjectDocs(docType string, dateField string, p Params, countSorts map[string]string, extraFilters ...string) (*SearchResult, error) {
+	filters := append([]string{fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId)}, extraFilters...)
+	if dateField != "" {
+		dateRange, err := dateRangeJson(dateField, p)
+		if err != nil {
+			return nil, err
+		}
+		if dateRange != "" {
+			filters = append(filters, dateRange)
+		}
+	}
+
+	sorts, err := s.sortJson(p, countSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [ %s ]
+					}
+				}
+			}
+		},
+		"from": %s,
+		"size": %s,
+		"sort": [ %s ]
+	}`, strings.Join(filters, ", "), p.From, p.Size, sorts)
+
+	return s.Store.Search(docType, searchJson)
+}
+
 // FindAsset looks up an asset by id.
 func (s *Server) FindAsset(id string) (asset *Asset, err error) {
-	err = s.EsConn.GetSource(s.Index, "assets", id, nil, &asset)
+	err = s.Store.Get("assets", id, &asset)
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an asset with the id %q.", id)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1863,24 +2606,68 @@ type Params struct {
 	Task     string
 	State    string
 	Verified string
+	Query    string          // optional search text, ex: the start of a user's name or email
+	Since    string          // optional, only return documents dated at or after this RFC 3339 time or date (ex: "2015-06-01")
+	Until    string          // optional, only return documents dated before this time, or on or before this date
+	Meta     []MetaCondition // optional, only return assets whose Metadata meets every condition
+	Cursor   string          // optional, return the page after the one whose Meta.Cursor this is, see cursorFilter
+}
+
+// dateRangeJson returns an elasticsearch range filter on field for p's Since and Until, or an empty string when neither is set.
+// A bare date for Until covers that whole day, so since=2015-06-01&until=2015-06-01 is everything on June 1st.
+func dateRangeJson(field string, p Params) (string, error) {
+	bounds := []string{}
+	if p.Since != "" {
+		since, _, err := parseDateParam(p.Since)
+		if err != nil {
+			return "", err
+		}
+		bounds = append(bounds, fmt.Sprintf(`"gte": "%s"`, since.Format(time.RFC3339Nano)))
+	}
+	if p.Until != "" {
+		until, dateOnly, err := parseDateParam(p.Until)
+		if err != nil {
+			return "", err
+		}
+		if dateOnly {
+			until = until.AddDate(0, 0, 1)
+		}
+		bounds = append(bounds, fmt.Sprintf(`"lt": "%s"`, until.Format(time.RFC3339Nano)))
+	}
+	if len(bounds) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf(`{ "range": { "%s": { %s } } }`, field, strings.Join(bounds, ", ")), nil
+}
+
+// parseDateParam reads an RFC 3339 time or a bare date in UTC, reporting which one it was.
+func parseDateParam(value string) (t time.Time, dateOnly bool, err error) {
+	t, err = time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t.UTC(), false, nil
+	}
+	t, err = time.Parse("2006-01-02", value)
+	if err == nil {
+		return t, true, nil
+	}
+	return t, false, fmt.Errorf("Sorry, %q isn't a date. Use a date like 2015-06-01 or a time like 2015-06-01T15:04:05Z.", value)
 }
 
 // FindAssets returns an array of assets in the current project, along with pagination meta information.
 // 'from' and 'size' parameters determine the offset and limit passed to the database.
 func (s *Server) FindAssets(p Params) (assets []Asset, m meta, err error) {
-	query := elastigo.Search(s.Index).Type("assets").Filter(
-		elastigo.Filter().Terms("Project", s.ActiveProjectId),
-	).From(p.From).Size(p.Size)
-	if p.SortDir == "desc" {
-		query = query.Sort(
-			elastigo.Sort(p.SortBy).Desc(),
-		)
-	} else {
-		query = query.Sort(
-			elastigo.Sort(p.SortBy).Asc(),
-		)
+	filters, err := s.metaFilters(p.Meta)
+	if err != nil {
+		return
+	}
+	cursor, err := cursorFilter(&p)
+	if err != nil {
+		return
 	}
-	results, err := query.Result(&s.EsConn)
+	if cursor != "" {
+		filters = append(filters, cursor)
+	}
+	results, err := s.findProjectDocs("assets", "Created", p, assetCountSorts, filters...)
 
 	if err != nil {
 		return
@@ -1891,6 +2678,7 @@ func (s *Server) FindAssets(p Params) (assets []Asset, m meta, err error) {
 	m.Total = resultCount
 	m.From, _ = strconv.Atoi(p.From)
 	m.Size, _ = strconv.Atoi(p.Size)
+	m.Cursor = nextCursor(p, results.Hits.Hits)
 
 	for _, hit := range results.Hits.Hits {
 		var asset Asset
@@ -1901,7 +2689,7 @@ func (s *Server) FindAssets(p Params) (assets []Asset, m meta, err error) {
 		}
 		/*
 			// use this when reindexing assets
-					_, err = s.EsConn.Index(s.Index, "assets", asset.Id, nil, asset)
+					_, err = s.Store.Put("assets", asset.Id, asset)
 					if err != nil {
 						return
 					}
@@ -1919,7 +2707,7 @@ func (s *Server) FindAssets(p Params) (assets []Asset, m meta, err error) {
 	}
 	/*
 		// use this when reindexing assets
-		_, err = s.EsConn.Refresh(s.Index)
+		err = s.Store.Refresh()
 		if err != nil {
 			return
 		}
@@ -1930,7 +2718,7 @@ func (s *Server) FindAssets(p Params) (assets []Asset, m meta, err error) {
 // FindAssignments returns an array of assignments in the current project, given task and state, along with pagination meta information.
 // 'from' and 'size' parameters determine the offset and limit passed to the database.
 func (s *Server) FindAssignments(p Params) (assignments []Assignment, m meta, err error) {
-	_, err = s.EsConn.Refresh(s.Index)
+	err = s.Store.Refresh()
 	if err != nil {
 		return
 	}
@@ -1950,6 +2738,22 @@ func (s *Server) FindAssignments(p Params) (assignments []Assignment, m meta, er
 		musts = append(musts, fmt.Sprintf(` { "query": { "match": { "State": "%s" } } }`, p.State))
 	}
 
+	// assignments are dated by when they were last submitted, skipped or changed
+	dateRange, err := dateRangeJson("Updated", p)
+	if err != nil {
+		return
+	}
+	if dateRange != "" {
+		musts = append(musts, dateRange)
+	}
+	cursor, err := cursorFilter(&p)
+	if err != nil {
+		return
+	}
+	if cursor != "" {
+		musts = append(musts, cursor)
+	}
+
 	searchQuery := `{
 		"query": {
 			"filtered": {
@@ -1962,11 +2766,15 @@ func (s *Server) FindAssignments(p Params) (assignments []Assignment, m meta, er
 		},
 		"from": %s,
 		"size": %s,
-		"sort": [ { "%s": { "order" : "%s" } } ]
+		"sort": [ %s ]
 	}`
 
-	searchJson := fmt.Sprintf(searchQuery, strings.Join(musts, ", "), p.From, p.Size, p.SortBy, p.SortDir)
-	results, err := s.EsConn.Search(s.Index, "assignments", nil, searchJson)
+	sorts, err := s.sortJson(p, nil)
+	if err != nil {
+		return
+	}
+	searchJson := fmt.Sprintf(searchQuery, strings.Join(musts, ", "), p.From, p.Size, sorts)
+	results, err := s.Store.Search("assignments", searchJson)
 	if err != nil {
 		return
 	}
@@ -1974,6 +2782,7 @@ func (s *Server) FindAssignments(p Params) (assignments []Assignment, m meta, er
 	m.Total = results.Hits.Total
 	m.From, _ = strconv.Atoi(p.From)
 	m.Size, _ = strconv.Atoi(p.Size)
+	m.Cursor = nextCursor(p, results.Hits.Hits)
 
 	for _, hit := range results.Hits.Hits {
 		var assignment Assignment
@@ -2015,6 +2824,25 @@ func (s *Server) FindAssetsWithDataForTask(p Params) (assets []Asset, m meta, er
 			exists = append(exists, fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, t.Name))
 		}
 	}
+	dateRange, err := dateRangeJson("Created", p)
+	if err != nil {
+		return
+	}
+	if dateRange != "" {
+		exists = append(exists, dateRange)
+	}
+	metaFilters, err := s.metaFilters(p.Meta)
+	if err != nil {
+		return
+	}
+	exists = append(exists, metaFilters...)
+	cursor, err := cursorFilter(&p)
+	if err != nil {
+		return
+	}
+	if cursor != "" {
+		exists = append(exists, cursor)
+	}
 	searchQuery := `{
 		"query": {
 			"filtered": {
@@ -2027,12 +2855,15 @@ func (s *Server) FindAssetsWithDataForTask(p Params) (assets []Asset, m meta, er
 		},
 		"from": %s,
 		"size": %s,
-		"sort": [ { "%s": { "order" : "%s" } } ]
+		"sort": [ %s ]
 	}`
 
-	searchJson := fmt.Sprintf(searchQuery, strings.Join(exists, ", "), p.From, p.Size, p.SortBy, p.SortDir)
-	log.Println(searchJson)
-	results, err := s.EsConn.Search(s.Index, "assets", nil, searchJson)
+	sorts, err := s.sortJson(p, assetCountSorts)
+	if err != nil {
+		return
+	}
+	searchJson := fmt.Sprintf(searchQuery, strings.Join(exists, ", "), p.From, p.Size, sorts)
+	results, err := s.Store.Search("assets", searchJson)
 	if err != nil {
 		return
 	}
@@ -2040,6 +2871,7 @@ func (s *Server) FindAssetsWithDataForTask(p Params) (assets []Asset, m meta, er
 	m.Total = results.Hits.Total
 	m.From, _ = strconv.Atoi(p.From)
 	m.Size, _ = strconv.Atoi(p.Size)
+	m.Cursor = nextCursor(p, results.Hits.Hits)
 
 	for _, hit := range results.Hits.Hits {
 		var asset Asset
@@ -2053,29 +2885,25 @@ func (s *Server) FindAssetsWithDataForTask(p Params) (assets []Asset, m meta, er
 	return
 }
 
-// FindAssignmentAsset returns an eligible asset for a given task and user, basing this on AssignmentCriteria.
-// It is called from CreateAssignment.
-func (s *Server) FindAssignmentAsset(task Task, user User) (Asset, error) {
-	var assignmentAsset Asset
-	var assetIds []string
-
+// assignedAssetIds returns the ids of assets the user has already been assigned for a task, in any state.
+func (s *Server) assignedAssetIds(task Task, user User) (assetIds []string, err error) {
 	assetQuery := fmt.Sprintf(`{
   "query": {
     "bool": {
       "must": [
         {
           "term": {
-            "assignments.Task": "%s"
+            "Task": "%s"
           }
 				},
         {
           "term": {
-            "assignments.User": "%s"
+            "User": "%s"
           }
 				},
 				{
 					"term": {
-						"assignments.Project": "%s"
+						"Project": "%s"
 					}
 				}
 				]
@@ -2084,47 +2912,42 @@ func (s *Server) FindAssignmentAsset(task Task, user User) (Asset, error) {
 		"from": 0,
 		"size": %d
 	}`, task.Id, user.Id, s.ActiveProjectId, user.Counts["Assignments"])
-	assetResults, err := s.EsConn.Search(s.Index, "assignments", nil, assetQuery)
+	assetResults, err := s.Store.Search("assignments", assetQuery)
 	if err != nil {
-		return assignmentAsset, err
+		return nil, err
 	}
 	for _, hit := range assetResults.Hits.Hits {
 		idParts := strings.Split(hit.Id, "HIVE")
 		assetIds = append(assetIds, idParts[2])
 	}
+	return assetIds, nil
+}
 
-	// the parts of a 'bool' query - so far no need for 'should'
-	musts := []string{}
-	mustNots := []string{}
+// FindAssignmentAsset returns an eligible asset for a given task and user, basing this on AssignmentCriteria.
+// It is called from CreateAssignment.
+func (s *Server) FindAssignmentAsset(task Task, user User) (Asset, error) {
+	var assignmentAsset Asset
 
-	// build up the pieces of the full elasticsearch query
-	for taskName, ruleI := range task.AssignmentCriteria.SubmittedData {
-		rule := ruleI.(map[string]interface{})
+	assetIds, err := s.assignedAssetIds(task, user)
+	if err != nil {
+		return assignmentAsset, err
+	}
 
-		// an empty rule means assets should have no data submitted for this task
-		if len(rule) == 0 {
-			tmpl := `{
-				"missing": {
-					"field": "SubmittedData.%s"
-				}
-			}`
+	task, err = s.liveCriteria(task)
+	if err != nil {
+		return assignmentAsset, err
+	}
 
-			musts = append(musts, fmt.Sprintf(tmpl, task.Name))
+	// the parts of a 'bool' query - so far no need for 'should'
+	musts := criteriaMusts(task)
+	mustNots := []string{}
 
-			// assets must have data submitted that exactly matches the rule
-		} else {
-			for fieldName, fieldValue := range rule {
-				tmpl := `{
-					"query": {
-						"match": {
-							"SubmittedData.%s.%s": "%s"
-						}
-					}
-				}`
-				musts = append(musts, fmt.Sprintf(tmpl, taskName, fieldName, fieldValue))
-			}
-		}
+	// assets wait until they're verified for the tasks that lead to this one
+	workflowMusts, err := s.workflowMusts(task)
+	if err != nil {
+		return assignmentAsset, err
 	}
+	musts = append(musts, workflowMusts...)
 
 	// limit query results to assets in this project
 	projectTmpl := `{
@@ -2136,54 +2959,290 @@ func (s *Server) FindAssignmentAsset(task Task, user User) (Asset, error) {
 	}`
 	musts = append(musts, fmt.Sprintf(projectTmpl, s.ActiveProjectId))
 
+	// excluded assets are out of circulation
+	mustNots = append(mustNots, `{ "term": { "Excluded": true } }`)
+
 	if len(assetIds) > 0 {
 		assetTmpl := `{ "query": { "terms": { "Id": [ %s ] } } }`
 		assetIdString := "\"" + strings.Join(assetIds, "\",\"") + "\""
 		mustNots = append(mustNots, fmt.Sprintf(assetTmpl, assetIdString))
 	}
 
+	// mix gold standard assets into the user's stream at the task's GoldPercent, falling back to any eligible asset
+	if task.GoldPercent > 0 {
+		goldMusts, goldMustNots := goldFilters(task, musts, mustNots)
+		goldAsset, err := s.searchAssignmentAsset(goldMusts, goldMustNots, user)
+		if err == nil {
+			return goldAsset, nil
+		}
+	}
+	return s.searchAssignmentAsset(musts, mustNots, user)
+}
+
+// searchAssignmentAsset picks one of the assets matching every must and none of the mustNots for the user,
+// preferring the user's languages and then the highest priority.
+func (s *Server) searchAssignmentAsset(musts []string, mustNots []string, user User) (Asset, error) {
+	var assignmentAsset Asset
+
 	mustsJson := strings.Join(musts, ", ")
 	mustNotsJson := strings.Join(mustNots, ", ")
 
-	var args map[string]interface{}
 	matchAllQuery := `{ "query": { "match_all" : { } } }`
-	countResponse, err := s.EsConn.Count(s.Index, "assets", args, matchAllQuery)
+	count, err := s.Store.Count("assets", matchAllQuery)
+	if err != nil {
+		return assignmentAsset, err
+	}
+
+	// finally, compose the entire filtered query, highest priority first
+	searchTmpl := `{"query":{"filtered":{"filter":{"bool":{"must":[%s],"must_not":[%s]}}}},"from":0,"size":%d,"sort":` + prioritySort + `}`
+
+	// prefer assets in one of the user's languages, falling back to any eligible asset
+	if len(user.Languages) > 0 {
+		languageTmpl := `{ "terms": { "Language": [ %s ] } }`
+		languageString := "\"" + strings.Join(user.Languages, "\",\"") + "\""
+		languageMusts := append([]string{fmt.Sprintf(languageTmpl, languageString)}, musts...)
+
+		languageQuery := fmt.Sprintf(searchTmpl, strings.Join(languageMusts, ", "), mustNotsJson, count)
+		languageResults, err := s.Store.Search("assets", languageQuery)
+		if err == nil && len(languageResults.Hits.Hits) > 0 {
+			return randomTopPriority(languageResults.Hits.Hits)
+		}
+	}
+
+	searchQuery := fmt.Sprintf(searchTmpl, mustsJson, mustNotsJson, count)
+
+	results, err := s.Store.Search("assets", searchQuery)
 	if err != nil {
 		return assignmentAsset, err
 	}
 
-	// finally, compose the entire filtered query
-	searchQuery := fmt.Sprintf(
-		`{"query":{"filtered":{"filter":{"bool":{"must":[%s],"must_not":[%s]}}}},"from":0,"size":%d}`, mustsJson, mustNotsJson, countResponse.Count)
-
-	results, err := s.EsConn.Search(s.Index, "assets", nil, searchQuery)
+	if results.Hits.Total <= 0 {
+		err = errors.New("No assets found")
+		return assignmentAsset, err
+	}
+	return randomTopPriority(results.Hits.Hits)
+}
+
+// FindAssignment looks up an assignment by id.
+func (s *Server) FindAssignment(id string) (assignment *Assignment, err error) {
+
+	err = s.Store.Get("assignments", id, &assignment)
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an assignment with the id %q.", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// FindUserAssignments returns every assignment belonging to a user in the current project.
+func (s *Server) FindUserAssignments(userId string) (assignments []Assignment, err error) {
+	userQuery := fmt.Sprintf(`{ "query": { "bool": { "must": [ { "term": { "User": "%s" } }, { "term": { "Project": "%s" } } ] } } }`, userId, s.ActiveProjectId)
+
+	count, err := s.Store.Count("assignments", userQuery)
+	if err != nil {
+		return
+	}
+	if count == 0 {
+		return
+	}
+
+	searchJson := fmt.Sprintf(`{ "query": { "bool": { "must": [ { "term": { "User": "%s" } }, { "term": { "Project": "%s" } } ] } }, "from": 0, "size": %d }`, userId, s.ActiveProjectId, count)
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return
+	}
+	for _, hit := range results.Hits.Hits {
+		var assignment Assignment
+		err = json.Unmarshal(*hit.Source, &assignment)
+		if err != nil {
+			return
+		}
+		assignments = append(assignments, assignment)
+	}
+	return
+}
+
+// tallyUserCounts recomputes a user's contribution counts from their assignments and favorites.
+func tallyUserCounts(user *User, assignments []Assignment, tasks []Task) {
+	tally := userTally{Tasks: Counts{}}
+	for _, assignment := range assignments {
+		if assignment.State != "finished" && assignment.State != "verified" {
+			continue
+		}
+		tally.Assignments++
+		tally.Tasks[assignment.Task]++
+		if assignment.State == "verified" {
+			tally.VerifiedAssets = appendIfMissing(tally.VerifiedAssets, assignment.Asset.Id)
+		}
+	}
+	applyUserTally(user, tally, tasks)
+}
+
+// userTally is what a user's finished and verified assignments add up to.
+type userTally struct {
+	Assignments    int
+	Tasks          Counts   // assignments by task id
+	VerifiedAssets []string // assets the user has a verified assignment for
+}
+
+// applyUserTally sets a user's Counts and VerifiedAssets from a tally of their assignments, with a count of 0
+// for each of tasks they haven't done.
+func applyUserTally(user *User, tally userTally, tasks []Task) {
+	user.Counts = Counts{
+		"Favorites":      len(user.Favorites),
+		"Assignments":    tally.Assignments,
+		"VerifiedAssets": len(tally.VerifiedAssets),
+	}
+	for _, task := range tasks {
+		user.Counts[task.Id] = 0
+	}
+	for taskId, count := range tally.Tasks {
+		user.Counts[taskId] = count
+	}
+	user.VerifiedAssets = append([]string{}, tally.VerifiedAssets...)
+}
+
+// MergeUsers folds the source user into the target user: assignments are reassigned, favorites combined
+// and counts recomputed, then the source user is deleted. When both users worked on the same asset for
+// the same task, the target's assignment is kept. Anything else the target hasn't set, ex: a Name or Email
+// for a user who only had a cookie before signing up, is taken from the source, and the source's place in
+// an identity goes to the target.
+func (s *Server) MergeUsers(sourceId string, targetId string) (*User, error) {
+	invalid := &ValidationError{}
+	if sourceId == "" {
+		invalid.add("Source", "is required")
+	}
+	if targetId == "" {
+		invalid.add("Target", "is required")
+	}
+	if sourceId != "" && sourceId == targetId {
+		invalid.add("Source", "has to be a different user than the target")
+	}
+	if err := invalid.orNil(); err != nil {
+		return nil, err
+	}
+
+	source, err := s.FindUser(sourceId)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.FindUser(targetId)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil || target == nil || source.Project != s.ActiveProjectId || target.Project != s.ActiveProjectId {
+		return nil, notFound("Sorry, there aren't users with both of those ids to merge in this project.")
+	}
+
+	sourceAssignments, err := s.FindUserAssignments(source.Id)
 	if err != nil {
-		return assignmentAsset, err
+		return nil, err
 	}
 
-	if results.Hits.Total <= 0 {
-		err = errors.New("No assets found")
-		return assignmentAsset, err
+	// assets whose duplicate assignments were dropped need their counts recalculated
+	var recountAssetIds []string
 
-	} else {
-		randomHit := rand.Intn(len(results.Hits.Hits))
-		rawMessage := results.Hits.Hits[randomHit].Source
-		err = json.Unmarshal(*rawMessage, &assignmentAsset)
+	for _, assignment := range sourceAssignments {
+		oldId := assignment.Id
+		assignment.User = target.Id
+		assignment.Id = strings.Join([]string{assignment.Project, assignment.Task, assignment.Asset.Id, target.Id}, "HIVE")
+
+		targetHasIt, _ := s.Store.Exists("assignments", assignment.Id)
+		if targetHasIt {
+			recountAssetIds = appendIfMissing(recountAssetIds, assignment.Asset.Id)
+		} else {
+			_, err = s.Store.Put("assignments", assignment.Id, assignment)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.Store.Delete("assignments", oldId)
 		if err != nil {
-			return assignmentAsset, err
+			return nil, err
 		}
 	}
-	return assignmentAsset, nil
-}
 
-// FindAssignment looks up an assignment by id.
-func (s *Server) FindAssignment(id string) (assignment *Assignment, err error) {
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assetId := range recountAssetIds {
+		asset, err := s.FindAsset(assetId)
+		if err != nil {
+			s.logError("failed recounting asset", err, logFields{"asset": assetId})
+			continue
+		}
+		_, err = s.CalculateAssetCounts(*asset)
+		if err != nil {
+			s.logError("failed recounting asset", err, logFields{"asset": assetId})
+		}
+	}
 
-	err = s.EsConn.GetSource(s.Index, "assignments", id, nil, &assignment)
+	targetAssignments, err := s.FindUserAssignments(target.Id)
 	if err != nil {
 		return nil, err
 	}
-	return assignment, nil
+	p := Params{
+		From:    "0",
+		Size:    "10",
+		SortBy:  "Name",
+		SortDir: "asc",
+	}
+	tasks, _, _ := s.FindTasks(p)
+	target, err = s.updateUser(target.Id, func(target *User) error {
+		if len(target.Favorites) <= 0 {
+			target.Favorites = userFavorites{}
+		}
+		for key, value := range source.Favorites {
+			target.Favorites[key] = value
+		}
+		mergeUserProfile(target, *source)
+		tallyUserCounts(target, targetAssignments, tasks)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Delete("users", source.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.relinkIdentity(source.Id, target.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	s.logEvent("merged users", logFields{"source": source.Id, "target": target.Id, "assignments": len(sourceAssignments)})
+	return target, nil
+}
+
+// mergeUserProfile fills in what target hasn't set from source, ex: a cookie user's languages for the account
+// they signed up for. Lists are combined. The target keeps its own Role, so merging a record in can't raise it.
+func mergeUserProfile(target *User, source User) {
+	if target.ExternalId == "" {
+		target.ExternalId = source.ExternalId
+	}
+	if target.Name == "" {
+		target.Name = source.Name
+	}
+	if target.Email == "" {
+		target.Email = source.Email
+	}
+	if target.ConsentVersion == "" {
+		target.ConsentVersion = source.ConsentVersion
+	}
+	for _, language := range source.Languages {
+		target.Languages = appendIfMissing(target.Languages, language)
+	}
+	for _, step := range source.OnboardingSteps {
+		target.OnboardingSteps = appendIfMissing(target.OnboardingSteps, step)
+	}
 }
 
 func (s *Server) RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -2214,7 +3273,7 @@ func (s *Server) AdminProjectsHandler(w http.ResponseWriter, r *http.Request) {
 
 	projects, m, err := s.FindProjects(p)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2225,7 +3284,7 @@ func (s *Server) AdminProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	projectsJson, err := json.Marshal(resp)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, projectsJson)
@@ -2241,14 +3300,14 @@ func (s *Server) AdminProjectsHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /admin/projects/{project_id} [get]
 func (s *Server) AdminProjectHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	var project *Project
 	var err error
 
 	project, err = s.FindProject(s.ActiveProjectId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	// format the json response
@@ -2258,7 +3317,7 @@ func (s *Server) AdminProjectHandler(w http.ResponseWriter, r *http.Request) {
 	projectJson, err := json.Marshal(resp)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, projectJson)
@@ -2269,19 +3328,20 @@ func (s *Server) AdminProjectHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Param   project_id        path   string     true        "Project ID"
 // @Success 200 {object}  projectResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
 // @Failure 500 {object} error	appropriate error message
 // @Resource /projects
 // @Router /admin/projects/{project_id} [post]
 func (s *Server) AdminCreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	var project *Project
 	var err error
 
 	project, err = s.CreateProject(r.Body)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2292,7 +3352,7 @@ func (s *Server) AdminCreateProjectHandler(w http.ResponseWriter, r *http.Reques
 	projectJson, err := json.Marshal(resp)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, projectJson)
@@ -2302,33 +3362,47 @@ func (s *Server) AdminCreateProjectHandler(w http.ResponseWriter, r *http.Reques
 // @Description returns a project by ID
 // @Accept  json
 // @Param   project_id        path   string     true        "Project ID"
+// @Param   If-None-Match        header   string     false        "The ETag of a copy already held, to get a 304 with no body when it hasn't changed"
 // @Success 200 {object}  projectResponse
+// @Success 304 {object} string	unchanged since the ETag in If-None-Match
+// @Failure 404 {object} errorResponse	there isn't one with that id in the project
 // @Failure 500 {object} error	appropriate error message
 // @Resource /projects
 // @Router /projects/{project_id} [get]
 func (s *Server) ProjectHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	var project *Project
 	var err error
 
 	project, err = s.FindProject(vars["project_id"])
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
+
+	announcement, err := s.ActiveAnnouncement()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	// contributors can see onboarding steps, but not quiz answers
+	project.Onboarding = publicOnboarding(project.Onboarding)
+
 	// format the json response
 	resp := projectResponse{
-		Project: *project,
+		Project:      *project,
+		Announcement: announcement,
 	}
 	projectJson, err := json.Marshal(resp)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	s.wrapResponse(w, r, 200, projectJson)
+	s.wrapCachedResponse(w, r, projectJson)
 }
 
 // @Title AssetHandler
@@ -2336,18 +3410,29 @@ func (s *Server) ProjectHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   asset_id        path   string     true        "Asset ID"
+// @Param   If-None-Match        header   string     false        "The ETag of a copy already held, to get a 304 with no body when it hasn't changed"
 // @Success 200 {object} assetResponse
+// @Success 304 {object} string	unchanged since the ETag in If-None-Match
+// @Failure 404 {object} errorResponse	there isn't one with that id in the project
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assets
 // @Router /projects/{project_id}/assets/{asset_id} [get]
 func (s *Server) AssetHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
 	assetId := vars["asset_id"]
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	asset, err := s.FindAsset(assetId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
+		return
+	}
+	// gold answers stay private to admins
+	asset.GoldData = nil
+
+	err = s.signAssetUrl(asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2358,10 +3443,10 @@ func (s *Server) AssetHandler(w http.ResponseWriter, r *http.Request) {
 	assetJson, err := json.Marshal(resp)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	s.wrapResponse(w, r, 200, assetJson)
+	s.wrapCachedResponse(w, r, assetJson)
 }
 
 // @Title AdminTaskHandler
@@ -2375,7 +3460,7 @@ func (s *Server) AssetHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /admin/projects/{project_id}/tasks/{task_id} [get]
 func (s *Server) AdminTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	taskId := vars["task_id"]
 	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
@@ -2384,7 +3469,7 @@ func (s *Server) AdminTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.FindTask(taskId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2392,7 +3477,7 @@ func (s *Server) AdminTaskHandler(w http.ResponseWriter, r *http.Request) {
 		Task: *task,
 	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, taskJson)
@@ -2404,23 +3489,24 @@ func (s *Server) AdminTaskHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   task_id        path   string     true        "Task ID"
 // @Success 200 {object} taskResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
 // @Failure 500 {object} error	appropriate error message
 // @Resource /tasks
-// @Router /projects/{project_id}/tasks/{task_id} [get]
+// @Router /admin/projects/{project_id}/tasks/{task_id} [post]
 func (s *Server) AdminCreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	task, err := s.CreateTask(r.Body)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	taskJson, err := json.Marshal(taskResponse{
 		Task: *task,
 	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, taskJson)
@@ -2431,13 +3517,16 @@ func (s *Server) AdminCreateTaskHandler(w http.ResponseWriter, r *http.Request)
 // @Accept  json
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   task_id        path   string     true        "Task ID"
+// @Param   If-None-Match        header   string     false        "The ETag of a copy already held, to get a 304 with no body when it hasn't changed"
 // @Success 200 {object} taskResponse
+// @Success 304 {object} string	unchanged since the ETag in If-None-Match
+// @Failure 404 {object} errorResponse	there isn't one with that id in the project
 // @Failure 500 {object} error	appropriate error message
 // @Resource /tasks
 // @Router /projects/{project_id}/tasks/{task_id} [get]
 func (s *Server) TaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	taskId := vars["task_id"]
 	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
@@ -2446,7 +3535,7 @@ func (s *Server) TaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.FindTask(taskId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2454,10 +3543,10 @@ func (s *Server) TaskHandler(w http.ResponseWriter, r *http.Request) {
 		Task: *task,
 	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	s.wrapResponse(w, r, 200, taskJson)
+	s.wrapCachedResponse(w, r, taskJson)
 }
 
 // @Title AssignmentHandler
@@ -2471,23 +3560,36 @@ func (s *Server) TaskHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /projects/{project_id}/assignments/{assignment_id} [get]
 func (s *Server) AssignmentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	assignmentId := vars["assignment_id"]
 
 	assignment, err := s.FindAssignment(assignmentId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.signAssetUrl(&assignment.Asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcement, err := s.ActiveAnnouncement()
+	if err != nil {
+		s.wrapFailure(w, r, err)
 		return
 	}
 
 	// format the json response
 	resp := assignmentResponse{
-		Assignment: *assignment,
+		Assignment:   *assignment,
+		Announcement: announcement,
 	}
 	assignmentJson, err := json.Marshal(resp)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assignmentJson)
@@ -2526,7 +3628,11 @@ func (s *Server) CreateUser(requestBody io.Reader) (user *User, err error) {
 	}
 
 	user.Project = s.ActiveProjectId
+	user.Role = "" // roles are only given out by owners, see AdminUserRoleHandler
+	user.Quality = Quality{}
+	user.Trust = nil
 	user.Favorites = userFavorites{}
+	user.Languages = normalizeLanguages(user.Languages)
 
 	user.Counts = Counts{
 		"Favorites":      0,
@@ -2548,18 +3654,40 @@ func (s *Server) CreateUser(requestBody io.Reader) (user *User, err error) {
 		}
 	}
 
+	// with signed sessions, choosing an existing user's id would be a way to sign in as them, so a chosen id is only
+	// saved if nobody has it yet, checked by the store as it saves so two requests can't both take it
+	if user.Id != "" && s.signedSessions() {
+		versioner, versioned := s.Store.(Versioner)
+		if versioned {
+			err = versioner.PutVersioned("users", user.Id, user, "")
+		} else {
+			var exists bool
+			exists, err = s.Store.Exists("users", user.Id)
+			if err == nil && exists {
+				err = ErrConflict
+			}
+			if err == nil {
+				_, err = s.Store.Put("users", user.Id, user)
+			}
+		}
+		if err == ErrConflict {
+			return nil, conflict("Sorry, that user id is already taken.")
+		}
+		return user, err
+	}
+
 	// store user in elasticsearch
 	// if user.Id is blank, es will generate a new one
 	// if user.Id is NOT blank, es will store the user with that id
-	result, err := s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+	resultId, err := s.Store.Put("users", user.Id, user)
 	if err != nil {
 		return user, err
 	}
 
 	// if the user didn't have an autogenerated id, store it now
 	if len(user.Id) == 0 {
-		user.Id = result.Id
-		_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+		user.Id = resultId
+		_, err = s.Store.Put("users", user.Id, user)
 		if err != nil {
 			return user, err
 		}
@@ -2601,15 +3729,15 @@ func (s *Server) CreateUserFromMissingCookieValue(userId string) (User, error) {
 	// store user in elasticsearch
 	// if user.Id is blank, es will generate a new one
 	// if user.Id is NOT blank, es will store the user with that id
-	result, err := s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+	resultId, err := s.Store.Put("users", user.Id, user)
 	if err != nil {
 		return user, err
 	}
 
 	// if the user didn't have an autogenerated id, store it now
 	if len(user.Id) == 0 {
-		user.Id = result.Id
-		_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+		user.Id = resultId
+		_, err = s.Store.Put("users", user.Id, user)
 		if err != nil {
 			return user, err
 		}
@@ -2648,15 +3776,15 @@ func (s *Server) CreateExternalUser(externalId string) (User, error) {
 	// store user in elasticsearch
 	// if user.Id is blank, es will generate a new one
 	// if user.Id is NOT blank, es will store the user with that id
-	result, err := s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+	resultId, err := s.Store.Put("users", user.Id, user)
 	if err != nil {
 		return user, err
 	}
 
 	// if the user didn't have an autogenerated id, store it now
 	if len(user.Id) == 0 {
-		user.Id = result.Id
-		_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+		user.Id = resultId
+		_, err = s.Store.Put("users", user.Id, user)
 		if err != nil {
 			return user, err
 		}
@@ -2671,73 +3799,80 @@ func (s *Server) CreateExternalUser(externalId string) (User, error) {
 // @Param   asset_id        path   string     true        "Retrieve asset with given ID only"
 // @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
 // @Success 200 {object} favoriteResponse
+// @Failure 401 {object} errorResponse	there's no session user
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assets
 // @Router /projects/{project_id}/assets/{asset_id}/favorite [get]
 func (s *Server) FavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	// find the asset
 	asset, err := s.FindAsset(vars["asset_id"])
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
 	// find the user
-	sessionCookieName := s.ActiveProjectId + "_user_id"
-	userId := s.FindCookieValue(r, sessionCookieName)
+	userId := s.SessionUserId(r)
 	user, err := s.FindUser(userId)
 	if user == nil {
-		s.wrapResponse(w, r, 500, s.wrapError(errors.New("Favoriting assets requires a valid user.")))
+		s.wrapFailure(w, r, unauthorized("Sorry, favoriting assets requires a valid user."))
 		return
 	}
 
 	faveResponse := favoriteResponse{AssetId: asset.Id, Action: "favorited"}
 
-	if len(asset.Counts) <= 0 {
-		asset.Counts = Counts{
-			"Favorites":   0,
-			"Assignments": 0,
-			"finished":    0,
-			"skipped":     0,
-			"unfinished":  0,
+	_, err = s.updateUser(user.Id, func(user *User) error {
+		if len(user.Favorites) <= 0 {
+			user.Favorites = userFavorites{}
 		}
-	}
-	if len(user.Favorites) <= 0 {
-		user.Favorites = userFavorites{}
-	}
-	// is this asset in the user's favorites?
-	_, ok := user.Favorites[asset.Id]
-	if ok {
-		delete(user.Favorites, asset.Id)
-		faveResponse.Action = "unfavorited"
-		if asset.Counts["Favorites"] > 0 {
-			asset.Counts["Favorites"] -= 1
+		// is this asset in the user's favorites?
+		_, ok := user.Favorites[asset.Id]
+		if ok {
+			delete(user.Favorites, asset.Id)
+			faveResponse.Action = "unfavorited"
+		} else {
+			// add the asset to the user's favorites, keeping gold answers private
+			favorite := *asset
+			favorite.GoldData = nil
+			user.Favorites[asset.Id] = favorite
+			faveResponse.Action = "favorited"
 		}
-	} else {
-		// add the asset to the user's favorites
-		user.Favorites[asset.Id] = *asset
-		asset.Counts["Favorites"] += 1
-	}
-	user.Counts["Favorites"] = len(user.Favorites)
-
-	_, err = s.EsConn.Index(s.Index, "assets", asset.Id, nil, asset)
+		user.Counts["Favorites"] = len(user.Favorites)
+		return nil
+	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+	_, err = s.updateAsset(asset.Id, func(asset *Asset) error {
+		if len(asset.Counts) <= 0 {
+			asset.Counts = Counts{
+				"Favorites":   0,
+				"Assignments": 0,
+				"finished":    0,
+				"skipped":     0,
+				"unfinished":  0,
+			}
+		}
+		if faveResponse.Action == "favorited" {
+			asset.Counts["Favorites"] += 1
+		} else if asset.Counts["Favorites"] > 0 {
+			asset.Counts["Favorites"] -= 1
+		}
+		return nil
+	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	responseJson, err := json.Marshal(faveResponse)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2757,13 +3892,12 @@ func (s *Server) FavoriteHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /projects/{project_id}/user/favorites [get]
 func (s *Server) FavoritesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
-	sessionCookieName := s.ActiveProjectId + "_user_id"
-	userId := s.FindCookieValue(r, sessionCookieName)
+	userId := s.SessionUserId(r)
 	user, err := s.FindUser(userId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2788,7 +3922,7 @@ func (s *Server) FavoritesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	favoritesJson, err := json.Marshal(resp)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, favoritesJson)
@@ -2805,19 +3939,19 @@ func (s *Server) FavoritesHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /admin/projects/{project_id}/tasks/{task_id}/complete [get]
 func (s *Server) CompleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	taskId := vars["task_id"]
 
 	assets, err := s.CompleteTask(taskId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	assetsJson, err := json.Marshal(assetsResponse{
 		Assets: assets,
 	})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2835,18 +3969,15 @@ func (s *Server) CompleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /projects/{project_id}/user [get]
 func (s *Server) UserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
-
-	// user id is stored in a cookie named according to the project
-	sessionCookieName := s.ActiveProjectId + "_user_id"
+	s = s.forProject(vars["project_id"])
 
 	// look for project's user session cookie
-	userId := s.FindCookieValue(r, sessionCookieName)
+	userId := s.SessionUserId(r)
 
 	// try to find a matching user
 	user, err := s.FindUser(userId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2854,15 +3985,15 @@ func (s *Server) UserHandler(w http.ResponseWriter, r *http.Request) {
 	if user == nil {
 		tmpUser, err := s.CreateUserFromMissingCookieValue(userId)
 		if err != nil {
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
 		user = &tmpUser
 	}
 
-	userJson, err := json.Marshal(user)
+	userJson, err := marshalUser(r, user)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, userJson)
@@ -2873,21 +4004,216 @@ func (s *Server) UserHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   project_id     path    string     true        "Project ID"
 // @Param   userdata        body   string     true        "JSON-formatted user data"
 // @Success 200 {object}  User
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
 // @Failure 500 {object} error	appropriate error message
 // @Resource /users
 // @Router /projects/{project_id}/user [post]
 func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	user, err := s.CreateUser(r.Body)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	// start a session for the new user, so later requests are made as them
+	s.SetSessionCookie(w, user.Id)
+
+	userJson, err := marshalUser(r, user)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, userJson)
+}
+
+// requireConsent wraps handlers that accept contributions, rejecting requests from users
+// who haven't accepted the project's current terms of service version.
+func (s *Server) requireConsent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r) // params in URL
+		projectId := vars["project_id"]
+
+		var project *Project
+		err := s.Store.Get("projects", projectId, &project)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+
+		// projects without a consent version don't require acceptance
+		if project.ConsentVersion == "" {
+			next(w, r)
+			return
+		}
+
+		scoped := s.forProject(projectId)
+		userId := scoped.SessionUserId(r)
+		if userId == "" {
+			s.wrapResponse(w, r, 403, s.wrapError(ErrConsentRequired))
+			return
+		}
+		user, err := scoped.FindUser(userId)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+		if user == nil || user.ConsentVersion != project.ConsentVersion {
+			s.wrapResponse(w, r, 403, s.wrapError(ErrConsentRequired))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// @Title UserConsentHandler
+// @Description records the current user's acceptance of the project's terms of service
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   consent        body   string     false        "JSON-formatted consent, ex: {\"Version\": \"2\"}. Defaults to the project's current version."
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object}  User
+// @Failure 400 {object} errorResponse	Version isn't the current terms of service version
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/consent [post]
+func (s *Server) UserConsentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, recording consent requires a valid user."))
+		return
+	}
+
+	var project *Project
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	var consentData struct {
+		Version string
+	}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &consentData)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+	}
+	if consentData.Version == "" {
+		consentData.Version = project.ConsentVersion
+	}
+	if consentData.Version != project.ConsentVersion {
+		invalid := &ValidationError{}
+		invalid.add("Version", "has to be the current terms of service version %q, not %q", project.ConsentVersion, consentData.Version)
+		s.wrapFailure(w, r, invalid)
+		return
+	}
+
+	user, err := s.FindUser(userId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if user == nil {
+		tmpUser, err := s.CreateUserFromMissingCookieValue(userId)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+		user = &tmpUser
+	}
+
+	user, err = s.updateUser(user.Id, func(user *User) error {
+		user.ConsentVersion = consentData.Version
+		return nil
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	userJson, err := marshalUser(r, user)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, userJson)
+}
+
+// @Title UserLanguagesHandler
+// @Description sets the languages the current user prefers to work in
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   languages        body   string     true        "JSON-formatted list of language codes, ex: {\"Languages\": [\"en\", \"es\"]}"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object}  User
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/languages [post]
+func (s *Server) UserLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, setting languages requires a valid user."))
+		return
+	}
+
+	user, err := s.FindUser(userId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if user == nil {
+		tmpUser, err := s.CreateUserFromMissingCookieValue(userId)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+		user = &tmpUser
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	var languageData struct {
+		Languages []string
+	}
+	err = json.Unmarshal(body, &languageData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	user, err = s.updateUser(user.Id, func(user *User) error {
+		user.Languages = normalizeLanguages(languageData.Languages)
+		return nil
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
 	}
 
-	userJson, err := json.Marshal(user)
+	userJson, err := marshalUser(r, user)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, userJson)
@@ -2895,9 +4221,10 @@ func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 // @Title ExternalUserHandler
 // @Description finds or creates a user by external ID
-// @Param   userdata        body   string     true        "JSON-formatted user data including ExternalId (3rd party uid) and Id (hive uid)"
-// @Param	connect	path	boolean	false "If specified, will try merging matching user records by ExternalId and/or Id"
+// @Param   userdata        body   string     true        "JSON-formatted user data including ExternalId (3rd party uid); the session's user is the hive user, if there is one"
+// @Param	connect	path	boolean	false "If specified, will try merging the user with the ExternalId into the session's user"
 // @Success 200 {object}  User
+// @Failure 401 {object} errorResponse	connecting accounts without a session
 // @Failure 500 {object} error	appropriate error message
 // @Resource /users
 // @Router /projects/{project_id}/user/external/{connect} [post]
@@ -2908,33 +4235,29 @@ func (s *Server) ExternalUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r) // params in URL
 	connectAccounts := vars["connect"]
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
 	var lookupData struct {
-		Id         string
 		ExternalId string
 	}
 
 	err = json.Unmarshal(body, &lookupData)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	query := elastigo.Search(s.Index).Type("users").Filter(
-		elastigo.Filter().Terms("ExternalId", lookupData.ExternalId),
-		elastigo.Filter().Terms("Project", s.ActiveProjectId),
-	)
-	results, err := query.Result(&s.EsConn)
+	searchJson := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "term": { "ExternalId": "%s" } }, { "term": { "Project": "%s" } } ] } } } } }`, lookupData.ExternalId, s.ActiveProjectId)
+	results, err := s.Store.Search("users", searchJson)
 
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
@@ -2942,7 +4265,7 @@ func (s *Server) ExternalUserHandler(w http.ResponseWriter, r *http.Request) {
 	if resultCount != 0 {
 		err = json.Unmarshal(*results.Hits.Hits[0].Source, &externalUser)
 		if err != nil {
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
 
@@ -2953,47 +4276,33 @@ func (s *Server) ExternalUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// found no matching users
 	if resultCount == 0 {
-		userId := lookupData.Id
-
-		if userId == "" && lookupData.Id != "" {
-			userId = lookupData.Id
-		}
-
-		if userId == "" {
-			// no ${project_id}_user_id set, create a new user
-			tmpUser, err := s.CreateExternalUser(lookupData.ExternalId)
-			if err != nil {
-				s.wrapResponse(w, r, 500, s.wrapError(err))
-				return
-			}
-			user = &tmpUser
-
-		} else {
-			// ${project_id}_user_id set, try looking up the user
-			tmpUser, err := s.FindUser(userId)
+		// the session's user, if there is one, takes the external id; the body can't say who that is
+		userId := s.SessionUserId(r)
+		if userId != "" {
+			user, err = s.FindUser(userId)
 			if err != nil {
-				s.wrapResponse(w, r, 500, s.wrapError(err))
+				s.wrapFailure(w, r, err)
 				return
 			}
-
-			user = tmpUser
-			// found a user, set the externalId on it
-			if user != nil {
-				user.ExternalId = lookupData.ExternalId
-				_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
-				if err != nil {
-					s.wrapResponse(w, r, 500, s.wrapError(err))
-					return
-				}
-
-			} else {
-				// failed finding a user for that cookie (how would we get here?)
-				*user, err = s.CreateExternalUser(lookupData.ExternalId)
-				if err != nil {
-					s.wrapResponse(w, r, 500, s.wrapError(err))
-					return
-				}
+		}
+
+		if user != nil {
+			user, err = s.updateUser(user.Id, func(user *User) error {
+				user.ExternalId = lookupData.ExternalId
+				return nil
+			})
+			if err != nil {
+				s.wrapFailure(w, r, err)
+				return
+			}
+		} else {
+			// no session, or its user is gone, so create a new user
+			tmpUser, err := s.CreateExternalUser(lookupData.ExternalId)
+			if err != nil {
+				s.wrapFailure(w, r, err)
+				return
 			}
+			user = &tmpUser
 		}
 	}
 
@@ -3001,62 +4310,60 @@ func (s *Server) ExternalUserHandler(w http.ResponseWriter, r *http.Request) {
 	if resultCount == 1 {
 		err = json.Unmarshal(*results.Hits.Hits[0].Source, &externalUser)
 		if err != nil {
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
 
 		if connectAccounts == "" {
 			user = &externalUser
 		} else {
-			userId := lookupData.Id
+			// only the signed in user can take over another record's work, never one named in the body
+			userId := s.SessionUserId(r)
+			if userId == "" {
+				s.wrapFailure(w, r, unauthorized("Sorry, connecting accounts requires a valid user."))
+				return
+			}
 			tmpUser, err := s.FindUser(userId)
 			if err != nil {
-				s.wrapResponse(w, r, 500, s.wrapError(err))
+				s.wrapFailure(w, r, err)
 				return
 			}
 			user = tmpUser
-			if user != nil {
-				user.ExternalId = lookupData.ExternalId
-
-				// merge all the things
-
-				// first: contribution counts
-				for key, count := range externalUser.Counts {
-					user.Counts[key] += count
-				}
-
-				// second: favorites
-				for key, value := range externalUser.Favorites {
-					user.Favorites[key] = value
-				}
-
-				user.Counts["VerifiedAssets"] = len(user.VerifiedAssets)
-
-				_, err = s.EsConn.Index(s.Index, "users", user.Id, nil, user)
+			// the session's user keeps the external user's work, see MergeUsers, unless they're the same user already
+			if user != nil && user.Id != externalUser.Id {
+				user, err = s.MergeUsers(externalUser.Id, user.Id)
 				if err != nil {
-					s.wrapResponse(w, r, 500, s.wrapError(err))
+					s.wrapFailure(w, r, err)
 					return
 				}
-
-				// now, kill the other account
-				var args map[string]interface{}
-				_, err := s.EsConn.Delete(s.Index, "users", externalUser.Id, args)
-				if err != nil {
-					s.wrapResponse(w, r, 500, s.wrapError(err))
-					return
+				if user.ExternalId != lookupData.ExternalId {
+					user.ExternalId = lookupData.ExternalId
+					_, err = s.Store.Put("users", user.Id, user)
+					if err != nil {
+						s.wrapFailure(w, r, err)
+						return
+					}
 				}
 			}
 		}
 	}
 
 	if resultCount > 1 {
-		s.wrapResponse(w, r, 500, s.wrapError(errors.New("found more than one user with this externalId")))
+		s.wrapFailure(w, r, errors.New("found more than one user with this externalId"))
 		return
 	}
 
-	userJson, err := json.Marshal(user)
+	if user != nil {
+		// signed, like a login link's session, so routes behind requireSession accept it
+		s.SetSessionCookie(w, user.Id)
+
+		// the same external id in other projects belongs to the same person
+		s.linkIdentityQuietly(*user)
+	}
+
+	userJson, err := marshalUser(r, user)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, userJson)
@@ -3070,6 +4377,8 @@ func (s *Server) ExternalUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   task_id     path    string     true        "Task ID"
 // @Param   asset_id        path   string     true        "Asset ID"
 // @Success 200 {object} Assignment
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 422 {object} errorResponse	the task isn't available or the asset is excluded
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assignments
 // @Router /projects/{project_id}/tasks/{task_id}/assets/{asset_id}/assignments [get]
@@ -3077,7 +4386,7 @@ func (s *Server) ExternalUserHandler(w http.ResponseWriter, r *http.Request) {
 // r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assets/{asset_id}/assignments", s.AssignAssetHandler).Methods("GET")
 func (s *Server) AssignAssetHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	taskId := vars["task_id"]
 	assetId := vars["asset_id"]
 
@@ -3087,22 +4396,37 @@ func (s *Server) AssignAssetHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get user id from session cookie
-	userId := s.FindCookieValue(r, s.ActiveProjectId+"_user_id")
+	userId := s.SessionUserId(r)
 	if userId == "" {
-		userError := errors.New("Assignments can't be created without a user.")
-		s.wrapResponse(w, r, 500, s.wrapError(userError))
+		s.wrapFailure(w, r, unauthorized("Sorry, assignments can't be created without a user."))
 		return
 	}
 
 	assignment, err := s.CreateAssetAssignment(taskId, userId, assetId)
+	if err == ErrTooManyUnfinished || err == ErrTaskLimitReached {
+		s.wrapResponse(w, r, 403, s.wrapError(err))
+		return
+	}
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.signAssetUrl(&assignment.Asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcement, err := s.ActiveAnnouncement()
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	assignJson, err := json.Marshal(assignment)
+	assignJson, err := json.Marshal(announcedAssignment{assignment, announcement})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assignJson)
@@ -3116,35 +4440,61 @@ func (s *Server) AssignAssetHandler(w http.ResponseWriter, r *http.Request) {
 // @Param   assignment        body   string     true        "JSON-formatted assignment including user submitted data"
 // @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
 // @Success 200 {object}  Assignment
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 404 {object} errorResponse	the assignment isn't the session user's
+// @Failure 422 {object} errorResponse	the assignment was already submitted or has expired, or the next task isn't available
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assignments
 // @Router /projects/{project_id}/tasks/{task_id}/assignments [post]
 func (s *Server) UserCreateAssignmentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	taskId := vars["task_id"]
 	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
 		taskId = s.ActiveProjectId + "-" + vars["task_id"]
 	}
 
 	// get user id from session cookie
-	userId := s.FindCookieValue(r, s.ActiveProjectId+"_user_id")
+	userId := s.SessionUserId(r)
+
+	submission, err := s.readAssignmentSubmission(w, r, userId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
 
-	_, err := s.UpdateAssignment(r.Body)
+	_, err = s.SubmitAssignment(submission, s.requestSource(r))
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
 	assignment, err := s.CreateAssignment(taskId, userId)
+	if err == ErrTooManyUnfinished || err == ErrTaskLimitReached {
+		s.wrapResponse(w, r, 403, s.wrapError(err))
+		return
+	}
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.signAssetUrl(&assignment.Asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcement, err := s.ActiveAnnouncement()
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	assignJson, err := json.Marshal(assignment)
+	assignJson, err := json.Marshal(announcedAssignment{assignment, announcement})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assignJson)
@@ -3158,42 +4508,59 @@ func (s *Server) UserCreateAssignmentHandler(w http.ResponseWriter, r *http.Requ
 // @Param   task_id     path    string     true        "Task ID"
 // @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
 // @Success 200 {object}  Assignment
+// @Failure 422 {object} errorResponse	the task isn't available
 // @Failure 500 {object} error	appropriate error message
 // @Resource /assignments
 // @Router /projects/{project_id}/tasks/{task_id}/assignments [get]
 func (s *Server) UserAssignmentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r) // params in URL
-	s.ActiveProjectId = vars["project_id"]
+	s = s.forProject(vars["project_id"])
 	taskId := vars["task_id"]
 	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
 		taskId = s.ActiveProjectId + "-" + vars["task_id"]
 	}
 
 	// get user id from session cookie
-	sessionCookie, err := r.Cookie(s.ActiveProjectId + "_user_id")
-	if err != nil { // TODO: figure out how to avoid getting here; frontend should check for user cookie before calling assign
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+	userId := s.SessionUserId(r)
+	if userId == "" { // TODO: figure out how to avoid getting here; frontend should check for user cookie before calling assign
+		s.wrapFailure(w, r, http.ErrNoCookie)
 		return
 	}
 
-	userId := sessionCookie.Value
-
 	assignment, err := s.CreateAssignment(taskId, userId)
+	if err == ErrTooManyUnfinished || err == ErrTaskLimitReached {
+		s.wrapResponse(w, r, 403, s.wrapError(err))
+		return
+	}
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.signAssetUrl(&assignment.Asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcement, err := s.ActiveAnnouncement()
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	assignJson, err := json.Marshal(assignment)
+	assignJson, err := json.Marshal(announcedAssignment{assignment, announcement})
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	s.wrapResponse(w, r, 200, assignJson)
 }
 
 // Admin endpoint clears out db, configures elasticsearch and creates a project
-//		ANY /admin/setup
+//
+//	ANY /admin/setup
+//
 // WARNING: this empties your database. Really.
 // @Title AdminSetupHandler
 // @Description finds or creates an unfinished task assignment for the current user.
@@ -3206,46 +4573,33 @@ func (s *Server) UserAssignmentHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	log.Println("Importing data into hive...")
-
-	log.Println("Step 1: configuring elasticsearch.")
-	indexExists, possible404 := s.EsConn.IndicesExists(s.Index)
-
-	// for reasons mysterious to me, elastigo wraps all of the http pkg's functions
-	// and does not check if the response to IndicesExists is a 404.
-	// Elasticsearch will respond with a 404 if the index does not exist.
-	// Here we check for this and correctly set the value of indexExists to false
-	if possible404 != nil && possible404.Error() == "record not found" {
-		indexExists = false
-
-		// otherwise some other error was thrown, so just 500 and give up here.
-	} else if possible404 != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(possible404))
+	s.logEvent("setup: configuring the store", logFields{"store": fmt.Sprint(s.Store)})
+	indexExists, err := s.Store.IndexExists()
+	if err != nil {
+		s.wrapFailure(w, r, err)
 		return
 	}
 
 	if vars["DELETE_MY_DATABASE"] == "YES_I_AM_SURE" && indexExists {
 		// Delete existing hive index (was: curl -XDELETE localhost:9200/hive  >/dev/null 2>&1)
-		_, err := s.EsConn.DeleteIndex(s.Index)
+		err := s.Store.DeleteIndex()
 		if err != nil {
-			log.Println("Failed to delete index:", err)
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
-		log.Println("Deleted index", s.Index, ". I hope that was ok - you said you were sure!")
+		s.logEvent("setup: deleted every project's data", logFields{"store": fmt.Sprint(s.Store)})
 		indexExists = false
 	} else if indexExists {
-		giveUpErr := fmt.Errorf("Index '%s' exists. Use a different value or add 'YES_I_AM_SURE' to delete it: /admin/setup/YES_I_AM_SURE.", s.Index)
-		s.wrapResponse(w, r, 500, s.wrapError(giveUpErr))
+		giveUpErr := fmt.Errorf("%s exists. Use a different value or add 'YES_I_AM_SURE' to delete it: /admin/setup/YES_I_AM_SURE.", s.Store)
+		s.wrapFailure(w, r, giveUpErr)
 		return
 	}
 
 	if !indexExists {
-		log.Println("Creating index", s.Index)
 		// Create hive index (was: curl -XPOST localhost:9200/hive >/dev/null 2>&1)
-		_, err := s.EsConn.CreateIndex(s.Index)
+		err := s.Store.CreateIndex()
 		if err != nil {
-			s.wrapResponse(w, r, 500, s.wrapError(err))
+			s.wrapFailure(w, r, err)
 			return
 		}
 	}
@@ -3268,6 +4622,9 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 						}
 					}
 				},
+				"Created": {
+					"type": "date"
+				},
 				"Id": {
 					"type": "string",
 					"index": "not_analyzed"
@@ -3284,6 +4641,37 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 					"type": "string",
 					"index": "not_analyzed"
 				},
+				"Updated": {
+					"type": "date"
+				},
+				"User": {
+					"type": "string",
+					"index": "not_analyzed"
+				}
+			}
+		}
+	}`
+
+	err = s.Store.PutMapping("assignments", assignmentsBody)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	flagsBody := `{
+		"flags": {
+			"properties": {
+				"Asset": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"Created": {
+					"type": "date"
+				},
+				"Project": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
 				"User": {
 					"type": "string",
 					"index": "not_analyzed"
@@ -3292,19 +4680,103 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}`
 
-	_, err := s.EsConn.DoCommand("PUT", fmt.Sprintf("/%s/%s/_mapping", s.Index, "assignments"), nil, assignmentsBody)
+	err = s.Store.PutMapping("flags", flagsBody)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	announcementsBody := `{
+		"announcements": {
+			"properties": {
+				"Created": {
+					"type": "date"
+				},
+				"Project": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"Severity": {
+					"type": "string",
+					"index": "not_analyzed"
+				}
+			}
+		}
+	}`
+
+	err = s.Store.PutMapping("announcements", announcementsBody)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	identitiesBody := `{
+		"identities": {
+			"properties": {
+				"Created": {
+					"type": "date"
+				},
+				"Email": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"ExternalId": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"Users": {
+					"properties": {
+						"Project": {
+							"type": "string",
+							"index": "not_analyzed"
+						},
+						"User": {
+							"type": "string",
+							"index": "not_analyzed"
+						}
+					}
+				}
+			}
+		}
+	}`
+
+	err = s.Store.PutMapping("identities", identitiesBody)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	log.Println("Done configuring elasticsearch")
+	hitsBody := `{
+		"hits": {
+			"properties": {
+				"Asset": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"Created": {
+					"type": "date"
+				},
+				"Project": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"Task": {
+					"type": "string",
+					"index": "not_analyzed"
+				}
+			}
+		}
+	}`
 
-	log.Println("Step 2: creating project.")
+	err = s.Store.PutMapping("hits", hitsBody)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	var importedJson struct {
@@ -3315,31 +4787,23 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &importedJson)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 
-	s.ActiveProjectId = importedJson.Project.Id
+	s = s.forProject(importedJson.Project.Id)
 
 	// store in elasticsearch
-	_, err = s.EsConn.Index(s.Index, "projects", s.ActiveProjectId, nil, importedJson.Project)
+	_, err = s.Store.Put("projects", s.ActiveProjectId, importedJson.Project)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	log.Println("Done creating project:", s.ActiveProjectId)
-
-	log.Println("Step 3: importing tasks.")
-
 	tasks, _, err := s.importTasks(importedJson.Tasks)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	log.Println("Done creating tasks:", len(tasks))
-
-	log.Println("Step 4: adding assets.")
-
 	assetsBody := `{
 		"assets": {
 			"properties": {
@@ -3347,11 +4811,22 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 					"type": "string",
 					"index": "not_analyzed"
 				},
+				"ImportId": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
 				"Metadata": {
 					"properties": {
 						%s
 					}
 				},
+				"Language": {
+					"type": "string",
+					"index": "not_analyzed"
+				},
+				"Excluded": {
+					"type": "boolean"
+				},
 				"Project": {
 					"type": "string"
 				},
@@ -3372,7 +4847,7 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 
 	project, err := s.FindProject(s.ActiveProjectId)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
 	var metaProperties []string
@@ -3388,38 +4863,142 @@ func (s *Server) AdminSetupHandler(w http.ResponseWriter, r *http.Request) {
 	taskPropertiesString := strings.Join(taskProperties, ",")
 	assetsMapping := fmt.Sprintf(assetsBody, metaPropertiesString, taskPropertiesString)
 
-	_, err = s.EsConn.DoCommand("PUT", fmt.Sprintf("/%s/%s/_mapping", s.Index, "assets"), nil, assetsMapping)
+	err = s.Store.PutMapping("assets", assetsMapping)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	assets, err := s.importAssets(importedJson.Assets)
+	assets, _, err := s.importAssets(importedJson.Assets, true)
 	if err != nil {
-		s.wrapResponse(w, r, 500, s.wrapError(err))
+		s.wrapFailure(w, r, err)
 		return
 	}
-	log.Println("Done adding", len(assets), "assets")
+	s.logEvent("setup: imported project", logFields{"tasks": len(tasks), "assets": len(assets)})
 
 	report := []byte(fmt.Sprintf(`{"status":"200 OK", "Project": "%s", "Tasks": "%d", "Assets": "%d"}`, s.ActiveProjectId, len(tasks), len(assets)))
 	s.wrapResponse(w, r, 200, report)
 	return
 }
 
+// defaultShutdownTimeout is how long Run waits for in-flight requests when it's stopped, unless ShutdownTimeout says otherwise.
+const defaultShutdownTimeout = 30 * time.Second
+
 // Starts up hive-server on the specified port, connecting to Elasticsearch at {esDomain}:{esPort} using the given index.
 // Default parameters:
-//		hive port: 8080
-//		elasticsearch domain: localhost
-//		elasticsearch port: 9200
-//		elasticsearch index: hive
+//
+//	hive port: 8080
+//	elasticsearch domain: localhost
+//	elasticsearch port: 9200
+//	elasticsearch index: hive
+//
+// On SIGINT or SIGTERM it stops accepting connections, lets in-flight requests and webhook deliveries finish
+// for up to ShutdownTimeout, then refreshes the store so everything saved is searchable before it exits.
 func (s *Server) Run() {
-	log.Println("running hive-server on port", s.Port, "storing data in elasticsearch under index", s.Index)
+	logJson("info", "running hive-server", logFields{"port": s.Port, "store": fmt.Sprint(s.Store)})
+	if len(s.AdminKeys) == 0 {
+		logJson("warn", "admin endpoints are open to anyone, set -adminKeys to require an API key", nil)
+	}
+	if !s.signedSessions() {
+		logJson("warn", "session cookies aren't signed, set -secret so users can't be impersonated", nil)
+	}
+
+	server := &http.Server{Addr: ":" + s.Port, Handler: s.Router()}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.ListenAndServe()
+	}()
+
+	// release assignments abandoned past their task's lease
+	stopSweeping := make(chan struct{})
+	go s.sweepLeases(stopSweeping)
+	if s.ReconcileInterval > 0 {
+		go s.reconcileCounts(s.ReconcileInterval, stopSweeping)
+	}
 
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-stopped:
+		logJson("error", "hive-server stopped", logFields{"error": err})
+		os.Exit(1)
+	case sig := <-signals:
+		logJson("info", "shutting down hive-server", logFields{"signal": sig.String()})
+	}
+	close(stopSweeping)
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	exitCode := 0
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logJson("error", "gave up waiting for in-flight requests", logFields{"error": err})
+		exitCode = 1
+	}
+	err = waitForWebhooks(ctx)
+	if err != nil {
+		logJson("error", "gave up waiting for webhook deliveries", logFields{"error": err})
+		exitCode = 1
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		logJson("error", "failed refreshing the store", logFields{"error": err})
+		exitCode = 1
+	}
+	logJson("info", "hive-server stopped", nil)
+	os.Exit(exitCode)
+}
+
+// Router returns hive's endpoints wrapped in any registered middleware, for Go programs that serve hive alongside
+// their own handlers instead of calling Run. To mount hive under a path, strip it first,
+// ex: http.Handle("/hive/", http.StripPrefix("/hive", s.Router())), and include it in BaseUrl.
+func (s *Server) Router() http.Handler {
 	r := mux.NewRouter()
 	r.StrictSlash(true)
 
+	// every api version, ex: /v1/projects/{project_id}; they share handlers, and differ in how responses are wrapped
+	for _, version := range apiVersions {
+		s.routes(r.PathPrefix(version).Subrouter())
+	}
+
+	// unprefixed routes are kept as aliases of /v1 so existing frontends keep working
+	s.routes(r)
+
+	// GET /healthz - reports the process is up
+	// GET /readyz - reports whether the store is reachable and set up, 503 when it isn't, for load balancers and orchestration
+	r.HandleFunc("/healthz", s.HealthzHandler).Methods("GET")
+	r.HandleFunc("/readyz", s.ReadyzHandler).Methods("GET")
+
+	// GET /swagger.json - describes the API's routes, parameters and responses
+	// GET /docs - browses and tries out the API with Swagger UI
+	r.HandleFunc("/swagger.json", s.SwaggerHandler).Methods("GET")
+	r.Handle(strings.TrimSuffix(docsPath, "/"), http.RedirectHandler(docsPath, http.StatusMovedPermanently))
+	r.PathPrefix(docsPath).Handler(docsHandler())
+
+	// GET /admin/ui - the admin dashboard: project progress, task states and recent assignments
+	r.Handle(strings.TrimSuffix(uiPath, "/"), http.RedirectHandler(uiPath, http.StatusMovedPermanently))
+	r.PathPrefix(uiPath).Handler(adminUiHandler())
+
+	// GET /blobs/{key} - serves uploaded files when they're stored on local disk
+	if disk, ok := s.Blobs.(*DiskBlobStore); ok {
+		r.PathPrefix("/blobs/").Handler(http.StripPrefix("/blobs/", http.FileServer(http.Dir(disk.Dir))))
+	}
+
+	return s.logRequests(r, s.cors(r, s.compress(s.wrapMiddleware(r))))
+}
+
+// routes registers hive's endpoints on r.
+func (s *Server) routes(r *mux.Router) {
 	// ANY / - lists endpoints
 	r.HandleFunc("/", s.RootHandler)
 
+	// admin endpoints for a single project say which role a signed in user needs with requireRole,
+	// the rest need an API key once the server has AdminKeys
+
 	// ANY /admin/setup - clears out db, configures elasticsearch and creates a project
 	r.HandleFunc("/admin/setup", s.AdminSetupHandler)
 	r.HandleFunc("/admin/setup/{DELETE_MY_DATABASE}", s.AdminSetupHandler)
@@ -3428,60 +5007,209 @@ func (s *Server) Run() {
 	r.HandleFunc("/admin/projects", s.AdminProjectsHandler).Methods("GET")
 
 	// GET /admin/projects/{project_id} - returns project information
-	r.HandleFunc("/admin/projects/{project_id}", s.AdminProjectHandler).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}", s.requireRole(RoleReviewer, s.AdminProjectHandler)).Methods("GET")
+
+	// POST /admin/projects/import - restores a project from a backup made by /admin/projects/{project_id}/export
+	r.HandleFunc("/admin/projects/import", s.AdminRestoreProjectHandler).Methods("POST")
 
 	// POST /admin/projects/{project_id} - creates or updates a project
-	r.HandleFunc("/admin/projects/{project_id}", s.AdminCreateProjectHandler).Methods("POST")
+	r.HandleFunc("/admin/projects/{project_id}", s.requireRole(RoleOwner, s.AdminCreateProjectHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/webhook - returns where verified assets are sent, without the secret
+	// POST /admin/projects/{project_id}/webhook - sets where verified assets are sent, ex: {"Url": "https://cms.example.com/hive"}
+	r.HandleFunc("/admin/projects/{project_id}/webhook", s.requireRole(RoleAdmin, s.AdminWebhookHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/webhook", s.requireRole(RoleOwner, s.AdminCreateWebhookHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/export - downloads a backup of the project and everything in it
+	r.HandleFunc("/admin/projects/{project_id}/export", s.requireRole(RoleOwner, s.AdminExportProjectHandler)).Methods("GET")
+
+	// POST /admin/identities - links one person's user records across projects
+	// GET /admin/identities/{identity_id} - returns an identity
+	// GET /admin/identities/{identity_id}/history - returns a person's contributions across projects
+	r.HandleFunc("/admin/identities", s.AdminCreateIdentityHandler).Methods("POST")
+	r.HandleFunc("/admin/identities/{identity_id}", s.AdminIdentityHandler).Methods("GET")
+	r.HandleFunc("/admin/identities/{identity_id}/history", s.AdminIdentityHistoryHandler).Methods("GET")
 
 	// GET /admin/projects/{project_id}/tasks - returns tasks in this project
-	r.HandleFunc("/admin/projects/{project_id}/tasks", s.AdminTasksHandler).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/tasks", s.requireRole(RoleReviewer, s.AdminTasksHandler)).Methods("GET")
 
 	// POST /admin/projects/{project_id}/tasks - imports tasks into this project
-	r.HandleFunc("/admin/projects/{project_id}/tasks", s.AdminCreateTasksHandler).Methods("POST")
+	r.HandleFunc("/admin/projects/{project_id}/tasks", s.requireRole(RoleAdmin, s.AdminCreateTasksHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/tasks/state - sets the state of several tasks at once
+	r.HandleFunc("/admin/projects/{project_id}/tasks/state", s.requireRole(RoleOwner, s.AdminTaskStatesHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/tasks/{task_id}/criteria/preview - checks assignment criteria and counts the assets they match
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/criteria/preview", s.requireRole(RoleAdmin, s.AdminCriteriaPreviewHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/tasks/{task_id}/eligibility/{user_id} - explains why a user can or can't get an assignment
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/eligibility/{user_id}", s.requireRole(RoleAdmin, s.AdminEligibilityHandler)).Methods("GET")
+
+	// POST /admin/projects/{project_id}/tasks/{task_id}/backfill - adds a task's placeholders to older assets and users
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/backfill", s.requireRole(RoleAdmin, s.AdminBackfillTaskHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/tasks/{task_id}/mturk/publish?n=100 - publishes unfinished assets as paid Mechanical Turk HITs
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/mturk/publish", s.requireRole(RoleAdmin, s.AdminPublishHitsHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/mturk/sync - brings Mechanical Turk answers back as assignments and verifies what they complete
+	r.HandleFunc("/admin/projects/{project_id}/mturk/sync", s.requireRole(RoleAdmin, s.AdminSyncHitsHandler)).Methods("POST")
 
 	// GET /admin/projects/{project_id}/tasks/{task_id} - returns task information
-	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}", s.AdminTaskHandler).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}", s.requireRole(RoleReviewer, s.AdminTaskHandler)).Methods("GET")
 
 	// POST /admin/projects/{project_id}/tasks/{task_id} - create or update a task
-	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}", s.AdminCreateTaskHandler).Methods("POST")
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}", s.requireRole(RoleAdmin, s.AdminCreateTaskHandler)).Methods("POST")
+
+	// DELETE /admin/projects/{project_id}/tasks/{task_id} - delete a task
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}", s.requireRole(RoleOwner, s.AdminDeleteTaskHandler)).Methods("DELETE")
 
-	// enable and disable tasks
-	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/enable", s.EnableTaskHandler).Methods("GET")
-	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/disable", s.DisableTaskHandler).Methods("GET")
+	// enable, disable and archive tasks
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/enable", s.requireRole(RoleOwner, s.EnableTaskHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/disable", s.requireRole(RoleOwner, s.DisableTaskHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/archive", s.requireRole(RoleOwner, s.ArchiveTaskHandler)).Methods("GET")
 
 	// GET /admin/projects/{project_id}/assets - returns assets in this project
 	// GET /admin/projects/{project_id}/assets?from=10&size=30 - paginates assets
 	// GET /admin/projects/{project_id}/assets?task=:task&state=:state - returns a list of assets based on task and state
-	r.HandleFunc("/admin/projects/{project_id}/assets", s.AdminAssetsHandler).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/assets", s.requireRole(RoleReviewer, s.AdminAssetsHandler)).Methods("GET")
 
 	// POST /admin/projects/{project_id}/assets - imports assets into this project
-	r.HandleFunc("/admin/projects/{project_id}/assets", s.AdminCreateAssetsHandler).Methods("POST")
+	r.HandleFunc("/admin/projects/{project_id}/assets", s.requireRole(RoleAdmin, s.AdminCreateAssetsHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets/upload - stores uploaded files and creates assets pointing at them
+	r.HandleFunc("/admin/projects/{project_id}/assets/upload", s.requireRole(RoleAdmin, s.AdminUploadAssetsHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets/import/s3 - creates an asset for each object under a prefix in an S3 bucket
+	r.HandleFunc("/admin/projects/{project_id}/assets/import/s3", s.requireRole(RoleAdmin, s.AdminImportS3AssetsHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets/import/urls?fetch=true - creates an asset for each page in a sitemap or list of urls
+	r.HandleFunc("/admin/projects/{project_id}/assets/import/urls", s.requireRole(RoleAdmin, s.AdminImportUrlsHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets.csv - imports assets from a spreadsheet
+	r.HandleFunc("/admin/projects/{project_id}/assets.csv", s.requireRole(RoleAdmin, s.AdminImportAssetsCsvHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/imports/{import_id} - returns an asset import's progress
+	r.HandleFunc("/admin/projects/{project_id}/imports/{import_id}", s.requireRole(RoleAdmin, s.AdminImportJobHandler)).Methods("GET")
+
+	// POST /admin/projects/{project_id}/imports/{import_id}/rollback - deletes the assets an import created, except those already assigned
+	r.HandleFunc("/admin/projects/{project_id}/imports/{import_id}/rollback", s.requireRole(RoleAdmin, s.AdminRollbackImportHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/assets/search?q=studebaker - finds assets by the words in their name, url, metadata or submitted data
+	r.HandleFunc("/admin/projects/{project_id}/assets/search", s.requireRole(RoleReviewer, s.AdminSearchAssetsHandler)).Methods("GET")
+
+	// GET /admin/projects/{project_id}/assets/sample?n=50&state=completed - returns a random sample of assets, ex: verified ones to audit
+	r.HandleFunc("/admin/projects/{project_id}/assets/sample", s.requireRole(RoleReviewer, s.AdminAssetSampleHandler)).Methods("GET")
+
+	// GET /admin/projects/{project_id}/export.csv?task=:task - downloads verified assets with their data for a task
+	r.HandleFunc("/admin/projects/{project_id}/export.csv", s.requireRole(RoleAdmin, s.AdminExportHandler)).Methods("GET")
+
+	// PATCH /admin/projects/{project_id}/assets/{asset_id} - correct an asset's Name, Url or Metadata
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}", s.requireRole(RoleAdmin, s.AdminPatchAssetHandler)).Methods("PATCH")
 
 	// GET /admin/projects/{project_id}/assets/{asset_id} - get a single asset's data
-	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}", s.AdminAssetHandler)
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}", s.requireRole(RoleReviewer, s.AdminAssetHandler))
+
+	// exclude assets from assignment without deleting them, and include them again
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}/exclude", s.requireRole(RoleAdmin, s.ExcludeAssetHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}/include", s.requireRole(RoleAdmin, s.IncludeAssetHandler)).Methods("GET")
+
+	// POST /admin/projects/{project_id}/assets/{asset_id}/priority - moves an asset up or down the assignment queue
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}/priority", s.requireRole(RoleAdmin, s.AssetPriorityHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets/{asset_id}/verify - verifies an asset for a task with an editor's answer, ex: {"Task": "vote", "SubmittedData": {...}}
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}/verify", s.requireRole(RoleReviewer, s.AdminForceVerifyAssetHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets/{asset_id}/recount - recomputes an asset's Counts from its assignments
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}/recount", s.requireRole(RoleAdmin, s.AdminRecountAssetHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/assets/{asset_id}/reset?task={task_id}&rollback=true - unverifies an asset for a task so it's handed out again
+	r.HandleFunc("/admin/projects/{project_id}/assets/{asset_id}/reset", s.requireRole(RoleReviewer, s.AdminResetAssetHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/announcements - returns a project's announcements, newest first
+	// POST /admin/projects/{project_id}/announcements - creates an announcement
+	r.HandleFunc("/admin/projects/{project_id}/announcements", s.requireRole(RoleAdmin, s.AdminAnnouncementsHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/announcements", s.requireRole(RoleAdmin, s.AdminCreateAnnouncementHandler)).Methods("POST")
+
+	// GET, POST and DELETE /admin/projects/{project_id}/announcements/{announcement_id} - reads, updates or removes an announcement
+	r.HandleFunc("/admin/projects/{project_id}/announcements/{announcement_id}", s.requireRole(RoleAdmin, s.AdminAnnouncementHandler)).Methods("GET")
+	r.HandleFunc("/admin/projects/{project_id}/announcements/{announcement_id}", s.requireRole(RoleAdmin, s.AdminCreateAnnouncementHandler)).Methods("POST")
+	r.HandleFunc("/admin/projects/{project_id}/announcements/{announcement_id}", s.requireRole(RoleAdmin, s.AdminDeleteAnnouncementHandler)).Methods("DELETE")
+
+	// GET /admin/projects/{project_id}/flags?asset={asset_id} - returns contributor reports about assets, newest first
+	r.HandleFunc("/admin/projects/{project_id}/flags", s.requireRole(RoleReviewer, s.AdminFlagsHandler)).Methods("GET")
+
+	// GET /admin/projects/{project_id}/skips?task={task_id}&asset={asset_id} - tallies why assignments were skipped, overall and for the most skipped assets
+	r.HandleFunc("/admin/projects/{project_id}/skips", s.requireRole(RoleReviewer, s.AdminSkipsHandler)).Methods("GET")
+
+	// GET /admin/projects/{project_id}/abandoned?task={task_id}&min=2 - returns the assets whose assignments expire most often
+	r.HandleFunc("/admin/projects/{project_id}/abandoned", s.requireRole(RoleReviewer, s.AdminAbandonedAssetsHandler)).Methods("GET")
+
+	// GET /admin/projects/{project_id}/tasks/{task_id}/audit-sample?n=50 - returns a random sample of assets verified for this task
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/audit-sample", s.requireRole(RoleReviewer, s.AdminAuditSampleHandler)).Methods("GET")
+
+	// GET /admin/projects/{project_id}/tasks/{task_id}/confusion - compares crowd answers against gold standard answers
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/confusion", s.requireRole(RoleReviewer, s.AdminConfusionMatrixHandler)).Methods("GET")
 
 	// GET /admin/projects/{project_id}/tasks/{task_id}/complete - mark any assets completed for this task
-	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/complete", s.CompleteTaskHandler)
+	r.HandleFunc("/admin/projects/{project_id}/tasks/{task_id}/complete", s.requireRole(RoleOwner, s.CompleteTaskHandler))
+
+	// GET /admin/projects/{project_id}/reviews?task={task_id}&state={state} - returns verified assets queued for spot-check review
+	r.HandleFunc("/admin/projects/{project_id}/reviews", s.requireRole(RoleReviewer, s.AdminReviewsHandler)).Methods("GET")
+
+	// POST /admin/projects/{project_id}/reviews/{review_id}/confirm - confirms a verified answer
+	// POST /admin/projects/{project_id}/reviews/{review_id}/reject - rejects a verified answer, reopening the asset for the task
+	r.HandleFunc("/admin/projects/{project_id}/reviews/{review_id}/{action}", s.requireRole(RoleReviewer, s.AdminResolveReviewHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/review?task={task_id}&disagreement=true - returns finished assignments no one has reviewed yet
+	r.HandleFunc("/admin/projects/{project_id}/review", s.requireRole(RoleReviewer, s.AdminReviewQueueHandler)).Methods("GET")
+
+	// POST /admin/projects/{project_id}/assignments/{assignment_id}/review - accepts or rejects a finished assignment, ex: {"Accepted": false}
+	r.HandleFunc("/admin/projects/{project_id}/assignments/{assignment_id}/review", s.requireRole(RoleReviewer, s.AdminReviewAssignmentHandler)).Methods("POST")
 
 	// GET /admin/projects/{project_id}/users - returns users in this project
 	// GET /admin/projects/{project_id}/users?from=0&size=10 - paginates users
-	r.HandleFunc("/admin/projects/{project_id}/users", s.AdminUsersHandler)
+	r.HandleFunc("/admin/projects/{project_id}/users", s.requireRole(RoleAdmin, s.AdminUsersHandler))
+
+	// POST /admin/projects/{project_id}/users/merge - merges a source user into a target user
+	r.HandleFunc("/admin/projects/{project_id}/users/merge", s.requireRole(RoleAdmin, s.AdminMergeUsersHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/users/{user_id}/merge - merges the source user in the body into this user
+	r.HandleFunc("/admin/projects/{project_id}/users/{user_id}/merge", s.requireRole(RoleAdmin, s.AdminMergeIntoUserHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/users/{user_id}/role - sets a user's role in the project, ex: {"Role": "reviewer"}
+	r.HandleFunc("/admin/projects/{project_id}/users/{user_id}/role", s.requireRole(RoleOwner, s.AdminUserRoleHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/gold/score - recalculates every user's accuracy on gold standard assets
+	r.HandleFunc("/admin/projects/{project_id}/gold/score", s.requireRole(RoleAdmin, s.AdminScoreGoldHandler)).Methods("POST")
+
+	// POST /admin/projects/{project_id}/trust/score - recalculates how often each user's answers agree with verified answers
+	r.HandleFunc("/admin/projects/{project_id}/trust/score", s.requireRole(RoleAdmin, s.AdminScoreTrustHandler)).Methods("POST")
+
+	// GET /admin/projects/{project_id}/workflow - returns the pipelines tasks make with NextTask, and how many assets are at each step
+	r.HandleFunc("/admin/projects/{project_id}/workflow", s.requireRole(RoleReviewer, s.AdminWorkflowHandler)).Methods("GET")
 
 	// GET /admin/projects/{project_id}/users/{user_id} - returns a single user in this project
-	r.HandleFunc("/admin/projects/{project_id}/users/{user_id}", s.AdminUserHandler)
+	r.HandleFunc("/admin/projects/{project_id}/users/{user_id}", s.requireRole(RoleAdmin, s.AdminUserHandler))
 
 	// GET /admin/projects/{project_id}/assignments?task={task_id}&state={state}
 	// GET /admin/projects/{project_id}/assignments?task={task_id}&state={state}&from=from&size=size
-	r.HandleFunc("/admin/projects/{project_id}/assignments", s.AdminAssignmentsHandler)
+	r.HandleFunc("/admin/projects/{project_id}/assignments", s.requireRole(RoleAdmin, s.AdminAssignmentsHandler))
+
+	// handlers that act as the current user are wrapped in requireSession, which turns away session cookies
+	// this server didn't sign
 
 	// GET /projects/{project_id}/tasks/{task_id} - returns task information
 	r.HandleFunc("/projects/{project_id}/tasks/{task_id}", s.TaskHandler).Methods("GET")
 
 	// GET /projects/{project_id}/tasks/find/assignments - returns a new assignment for the given task + current user
-	r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assignments", s.UserAssignmentHandler).Methods("GET")
+	r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assignments", s.requireSession(s.UserAssignmentHandler)).Methods("GET")
 
 	// POST /projects/{project_id}/tasks/find/assignments - submit assignment (contribute, fill in form, etc)
-	r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assignments", s.UserCreateAssignmentHandler).Methods("POST")
+	// rejected until the user has accepted the project's current terms of service, if any
+	r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assignments", s.requireSession(s.requireConsent(s.UserCreateAssignmentHandler))).Methods("POST")
+
+	// GET /projects - returns active projects for public landing pages
+	r.HandleFunc("/projects", s.ProjectsHandler).Methods("GET")
 
 	// GET /projects/{project_id} - returns project information
 	r.HandleFunc("/projects/{project_id}", s.ProjectHandler).Methods("GET")
@@ -3489,34 +5217,67 @@ func (s *Server) Run() {
 	// GET /projects/{project_id}/assets/SOPB9LrQTRyKeQCi4xDdTA - returns asset information
 	r.HandleFunc("/projects/{project_id}/assets/{asset_id}", s.AssetHandler).Methods("GET")
 
+	// GET /projects/{project_id}/assets/SOPB9LrQTRyKeQCi4xDdTA/thumb?w=400 - returns a JPEG thumbnail of the asset's image
+	r.HandleFunc("/projects/{project_id}/assets/{asset_id}/thumb", s.AssetThumbHandler).Methods("GET")
+
 	// GET /projects/{project_id}/tasks - returns tasks in this project
 	r.HandleFunc("/projects/{project_id}/tasks", s.TasksHandler).Methods("GET")
 
 	// GET /projects/{project_id}/tasks/find/assets/W1fpeD0lQs2tR1R4OqkzAQ/assignments - returns a new assignment for task + asset + current user
-	r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assets/{asset_id}/assignments", s.AssignAssetHandler).Methods("GET")
+	r.HandleFunc("/projects/{project_id}/tasks/{task_id}/assets/{asset_id}/assignments", s.requireSession(s.AssignAssetHandler)).Methods("GET")
 
 	// GET /projects/{project_id}/user - returns user information based on project session cookie
-	r.HandleFunc("/projects/{project_id}/user", s.UserHandler).Methods("GET")
+	r.HandleFunc("/projects/{project_id}/user", s.requireSession(s.UserHandler)).Methods("GET")
 
 	// POST /projects/{project_id}/user - creates a user based on json data posted
 	r.HandleFunc("/projects/{project_id}/user", s.CreateUserHandler).Methods("POST")
 
+	// GET /projects/{project_id}/user/stats - returns the current user's contribution stats
+	r.HandleFunc("/projects/{project_id}/user/stats", s.requireSession(s.UserStatsHandler)).Methods("GET")
+	// GET /projects/{project_id}/leaderboard?task={task_id}&period=week - returns the project's most active contributors, ?format=csv for a spreadsheet
+	r.HandleFunc("/projects/{project_id}/leaderboard", s.LeaderboardHandler).Methods("GET")
+
+	// POST /projects/{project_id}/user/consent - records the current user's acceptance of the terms of service
+	r.HandleFunc("/projects/{project_id}/user/consent", s.requireSession(s.UserConsentHandler)).Methods("POST")
+
+	// POST /projects/{project_id}/user/languages - sets the current user's preferred languages
+	r.HandleFunc("/projects/{project_id}/user/languages", s.requireSession(s.UserLanguagesHandler)).Methods("POST")
+
+	// POST /projects/{project_id}/user/leaderboard - hides the current user from the leaderboard, or shows them again, ex: {"Hidden": true}
+	r.HandleFunc("/projects/{project_id}/user/leaderboard", s.requireSession(s.UserLeaderboardHandler)).Methods("POST")
+
+	// GET /projects/{project_id}/user/history - returns the current user's contributions across linked projects
+	r.HandleFunc("/projects/{project_id}/user/history", s.requireSession(s.UserHistoryHandler)).Methods("GET")
+
+	// GET /projects/{project_id}/user/onboarding - returns onboarding steps and the current user's progress through them
+	// POST /projects/{project_id}/user/onboarding/{step_id} - marks a step completed, checking quiz answers
+	r.HandleFunc("/projects/{project_id}/user/onboarding", s.requireSession(s.OnboardingHandler)).Methods("GET")
+	r.HandleFunc("/projects/{project_id}/user/onboarding/{step_id}", s.requireSession(s.CompleteOnboardingStepHandler)).Methods("POST")
+
+	// POST /projects/{project_id}/user/login - emails a one-time login link
+	// GET /projects/{project_id}/user/login/{token} - redeems a login link, setting the session cookie
+	r.HandleFunc("/projects/{project_id}/user/login", s.LoginHandler).Methods("POST")
+	r.HandleFunc("/projects/{project_id}/user/login/{token}", s.LoginTokenHandler).Methods("GET")
+
 	// POST /projects/{project_id}/user/external - looks up user by external id, returns session token
 	r.HandleFunc("/projects/{project_id}/user/external", s.ExternalUserHandler).Methods("POST")
 	r.HandleFunc("/projects/{project_id}/user/external/{connect}", s.ExternalUserHandler).Methods("POST")
 
 	// GET /projects/{project_id}/assets/SOPB9LrQTRyKeQCi4xDdTA/favorite - favorites an asset
-	r.HandleFunc("/projects/{project_id}/assets/{asset_id}/favorite", s.FavoriteHandler).Methods("GET")
+	r.HandleFunc("/projects/{project_id}/assets/{asset_id}/favorite", s.requireSession(s.FavoriteHandler)).Methods("GET")
+
+	// POST /projects/{project_id}/assets/{asset_id}/flag - reports a problem with an asset
+	r.HandleFunc("/projects/{project_id}/assets/{asset_id}/flag", s.requireSession(s.FlagAssetHandler)).Methods("POST")
 
 	// GET /projects/{project_id}/user/favorites - returns a user's favorited ads
-	r.HandleFunc("/projects/{project_id}/user/favorites", s.FavoritesHandler).Methods("GET")
+	r.HandleFunc("/projects/{project_id}/user/favorites", s.requireSession(s.FavoritesHandler)).Methods("GET")
 
 	// GET /projects/{project_id}/assignments/{assignment} - returns assignment information
 	r.HandleFunc("/projects/{project_id}/assignments/{assignment_id}", s.AssignmentHandler).Methods("GET")
 
-	http.Handle("/", r)
-	err := http.ListenAndServe(":"+s.Port, nil)
-	if err != nil {
-		log.Fatalf(err.Error())
-	}
+	// PATCH /projects/{project_id}/assignments/{assignment_id} - saves part of an assignment's submitted data, leaving it unfinished
+	r.HandleFunc("/projects/{project_id}/assignments/{assignment_id}", s.requireSession(s.requireConsent(s.PartialAssignmentHandler))).Methods("PATCH")
+
+	// POST /projects/{project_id}/assignments/{assignment_id}/draft - autosaves work in progress on an assignment
+	r.HandleFunc("/projects/{project_id}/assignments/{assignment_id}/draft", s.requireSession(s.AssignmentDraftHandler)).Methods("POST")
 }
@@ -0,0 +1,465 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Identity links the separate user records one person has across projects.
+// Users are linked when they share the identity's ExternalId or log in with its Email.
+type Identity struct {
+	Id         string         // guid, auto-generated
+	ExternalId string         // optional, users in any project with this external id belong to this identity
+	Email      string         // optional, users who log in with this email address belong to this identity
+	Users      []IdentityUser // the linked per-project user records
+	Created    time.Time
+}
+
+// IdentityUser points at one project's user record.
+type IdentityUser struct {
+	Project string
+	User    string
+}
+
+// projectContributions are one linked user's finished work in a single project.
+type projectContributions struct {
+	Project     string
+	User        User
+	Assignments []Assignment // finished and verified assignments, gold answers removed
+}
+
+// identityHistory is a person's combined contribution history across projects.
+type identityHistory struct {
+	Identity Identity
+	Projects []projectContributions
+	Totals   Counts // Assignments, VerifiedAssets and Favorites summed across projects
+}
+
+type identityResponse struct {
+	Identity Identity
+}
+
+// hasUser reports whether the identity already links the given project's user.
+func (i *Identity) hasUser(projectId string, userId string) bool {
+	for _, linked := range i.Users {
+		if linked.Project == projectId && linked.User == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// FindIdentity looks up an identity by id.
+func (s *Server) FindIdentity(id string) (identity *Identity, err error) {
+	err = s.Store.Get("identities", id, &identity)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// searchIdentity returns the first identity matching the query's filter, or nil when none do.
+func (s *Server) searchIdentity(filterJson string) (*Identity, error) {
+	searchJson := fmt.Sprintf(`{ "query": { "filtered": { "filter": %s } }, "from": 0, "size": 1 }`, filterJson)
+	results, err := s.Store.Search("identities", searchJson)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Hits.Hits) == 0 {
+		return nil, nil
+	}
+
+	var identity Identity
+	err = json.Unmarshal(*results.Hits.Hits[0].Source, &identity)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// findMatchingIdentity returns the identity with the given external id or email, or nil when there isn't one.
+func (s *Server) findMatchingIdentity(externalId string, email string) (*Identity, error) {
+	var terms []string
+	if externalId != "" {
+		externalIdJson, _ := json.Marshal(externalId)
+		terms = append(terms, fmt.Sprintf(`{ "term": { "ExternalId": %s } }`, externalIdJson))
+	}
+	if email != "" {
+		emailJson, _ := json.Marshal(strings.ToLower(email))
+		terms = append(terms, fmt.Sprintf(`{ "term": { "Email": %s } }`, emailJson))
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return s.searchIdentity(fmt.Sprintf(`{ "bool": { "should": [ %s ] } }`, strings.Join(terms, ", ")))
+}
+
+// findUserIdentity returns the identity linking a project's user, or nil when the user isn't linked.
+func (s *Server) findUserIdentity(projectId string, userId string) (*Identity, error) {
+	identity, err := s.searchIdentity(fmt.Sprintf(`{ "term": { "Users.User": "%s" } }`, userId))
+	if err != nil || identity == nil {
+		return identity, err
+	}
+	if !identity.hasUser(projectId, userId) {
+		return nil, nil
+	}
+	return identity, nil
+}
+
+// findLinkableUsers returns users in every project with the given external id or email address.
+func (s *Server) findLinkableUsers(externalId string, email string) (users []User, err error) {
+	var shoulds []string
+	if externalId != "" {
+		externalIdJson, _ := json.Marshal(externalId)
+		shoulds = append(shoulds, fmt.Sprintf(`{ "term": { "ExternalId": %s } }`, externalIdJson))
+	}
+	if email != "" {
+		emailJson, _ := json.Marshal(email)
+		shoulds = append(shoulds, fmt.Sprintf(`{ "match_phrase": { "Email": %s } }`, emailJson))
+	}
+	if len(shoulds) == 0 {
+		return
+	}
+
+	searchJson := fmt.Sprintf(`{ "query": { "bool": { "should": [ %s ] } }, "from": 0, "size": 1000 }`, strings.Join(shoulds, ", "))
+	results, err := s.Store.Search("users", searchJson)
+	if err != nil {
+		return
+	}
+
+	// match_phrase runs against the analyzed field, so confirm exact matches here
+	for _, hit := range results.Hits.Hits {
+		var user User
+		err = json.Unmarshal(*hit.Source, &user)
+		if err != nil {
+			return
+		}
+		if (externalId != "" && user.ExternalId == externalId) || (email != "" && strings.EqualFold(user.Email, email)) {
+			users = append(users, user)
+		}
+	}
+	return
+}
+
+// saveIdentity stores an identity, generating an id for new ones.
+func (s *Server) saveIdentity(identity *Identity) error {
+	identity.Email = strings.ToLower(identity.Email)
+	if identity.Id == "" {
+		identity.Created = time.Now().UTC()
+
+		// store in elasticsearch, which will generate a unique id
+		resultId, err := s.Store.Put("identities", "", identity)
+		if err != nil {
+			return err
+		}
+		identity.Id = resultId
+	}
+
+	_, err := s.Store.Put("identities", identity.Id, identity)
+	if err != nil {
+		return err
+	}
+	err = s.Store.Refresh()
+	return err
+}
+
+// LinkIdentity adds a user to the identity sharing its external id or email address, creating the identity if needed.
+// Users with neither aren't linked, and nil is returned.
+func (s *Server) LinkIdentity(user User) (*Identity, error) {
+	if user.ExternalId == "" && user.Email == "" {
+		return nil, nil
+	}
+
+	identity, err := s.findMatchingIdentity(user.ExternalId, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if identity == nil {
+		identity = &Identity{}
+	}
+	if identity.hasUser(user.Project, user.Id) {
+		return identity, nil
+	}
+
+	if identity.ExternalId == "" {
+		identity.ExternalId = user.ExternalId
+	}
+	if identity.Email == "" {
+		identity.Email = user.Email
+	}
+	identity.Users = append(identity.Users, IdentityUser{Project: user.Project, User: user.Id})
+
+	err = s.saveIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// linkIdentityQuietly links a user like LinkIdentity, only logging failures so they never block signing in.
+func (s *Server) linkIdentityQuietly(user User) {
+	_, err := s.LinkIdentity(user)
+	if err != nil {
+		s.logError("failed linking user to an identity", err, logFields{"user": user.Id})
+	}
+}
+
+// relinkIdentity gives a user merged into another their place in their identity, see MergeUsers. When the target
+// is linked already, the source is just dropped.
+func (s *Server) relinkIdentity(sourceId string, targetId string) error {
+	identity, err := s.findUserIdentity(s.ActiveProjectId, sourceId)
+	if err != nil || identity == nil {
+		return err
+	}
+	targetIdentity, err := s.findUserIdentity(s.ActiveProjectId, targetId)
+	if err != nil {
+		return err
+	}
+
+	var users []IdentityUser
+	for _, linked := range identity.Users {
+		if linked.Project != s.ActiveProjectId || linked.User != sourceId {
+			users = append(users, linked)
+		}
+	}
+	if targetIdentity == nil {
+		users = append(users, IdentityUser{Project: s.ActiveProjectId, User: targetId})
+	}
+	identity.Users = users
+	_, err = s.Store.Put("identities", identity.Id, identity)
+	return err
+}
+
+// CreateIdentity builds an identity from the request body, linking any listed users plus every user in any project
+// that shares its external id or email address. Posting an external id or email that already has an identity adds to it.
+func (s *Server) CreateIdentity(requestBody io.Reader) (*Identity, error) {
+	body, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	var newIdentity Identity
+	err = json.Unmarshal(body, &newIdentity)
+	if err != nil {
+		return nil, err
+	}
+	if newIdentity.ExternalId == "" && newIdentity.Email == "" && len(newIdentity.Users) == 0 {
+		return nil, errors.New("Sorry, identities need an ExternalId, an Email or a list of Users to link.")
+	}
+
+	identity, err := s.findMatchingIdentity(newIdentity.ExternalId, newIdentity.Email)
+	if err != nil {
+		return nil, err
+	}
+	if identity == nil {
+		identity = &Identity{ExternalId: newIdentity.ExternalId, Email: newIdentity.Email}
+	}
+
+	for _, linked := range newIdentity.Users {
+		var user *User
+		err = s.Store.Get("users", linked.User, &user)
+		if err != nil || user == nil || user.Project != linked.Project {
+			return nil, fmt.Errorf("Failed finding user %s in project %s.", linked.User, linked.Project)
+		}
+		if !identity.hasUser(linked.Project, linked.User) {
+			identity.Users = append(identity.Users, linked)
+		}
+	}
+
+	users, err := s.findLinkableUsers(identity.ExternalId, identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if !identity.hasUser(user.Project, user.Id) {
+			identity.Users = append(identity.Users, IdentityUser{Project: user.Project, User: user.Id})
+		}
+	}
+
+	err = s.saveIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// IdentityHistory collects the finished work of every user linked to an identity.
+func (s *Server) IdentityHistory(identity Identity) (*identityHistory, error) {
+	history := &identityHistory{
+		Identity: identity,
+		Projects: make([]projectContributions, 0),
+		Totals: Counts{
+			"Assignments":    0,
+			"VerifiedAssets": 0,
+			"Favorites":      0,
+		},
+	}
+	for _, linked := range identity.Users {
+		// each linked user is looked up in its own project
+		scoped := s.forProject(linked.Project)
+		user, err := scoped.FindUser(linked.User)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			continue
+		}
+
+		assignments, err := scoped.FindUserAssignments(user.Id)
+		if err != nil {
+			return nil, err
+		}
+		finished := make([]Assignment, 0)
+		for _, assignment := range assignments {
+			if assignment.State != "finished" && assignment.State != "verified" {
+				continue
+			}
+			// gold answers stay private to admins
+			assignment.Asset.GoldData = nil
+			finished = append(finished, assignment)
+		}
+
+		history.Projects = append(history.Projects, projectContributions{
+			Project:     linked.Project,
+			User:        *user,
+			Assignments: finished,
+		})
+		history.Totals["Assignments"] += len(finished)
+		history.Totals["VerifiedAssets"] += len(user.VerifiedAssets)
+		history.Totals["Favorites"] += len(user.Favorites)
+	}
+	return history, nil
+}
+
+// @Title AdminCreateIdentityHandler
+// @Description creates an identity linking one person's user records across projects, or adds to an existing one
+// @Accept  json
+// @Param   identity        body   string     true        "JSON-formatted identity, ex: {\"ExternalId\": \"12345\", \"Users\": [{\"Project\": \"crowd\", \"User\": \"GorJ0TxVRbipE9SIJypEVQ\"}]}"
+// @Success 200 {object}  identityResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/identities [post]
+func (s *Server) AdminCreateIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := s.CreateIdentity(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	identityJson, err := json.Marshal(identityResponse{
+		Identity: *identity,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, identityJson)
+}
+
+// @Title AdminIdentityHandler
+// @Description returns a single identity and the users it links
+// @Accept  json
+// @Param   identity_id     path    string     true        "Identity ID"
+// @Success 200 {object}  identityResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/identities/{identity_id} [get]
+func (s *Server) AdminIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+
+	identity, err := s.FindIdentity(vars["identity_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	identityJson, err := json.Marshal(identityResponse{
+		Identity: *identity,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, identityJson)
+}
+
+// @Title AdminIdentityHistoryHandler
+// @Description returns a person's combined contribution history across every project their identity links
+// @Accept  json
+// @Param   identity_id     path    string     true        "Identity ID"
+// @Success 200 {object}  identityHistory
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/identities/{identity_id}/history [get]
+func (s *Server) AdminIdentityHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+
+	identity, err := s.FindIdentity(vars["identity_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	history, err := s.IdentityHistory(*identity)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	historyJson, err := json.Marshal(history)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, historyJson)
+}
+
+// @Title UserHistoryHandler
+// @Description returns the current user's contribution history across every project their identity links
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object}  identityHistory
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/history [get]
+func (s *Server) UserHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, contribution history requires a valid user."))
+		return
+	}
+
+	identity, err := s.findUserIdentity(s.ActiveProjectId, userId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	// users without an identity only have this project's history
+	if identity == nil {
+		identity = &Identity{Users: []IdentityUser{{Project: s.ActiveProjectId, User: userId}}}
+	}
+
+	history, err := s.IdentityHistory(*identity)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	historyJson, err := json.Marshal(history)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, historyJson)
+}
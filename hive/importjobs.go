@@ -0,0 +1,266 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ImportJob records how far an asset import got, so one that stops partway, ex: on a bad row or an elasticsearch
+// outage, can be resumed where it left off or rolled back. The assets it creates are tagged with its id, see
+// Asset.ImportId, rather than listed here, so a job stays small however many assets it imports.
+type ImportJob struct {
+	Id        string
+	Project   string
+	Source    string // what was imported, one of "json", "ndjson", "csv", "s3" or "urls"
+	State     string // "running", "failed", "completed" or "rolledBack"
+	Processed int    // how many of the source's assets were stored or found there already; a resume skips them
+	Imported  int    // assets created, over every run
+	Updated   int    // assets matched to one already in the project, and updated in place
+	Existing  int    // assets left as they were because their hashed id had already been imported
+	Failed    int    // assets elasticsearch refused to store
+	Error     string `json:",omitempty"` // why the last run stopped, when it failed
+	Removed   int    `json:",omitempty"` // assets a rollback deleted
+	Kept      int    `json:",omitempty"` // assets a rollback left because they'd already been assigned
+	Started   time.Time
+	Stopped   time.Time // when the last run ended, or the import was rolled back
+}
+
+type importJobResponse struct {
+	Import ImportJob
+}
+
+// FindImportJob returns the current project's import with id.
+func (s *Server) FindImportJob(id string) (*ImportJob, error) {
+	var job *ImportJob
+	err := s.Store.Get("imports", id, &job)
+	if err != nil || job == nil || job.Project != s.ActiveProjectId {
+		return nil, notFound("Sorry, there isn't an import with that id in this project.")
+	}
+	return job, nil
+}
+
+// startImport returns a copy of the server recording an import's progress, see importAssetBatches. Without an id
+// a new import is started; with one, that import is resumed, and source has to be what it was importing before.
+func (s *Server) startImport(id string, source string) (*Server, error) {
+	job := &ImportJob{Project: s.ActiveProjectId, Source: source, Started: time.Now().UTC()}
+	if id != "" {
+		var err error
+		job, err = s.FindImportJob(id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case job.State == "completed":
+			return nil, invalidState("Sorry, import %s already completed.", job.Id)
+		case job.State == "rolledBack":
+			return nil, invalidState("Sorry, import %s was rolled back, start a new import instead.", job.Id)
+		case job.Source != source:
+			return nil, fmt.Errorf("Sorry, import %s was a %s import, resume it with the same source.", job.Id, job.Source)
+		}
+		s.logEvent("resuming import", logFields{"import": job.Id, "processed": job.Processed})
+	} else {
+		var err error
+		job.Id, err = randomId()
+		if err != nil {
+			return nil, err
+		}
+	}
+	job.State = "running"
+	job.Error = ""
+	_, err := s.Store.Put("imports", job.Id, job)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := *s
+	scoped.activeImport = job
+	return &scoped, nil
+}
+
+// finishImport records how the request's import ended, and returns err saying how to resume it when it failed.
+func (s *Server) finishImport(err error) error {
+	job := s.activeImport
+	if job == nil {
+		return err
+	}
+	job.State = "completed"
+	if err != nil {
+		job.State = "failed"
+		job.Error = err.Error()
+	}
+	job.Stopped = time.Now().UTC()
+	_, putErr := s.Store.Put("imports", job.Id, job)
+	if putErr != nil {
+		s.logError("failed saving import", putErr, logFields{"import": job.Id})
+	}
+	resume := fmt.Sprintf("Resume it by sending the same assets with import=%s, or roll it back.", job.Id)
+	if invalid, ok := err.(*ValidationError); ok {
+		invalid.Message = invalid.Error() + " " + resume
+		return invalid
+	}
+	if err != nil {
+		return fmt.Errorf("%v %s", err, resume)
+	}
+	return nil
+}
+
+// RollbackImport deletes the assets an import created, except those that were already assigned, whose
+// assignments would be left without them. Assets the import updated keep their changes.
+func (s *Server) RollbackImport(id string) (*ImportJob, error) {
+	job, err := s.FindImportJob(id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	// the ids are collected first, so deleting them doesn't shift the pages being read
+	var ids []string
+	importFilter := fmt.Sprintf(`{ "term": { "ImportId": "%s" } }`, job.Id)
+	err = s.forEachMatchingDoc("assets", []string{importFilter}, func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		ids = append(ids, asset.Id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(ids); start += backfillPageSize {
+		end := start + backfillPageSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		assigned, err := s.assignedAssets(ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, assetId := range ids[start:end] {
+			if assigned[assetId] {
+				job.Kept++
+				continue
+			}
+			err = s.Store.Delete("assets", assetId)
+			if err != nil {
+				return nil, err
+			}
+			job.Removed++
+		}
+	}
+
+	job.State = "rolledBack"
+	job.Stopped = time.Now().UTC()
+	_, err = s.Store.Put("imports", job.Id, job)
+	if err != nil {
+		return nil, err
+	}
+	s.logEvent("rolled back import", logFields{"import": job.Id, "removed": job.Removed, "kept": job.Kept})
+	return job, s.Store.Refresh()
+}
+
+// assignedAssets returns which of assetIds have assignments in the current project, in one search.
+func (s *Server) assignedAssets(assetIds []string) (map[string]bool, error) {
+	idsJson, err := json.Marshal(assetIds)
+	if err != nil {
+		return nil, err
+	}
+	searchJson := fmt.Sprintf(`{
+		"size": 0,
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [
+							{ "term": { "Project": "%s" } },
+							{ "terms": { "Asset.Id": %s } }
+						]
+					}
+				}
+			}
+		},
+		"aggs": {
+			"assets": { "terms": { "field": "Asset.Id", "size": %d } }
+		}
+	}`, s.ActiveProjectId, idsJson, len(assetIds))
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return nil, err
+	}
+	var agg struct {
+		Assets struct {
+			Buckets []termBucket `json:"buckets"`
+		} `json:"assets"`
+	}
+	err = json.Unmarshal(results.Aggregations, &agg)
+	if err != nil {
+		return nil, err
+	}
+	assigned := make(map[string]bool)
+	for _, bucket := range agg.Assets.Buckets {
+		assigned[bucket.Term] = true
+	}
+	return assigned, nil
+}
+
+// @Title AdminImportJobHandler
+// @Description returns an asset import's progress, ex: to see how far one that failed got
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   import_id        path   string     true        "Import ID, from the Import of the import's response"
+// @Success 200 {object}  importJobResponse
+// @Failure 404 {object} errorResponse	there isn't one with that id in the project
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/imports/{import_id} [get]
+func (s *Server) AdminImportJobHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	job, err := s.FindImportJob(vars["import_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	jobJson, err := json.Marshal(importJobResponse{Import: *job})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, jobJson)
+}
+
+// @Title AdminRollbackImportHandler
+// @Description deletes the assets an import created, except those already assigned, ex: to undo one that failed partway
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   import_id        path   string     true        "Import ID, from the Import of the import's response"
+// @Success 200 {object}  importJobResponse
+// @Failure 404 {object} errorResponse	there isn't one with that id in the project
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/imports/{import_id}/rollback [post]
+func (s *Server) AdminRollbackImportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	job, err := s.RollbackImport(vars["import_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	jobJson, err := json.Marshal(importJobResponse{Import: *job})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, jobJson)
+}
@@ -0,0 +1,291 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// leader is a user's public standing in a project. Emails and external ids are left out.
+type leader struct {
+	Rank     int // position by finished assignments, starting at 1; ties share a rank
+	User     string
+	Name     string
+	Finished int     // finished assignments, including those that went on to verify their asset
+	Verified int     // assignments that helped verify their asset
+	Accuracy float64 // the user's Quality.Accuracy on gold standard assets
+}
+
+type leaderboardResponse struct {
+	Leaders []leader
+	Meta    meta
+}
+
+// leaderBucket is a user's tally in the leaderboard aggregation.
+type leaderBucket struct {
+	User     string `json:"key"`
+	Count    int    `json:"doc_count"`
+	Verified struct {
+		Count int `json:"doc_count"`
+	} `json:"verified"`
+}
+
+type leaderAgg struct {
+	Users struct {
+		Buckets []leaderBucket `json:"buckets"`
+	} `json:"users"`
+}
+
+// leaderboardPeriods are how far back a leaderboard can look, by the name used in ?period=.
+var leaderboardPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// hiddenLeaders returns the ids of the project's users who've asked to be left off the leaderboard.
+func (s *Server) hiddenLeaders() ([]string, error) {
+	var hidden []string
+	filters := []string{`{ "term": { "HideFromLeaderboard": true } }`}
+	err := s.forEachMatchingDoc("users", filters, func(source json.RawMessage) error {
+		var user User
+		err := json.Unmarshal(source, &user)
+		if err != nil {
+			return err
+		}
+		hidden = append(hidden, user.Id)
+		return nil
+	})
+	return hidden, err
+}
+
+// FindLeaders returns the project's users with the most finished assignments first, tallied by an aggregation
+// over their assignments. taskId limits it to one task, and since to assignments finished after it, unless
+// either is empty. Users who've hidden themselves are left out.
+func (s *Server) FindLeaders(p Params, taskId string, since time.Time) (leaders []leader, m meta, err error) {
+	musts := []string{
+		fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId),
+		`{ "terms": { "State": [ "finished", "verified" ] } }`,
+	}
+	if taskId != "" {
+		musts = append(musts, fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskId))
+	}
+	if !since.IsZero() {
+		musts = append(musts, fmt.Sprintf(`{ "range": { "Updated": { "gte": "%s" } } }`, since.UTC().Format(time.RFC3339)))
+	}
+	mustNots := []string{}
+	hidden, err := s.hiddenLeaders()
+	if err != nil {
+		return
+	}
+	if len(hidden) > 0 {
+		mustNots = append(mustNots, fmt.Sprintf(`{ "terms": { "User": [ "%s" ] } }`, strings.Join(hidden, `", "`)))
+	}
+
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": { "must": [ %s ], "must_not": [ %s ] }
+				}
+			}
+		},
+		"size": 0,
+		"aggs": {
+			"users": {
+				"terms": { "field": "User", "size": 0, "order": { "_count": "desc" } },
+				"aggs": {
+					"verified": { "filter": { "term": { "State": "verified" } } }
+				}
+			}
+		}
+	}`, strings.Join(musts, ", "), strings.Join(mustNots, ", "))
+
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return
+	}
+	var agg leaderAgg
+	err = json.Unmarshal(results.Aggregations, &agg)
+	if err != nil {
+		return
+	}
+
+	buckets := agg.Users.Buckets
+	m.Total = len(buckets)
+	m.From, _ = strconv.Atoi(p.From)
+	m.Size, _ = strconv.Atoi(p.Size)
+
+	leaders = make([]leader, 0)
+	rank := 0
+	for i, bucket := range buckets {
+		// ties share the rank of the first user with their count
+		if i == 0 || bucket.Count < buckets[i-1].Count {
+			rank = i + 1
+		}
+		if i < m.From || len(leaders) >= m.Size {
+			continue
+		}
+		user, err := s.FindUser(bucket.User)
+		if err != nil {
+			return nil, m, err
+		}
+		l := leader{
+			Rank:     rank,
+			User:     bucket.User,
+			Finished: bucket.Count,
+			Verified: bucket.Verified.Count,
+		}
+		if user != nil {
+			l.Name = user.Name
+			l.Accuracy = user.Quality.Accuracy
+		}
+		leaders = append(leaders, l)
+	}
+	return
+}
+
+// @Title UserLeaderboardHandler
+// @Description hides the current user from the project's leaderboard, or shows them again
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   hidden        body   string     true        "JSON-formatted choice, ex: {\"Hidden\": true}"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object}  User
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/leaderboard [post]
+func (s *Server) UserLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, hiding from the leaderboard requires a valid user."))
+		return
+	}
+	user, err := s.FindUser(userId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if user == nil {
+		s.wrapFailure(w, r, notFound("Sorry, there's no user with that id in this project."))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var leaderboardData struct {
+		Hidden bool
+	}
+	err = json.Unmarshal(body, &leaderboardData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	user, err = s.updateUser(user.Id, func(user *User) error {
+		user.HideFromLeaderboard = leaderboardData.Hidden
+		return nil
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	userJson, err := marshalUser(r, user)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, userJson)
+}
+
+// @Title LeaderboardHandler
+// @Description returns the project's most active contributors, with finished and verified counts and accuracy
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task        query   string     false        "If specified, only counts assignments for this task"
+// @Param   period        query   string     false        "day, week or month to only count recent assignments; defaults to all"
+// @Param   from        query   int     false        "If specified, will return a set of leaders starting with from number"
+// @Param   size        query   int     false        "If specified, will return a total number of leaders specified as size"
+// @Param   format        query   string     false        "csv to download a spreadsheet instead of json"
+// @Success 200 {object}  leaderboardResponse
+// @Failure 400 {object} errorResponse	period isn't one of day, week, month or all
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/leaderboard [get]
+func (s *Server) LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	p := Params{
+		From: defaultQuery(queryParams, "from", "0"),
+		Size: defaultQuery(queryParams, "size", "10"),
+	}
+
+	taskId := queryParams.Get("task")
+	if taskId != "" && !strings.HasPrefix(taskId, s.ActiveProjectId+"-") {
+		taskId = s.ActiveProjectId + "-" + taskId
+	}
+	period, ok := leaderboardPeriods[defaultQuery(queryParams, "period", "all")]
+	if !ok {
+		invalid := &ValidationError{}
+		invalid.add("period", "has to be one of day, week, month or all, not %q", queryParams.Get("period"))
+		s.wrapFailure(w, r, invalid)
+		return
+	}
+	var since time.Time
+	if period > 0 {
+		since = time.Now().Add(-period)
+	}
+
+	leaders, m, err := s.FindLeaders(p, taskId, since)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	if wantsCsv(r) {
+		rows := [][]string{{"Rank", "User", "Name", "Finished", "Verified", "Accuracy"}}
+		for _, l := range leaders {
+			rows = append(rows, []string{
+				strconv.Itoa(l.Rank),
+				l.User,
+				l.Name,
+				strconv.Itoa(l.Finished),
+				strconv.Itoa(l.Verified),
+				strconv.FormatFloat(l.Accuracy, 'f', 4, 64),
+			})
+		}
+		s.wrapCsv(w, r, s.ActiveProjectId+"-leaderboard.csv", rows)
+		return
+	}
+
+	leadersJson, err := json.Marshal(leaderboardResponse{
+		Leaders: leaders,
+		Meta:    m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, leadersJson)
+}
@@ -0,0 +1,155 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// leaseSweepInterval is how often Run looks for unfinished assignments whose lease has run out.
+const leaseSweepInterval = time.Minute
+
+// leaseExpired reports whether an unfinished assignment has gone untouched for longer than its task's lease.
+// Submitting part of it or autosaving a draft counts as touching it.
+func leaseExpired(task Task, assignment Assignment, now time.Time) bool {
+	if task.LeaseMinutes <= 0 || assignment.State != "unfinished" {
+		return false
+	}
+	touched := assignment.Updated
+	if assignment.DraftSaved.After(touched) {
+		touched = assignment.DraftSaved
+	}
+	return now.Sub(touched) > time.Duration(task.LeaseMinutes)*time.Minute
+}
+
+// ExpireAssignments releases the task's unfinished assignments that have outlived its LeaseMinutes, marking them
+// "expired" and taking them off their assets' unfinished and Assignments counts, so the assets show as needing
+// another assignment and are handed out to someone else.
+// It returns how many were expired.
+func (s *Server) ExpireAssignments(task Task) (int, error) {
+	if task.LeaseMinutes <= 0 {
+		return 0, nil
+	}
+	now := time.Now().UTC()
+	cutoff := now.Add(-time.Duration(task.LeaseMinutes) * time.Minute).Format(time.RFC3339Nano)
+	filters := []string{
+		fmt.Sprintf(`{ "term": { "Task": "%s" } }`, task.Id),
+		`{ "term": { "State": "unfinished" } }`,
+		fmt.Sprintf(`{ "range": { "Updated": { "lt": "%s" } } }`, cutoff),
+	}
+
+	// collect them first, since expiring them takes them out of the pages being read
+	var stale []Assignment
+	err := s.forEachMatchingDoc("assignments", filters, func(source json.RawMessage) error {
+		var assignment Assignment
+		err := json.Unmarshal(source, &assignment)
+		if err != nil {
+			return err
+		}
+		if leaseExpired(task, assignment, now) {
+			stale = append(stale, assignment)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, candidate := range stale {
+		// it may have been submitted, or a draft saved, since it was read, which keeps it as it is
+		changed := false
+		var assignment Assignment
+		err = s.updateDoc("assignments", candidate.Id, &assignment, func(found bool) error {
+			changed = false
+			if !found || !leaseExpired(task, assignment, now) {
+				return errUnchanged
+			}
+			assignment.State = "expired"
+			assignment.Updated = now
+			changed = true
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+		if !changed {
+			continue
+		}
+		expired++
+
+		_, err = s.updateAsset(assignment.Asset.Id, func(asset *Asset) error {
+			if asset.Counts["unfinished"] > 0 {
+				asset.Counts["unfinished"] -= 1
+			}
+			if asset.Counts["Assignments"] > 0 {
+				asset.Counts["Assignments"] -= 1
+			}
+			asset.Counts["expired"] += 1
+			return nil
+		})
+		if err != nil && err != ErrNotFound {
+			return expired, err
+		}
+		s.AssignmentChanged("assignment.expired", assignment)
+	}
+	if expired > 0 {
+		s.logEvent("expired assignments", logFields{"task": task.Id, "assignments": expired})
+		err = s.Store.Refresh()
+	}
+	return expired, err
+}
+
+// ExpireLeases expires stale assignments for every task, in every project, that has a lease.
+func (s *Server) ExpireLeases() error {
+	for from := 0; ; from += backfillPageSize {
+		searchJson := fmt.Sprintf(`{
+			"query": {
+				"filtered": {
+					"filter": { "range": { "LeaseMinutes": { "gt": 0 } } }
+				}
+			},
+			"from": %d,
+			"size": %d,
+			"sort": [ { "Id": { "order": "asc" } } ]
+		}`, from, backfillPageSize)
+
+		results, err := s.Store.Search("tasks", searchJson)
+		if err != nil {
+			return err
+		}
+		for _, hit := range results.Hits.Hits {
+			var task Task
+			err = json.Unmarshal(*hit.Source, &task)
+			if err != nil {
+				return err
+			}
+			// one task's trouble shouldn't hold up the rest
+			scoped := s.forProject(task.Project)
+			_, err = scoped.ExpireAssignments(task)
+			if err != nil {
+				scoped.logError("failed expiring assignments", err, logFields{"task": task.Id})
+			}
+		}
+		if len(results.Hits.Hits) < backfillPageSize {
+			return nil
+		}
+	}
+}
+
+// sweepLeases runs ExpireLeases every leaseSweepInterval until stop is closed.
+func (s *Server) sweepLeases(stop <-chan struct{}) {
+	ticker := time.NewTicker(leaseSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := s.ExpireLeases()
+			if err != nil {
+				logJson("error", "failed expiring leases", logFields{"error": err})
+			}
+		}
+	}
+}
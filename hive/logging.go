@@ -0,0 +1,182 @@
+package hive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// logFields are the named values on a structured log line, ex: logFields{"asset": asset.Id}.
+type logFields map[string]interface{}
+
+// jsonLog writes hive's logs, one JSON object per line.
+var jsonLog = log.New(os.Stderr, "", 0)
+
+// logJson writes a log line with the time, level, msg and fields, ex:
+// {"level":"info","msg":"asset verified","asset":"SOPB9LrQTRyKeQCi4xDdTA","time":"2015-03-02T22:00:00Z"}.
+func logJson(level string, msg string, fields logFields) {
+	line := logFields{}
+	for name, value := range fields {
+		// errors marshal as {} otherwise
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		line[name] = value
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = level
+	line["msg"] = msg
+
+	lineJson, err := json.Marshal(line)
+	if err != nil {
+		lineJson = []byte(fmt.Sprintf(`{"level":"error","msg":"failed encoding log line","error":%q,"line":%q}`, err, fmt.Sprint(line)))
+	}
+	jsonLog.Println(string(lineJson))
+}
+
+// logEvent logs something that happened in the current project.
+func (s *Server) logEvent(msg string, fields logFields) {
+	if fields == nil {
+		fields = logFields{}
+	}
+	if s.ActiveProjectId != "" {
+		fields["project"] = s.ActiveProjectId
+	}
+	logJson("info", msg, fields)
+}
+
+// logError logs something that went wrong in the current project, and why.
+func (s *Server) logError(msg string, err error, fields logFields) {
+	if fields == nil {
+		fields = logFields{}
+	}
+	if s.ActiveProjectId != "" {
+		fields["project"] = s.ActiveProjectId
+	}
+	fields["error"] = err
+	logJson("error", msg, fields)
+}
+
+// requestLogKey holds a request's *requestLog in its context.
+type requestLogKey struct{}
+
+// requestLog collects what's logged about a request once it's served.
+type requestLog struct {
+	Id    string
+	Error string // the error message sent back, if any, see wrapResponse
+}
+
+// RequestId returns the id hive logs a request under, which it also sends back as the X-Request-Id header.
+// Embedding programs can use it to tie their own logs to hive's.
+func RequestId(r *http.Request) string {
+	if entry, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		return entry.Id
+	}
+	return ""
+}
+
+// newRequestId returns the id a client sent in X-Request-Id, when it's a reasonable one, or a new one.
+func newRequestId(r *http.Request) string {
+	requestId := r.Header.Get("X-Request-Id")
+	if requestId != "" && len(requestId) <= 128 {
+		printable := true
+		for _, c := range requestId {
+			if c < '!' || c > '~' {
+				printable = false
+			}
+		}
+		if printable {
+			return requestId
+		}
+	}
+	requestId, err := randomId()
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return requestId
+}
+
+// loggedResponse records the status code and size of a response as it's written.
+type loggedResponse struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (lr *loggedResponse) WriteHeader(status int) {
+	if lr.status == 0 {
+		lr.status = status
+	}
+	lr.ResponseWriter.WriteHeader(status)
+}
+
+func (lr *loggedResponse) Write(b []byte) (int, error) {
+	if lr.status == 0 {
+		lr.status = 200
+	}
+	n, err := lr.ResponseWriter.Write(b)
+	lr.size += n
+	return n, err
+}
+
+// Flush lets streaming responses, ex: exports, send what they've written so far.
+func (lr *loggedResponse) Flush() {
+	if flusher, ok := lr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// logRequests wraps h to give each request an id, sent back as X-Request-Id, and log it once it's served
+// with its route, project, status code and how long it took. router is used to name the route.
+func (s *Server) logRequests(router *mux.Router, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{Id: newRequestId(r)}
+		w.Header().Set("X-Request-Id", entry.Id)
+		lr := &loggedResponse{ResponseWriter: w}
+
+		h.ServeHTTP(lr, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, entry)))
+
+		// handlers that never write still answer 200
+		status := lr.status
+		if status == 0 {
+			status = 200
+		}
+		fields := logFields{
+			"request_id":  entry.Id,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      status,
+			"bytes":       lr.size,
+			"duration_ms": float64(time.Since(start).Nanoseconds()) / 1e6,
+		}
+
+		// the route's template names the handler, ex: "/projects/{project_id}/tasks"
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			route, err := match.Route.GetPathTemplate()
+			if err == nil {
+				fields["route"] = route
+			}
+			if projectId := match.Vars["project_id"]; projectId != "" {
+				fields["project"] = projectId
+			}
+		}
+
+		level := "info"
+		if entry.Error != "" {
+			fields["error"] = entry.Error
+			level = "warn"
+		}
+		if status >= 500 {
+			level = "error"
+		}
+		logJson(level, "request", fields)
+	})
+}
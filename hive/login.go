@@ -0,0 +1,348 @@
+package hive
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// loginTokenTTL is how long a magic login link stays valid after it is emailed.
+const loginTokenTTL = 15 * time.Minute
+
+// Logging in needs no session, so the links that can be asked for are limited, counting the ones that haven't
+// expired yet: to an address, so hive can't be used to flood someone's inbox, and by a client, so one can't go
+// through many addresses.
+const (
+	loginLinksPerAddress = 3
+	loginLinksPerClient  = 10
+)
+
+// Mailer sends email on hive's behalf, used for passwordless login links.
+type Mailer interface {
+	SendMail(to, subject, body string) error
+}
+
+// SMTPMailer is a Mailer that delivers through an SMTP relay.
+type SMTPMailer struct {
+	Addr string    // host:port of the smtp server
+	From string    // address login emails are sent from
+	Auth smtp.Auth // optional, ex: smtp.PlainAuth
+}
+
+// SendMail delivers a plain text email.
+func (m *SMTPMailer) SendMail(to, subject, body string) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", m.From, to, subject, body)
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg))
+}
+
+// loginToken records a pending magic link. It is deleted as soon as the link is used,
+// so each link can only establish a session once.
+type loginToken struct {
+	Id      string // random nonce, also the document id
+	Project string
+	User    string // empty for an address without a user yet, who's created when the link is used
+	Email   string
+	Address string // loginKey of Email, to count the links sent to it
+	Client  string // loginKey of the IP address that asked for the link
+	Expires int64  // unix timestamp
+}
+
+// loginKey hashes an address, so pending logins can be counted by it with a term filter.
+func loginKey(address string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(address)))
+	return hex.EncodeToString(hash[:16])
+}
+
+// pendingLogins counts the login links whose field is key that are still valid, see loginLinksPerAddress.
+func (s *Server) pendingLogins(field string, key string) (int, error) {
+	countJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [
+							{ "term": { "Project": "%s" } },
+							{ "term": { "%s": "%s" } },
+							{ "range": { "Expires": { "gt": %d } } }
+						]
+					}
+				}
+			}
+		}
+	}`, s.ActiveProjectId, field, key, time.Now().Unix())
+	return s.Store.Count("logins", countJson)
+}
+
+// signToken returns payload with an HMAC signature appended, using the server's secret key.
+func (s *Server) signToken(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.SecretKey))
+	mac.Write([]byte(payload))
+	signature := mac.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// verifyToken checks a token produced by signToken and returns its payload.
+func (s *Server) verifyToken(token string) (payload string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", errors.New("Malformed token.")
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", errors.New("Malformed token.")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("Malformed token.")
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.SecretKey))
+	mac.Write(payloadBytes)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", errors.New("Invalid token signature.")
+	}
+	return string(payloadBytes), nil
+}
+
+// randomId returns a random hex string suitable for nonces.
+func randomId() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FindUserByEmail looks up a user in the current project by email address, returning nil if there isn't one.
+func (s *Server) FindUserByEmail(email string) (user *User, err error) {
+	emailJson, err := json.Marshal(email)
+	if err != nil {
+		return nil, err
+	}
+	searchJson := fmt.Sprintf(`{ "query": { "match_phrase": { "Email": %s } }, "from": 0, "size": 50 }`, emailJson)
+	results, err := s.Store.Search("users", searchJson)
+	if err != nil {
+		return nil, err
+	}
+
+	// match_phrase runs against the analyzed field, so confirm the exact address here
+	for _, hit := range results.Hits.Hits {
+		var candidate User
+		err = json.Unmarshal(*hit.Source, &candidate)
+		if err != nil {
+			continue
+		}
+		if candidate.Project == s.ActiveProjectId && strings.EqualFold(candidate.Email, email) {
+			return s.FindUser(candidate.Id)
+		}
+	}
+	return nil, nil
+}
+
+// SendLoginLink mails a one-time login link to email, at the request of client, the IP address asking for it.
+// The user with that email signs in with it, or is created when it's used, if there isn't one yet.
+func (s *Server) SendLoginLink(email string, client string) error {
+	if s.Mailer == nil || s.SecretKey == "" {
+		return errors.New("Passwordless login isn't configured on this server.")
+	}
+	if !strings.Contains(email, "@") {
+		return errors.New("Sorry, a valid email address is required.")
+	}
+
+	pending := loginToken{
+		Project: s.ActiveProjectId,
+		Email:   email,
+		Address: loginKey(email),
+		Client:  loginKey(client),
+		Expires: time.Now().Add(loginTokenTTL).Unix(),
+	}
+	sent, err := s.pendingLogins("Address", pending.Address)
+	if err != nil {
+		return err
+	}
+	if sent >= loginLinksPerAddress {
+		return tooManyRequests("Sorry, a few login links have been sent to that address already. Please use one of those, or try again in %d minutes.", int(loginTokenTTL.Minutes()))
+	}
+	sent, err = s.pendingLogins("Client", pending.Client)
+	if err != nil {
+		return err
+	}
+	if sent >= loginLinksPerClient {
+		return tooManyRequests("Sorry, too many login links have been asked for lately. Please try again in %d minutes.", int(loginTokenTTL.Minutes()))
+	}
+
+	user, err := s.FindUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		pending.User = user.Id
+	}
+
+	pending.Id, err = randomId()
+	if err != nil {
+		return err
+	}
+	_, err = s.Store.Put("logins", pending.Id, pending)
+	if err != nil {
+		return err
+	}
+
+	token := s.signToken(strings.Join([]string{pending.Project, pending.User, pending.Id}, "|"))
+	link := fmt.Sprintf("%s/projects/%s/user/login/%s", strings.TrimRight(s.BaseUrl, "/"), s.ActiveProjectId, token)
+	body := fmt.Sprintf("Use this link to sign in. It expires in %d minutes and can only be used once.\r\n\r\n%s", int(loginTokenTTL.Minutes()), link)
+
+	return s.Mailer.SendMail(email, "Your sign in link", body)
+}
+
+// RedeemLoginToken validates a magic link token, consuming it, and returns the user it was issued for.
+func (s *Server) RedeemLoginToken(token string) (user *User, err error) {
+	payload, err := s.verifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(payload, "|")
+	if len(parts) != 3 || parts[0] != s.ActiveProjectId {
+		return nil, errors.New("This login link isn't valid for this project.")
+	}
+
+	var pending loginToken
+	err = s.Store.Get("logins", parts[2], &pending)
+	if err != nil {
+		return nil, errors.New("This login link has already been used or has expired.")
+	}
+
+	// one time use: remove the pending login before going any further
+	err = s.Store.Delete("logins", pending.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if pending.User != parts[1] || time.Now().Unix() > pending.Expires {
+		return nil, errors.New("This login link has already been used or has expired.")
+	}
+
+	if pending.User == "" {
+		return s.loginUserByEmail(pending.Email)
+	}
+	user, err = s.FindUser(pending.User)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("Sorry, there isn't the user for this login link.")
+	}
+	return user, nil
+}
+
+// loginUserByEmail returns the user with email, creating them the first time they sign in. Another link to the
+// address may have been used first, so there may be one by now.
+func (s *Server) loginUserByEmail(email string) (*User, error) {
+	user, err := s.FindUserByEmail(email)
+	if err != nil || user != nil {
+		return user, err
+	}
+	userJson, err := json.Marshal(User{Email: email})
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateUser(strings.NewReader(string(userJson)))
+}
+
+// @Title LoginHandler
+// @Description emails a one-time login link to the given address, which creates a user for it if needed
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   login        body   string     true        "JSON-formatted email address, ex: {\"Email\": \"person@example.com\"}"
+// @Success 200 {object}  string
+// @Failure 429 {object} errorResponse	too many links have been sent to the address, or asked for by the client
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/login [post]
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	var loginData struct {
+		Email string
+	}
+	err = json.Unmarshal(body, &loginData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.SendLoginLink(strings.TrimSpace(loginData.Email), s.clientIp(r))
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, []byte(`{"status": "sent"}`))
+}
+
+// localRedirect reports whether redirect is a path on this site, so login links can't bounce users to other sites.
+// Browsers read a backslash as a slash, so /\evil.com is as much another site as //evil.com is.
+func localRedirect(redirect string) bool {
+	if !strings.HasPrefix(redirect, "/") || strings.Contains(redirect, "\\") {
+		return false
+	}
+	parsed, err := url.Parse(redirect)
+	return err == nil && parsed.Scheme == "" && parsed.Host == "" && !strings.HasPrefix(parsed.Path, "//")
+}
+
+// @Title LoginTokenHandler
+// @Description redeems a one-time login link, setting the project's user session cookie
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   token     path    string     true        "Signed login token from the emailed link"
+// @Param   redirect        query   string     false        "If specified, a relative path to redirect to once signed in"
+// @Success 200 {object}  User
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/login/{token} [get]
+func (s *Server) LoginTokenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	user, err := s.RedeemLoginToken(vars["token"])
+	if err != nil {
+		s.wrapResponse(w, r, 403, s.wrapError(err))
+		return
+	}
+
+	s.SetSessionCookie(w, user.Id)
+
+	// logging in with the same email in other projects links the records to one person
+	s.linkIdentityQuietly(*user)
+
+	redirect := r.URL.Query().Get("redirect")
+	if localRedirect(redirect) {
+		http.Redirect(w, r, redirect, http.StatusFound)
+		return
+	}
+
+	userJson, err := marshalUser(r, user)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, userJson)
+}
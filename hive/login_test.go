@@ -0,0 +1,58 @@
+package hive
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyToken(t *testing.T) {
+	s := &Server{SecretKey: "change-me"}
+	token := s.signToken("crowd|user1|nonce1")
+	payload, err := s.verifyToken(token)
+	if err != nil || payload != "crowd|user1|nonce1" {
+		t.Fatalf("verifyToken(signToken(payload)) = %q, %v, want the payload", payload, err)
+	}
+
+	parts := strings.Split(token, ".")
+	other := &Server{SecretKey: "other"}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", parts[0]},
+		{"extra part", token + ".more"},
+		{"not base64", "!!!." + parts[1]},
+		{"changed payload", s.signToken("crowd|user2|nonce1")[:len(parts[0])] + "." + parts[1]},
+		{"signed with another secret", other.signToken("crowd|user1|nonce1")},
+	}
+	for _, test := range tests {
+		if _, err := s.verifyToken(test.token); err == nil {
+			t.Errorf("%s: verifyToken(%q) succeeded, want an error", test.name, test.token)
+		}
+	}
+}
+
+func TestLocalRedirect(t *testing.T) {
+	tests := []struct {
+		redirect string
+		want     bool
+	}{
+		{"/", true},
+		{"/projects/crowd", true},
+		{"/tasks?page=2#top", true},
+		{"", false},
+		{"projects/crowd", false},
+		{"//evil.com", false},
+		{"/\\evil.com", false},
+		{"/\\/evil.com", false},
+		{"https://evil.com", false},
+		{"/\t/evil.com", false},
+		{"javascript:alert(1)", false},
+	}
+	for _, test := range tests {
+		if got := localRedirect(test.redirect); got != test.want {
+			t.Errorf("localRedirect(%q) = %v, want %v", test.redirect, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metaRanges are the elasticsearch range bounds for each comparison a MetaCondition can make, besides "=".
+var metaRanges = map[string]string{">": "gt", ">=": "gte", "<": "lt", "<=": "lte"}
+
+// MetaCondition is a comparison on one of an asset's Metadata fields, ex: issueDate >= 1912-01-01.
+type MetaCondition struct {
+	Field string
+	Op    string // "=", ">", ">=", "<" or "<="
+	Value string
+}
+
+// ParseMetaConditions reads the meta.<field> query parameters, ex: meta.page=4 or meta.issueDate>=1912-01-01,
+// which reaches us as the parameter "meta.issueDate>" with the value "1912-01-01". Parameters can be repeated,
+// ex: meta.page>=4&meta.page<=8.
+func ParseMetaConditions(queryParams url.Values) (conditions []MetaCondition, err error) {
+	var keys []string
+	for key := range queryParams {
+		if strings.HasPrefix(key, "meta.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range queryParams[key] {
+			condition := MetaCondition{Field: strings.TrimPrefix(key, "meta."), Op: "=", Value: value}
+			if i := strings.IndexAny(condition.Field, "<>"); i >= 0 {
+				// meta.page>=4 splits at the "=", meta.page>4 has no "=" to split at
+				condition.Op = condition.Field[i:i+1] + "="
+				if i < len(condition.Field)-1 {
+					condition.Op = condition.Field[i : i+1]
+					condition.Value = condition.Field[i+1:]
+				}
+				condition.Field = condition.Field[:i]
+			}
+			if condition.Field == "" || condition.Value == "" {
+				invalid := &ValidationError{}
+				invalid.add(key, "isn't a metadata condition, try something like meta.page=4 or meta.issueDate>=1912-01-01, not %q", key+"="+value)
+				return nil, invalid
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return
+}
+
+// metaFilters turns conditions into filters on Asset.Metadata. Each field has to be one of the project's
+// MetaProperties, whose Type says whether the value is compared as a number, a boolean or as written.
+func (s *Server) metaFilters(conditions []MetaCondition) ([]string, error) {
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	var project *Project
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string)
+	for _, metaProp := range project.MetaProperties {
+		types[metaProp.Name] = metaProp.Type
+	}
+
+	var filters []string
+	for _, condition := range conditions {
+		metaType, ok := types[condition.Field]
+		if !ok {
+			invalid := &ValidationError{}
+			invalid.add("meta."+condition.Field, "can only filter on the project's MetaProperties, not %q", condition.Field)
+			return nil, invalid
+		}
+		valueJson, err := metaValueJson(metaType, condition.Value)
+		if err != nil {
+			invalid := &ValidationError{}
+			invalid.add("meta."+condition.Field, "has to be a %s, not %q", metaType, condition.Value)
+			return nil, invalid
+		}
+		if condition.Op == "=" {
+			filters = append(filters, fmt.Sprintf(`{ "term": { "Metadata.%s": %s } }`, condition.Field, valueJson))
+		} else {
+			filters = append(filters, fmt.Sprintf(`{ "range": { "Metadata.%s": { "%s": %s } } }`, condition.Field, metaRanges[condition.Op], valueJson))
+		}
+	}
+	return filters, nil
+}
+
+// metaValueJson encodes a query parameter value as the JSON elasticsearch expects for a field mapped as metaType.
+func metaValueJson(metaType string, value string) (string, error) {
+	switch metaType {
+	case "integer", "long", "short", "byte":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "float", "double":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	}
+	valueJson, err := json.Marshal(value)
+	return string(valueJson), err
+}
@@ -0,0 +1,51 @@
+package hive
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetaConditions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []MetaCondition
+	}{
+		{"", nil},
+		{"task=find&from=0", nil},
+		{"meta.page=4", []MetaCondition{{Field: "page", Op: "=", Value: "4"}}},
+		{"meta.issueDate>=1912-01-01", []MetaCondition{{Field: "issueDate", Op: ">=", Value: "1912-01-01"}}},
+		{"meta.page<=8", []MetaCondition{{Field: "page", Op: "<=", Value: "8"}}},
+		// without an "=" to split at, url.Values has the whole condition as the key
+		{"meta.page>4", []MetaCondition{{Field: "page", Op: ">", Value: "4"}}},
+		{"meta.page<8", []MetaCondition{{Field: "page", Op: "<", Value: "8"}}},
+		{"meta.page>=4&meta.page<=8", []MetaCondition{
+			{Field: "page", Op: "<=", Value: "8"},
+			{Field: "page", Op: ">=", Value: "4"},
+		}},
+		{"meta.section=A&meta.section=B", []MetaCondition{
+			{Field: "section", Op: "=", Value: "A"},
+			{Field: "section", Op: "=", Value: "B"},
+		}},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		got, err := ParseMetaConditions(values)
+		if err != nil {
+			t.Errorf("ParseMetaConditions(%q) failed: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseMetaConditions(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseMetaConditionsInvalid(t *testing.T) {
+	for _, query := range []string{"meta.=4", "meta.page=", "meta.>=4", "meta.page>="} {
+		values, _ := url.ParseQuery(query)
+		if _, err := ParseMetaConditions(values); errorStatus(err) != 400 {
+			t.Errorf("ParseMetaConditions(%q) = %v, want a 400", query, err)
+		}
+	}
+}
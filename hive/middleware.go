@@ -0,0 +1,56 @@
+package hive
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps hive's handlers with extra behavior, ex: authentication, logging or metrics.
+type Middleware func(http.Handler) http.Handler
+
+// Use adds middleware around every request. Middleware runs in the order it's added, so the first sees requests first.
+// Call it before Run or Router.
+func (s *Server) Use(middleware ...Middleware) {
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// UseAdmin adds middleware around requests to the admin endpoints only, ex: to require staff credentials.
+// It runs after any middleware added with Use.
+func (s *Server) UseAdmin(middleware ...Middleware) {
+	s.adminMiddleware = append(s.adminMiddleware, middleware...)
+}
+
+// isAdminPath reports whether a request path is for an admin endpoint, with or without the api version prefix.
+func isAdminPath(path string) bool {
+	path = trimApiVersion(path)
+	return path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// wrapMiddleware returns h wrapped in the registered middleware.
+// When AdminKeys are set, admin requests have to pass requireAdminKey before any admin middleware.
+func (s *Server) wrapMiddleware(h http.Handler) http.Handler {
+	adminMiddleware := s.adminMiddleware
+	if len(s.AdminKeys) > 0 {
+		adminMiddleware = append([]Middleware{s.requireAdminKey}, adminMiddleware...)
+	}
+	if len(adminMiddleware) > 0 {
+		admin := chainMiddleware(h, adminMiddleware)
+		public := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAdminPath(r.URL.Path) {
+				admin.ServeHTTP(w, r)
+				return
+			}
+			public.ServeHTTP(w, r)
+		})
+	}
+	return chainMiddleware(h, s.middleware)
+}
+
+// chainMiddleware wraps h so the first middleware given is the outermost.
+func chainMiddleware(h http.Handler, middleware []Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
@@ -0,0 +1,578 @@
+package hive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// When volunteers can't keep up, a task's remaining assets can be paid for on Amazon Mechanical Turk. Each asset is
+// published as a HIT (a Human Intelligence Task) asking for as many answers as its CompletionCriteria still needs.
+// Workers do the task on the project's own frontend, shown in a frame on MTurk, and syncing brings their answers
+// back as finished assignments by users standing in for each worker, so they're verified like anyone else's.
+
+const (
+	mturkProductionEndpoint = "https://mturk-requester.us-east-1.amazonaws.com"
+	mturkSandboxEndpoint    = "https://mturk-requester-sandbox.us-east-1.amazonaws.com"
+
+	// mturkExternalIdPrefix marks the users standing in for MTurk workers, ex: "mturk:A1B2C3D4E5"
+	mturkExternalIdPrefix = "mturk:"
+)
+
+// errEnoughHits stops paging through assets once a publish has made as many HITs as it was asked for.
+var errEnoughHits = errors.New("enough hits")
+
+// MturkClient calls Mechanical Turk's requester API.
+type MturkClient struct {
+	Endpoint  string // ex: mturkSandboxEndpoint to try things out without paying anyone
+	AccessKey string
+	SecretKey string
+}
+
+// MturkSettings say how a task's assets are published as HITs. Reward and ExternalUrl are required.
+type MturkSettings struct {
+	Reward            string // paid per answer, in US dollars, ex: "0.05"
+	Title             string // shown to workers browsing HITs, defaults to the task's Description
+	Description       string // defaults to the task's Description
+	Keywords          string // comma-separated, ex: "transcription, newspaper"
+	ExternalUrl       string // the frontend page workers do the task on; asset, task and project query params are added
+	FrameHeight       int    // pixels, defaults to 800
+	LifetimeMinutes   int    // how long a HIT can be found, defaults to a week
+	AssignmentMinutes int    // how long a worker has to answer, defaults to 30
+	AutoApproveHours  int    // how long before answers are approved and paid automatically, defaults to 72
+}
+
+// mturkHit records a HIT published for an asset, and which of its answers have been brought back.
+type mturkHit struct {
+	Id             string // the HITId
+	Project        string
+	Task           string
+	Asset          string
+	MaxAssignments int       // answers asked for
+	Ingested       []string  // MTurk assignment ids already brought back as assignments
+	Closed         bool      // no more answers are expected: it's had them all, or its asset was verified and it was expired
+	Created        time.Time // when it was published
+}
+
+type mturkPublishResponse struct {
+	Published int // HITs created
+	Answers   int // answers asked for across them
+	Hits      []mturkHit
+}
+
+type mturkSyncResponse struct {
+	Ingested int // answers brought back as finished assignments
+	Refused  int // answers from workers who'd reached the task's MaxAssignmentsPerUser, left out
+	Verified int // assets the new answers verified
+	Closed   int // HITs that won't get any more answers
+}
+
+// mturkAnswers is the QuestionFormAnswers XML MTurk hands back a worker's answer in.
+type mturkAnswers struct {
+	Answers []struct {
+		QuestionIdentifier string
+		FreeText           string
+	} `xml:"Answer"`
+}
+
+// call makes a request to the MTurk API, ex: "CreateHIT", signed with AWS signature version 4.
+func (m *MturkClient) call(operation string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	endpoint := m.Endpoint
+	if endpoint == "" {
+		endpoint = mturkProductionEndpoint
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	target := "MTurkRequesterServiceV20170117." + operation
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := sha256.Sum256(body)
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalRequest := strings.Join([]string{
+		"POST",
+		"/",
+		"",
+		"content-type:application/x-amz-json-1.1\nhost:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\nx-amz-target:" + target + "\n",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope, signature := awsSignature(m.SecretKey, "us-east-1", "mturk-requester", canonicalRequest, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		m.AccessKey, scope, signedHeaders, signature))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Sorry, MTurk refused %s: %s %s", operation, resp.Status, respBody)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, response)
+}
+
+// mturkQuestion is the ExternalQuestion XML that shows a task's frontend for asset in a frame on MTurk.
+func mturkQuestion(settings MturkSettings, task Task, assetId string) (string, error) {
+	pageUrl, err := url.Parse(settings.ExternalUrl)
+	if err != nil {
+		return "", err
+	}
+	query := pageUrl.Query()
+	query.Set("project", task.Project)
+	query.Set("task", task.Name)
+	query.Set("asset", assetId)
+	pageUrl.RawQuery = query.Encode()
+
+	var escapedUrl bytes.Buffer
+	err = xml.EscapeText(&escapedUrl, []byte(pageUrl.String()))
+	if err != nil {
+		return "", err
+	}
+	frameHeight := settings.FrameHeight
+	if frameHeight <= 0 {
+		frameHeight = 800
+	}
+	return fmt.Sprintf(`<ExternalQuestion xmlns="http://mechanicalturk.amazonaws.com/AWSMechanicalTurkDataSchemas/2006-07-14/ExternalQuestion.xsd"><ExternalURL>%s</ExternalURL><FrameHeight>%d</FrameHeight></ExternalQuestion>`, escapedUrl.String(), frameHeight), nil
+}
+
+// PublishHits creates a HIT for each of up to n of the task's assets that still need answers and don't have one
+// already, asking for as many answers as the task's CompletionCriteria.Total still needs.
+// Assets are picked by the same criteria assignments are.
+func (s *Server) PublishHits(task Task, n int) (published mturkPublishResponse, err error) {
+	published.Hits = []mturkHit{}
+	if s.Mturk == nil {
+		return published, errors.New("Sorry, Mechanical Turk isn't configured on this server: it needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.")
+	}
+	if task.Mturk == nil || task.Mturk.Reward == "" || task.Mturk.ExternalUrl == "" {
+		return published, errors.New("Sorry, set the task's Mturk settings, with at least a Reward and an ExternalUrl, before publishing it.")
+	}
+	settings := *task.Mturk
+	if settings.Title == "" {
+		settings.Title = task.Description
+	}
+	if settings.Description == "" {
+		settings.Description = task.Description
+	}
+	if settings.LifetimeMinutes <= 0 {
+		settings.LifetimeMinutes = 7 * 24 * 60
+	}
+	if settings.AssignmentMinutes <= 0 {
+		settings.AssignmentMinutes = 30
+	}
+	if settings.AutoApproveHours <= 0 {
+		settings.AutoApproveHours = 72
+	}
+
+	// assets that already have a HIT out are left alone
+	openHits := make(map[string]bool)
+	pastHits := make(map[string]int)
+	err = s.forEachMatchingDoc("hits", []string{fmt.Sprintf(`{ "term": { "Task": "%s" } }`, task.Id)}, func(source json.RawMessage) error {
+		var hit mturkHit
+		err := json.Unmarshal(source, &hit)
+		if err == nil && !hit.Closed {
+			openHits[hit.Asset] = true
+		}
+		pastHits[hit.Asset]++
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	task, err = s.liveCriteria(task)
+	if err != nil {
+		return
+	}
+	filters := criteriaMusts(task)
+	workflowMusts, err := s.workflowMusts(task)
+	if err != nil {
+		return
+	}
+	filters = append(filters, workflowMusts...)
+	filters = append(filters, fmt.Sprintf(`{ "missing": { "field": "SubmittedData.%s" } }`, task.Name))
+
+	err = s.forEachMatchingDoc("assets", filters, func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		if err != nil {
+			return err
+		}
+		if asset.Excluded || openHits[asset.Id] {
+			return nil
+		}
+
+		answered, err := s.FindAssetAssignments(task.Id, asset.Id, "finished", "verified")
+		if err != nil {
+			return err
+		}
+		needed := task.CompletionCriteria.Total - len(answered)
+		if needed < 1 {
+			needed = 1
+		}
+
+		hit, err := s.createHit(task, settings, asset.Id, needed, pastHits[asset.Id])
+		if err != nil {
+			return err
+		}
+		published.Published++
+		published.Answers += needed
+		published.Hits = append(published.Hits, hit)
+		if published.Published >= n {
+			return errEnoughHits
+		}
+		return nil
+	})
+	if err == errEnoughHits {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	err = s.Store.Refresh()
+	return
+}
+
+// createHit publishes one asset as a HIT and records it. pastHits is how many HITs the asset has had before.
+func (s *Server) createHit(task Task, settings MturkSettings, assetId string, maxAssignments int, pastHits int) (hit mturkHit, err error) {
+	question, err := mturkQuestion(settings, task, assetId)
+	if err != nil {
+		return
+	}
+	// MTurk won't create a second HIT for the same token, so a publish retried after a failure doesn't pay twice
+	token := sha256.Sum256([]byte(strings.Join([]string{task.Id, assetId, strconv.Itoa(pastHits)}, "\x00")))
+
+	var created struct {
+		HIT struct {
+			HITId string
+		}
+	}
+	err = s.Mturk.call("CreateHIT", map[string]interface{}{
+		"Title":                       settings.Title,
+		"Description":                 settings.Description,
+		"Keywords":                    settings.Keywords,
+		"Reward":                      settings.Reward,
+		"MaxAssignments":              maxAssignments,
+		"LifetimeInSeconds":           settings.LifetimeMinutes * 60,
+		"AssignmentDurationInSeconds": settings.AssignmentMinutes * 60,
+		"AutoApprovalDelayInSeconds":  settings.AutoApproveHours * 60 * 60,
+		"Question":                    question,
+		"RequesterAnnotation":         assetId,
+		"UniqueRequestToken":          hex.EncodeToString(token[:16]),
+	}, &created)
+	if err != nil {
+		return
+	}
+
+	hit = mturkHit{
+		Id:             created.HIT.HITId,
+		Project:        s.ActiveProjectId,
+		Task:           task.Id,
+		Asset:          assetId,
+		MaxAssignments: maxAssignments,
+		Ingested:       []string{},
+		Created:        time.Now().UTC(),
+	}
+	_, err = s.Store.Put("hits", hit.Id, hit)
+	return
+}
+
+// mturkWorker returns the user standing in for an MTurk worker, creating it the first time. These users are left
+// off the leaderboard.
+func (s *Server) mturkWorker(workerId string) (*User, error) {
+	externalId := mturkExternalIdPrefix + workerId
+	searchJson := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "term": { "ExternalId": "%s" } }, { "term": { "Project": "%s" } } ] } } } } }`, externalId, s.ActiveProjectId)
+	results, err := s.Store.Search("users", searchJson)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Hits.Hits) > 0 {
+		var user User
+		err = json.Unmarshal(*results.Hits.Hits[0].Source, &user)
+		return &user, err
+	}
+
+	user, err := s.CreateExternalUser(externalId)
+	if err != nil {
+		return nil, err
+	}
+	user.Name = "MTurk worker " + workerId
+	user.HideFromLeaderboard = true
+	_, err = s.Store.Put("users", user.Id, user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, s.Store.Refresh()
+}
+
+// mturkSubmittedData reads a worker's answer. A frontend can post its answer as JSON in a field named
+// "SubmittedData", otherwise each field it posts becomes a key of the submitted data.
+func mturkSubmittedData(answerXml string) (SubmittedData, error) {
+	var answers mturkAnswers
+	err := xml.Unmarshal([]byte(answerXml), &answers)
+	if err != nil {
+		return nil, err
+	}
+	submittedData := SubmittedData{}
+	for _, answer := range answers.Answers {
+		if answer.QuestionIdentifier == "SubmittedData" {
+			err = json.Unmarshal([]byte(answer.FreeText), &submittedData)
+			if err != nil {
+				return nil, fmt.Errorf("Sorry, a worker's SubmittedData isn't JSON: %v", err)
+			}
+			continue
+		}
+		submittedData[answer.QuestionIdentifier] = answer.FreeText
+	}
+	return submittedData, nil
+}
+
+// SyncHits brings the answers workers have submitted to the project's open HITs back as finished assignments,
+// then verifies the assets that now meet their task's CompletionCriteria and expires the HITs they no longer need.
+func (s *Server) SyncHits() (synced mturkSyncResponse, err error) {
+	if s.Mturk == nil {
+		return synced, errors.New("Sorry, Mechanical Turk isn't configured on this server: it needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.")
+	}
+
+	var hits []mturkHit
+	err = s.forEachMatchingDoc("hits", []string{`{ "term": { "Closed": false } }`}, func(source json.RawMessage) error {
+		var hit mturkHit
+		err := json.Unmarshal(source, &hit)
+		hits = append(hits, hit)
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	tasks := make(map[string]*Task)
+	for i := range hits {
+		hit := &hits[i]
+		if tasks[hit.Task] == nil {
+			tasks[hit.Task], err = s.FindTask(hit.Task)
+			if err != nil {
+				return
+			}
+		}
+		ingested, refused, err := s.ingestHit(hit)
+		synced.Ingested += ingested
+		synced.Refused += refused
+		if err != nil {
+			return synced, err
+		}
+	}
+
+	// the new answers count towards completion like anyone else's
+	for _, task := range tasks {
+		completed, err := s.CompleteTask(task.Name)
+		if err != nil {
+			return synced, err
+		}
+		synced.Verified += len(completed)
+	}
+
+	for i := range hits {
+		hit := &hits[i]
+		asset, err := s.FindAsset(hit.Asset)
+		if err != nil {
+			return synced, err
+		}
+		verified := asset != nil && asset.SubmittedData[tasks[hit.Task].Name] != nil
+		if verified {
+			// stop paying for answers that aren't needed anymore
+			err = s.Mturk.call("UpdateExpirationForHIT", map[string]interface{}{"HITId": hit.Id, "ExpireAt": 0}, nil)
+			if err != nil {
+				return synced, err
+			}
+		}
+		if verified || len(hit.Ingested) >= hit.MaxAssignments {
+			hit.Closed = true
+			synced.Closed++
+			_, err = s.Store.Put("hits", hit.Id, hit)
+			if err != nil {
+				return synced, err
+			}
+		}
+	}
+	err = s.Store.Refresh()
+	return
+}
+
+// ingestHit records the answers submitted to hit since it was last synced.
+func (s *Server) ingestHit(hit *mturkHit) (ingested int, refused int, err error) {
+	seen := make(map[string]bool)
+	for _, id := range hit.Ingested {
+		seen[id] = true
+	}
+
+	nextToken := ""
+	for {
+		request := map[string]interface{}{
+			"HITId":              hit.Id,
+			"MaxResults":         100,
+			"AssignmentStatuses": []string{"Submitted", "Approved"},
+		}
+		if nextToken != "" {
+			request["NextToken"] = nextToken
+		}
+		var listed struct {
+			Assignments []struct {
+				AssignmentId string
+				WorkerId     string
+				Answer       string
+			}
+			NextToken string
+		}
+		err = s.Mturk.call("ListAssignmentsForHIT", request, &listed)
+		if err != nil {
+			return
+		}
+
+		for _, answer := range listed.Assignments {
+			if seen[answer.AssignmentId] {
+				continue
+			}
+			err = s.ingestAnswer(*hit, answer.WorkerId, answer.Answer)
+			if err == ErrTaskLimitReached || err == ErrTooManyUnfinished {
+				refused++
+			} else if err != nil {
+				return
+			} else {
+				ingested++
+			}
+			seen[answer.AssignmentId] = true
+			hit.Ingested = append(hit.Ingested, answer.AssignmentId)
+			_, err = s.Store.Put("hits", hit.Id, hit)
+			if err != nil {
+				return
+			}
+		}
+
+		if listed.NextToken == "" || len(listed.Assignments) == 0 {
+			return ingested, refused, nil
+		}
+		nextToken = listed.NextToken
+	}
+}
+
+// ingestAnswer hands hit's asset to the worker's user and submits their answer for it.
+func (s *Server) ingestAnswer(hit mturkHit, workerId string, answerXml string) error {
+	submittedData, err := mturkSubmittedData(answerXml)
+	if err != nil {
+		return err
+	}
+	user, err := s.mturkWorker(workerId)
+	if err != nil {
+		return err
+	}
+	assignment, err := s.CreateAssetAssignment(hit.Task, user.Id, hit.Asset)
+	if err != nil {
+		return err
+	}
+	assignment.State = "finished"
+	assignment.SubmittedData = submittedData
+	assignmentJson, err := json.Marshal(assignment)
+	if err != nil {
+		return err
+	}
+	_, err = s.UpdateAssignment(bytes.NewReader(assignmentJson), "mturk")
+	return err
+}
+
+// @Title AdminPublishHitsHandler
+// @Description publishes a task's unfinished assets as paid Mechanical Turk HITs, to finish a backlog volunteers can't keep up with
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task_id        path   string     true        "Task ID"
+// @Param   n        query   int     false        "How many assets to publish, defaults to 10 (max 1000)"
+// @Success 200 {object}  mturkPublishResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/tasks/{task_id}/mturk/publish [post]
+func (s *Server) AdminPublishHitsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	taskId := vars["task_id"]
+	if !strings.HasPrefix(vars["task_id"], s.ActiveProjectId) && vars["task_id"] != "" {
+		taskId = s.ActiveProjectId + "-" + vars["task_id"]
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	n, err := strconv.Atoi(defaultQuery(r.URL.Query(), "n", "10"))
+	if err != nil || n < 1 {
+		n = 10
+	}
+	if n > 1000 {
+		n = 1000
+	}
+
+	published, err := s.PublishHits(*task, n)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	publishedJson, err := json.Marshal(published)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, publishedJson)
+}
+
+// @Title AdminSyncHitsHandler
+// @Description brings answers submitted on Mechanical Turk back as assignments, verifies the assets they complete and expires HITs that aren't needed anymore
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Success 200 {object}  mturkSyncResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/mturk/sync [post]
+func (s *Server) AdminSyncHitsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	synced, err := s.SyncHits()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	syncedJson, err := json.Marshal(synced)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, syncedJson)
+}
@@ -0,0 +1,237 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// OnboardingStep is one screen of a project's tutorial, shown to new contributors before their first assignment.
+// Steps are stored in order on the project and can introduce the project, walk through example assets or ask a quiz question.
+type OnboardingStep struct {
+	Id       string   // required, unique within the project (ex: "intro", "example-1")
+	Type     string   // "intro", "example" or "quiz"; defaults to "intro"
+	Title    string   // optional, a displayable heading
+	Text     string   // optional, intro copy or explanation
+	Assets   []string // optional, ids of example assets to show alongside the step
+	Question string   // quiz steps only, the question to ask
+	Choices  []string // quiz steps only, optional answers to pick from
+	Answer   string   `json:",omitempty"` // quiz steps only, the correct answer; never sent to contributors
+}
+
+// onboardingStepStatus is a step as seen by a contributor, with the quiz answer removed.
+type onboardingStepStatus struct {
+	OnboardingStep
+	Completed bool
+}
+
+type onboardingResponse struct {
+	Steps     []onboardingStepStatus
+	Completed bool // true once the user has completed every step
+}
+
+var onboardingStepTypes = map[string]bool{
+	"intro":   true,
+	"example": true,
+	"quiz":    true,
+}
+
+// validateOnboarding checks a project's onboarding steps, filling in the default step type.
+func validateOnboarding(steps []OnboardingStep) error {
+	seen := make(map[string]bool)
+	for i := range steps {
+		step := &steps[i]
+		if step.Id == "" {
+			return errors.New("Sorry, onboarding steps require an Id.")
+		}
+		if seen[step.Id] {
+			return fmt.Errorf("Sorry, onboarding step '%s' is listed more than once.", step.Id)
+		}
+		seen[step.Id] = true
+
+		if step.Type == "" {
+			step.Type = "intro"
+		}
+		if !onboardingStepTypes[step.Type] {
+			return fmt.Errorf("Sorry, onboarding step '%s' has an unknown type; use intro, example or quiz.", step.Id)
+		}
+		if step.Type == "quiz" && (step.Question == "" || step.Answer == "") {
+			return fmt.Errorf("Sorry, quiz step '%s' requires a Question and an Answer.", step.Id)
+		}
+	}
+	return nil
+}
+
+// publicOnboarding returns a copy of the steps without quiz answers.
+func publicOnboarding(steps []OnboardingStep) []OnboardingStep {
+	public := make([]OnboardingStep, len(steps))
+	for i, step := range steps {
+		step.Answer = ""
+		public[i] = step
+	}
+	return public
+}
+
+// onboardingStatus lists the project's onboarding steps, marking the ones the user has completed.
+func onboardingStatus(project Project, user User) onboardingResponse {
+	done := make(map[string]bool)
+	for _, stepId := range user.OnboardingSteps {
+		done[stepId] = true
+	}
+
+	resp := onboardingResponse{
+		Steps:     make([]onboardingStepStatus, 0),
+		Completed: true,
+	}
+	for _, step := range publicOnboarding(project.Onboarding) {
+		resp.Steps = append(resp.Steps, onboardingStepStatus{
+			OnboardingStep: step,
+			Completed:      done[step.Id],
+		})
+		if !done[step.Id] {
+			resp.Completed = false
+		}
+	}
+	return resp
+}
+
+// findOnboardingUser looks up the project and the current user from the session cookie, creating the user record if needed.
+func (s *Server) findOnboardingUser(r *http.Request) (*Project, *User, error) {
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		return nil, nil, unauthorized("Sorry, onboarding requires a valid user.")
+	}
+
+	var project *Project
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.FindUser(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		tmpUser, err := s.CreateUserFromMissingCookieValue(userId)
+		if err != nil {
+			return nil, nil, err
+		}
+		user = &tmpUser
+	}
+	return project, user, nil
+}
+
+// @Title OnboardingHandler
+// @Description returns the project's onboarding steps in order, marking which ones the current user has completed
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object}  onboardingResponse
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/onboarding [get]
+func (s *Server) OnboardingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	project, user, err := s.findOnboardingUser(r)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	onboardingJson, err := json.Marshal(onboardingStatus(*project, *user))
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, onboardingJson)
+}
+
+// @Title CompleteOnboardingStepHandler
+// @Description marks an onboarding step completed for the current user; quiz steps require the correct answer
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   step_id     path    string     true        "Onboarding step ID"
+// @Param   answer        body   string     false        "JSON-formatted quiz answer, ex: {\"Answer\": \"yes\"}"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object}  onboardingResponse
+// @Failure 400 {object} errorResponse	Answer isn't the right answer
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/onboarding/{step_id} [post]
+func (s *Server) CompleteOnboardingStepHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+	stepId := vars["step_id"]
+
+	project, user, err := s.findOnboardingUser(r)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	var step *OnboardingStep
+	for i := range project.Onboarding {
+		if project.Onboarding[i].Id == stepId {
+			step = &project.Onboarding[i]
+			break
+		}
+	}
+	if step == nil {
+		s.wrapFailure(w, r, notFound("Sorry, there isn't an onboarding step '%s' in this project.", stepId))
+		return
+	}
+
+	if step.Type == "quiz" {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+		var answerData struct {
+			Answer string
+		}
+		if len(body) > 0 {
+			err = json.Unmarshal(body, &answerData)
+			if err != nil {
+				s.wrapFailure(w, r, err)
+				return
+			}
+		}
+		if !strings.EqualFold(strings.TrimSpace(answerData.Answer), strings.TrimSpace(step.Answer)) {
+			s.wrapFailure(w, r, &ValidationError{
+				Message: "Sorry, that's not the right answer. Please try again.",
+				Fields:  []FieldError{{Field: "Answer", Error: "isn't the right answer"}},
+			})
+			return
+		}
+	}
+
+	user, err = s.updateUser(user.Id, func(user *User) error {
+		for _, done := range user.OnboardingSteps {
+			if done == stepId {
+				return errUnchanged
+			}
+		}
+		user.OnboardingSteps = append(user.OnboardingSteps, stepId)
+		return nil
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	onboardingJson, err := json.Marshal(onboardingStatus(*project, *user))
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, onboardingJson)
+}
@@ -0,0 +1,108 @@
+package hive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// assetPatch holds the asset fields an admin can correct after import. Fields left out of the request are unchanged.
+type assetPatch struct {
+	Name     *string
+	Url      *string
+	Metadata map[string]interface{} // merged into the asset's Metadata; a null value removes that key
+}
+
+// PatchAsset applies a patch to an asset in the current project, keeping its SubmittedData, Counts and Verified flag.
+func (s *Server) PatchAsset(assetId string, patch assetPatch) (asset *Asset, err error) {
+	if patch.Url != nil {
+		invalid := &ValidationError{}
+		if problem := validateAssetUrl(*patch.Url); problem != "" {
+			invalid.add("Url", "%s", problem)
+		}
+		err = invalid.orNil()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	asset, err = s.updateAsset(assetId, func(asset *Asset) error {
+		if asset.Project != s.ActiveProjectId {
+			return ErrNotFound
+		}
+		if patch.Name != nil {
+			asset.Name = *patch.Name
+		}
+		if patch.Url != nil {
+			asset.Url = *patch.Url
+		}
+		for key, value := range patch.Metadata {
+			if value == nil {
+				delete(asset.Metadata, key)
+				continue
+			}
+			if asset.Metadata == nil {
+				asset.Metadata = make(map[string]interface{})
+			}
+			asset.Metadata[key] = value
+		}
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// @Title AdminPatchAssetHandler
+// @Description corrects an asset's Name, Url or Metadata without reimporting it, so crowdsourcing progress is kept
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Param   asset        body   string     true        "JSON-formatted fields to change, ex: {\"Name\": \"Page 2\", \"Metadata\": {\"page\": 2, \"typo\": null}}"
+// @Success 200 {object}  assetResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id} [patch]
+func (s *Server) AdminPatchAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	var patch assetPatch
+	err = json.Unmarshal(body, &patch)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	asset, err := s.PatchAsset(vars["asset_id"], patch)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assetJson, err := json.Marshal(assetResponse{
+		Asset: *asset,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetJson)
+}
@@ -0,0 +1,100 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// prioritySort orders eligible assets highest Priority first. Assets imported before priorities existed count as 0.
+const prioritySort = `[ { "Priority": { "order": "desc", "missing": 0 } } ]`
+
+// randomTopPriority picks a random asset from the highest priority ones among hits, which are sorted by prioritySort.
+func randomTopPriority(hits []SearchHit) (asset Asset, err error) {
+	var top []Asset
+	for _, hit := range hits {
+		var hitAsset Asset
+		err = json.Unmarshal(*hit.Source, &hitAsset)
+		if err != nil {
+			return asset, err
+		}
+		if len(top) > 0 && hitAsset.Priority < top[0].Priority {
+			break
+		}
+		top = append(top, hitAsset)
+	}
+	if len(top) == 0 {
+		return asset, errors.New("No assets found")
+	}
+	return top[rand.Intn(len(top))], nil
+}
+
+// UpdateAssetPriority changes where an asset stands in the assignment queue: eligible assets with higher priorities
+// are handed out first.
+func (s *Server) UpdateAssetPriority(assetId string, priority int) (asset *Asset, err error) {
+	asset, err = s.updateAsset(assetId, func(asset *Asset) error {
+		if asset.Project != s.ActiveProjectId {
+			return ErrNotFound
+		}
+		asset.Priority = priority
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// @Title AssetPriorityHandler
+// @Description sets an asset's priority, ex: to push time-sensitive material to the front of the assignment queue
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Param   priority       body    string     true        "JSON-formatted priority, ex: {\"Priority\": 10}"
+// @Success 200 {object}  assetResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id}/priority [post]
+func (s *Server) AssetPriorityHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var priorityData struct {
+		Priority int
+	}
+	err = json.Unmarshal(body, &priorityData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	asset, err := s.UpdateAssetPriority(vars["asset_id"], priorityData.Priority)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	assetJson, err := json.Marshal(assetResponse{
+		Asset: *asset,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetJson)
+}
@@ -0,0 +1,407 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultReconcileInterval is how often Run reconciles stored counts unless configured otherwise.
+const defaultReconcileInterval = time.Hour
+
+// assignmentStates are the states an assignment can be in, each of which is counted on its asset.
+var assignmentStates = []string{"unfinished", "finished", "skipped", "expired", "verified", "rejected"}
+
+// reconcileResponse says how many stored counts a reconciliation found had drifted, and repaired.
+type reconcileResponse struct {
+	Assets int // assets whose Counts didn't match their assignments
+	Users  int // users whose Counts or VerifiedAssets didn't match their assignments
+}
+
+// assignmentCounts returns an asset's Counts recomputed from how many of its assignments are in each state.
+// Counts that don't come from assignments, ex: Favorites and Flags, are kept.
+func assignmentCounts(counts Counts, states []termBucket) Counts {
+	recounted := Counts{
+		"Favorites":   0,
+		"Assignments": 0,
+		"finished":    0,
+		"skipped":     0,
+		"unfinished":  0,
+	}
+	for key, count := range counts {
+		if key != "Assignments" && !containsString(assignmentStates, key) {
+			recounted[key] = count
+		}
+	}
+	for _, bucket := range states {
+		recounted[bucket.Term] = bucket.Count
+		// abandoned assignments don't count, see ExpireAssignments
+		if bucket.Term != "expired" {
+			recounted["Assignments"] += bucket.Count
+		}
+	}
+	return recounted
+}
+
+// countsEqual compares counts, treating a missing count as 0.
+func countsEqual(a Counts, b Counts) bool {
+	for key, count := range a {
+		if b[key] != count {
+			return false
+		}
+	}
+	for key, count := range b {
+		if a[key] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// sameStrings reports whether a and b hold the same strings, in any order.
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether list holds s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileCounts repairs the stored counts of every project, see ReconcileProjectCounts.
+func (s *Server) ReconcileCounts() error {
+	for from := 0; ; from += backfillPageSize {
+		searchJson := fmt.Sprintf(`{
+			"query": { "match_all": {} },
+			"from": %d,
+			"size": %d,
+			"sort": [ { "Id": { "order": "asc" } } ]
+		}`, from, backfillPageSize)
+
+		results, err := s.Store.Search("projects", searchJson)
+		if err != nil {
+			return err
+		}
+		for _, hit := range results.Hits.Hits {
+			// one project's trouble shouldn't hold up the rest
+			scoped := s.forProject(hit.Id)
+			_, err = scoped.ReconcileProjectCounts()
+			if err != nil {
+				scoped.logError("failed reconciling counts", err, nil)
+			}
+		}
+		if len(results.Hits.Hits) < backfillPageSize {
+			return nil
+		}
+	}
+}
+
+// ReconcileProjectCounts recomputes the Counts stored on the current project's assets and users from their
+// assignments, and saves those that had drifted. Counts are updated as assignments are written, so this only
+// catches what those updates missed, ex: two submissions for the same asset at once. A project's own counts are
+// tallied whenever it's read, so never drift.
+func (s *Server) ReconcileProjectCounts() (reconciled reconcileResponse, err error) {
+	err = s.Store.Refresh()
+	if err != nil {
+		return
+	}
+
+	var page []Asset
+	reconcileAssets := func() error {
+		repaired, err := s.reconcileAssetCounts(page)
+		reconciled.Assets += repaired
+		page = nil
+		return err
+	}
+	err = s.forEachProjectDoc("assets", func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		if err != nil {
+			return err
+		}
+		page = append(page, asset)
+		if len(page) < backfillPageSize {
+			return nil
+		}
+		return reconcileAssets()
+	})
+	if err == nil {
+		err = reconcileAssets()
+	}
+	if err != nil {
+		return
+	}
+
+	var tasks []Task
+	err = s.forEachProjectDoc("tasks", func(source json.RawMessage) error {
+		var task Task
+		err := json.Unmarshal(source, &task)
+		tasks = append(tasks, task)
+		return err
+	})
+	if err != nil {
+		return
+	}
+	var users []User
+	reconcileUsers := func() error {
+		repaired, err := s.reconcileUserCounts(users, tasks)
+		reconciled.Users += repaired
+		users = nil
+		return err
+	}
+	err = s.forEachProjectDoc("users", func(source json.RawMessage) error {
+		var user User
+		err := json.Unmarshal(source, &user)
+		if err != nil {
+			return err
+		}
+		users = append(users, user)
+		if len(users) < backfillPageSize {
+			return nil
+		}
+		return reconcileUsers()
+	})
+	if err == nil {
+		err = reconcileUsers()
+	}
+	if err != nil {
+		return
+	}
+
+	if reconciled.Assets > 0 || reconciled.Users > 0 {
+		s.logEvent("reconciled counts", logFields{"assets": reconciled.Assets, "users": reconciled.Users})
+	}
+	return reconciled, s.Store.Refresh()
+}
+
+// reconcileAssetCounts tallies the assignments of a page of assets in one search, and saves the assets whose Counts
+// had drifted. It returns how many were.
+func (s *Server) reconcileAssetCounts(assets []Asset) (int, error) {
+	if len(assets) == 0 {
+		return 0, nil
+	}
+	var ids []string
+	for _, asset := range assets {
+		ids = append(ids, asset.Id)
+	}
+	idsJson, err := json.Marshal(ids)
+	if err != nil {
+		return 0, err
+	}
+	searchJson := fmt.Sprintf(`{
+		"size": 0,
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [
+							{ "term": { "Project": "%s" } },
+							{ "terms": { "Asset.Id": %s } }
+						]
+					}
+				}
+			}
+		},
+		"aggs": {
+			"assets": {
+				"terms": { "field": "Asset.Id", "size": %d },
+				"aggs": {
+					"States": { "terms": { "field": "State" } }
+				}
+			}
+		}
+	}`, s.ActiveProjectId, idsJson, len(ids))
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return 0, err
+	}
+	var agg struct {
+		Assets struct {
+			Buckets []struct {
+				Id     string `json:"key"`
+				States struct {
+					Buckets []termBucket `json:"buckets"`
+				}
+			} `json:"buckets"`
+		} `json:"assets"`
+	}
+	err = json.Unmarshal(results.Aggregations, &agg)
+	if err != nil {
+		return 0, err
+	}
+	states := make(map[string][]termBucket)
+	for _, bucket := range agg.Assets.Buckets {
+		states[bucket.Id] = bucket.States.Buckets
+	}
+
+	repaired := 0
+	for _, asset := range assets {
+		recounted := assignmentCounts(asset.Counts, states[asset.Id])
+		if countsEqual(asset.Counts, recounted) {
+			continue
+		}
+		// saved through updateAsset, so a submission since the page was read isn't undone
+		_, err = s.updateAsset(asset.Id, func(current *Asset) error {
+			current.Counts = assignmentCounts(current.Counts, states[asset.Id])
+			return nil
+		})
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return repaired, err
+		}
+		s.logEvent("repaired asset counts", logFields{"asset": asset.Id, "before": asset.Counts, "after": recounted})
+		repaired++
+	}
+	return repaired, nil
+}
+
+// userTallies adds up the finished and verified assignments of each of users, in one search, by user id.
+func (s *Server) userTallies(users []User) (map[string]userTally, error) {
+	var ids []string
+	for _, user := range users {
+		ids = append(ids, user.Id)
+	}
+	idsJson, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	searchJson := fmt.Sprintf(`{
+		"size": 0,
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [
+							{ "term": { "Project": "%s" } },
+							{ "terms": { "User": %s } },
+							{ "terms": { "State": [ "finished", "verified" ] } }
+						]
+					}
+				}
+			}
+		},
+		"aggs": {
+			"users": {
+				"terms": { "field": "User", "size": %d },
+				"aggs": {
+					"tasks": { "terms": { "field": "Task", "size": 0 } },
+					"verified": {
+						"filter": { "term": { "State": "verified" } },
+						"aggs": {
+							"assets": { "terms": { "field": "Asset.Id", "size": 0 } }
+						}
+					}
+				}
+			}
+		}
+	}`, s.ActiveProjectId, idsJson, len(ids))
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return nil, err
+	}
+	var agg struct {
+		Users struct {
+			Buckets []struct {
+				Id    string `json:"key"`
+				Count int    `json:"doc_count"`
+				Tasks struct {
+					Buckets []termBucket `json:"buckets"`
+				} `json:"tasks"`
+				Verified struct {
+					Assets struct {
+						Buckets []termBucket `json:"buckets"`
+					} `json:"assets"`
+				} `json:"verified"`
+			} `json:"buckets"`
+		} `json:"users"`
+	}
+	err = json.Unmarshal(results.Aggregations, &agg)
+	if err != nil {
+		return nil, err
+	}
+
+	tallies := make(map[string]userTally)
+	for _, bucket := range agg.Users.Buckets {
+		tally := userTally{Assignments: bucket.Count, Tasks: Counts{}}
+		for _, task := range bucket.Tasks.Buckets {
+			tally.Tasks[task.Term] = task.Count
+		}
+		for _, asset := range bucket.Verified.Assets.Buckets {
+			tally.VerifiedAssets = append(tally.VerifiedAssets, asset.Term)
+		}
+		tallies[bucket.Id] = tally
+	}
+	return tallies, nil
+}
+
+// reconcileUserCounts tallies the assignments of a page of users in one search, and saves the users whose Counts
+// or VerifiedAssets had drifted. It returns how many were.
+func (s *Server) reconcileUserCounts(users []User, tasks []Task) (int, error) {
+	if len(users) == 0 {
+		return 0, nil
+	}
+	tallies, err := s.userTallies(users)
+	if err != nil {
+		return 0, err
+	}
+
+	repaired := 0
+	for _, user := range users {
+		recounted := user
+		applyUserTally(&recounted, tallies[user.Id], tasks)
+		if countsEqual(user.Counts, recounted.Counts) && sameStrings(user.VerifiedAssets, recounted.VerifiedAssets) {
+			continue
+		}
+		// saved through updateUser, so changes since the page was read aren't undone
+		_, err = s.updateUser(user.Id, func(current *User) error {
+			applyUserTally(current, tallies[user.Id], tasks)
+			return nil
+		})
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return repaired, err
+		}
+		s.logEvent("repaired user counts", logFields{"user": user.Id, "before": user.Counts, "after": recounted.Counts})
+		repaired++
+	}
+	return repaired, nil
+}
+
+// reconcileCounts runs ReconcileCounts every interval until stop is closed.
+func (s *Server) reconcileCounts(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := s.ReconcileCounts()
+			if err != nil {
+				logJson("error", "failed reconciling counts", logFields{"error": err})
+			}
+		}
+	}
+}
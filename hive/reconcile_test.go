@@ -0,0 +1,63 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssignmentCounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts Counts
+		states []termBucket
+		want   Counts
+	}{
+		{
+			"nothing yet",
+			nil,
+			nil,
+			Counts{"Favorites": 0, "Assignments": 0, "finished": 0, "skipped": 0, "unfinished": 0},
+		},
+		{
+			"drifted",
+			Counts{"Favorites": 2, "Assignments": 9, "finished": 7, "skipped": 0, "unfinished": 2},
+			[]termBucket{{"finished", 3}, {"unfinished", 1}},
+			Counts{"Favorites": 2, "Assignments": 4, "finished": 3, "skipped": 0, "unfinished": 1},
+		},
+		{
+			"expired left out of Assignments",
+			Counts{"Assignments": 3, "unfinished": 3},
+			[]termBucket{{"finished", 1}, {"expired", 2}},
+			Counts{"Favorites": 0, "Assignments": 1, "finished": 1, "skipped": 0, "unfinished": 0, "expired": 2},
+		},
+		{
+			"states no longer there are zeroed",
+			Counts{"Assignments": 2, "verified": 2},
+			[]termBucket{{"rejected", 1}},
+			Counts{"Favorites": 0, "Assignments": 1, "finished": 0, "skipped": 0, "unfinished": 0, "rejected": 1},
+		},
+	}
+	for _, test := range tests {
+		if got := assignmentCounts(test.counts, test.states); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: assignmentCounts = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCountsEqual(t *testing.T) {
+	tests := []struct {
+		a, b Counts
+		want bool
+	}{
+		{Counts{}, Counts{}, true},
+		{Counts{"finished": 1}, Counts{"finished": 1}, true},
+		{Counts{"finished": 1, "skipped": 0}, Counts{"finished": 1}, true},
+		{Counts{"finished": 1}, Counts{"finished": 2}, false},
+		{Counts{}, Counts{"finished": 1}, false},
+	}
+	for _, test := range tests {
+		if got := countsEqual(test.a, test.b); got != test.want {
+			t.Errorf("countsEqual(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package hive
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RecountAsset recomputes an asset's Counts from its assignments and saves them. Counts are kept up to date as
+// assignments are written, so this is only needed when they've drifted, ex: after assignments were edited by hand.
+func (s *Server) RecountAsset(assetId string) (*Asset, error) {
+	asset, err := s.FindAsset(assetId)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil || asset.Project != s.ActiveProjectId {
+		return nil, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+
+	// the assignments count has to see every assignment written so far
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	recounted, err := s.CalculateAssetCounts(*asset)
+	if err != nil {
+		return nil, err
+	}
+	s.logEvent("asset recounted", logFields{"asset": asset.Id, "before": asset.Counts, "after": recounted.Counts})
+	return &recounted, nil
+}
+
+// @Title AdminRecountAssetHandler
+// @Description recomputes an asset's Counts from its assignments, for when the stored counts have drifted
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Success 200 {object}  assetResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id}/recount [post]
+func (s *Server) AdminRecountAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	asset, err := s.RecountAsset(vars["asset_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assetJson, err := json.Marshal(assetResponse{
+		Asset: *asset,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetJson)
+}
@@ -0,0 +1,79 @@
+package hive
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ResetAsset unverifies an asset for a task after a bad consensus is found, so it's handed out again.
+// With rollback, the assignments that were verified for it go back to "finished"; otherwise they stay verified.
+func (s *Server) ResetAsset(assetId string, taskId string, rollback bool) (*Asset, error) {
+	if taskId == "" {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "task", Error: "is required, ex: ?task=vote"}}}
+	}
+	if !strings.HasPrefix(taskId, s.ActiveProjectId+"-") {
+		taskId = s.ActiveProjectId + "-" + taskId
+	}
+	task, err := s.FindTask(taskId)
+	if errorStatus(err) == 404 {
+		return nil, notFound("Sorry, there's no task with that name in this project.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	asset, err := s.FindAsset(assetId)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil || asset.Project != s.ActiveProjectId {
+		return nil, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+
+	assignmentState := ""
+	if rollback {
+		assignmentState = "finished"
+	}
+	asset, err = s.ReopenAsset(asset.Id, *task, assignmentState)
+	if err != nil {
+		return nil, err
+	}
+	s.logEvent("asset reset", logFields{"asset": asset.Id, "task": task.Name, "rollback": rollback})
+	return asset, nil
+}
+
+// @Title AdminResetAssetHandler
+// @Description clears an asset's verified answer for a task so it re-enters the assignment pool, ex: after a bad consensus
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Param   task        query   string     true        "The task to reset the asset for"
+// @Param   rollback        query   bool     false        "If true, assignments verified for the task go back to finished"
+// @Success 200 {object}  assetResponse
+// @Failure 400 {object} errorResponse	task is missing
+// @Failure 404 {object} errorResponse	there's no such task or asset in the project
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id}/reset [post]
+func (s *Server) AdminResetAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	asset, err := s.ResetAsset(vars["asset_id"], queryParams.Get("task"), queryParams.Get("rollback") == "true")
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assetJson, err := json.Marshal(assetResponse{
+		Asset: *asset,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetJson)
+}
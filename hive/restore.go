@@ -0,0 +1,302 @@
+package hive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type restoreResponse struct {
+	Project     string
+	Tasks       int
+	Assets      int
+	Users       int
+	Assignments int
+}
+
+// restorer stores a backup's documents as they're read, a batch at a time, checking each belongs to the backed up project.
+type restorer struct {
+	s       *Server
+	restore restoreResponse
+	source  json.RawMessage                   // the project document, stored last so an interrupted restore can be run again
+	batches map[string]map[string]interface{} // documents waiting to be stored, by type then id
+}
+
+// project starts a restore with the backup's project document, refusing to overwrite a project that's already here.
+func (rs *restorer) project(source json.RawMessage) error {
+	var project Project
+	err := json.Unmarshal(source, &project)
+	if err != nil {
+		return err
+	}
+	if project.Id == "" {
+		return errors.New("Sorry, the backup's project has no id.")
+	}
+	exists, err := rs.s.Store.Exists("projects", project.Id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return conflict("Sorry, project %s already exists here, so it wasn't restored.", project.Id)
+	}
+
+	rs.source = source
+	rs.restore.Project = project.Id
+	return nil
+}
+
+// add queues one of the project's documents, storing the batch once it's full.
+func (rs *restorer) add(docType string, source json.RawMessage) error {
+	if rs.restore.Project == "" {
+		return errors.New("Sorry, a backup has to start with its project.")
+	}
+
+	var doc struct {
+		Id      string
+		Project string
+	}
+	err := json.Unmarshal(source, &doc)
+	if err != nil {
+		return err
+	}
+	if doc.Id == "" || doc.Project != rs.restore.Project {
+		return fmt.Errorf("Sorry, the backup has %s that don't belong to project %s, ex: %.200s", docType, rs.restore.Project, source)
+	}
+
+	batch := rs.batches[docType]
+	if batch == nil {
+		batch = make(map[string]interface{})
+		rs.batches[docType] = batch
+	}
+	batch[doc.Id] = source
+	if len(batch) >= backfillPageSize {
+		return rs.flush(docType)
+	}
+	return nil
+}
+
+// flush stores a document type's waiting batch.
+func (rs *restorer) flush(docType string) error {
+	batch := rs.batches[docType]
+	if len(batch) == 0 {
+		return nil
+	}
+	err := rs.s.Store.PutMany(docType, batch)
+	if err != nil {
+		return err
+	}
+
+	switch docType {
+	case "tasks":
+		rs.restore.Tasks += len(batch)
+	case "assets":
+		rs.restore.Assets += len(batch)
+	case "users":
+		rs.restore.Users += len(batch)
+	case "assignments":
+		rs.restore.Assignments += len(batch)
+	}
+	delete(rs.batches, docType)
+	return nil
+}
+
+// finish stores everything still waiting, then the project itself.
+func (rs *restorer) finish() (restoreResponse, error) {
+	if rs.restore.Project == "" {
+		return rs.restore, errors.New("Sorry, the backup has no project.")
+	}
+	for _, docType := range backupDocTypes {
+		err := rs.flush(docType)
+		if err != nil {
+			return rs.restore, err
+		}
+	}
+	_, err := rs.s.Store.Put("projects", rs.restore.Project, rs.source)
+	if err != nil {
+		return rs.restore, err
+	}
+	return rs.restore, rs.s.Store.Refresh()
+}
+
+// isBackupDocType reports whether docType is one of the document types a backup holds.
+func isBackupDocType(docType string) bool {
+	for _, backupDocType := range backupDocTypes {
+		if docType == backupDocType {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreProject recreates a project and its documents from a backup made by AdminExportProjectHandler,
+// either the single JSON file or the gzipped tarball. Documents keep their ids, so assignments still point at
+// their assets and users. Projects that already exist are left alone.
+func (s *Server) RestoreProject(body io.Reader) (restoreResponse, error) {
+	rs := &restorer{s: s, batches: make(map[string]map[string]interface{})}
+
+	// gzip files start with 0x1f 0x8b
+	buffered := bufio.NewReader(body)
+	magic, _ := buffered.Peek(2)
+	var err error
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		err = rs.readTar(buffered)
+	} else {
+		err = rs.readJson(buffered)
+	}
+	if err != nil {
+		return rs.restore, err
+	}
+	return rs.finish()
+}
+
+// readJson reads a single file backup, {"Project": {...}, "Tasks": [...], ...}, one document at a time.
+func (rs *restorer) readJson(body io.Reader) error {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return errors.New("Sorry, a JSON backup should be an object with a Project and lists of documents.")
+	}
+
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		docType := strings.ToLower(key)
+
+		if key == "Project" {
+			var source json.RawMessage
+			err = decoder.Decode(&source)
+			if err != nil {
+				return err
+			}
+			err = rs.project(source)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// anything else in the file is passed over
+		if !isBackupDocType(docType) {
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("Sorry, %s in a backup should be a list.", key)
+		}
+		for decoder.More() {
+			var source json.RawMessage
+			err = decoder.Decode(&source)
+			if err != nil {
+				return err
+			}
+			err = rs.add(docType, source)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTar reads a gzipped tarball backup: project.json first, then a {docType}.ndjson file for each document type.
+func (rs *restorer) readTar(body io.Reader) error {
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Base(header.Name)
+		if name == "project.json" {
+			source, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			err = rs.project(json.RawMessage(source))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		docType := strings.TrimSuffix(name, ".ndjson")
+		if !isBackupDocType(docType) || docType == name {
+			continue
+		}
+		decoder := json.NewDecoder(tr)
+		for {
+			var source json.RawMessage
+			err = decoder.Decode(&source)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			err = rs.add(docType, source)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// @Title AdminRestoreProjectHandler
+// @Description recreates a project and everything in it from a backup downloaded from /admin/projects/{project_id}/export, alongside the projects already here
+// @Accept  json
+// @Param   backup        body   string     true        "The backup, as the single JSON file or the tar.gz"
+// @Success 200 {object}  restoreResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/import [post]
+func (s *Server) AdminRestoreProjectHandler(w http.ResponseWriter, r *http.Request) {
+	restore, err := s.RestoreProject(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	restoreJson, err := json.Marshal(restore)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, restoreJson)
+}
@@ -0,0 +1,317 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Review is a spot-check of an asset verified for a task. A share of verified assets (Task.ReviewPercent)
+// are queued for review; reviewers confirm the verified answer or reject it, which reopens the asset.
+type Review struct {
+	Id            string        // guid composed of ids from project + task + asset
+	Project       string        // the project
+	Task          string        // the task the asset was verified for
+	Asset         string        // the verified asset's id
+	SubmittedData SubmittedData // the verified answer under review
+	State         string        // reviews start out "pending" and become "confirmed" or "rejected"
+	Reviewer      string        // optional, who confirmed or rejected the answer
+	Created       time.Time
+	Updated       time.Time
+}
+
+type reviewResponse struct {
+	Review Review
+}
+type reviewsResponse struct {
+	Reviews []Review
+	Meta    meta
+}
+
+// QueueReview randomly places a freshly verified asset in the review queue according to the task's ReviewPercent.
+func (s *Server) QueueReview(task Task, asset Asset) (queued bool, err error) {
+	if task.ReviewPercent <= 0 || rand.Intn(100) >= task.ReviewPercent {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	review := Review{
+		Id:      strings.Join([]string{s.ActiveProjectId, task.Id, asset.Id}, "HIVE"),
+		Project: s.ActiveProjectId,
+		Task:    task.Id,
+		Asset:   asset.Id,
+		State:   "pending",
+		Created: now,
+		Updated: now,
+	}
+	if data, ok := asset.SubmittedData[task.Name].(map[string]interface{}); ok {
+		review.SubmittedData = SubmittedData(data)
+	}
+
+	_, err = s.Store.Put("reviews", review.Id, review)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FindReview looks up a review by id.
+func (s *Server) FindReview(id string) (review *Review, err error) {
+	err = s.Store.Get("reviews", id, &review)
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// FindReviews returns reviews in the current project, optionally scoped to a task and state, along with pagination meta information.
+func (s *Server) FindReviews(p Params) (reviews []Review, m meta, err error) {
+	err = s.Store.Refresh()
+	if err != nil {
+		return
+	}
+
+	if !strings.HasPrefix(p.Task, s.ActiveProjectId) && p.Task != "" {
+		p.Task = s.ActiveProjectId + "-" + p.Task
+	}
+
+	musts := []string{}
+	musts = append(musts, fmt.Sprintf(`{ "query": { "match": { "Project": "%s" } } }`, s.ActiveProjectId))
+	if p.Task != "" {
+		musts = append(musts, fmt.Sprintf(`{ "query": { "match": { "Task": "%s" } } }`, p.Task))
+	}
+	if p.State != "" {
+		musts = append(musts, fmt.Sprintf(`{ "query": { "match": { "State": "%s" } } }`, p.State))
+	}
+
+	searchQuery := `{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": {
+						"must": [%s ]
+					}
+				}
+			}
+		},
+		"from": %s,
+		"size": %s,
+		"sort": [ { "Created": { "order" : "asc" } } ]
+	}`
+	searchJson := fmt.Sprintf(searchQuery, strings.Join(musts, ", "), p.From, p.Size)
+	results, err := s.Store.Search("reviews", searchJson)
+	if err != nil {
+		return
+	}
+
+	m.Total = results.Hits.Total
+	m.From, _ = strconv.Atoi(p.From)
+	m.Size, _ = strconv.Atoi(p.Size)
+
+	for _, hit := range results.Hits.Hits {
+		var review Review
+		err = json.Unmarshal(*hit.Source, &review)
+		if err != nil {
+			return
+		}
+		reviews = append(reviews, review)
+	}
+	if len(reviews) <= 0 {
+		reviews = make([]Review, 0)
+	}
+	return
+}
+
+// ReopenAsset clears an asset's verified data for a task so it becomes eligible for assignment again.
+// Assignments that were marked verified for the task are moved to assignmentState, or left verified when it's empty.
+func (s *Server) ReopenAsset(assetId string, task Task, assignmentState string) (*Asset, error) {
+	asset, err := s.updateAsset(assetId, func(asset *Asset) error {
+		if asset.SubmittedData == nil {
+			asset.SubmittedData = SubmittedData{}
+		}
+		asset.SubmittedData[task.Name] = nil
+		asset.Verified = false
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil, notFound("Sorry, there isn't an asset with the id %q.", assetId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var assignments []Assignment
+	if assignmentState != "" {
+		assignments, err = s.FindAssetAssignments(task.Id, asset.Id, "verified")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, found := range assignments {
+		var assignment Assignment
+		err = s.updateDoc("assignments", found.Id, &assignment, func(exists bool) error {
+			if !exists || assignment.State != "verified" {
+				return errUnchanged
+			}
+			assignment.State = assignmentState
+			assignment.Updated = time.Now().UTC()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.Store.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// ResolveReview confirms or rejects a pending review. Rejecting reopens the asset for the task and
+// marks the assignments that produced the rejected answer as "rejected" so they no longer count towards consensus.
+func (s *Server) ResolveReview(reviewId string, confirmed bool, reviewer string) (*Review, error) {
+	review, err := s.FindReview(reviewId)
+	if err != nil {
+		return nil, err
+	}
+	if review.Project != s.ActiveProjectId {
+		return nil, notFound("Sorry, there isn't a review with that id in this project.")
+	}
+	if review.State != "pending" {
+		return nil, invalidState("Sorry, this review was already %s.", review.State)
+	}
+
+	if confirmed {
+		review.State = "confirmed"
+	} else {
+		task, err := s.FindTask(review.Task)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.ReopenAsset(review.Asset, *task, "rejected")
+		if err != nil {
+			return nil, err
+		}
+		review.State = "rejected"
+	}
+	review.Reviewer = reviewer
+	review.Updated = time.Now().UTC()
+
+	_, err = s.Store.Put("reviews", review.Id, review)
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// @Title AdminReviewsHandler
+// @Description returns a paginated list of verified assets queued for spot-check review
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task        query   string     false        "If specified, will scope reviews to this task"
+// @Param   state        query   string     false        "Review state (pending, confirmed, rejected), defaults to pending"
+// @Param   from        query   int     false        "If specified, will return a set of reviews starting with from number"
+// @Param   size        query   int     false        "If specified, will return a total number of reviews specified as size"
+// @Success 200 {object}  reviewsResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /reviews
+// @Router /admin/projects/{project_id}/reviews [get]
+func (s *Server) AdminReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	p := Params{
+		From:  defaultQuery(queryParams, "from", "0"),
+		Size:  defaultQuery(queryParams, "size", "10"),
+		Task:  defaultQuery(queryParams, "task", ""),
+		State: defaultQuery(queryParams, "state", "pending"),
+	}
+
+	reviews, m, err := s.FindReviews(p)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	reviewsJson, err := json.Marshal(reviewsResponse{
+		Reviews: reviews,
+		Meta:    m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, reviewsJson)
+}
+
+// @Title AdminResolveReviewHandler
+// @Description confirms or rejects a verified answer in the review queue; rejecting reopens the asset for the task
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   review_id     path    string     true        "Review ID"
+// @Param   action     path    string     true        "confirm or reject"
+// @Param   reviewer        body   string     false        "JSON-formatted reviewer, ex: {\"Reviewer\": \"editor@example.com\"}"
+// @Success 200 {object}  reviewResponse
+// @Failure 400 {object} errorResponse	action isn't confirm or reject
+// @Failure 422 {object} errorResponse	the review was already resolved
+// @Failure 500 {object} error	appropriate error message
+// @Resource /reviews
+// @Router /admin/projects/{project_id}/reviews/{review_id}/{action} [post]
+func (s *Server) AdminResolveReviewHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	var confirmed bool
+	switch vars["action"] {
+	case "confirm":
+		confirmed = true
+	case "reject":
+		confirmed = false
+	default:
+		invalid := &ValidationError{}
+		invalid.add("action", "has to be confirm or reject, not %q", vars["action"])
+		s.wrapFailure(w, r, invalid)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var reviewData struct {
+		Reviewer string
+	}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &reviewData)
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+	}
+
+	review, err := s.ResolveReview(vars["review_id"], confirmed, reviewData.Reviewer)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	reviewJson, err := json.Marshal(reviewResponse{
+		Review: *review,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, reviewJson)
+}
@@ -0,0 +1,159 @@
+package hive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Roles a user can have in their project. Each can do everything the roles below it can.
+const (
+	RoleOwner       = "owner"       // runs the project: its settings, task states, completion and backups, and who has which role
+	RoleAdmin       = "admin"       // manages tasks, assets, users and announcements
+	RoleReviewer    = "reviewer"    // reviews contributions, ex: lists assets, audits and resolves reviews
+	RoleContributor = "contributor" // only takes assignments; users without a role are contributors
+)
+
+// roleRanks orders the roles, contributors lowest.
+var roleRanks = map[string]int{
+	"":              0,
+	RoleContributor: 0,
+	RoleReviewer:    1,
+	RoleAdmin:       2,
+	RoleOwner:       3,
+}
+
+// isRole reports whether role is one of the roles above.
+func isRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok && role != ""
+}
+
+// hasRole reports whether user's role is role or one above it.
+func hasRole(user *User, role string) bool {
+	return roleRanks[user.Role] >= roleRanks[role]
+}
+
+// adminUserKey holds the user making an admin request with their session rather than an API key, see requireAdminKey.
+type adminUserKey struct{}
+
+// adminPathProject returns the project in an admin request's path, ex: "crowd" for /admin/projects/crowd/assets,
+// or an empty string for admin endpoints that aren't about a single project.
+func adminPathProject(path string) string {
+	parts := strings.Split(trimApiVersion(path), "/")
+	if len(parts) < 4 || parts[1] != "admin" || parts[2] != "projects" || parts[3] == "import" {
+		return ""
+	}
+	return parts[3]
+}
+
+// adminSessionUser returns the signed in user making an admin request, when they're a reviewer or above in
+// the project it's for. Without signed sessions nobody can be trusted by their cookie, so it's always nil.
+func (s *Server) adminSessionUser(r *http.Request) *User {
+	projectId := adminPathProject(r.URL.Path)
+	if projectId == "" || !s.signedSessions() {
+		return nil
+	}
+	scoped := s.forProject(projectId)
+	userId := scoped.SessionUserId(r)
+	if userId == "" {
+		return nil
+	}
+	user, err := scoped.FindUser(userId)
+	if err != nil || user == nil || !hasRole(user, RoleReviewer) {
+		return nil
+	}
+	return user
+}
+
+// withAdminUser returns r carrying the user it's made by, for requireRole.
+func withAdminUser(r *http.Request, user *User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), adminUserKey{}, user))
+}
+
+// requireRole wraps admin handlers, turning away signed in users whose role in the project is below role with a 403.
+// Requests made with an API key, and all requests when the server has no AdminKeys, aren't limited by role.
+func (s *Server) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := r.Context().Value(adminUserKey{}).(*User)
+		if user != nil && !hasRole(user, role) {
+			s.wrapResponse(w, r, 403, s.wrapError(fmt.Errorf("Sorry, only a project's %ss can do that.", role)))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// SetUserRole gives a user in the current project a role, ex: "reviewer".
+func (s *Server) SetUserRole(userId string, role string) (*User, error) {
+	if !isRole(role) {
+		invalid := &ValidationError{}
+		invalid.add("Role", "has to be one of owner, admin, reviewer or contributor, not %q", role)
+		return nil, invalid
+	}
+	user, err := s.FindUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("Sorry, there's no user with that id in this project.")
+	}
+
+	user, err = s.updateUser(user.Id, func(user *User) error {
+		user.Role = role
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, s.Store.Refresh()
+}
+
+// @Title AdminUserRoleHandler
+// @Description sets a user's role in a project: owner, admin, reviewer or contributor
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   user_id        path    string     true        "User ID"
+// @Param   role           body    string     true        "JSON-formatted role, ex: {\"Role\": \"reviewer\"}"
+// @Success 200 {object}  User
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 404 {object} errorResponse	there's no user with that id in the project
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/projects/{project_id}/users/{user_id}/role [post]
+func (s *Server) AdminUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var roleData struct {
+		Role string
+	}
+	err = json.Unmarshal(body, &roleData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	user, err := s.SetUserRole(vars["user_id"], roleData.Role)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	userJson, err := marshalUser(r, user)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, userJson)
+}
@@ -0,0 +1,200 @@
+package hive
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// s3ImportRequest says which objects to make assets of.
+type s3ImportRequest struct {
+	Bucket  string
+	Prefix  string // optional, only objects whose keys start with this, ex: "scans/1921/"
+	Region  string // optional, defaults to the server's s3Region
+	Private bool   // store s3:// urls that contributors only see as signed links, for buckets that aren't public
+	Asset   Asset  // optional fields shared by every new asset, ex: Metadata, Language
+}
+
+// s3Object is one entry in a bucket listing.
+type s3Object struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified string
+}
+
+type s3ListPage struct {
+	Contents              []s3Object
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns a page of up to 1000 objects under prefix, continuing from token unless it's empty.
+func (b *S3BlobStore) List(prefix string, token string) (page s3ListPage, err error) {
+	params := map[string]string{"list-type": "2", "prefix": prefix}
+	if token != "" {
+		params["continuation-token"] = token
+	}
+	// the signature covers the query string, which has to be escaped and sorted the way AWS does it
+	var names []string
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	query := make([]string, len(names))
+	for i, name := range names {
+		query[i] = awsEscape(name, true) + "=" + awsEscape(params[name], true)
+	}
+
+	bucketUrl := b.objectUrl("")
+	if b.Endpoint != "" {
+		// path-style listings are of /bucket, not /bucket/
+		bucketUrl.Path = strings.TrimSuffix(bucketUrl.Path, "/")
+		bucketUrl.RawPath = strings.TrimSuffix(bucketUrl.RawPath, "/")
+	}
+	bucketUrl.RawQuery = strings.Join(query, "&")
+
+	req, err := http.NewRequest("GET", bucketUrl.String(), nil)
+	if err != nil {
+		return
+	}
+	b.sign(req, emptyPayloadHash, time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != 200 {
+		return page, fmt.Errorf("Failed listing %s in S3: %s %s", b.Bucket, resp.Status, body)
+	}
+	err = xml.Unmarshal(body, &page)
+	return
+}
+
+// ImportS3Assets creates an asset for every object under a bucket prefix, recording the object's key, size, ETag
+// and last modified time in its Metadata as s3Key, s3Size, s3ETag and s3LastModified. Folder placeholders are skipped.
+// Credentials are the server's AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func (s *Server) ImportS3Assets(req s3ImportRequest, batchSize int, dedup bool) (assetStreamResponse, error) {
+	if req.Bucket == "" {
+		return assetStreamResponse{}, errors.New("Sorry, say which Bucket to import from.")
+	}
+	if s.Config.AwsAccessKey == "" || s.Config.AwsSecretKey == "" {
+		return assetStreamResponse{}, errors.New("Sorry, S3 imports need AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY set on the server.")
+	}
+	bucket := &S3BlobStore{
+		Bucket:    req.Bucket,
+		Region:    req.Region,
+		AccessKey: s.Config.AwsAccessKey,
+		SecretKey: s.Config.AwsSecretKey,
+		Endpoint:  s.Config.S3Endpoint,
+	}
+	if bucket.Region == "" {
+		bucket.Region = s.Config.S3Region
+	}
+
+	var page s3ListPage
+	listed := false
+	return s.importAssetStream(batchSize, dedup, func() (*Asset, error) {
+		for {
+			for len(page.Contents) > 0 {
+				object := page.Contents[0]
+				page.Contents = page.Contents[1:]
+				if strings.HasSuffix(object.Key, "/") {
+					continue
+				}
+				return s3Asset(bucket, req, object), nil
+			}
+			if listed && !page.IsTruncated {
+				return nil, nil
+			}
+			var err error
+			page, err = bucket.List(req.Prefix, page.NextContinuationToken)
+			if err != nil {
+				return nil, fmt.Errorf("the bucket couldn't be listed: %v", err)
+			}
+			listed = true
+		}
+	})
+}
+
+// s3Asset makes the asset for an object, from the fields shared by the import.
+func s3Asset(bucket *S3BlobStore, req s3ImportRequest, object s3Object) *Asset {
+	asset := req.Asset
+	asset.Id = ""
+	asset.Url = bucket.objectUrl(object.Key).String()
+	if req.Private {
+		asset.Url = "s3://" + bucket.Bucket + "/" + object.Key
+		asset.Private = true
+	}
+	if asset.Name == "" {
+		asset.Name = object.Key[strings.LastIndex(object.Key, "/")+1:]
+	}
+
+	// the template's metadata is shared, so each asset gets its own copy
+	asset.Metadata = make(map[string]interface{})
+	for key, value := range req.Asset.Metadata {
+		asset.Metadata[key] = value
+	}
+	asset.Metadata["s3Key"] = object.Key
+	asset.Metadata["s3Size"] = object.Size
+	asset.Metadata["s3ETag"] = strings.Trim(object.ETag, `"`)
+	asset.Metadata["s3LastModified"] = object.LastModified
+	return &asset
+}
+
+// @Title AdminImportS3AssetsHandler
+// @Description creates an asset for each object under a prefix in an S3 bucket, ex: scans dropped there by a digitization pipeline
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   import        body   s3ImportRequest     true        "The bucket and prefix, ex: {\"Bucket\": \"scans\", \"Prefix\": \"1921/\", \"Private\": true}"
+// @Param   batch        query   int     false        "How many assets to store per bulk request, defaults to 500 (max 5000)"
+// @Param   dedup        query   bool     false        "Unless false, objects whose Url is already an asset in the project update it instead of creating another"
+// @Param   import        query   string     false        "The id of an import that stopped partway, to resume it: send the same bucket and prefix and those it already got through are skipped"
+// @Success 200 {object}  assetStreamResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/import/s3 [post]
+func (s *Server) AdminImportS3AssetsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	var req s3ImportRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	scoped, err := s.startImport(r.URL.Query().Get("import"), "s3")
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s = scoped
+	imported, err := s.ImportS3Assets(req, importBatchSize(r), dedupImports(r))
+	err = s.finishImport(err)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	importedJson, err := json.Marshal(imported)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, importedJson)
+}
@@ -0,0 +1,134 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// assetSearchFields are the asset fields full-text search looks through, GoldData isn't one of them.
+var assetSearchFields = []string{"Name", "Url", "Metadata.*", "SubmittedData.*"}
+
+// assetSearchHit is an asset matching a search, with snippets of where it matched.
+type assetSearchHit struct {
+	Asset      Asset
+	Highlights map[string][]string // snippets by field, ex: "Metadata.caption", with the matched words in <em> tags
+}
+
+type assetSearchResponse struct {
+	Results []assetSearchHit
+	Meta    meta
+}
+
+// SearchAssets finds assets in the current project with every word of text somewhere in their Name, Url, Metadata
+// or SubmittedData, best matches first.
+func (s *Server) SearchAssets(text string, from int, size int) (hits []assetSearchHit, m meta, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, m, errors.New("Sorry, say what to search for, ex: ?q=studebaker")
+	}
+	textJson, err := json.Marshal(text)
+	if err != nil {
+		return
+	}
+	fieldsJson, err := json.Marshal(assetSearchFields)
+	if err != nil {
+		return
+	}
+	highlights := make([]string, len(assetSearchFields))
+	for i, field := range assetSearchFields {
+		highlights[i] = fmt.Sprintf(`"%s": {}`, field)
+	}
+
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"query": {
+					"multi_match": {
+						"query": %s,
+						"fields": %s,
+						"operator": "and",
+						"lenient": true
+					}
+				},
+				"filter": { "term": { "Project": "%s" } }
+			}
+		},
+		"highlight": {
+			"fields": { %s }
+		},
+		"from": %d,
+		"size": %d
+	}`, textJson, fieldsJson, s.ActiveProjectId, strings.Join(highlights, ", "), from, size)
+
+	results, err := s.Store.Search("assets", searchJson)
+	if err != nil {
+		return
+	}
+
+	m = meta{Total: results.Hits.Total, From: from, Size: size}
+	hits = []assetSearchHit{}
+	for _, hit := range results.Hits.Hits {
+		var asset Asset
+		err = json.Unmarshal(*hit.Source, &asset)
+		if err != nil {
+			return
+		}
+		highlight := hit.Highlight
+		if highlight == nil {
+			highlight = map[string][]string{}
+		}
+		hits = append(hits, assetSearchHit{
+			Asset:      asset,
+			Highlights: highlight,
+		})
+	}
+	return
+}
+
+// @Title AdminSearchAssetsHandler
+// @Description finds assets by the words in their name, url, metadata or submitted data, with snippets of where they matched
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   q        query   string     true        "The words to search for, ex: studebaker"
+// @Param   from        query   int     false        "If specified, will return a set of assets starting with from number"
+// @Param   size        query   int     false        "If specified, will return a total number of assets specified as size"
+// @Success 200 {object}  assetSearchResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/search [get]
+func (s *Server) AdminSearchAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	from, err := strconv.Atoi(defaultQuery(queryParams, "from", "0"))
+	if err != nil || from < 0 {
+		from = 0
+	}
+	size, err := strconv.Atoi(defaultQuery(queryParams, "size", "10"))
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	hits, m, err := s.SearchAssets(queryParams.Get("q"), from, size)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	searchJson, err := json.Marshal(assetSearchResponse{
+		Results: hits,
+		Meta:    m,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, searchJson)
+}
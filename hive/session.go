@@ -0,0 +1,145 @@
+package hive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sessionLifetimeDays is how long session cookies last when a project doesn't say otherwise.
+const sessionLifetimeDays = 365
+
+// SessionSettings configure the cookie that holds a project's current user id. Every field is optional.
+type SessionSettings struct {
+	CookieName string // defaults to "{project_id}_user_id"
+	Domain     string // ex: ".nytimes.com" to share the session across subdomains; defaults to the request's host
+	Path       string // defaults to "/"
+	SameSite   string // "lax", "strict" or "none"; left to the browser when unset
+	Lifetime   int    // in days, defaults to 365
+}
+
+// sessionSettings returns the current project's session settings with defaults filled in.
+func (s *Server) sessionSettings() SessionSettings {
+	var settings SessionSettings
+	var project *Project
+	err := s.Store.Get("projects", s.ActiveProjectId, &project)
+	if err == nil && project != nil {
+		settings = project.Session
+	}
+
+	if settings.CookieName == "" {
+		settings.CookieName = s.ActiveProjectId + "_user_id"
+	}
+	if settings.Path == "" {
+		settings.Path = "/"
+	}
+	if settings.Lifetime <= 0 {
+		settings.Lifetime = sessionLifetimeDays
+	}
+	return settings
+}
+
+// ErrSessionInvalid is returned when a request's session cookie wasn't issued by this server, or has expired.
+var ErrSessionInvalid = errors.New("Sorry, your session isn't valid anymore. Please sign in again.")
+
+// signedSessions reports whether session cookies hold signed tokens rather than bare user ids.
+// Sessions are signed whenever the server has a SecretKey.
+func (s *Server) signedSessions() bool {
+	return s.SecretKey != ""
+}
+
+// sessionToken signs userId into a session for the current project, ex: for a session cookie.
+func (s *Server) sessionToken(userId string, expires time.Time) string {
+	return s.signToken(fmt.Sprintf("session\n%s\n%s\n%d", s.ActiveProjectId, userId, expires.Unix()))
+}
+
+// verifySessionToken checks a token made by sessionToken and returns its user id.
+// Tokens for other projects, and expired ones, are refused.
+func (s *Server) verifySessionToken(token string) (string, error) {
+	payload, err := s.verifyToken(token)
+	if err != nil {
+		return "", ErrSessionInvalid
+	}
+	parts := strings.Split(payload, "\n")
+	if len(parts) != 4 || parts[0] != "session" || parts[1] != s.ActiveProjectId || parts[2] == "" {
+		return "", ErrSessionInvalid
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", ErrSessionInvalid
+	}
+	return parts[2], nil
+}
+
+// SessionUserId returns the current user's id from the project's session cookie, or an empty string without one.
+// When sessions are signed, cookies that don't verify are treated as no session at all.
+func (s *Server) SessionUserId(r *http.Request) string {
+	value := s.FindCookieValue(r, s.sessionSettings().CookieName)
+	if value == "" || !s.signedSessions() {
+		return value
+	}
+	userId, err := s.verifySessionToken(value)
+	if err != nil {
+		return ""
+	}
+	return userId
+}
+
+// requireSession wraps handlers that act as the current user, turning away requests whose session cookie
+// doesn't verify with a 401 and clearing the cookie, so clients can start a new session. Requests without
+// a session cookie are passed along, for handlers that create anonymous users.
+func (s *Server) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.signedSessions() {
+			next(w, r)
+			return
+		}
+
+		scoped := s.forProject(mux.Vars(r)["project_id"])
+		settings := scoped.sessionSettings()
+		value := scoped.FindCookieValue(r, settings.CookieName)
+		if value != "" {
+			_, err := scoped.verifySessionToken(value)
+			if err != nil {
+				http.SetCookie(w, &http.Cookie{Name: settings.CookieName, Domain: settings.Domain, Path: settings.Path, MaxAge: -1})
+				s.wrapResponse(w, r, 401, s.wrapError(err))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// SetSessionCookie starts a session for userId in the current project, signed when sessions are.
+func (s *Server) SetSessionCookie(w http.ResponseWriter, userId string) {
+	settings := s.sessionSettings()
+	expires := time.Now().AddDate(0, 0, settings.Lifetime)
+	value := userId
+	if s.signedSessions() {
+		value = s.sessionToken(userId, expires)
+	}
+	cookie := &http.Cookie{
+		Name:    settings.CookieName,
+		Value:   value,
+		Domain:  settings.Domain,
+		Path:    settings.Path,
+		Expires: expires,
+	}
+
+	switch strings.ToLower(settings.SameSite) {
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		// browsers ignore SameSite=None on cookies that aren't also Secure
+		cookie.SameSite = http.SameSiteNoneMode
+		cookie.Secure = true
+	}
+	http.SetCookie(w, cookie)
+}
@@ -0,0 +1,228 @@
+package hive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxSitemapSize caps a sitemap or url list, in bytes. The sitemap protocol allows 50MB.
+	maxSitemapSize = 50 << 20
+	// maxSitemapDepth caps how many sitemap indexes deep an import follows.
+	maxSitemapDepth = 3
+	// maxPageSize caps how much of a page is read looking for its title and og:image, in bytes.
+	maxPageSize = 1 << 20
+)
+
+// pageClient fetches sitemaps and the pages listed in them.
+var pageClient = &http.Client{Timeout: 30 * time.Second}
+
+var (
+	titlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagPattern = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern    = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+)
+
+// sitemapEntry is a page to make an asset of.
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapXml is either a sitemap listing pages, or a sitemap index listing more sitemaps.
+type sitemapXml struct {
+	Urls     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapEntries reads the pages in a sitemap or a plain list of urls, one per line, where blank lines and lines
+// starting with # are skipped. Sitemap indexes are followed, fetching the sitemaps they list.
+func sitemapEntries(body []byte, depth int) ([]sitemapEntry, error) {
+	body = bytes.TrimSpace(body)
+	if !bytes.HasPrefix(body, []byte("<")) {
+		var entries []sitemapEntry
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, sitemapEntry{Loc: line})
+		}
+		return entries, scanner.Err()
+	}
+
+	var sitemap sitemapXml
+	err := xml.Unmarshal(body, &sitemap)
+	if err != nil {
+		return nil, fmt.Errorf("Sorry, that isn't a sitemap: %v", err)
+	}
+	entries := sitemap.Urls
+	for _, nested := range sitemap.Sitemaps {
+		if depth >= maxSitemapDepth {
+			return nil, fmt.Errorf("Sorry, sitemap indexes can only be followed %d deep.", maxSitemapDepth)
+		}
+		nestedBody, err := fetchPage(strings.TrimSpace(nested.Loc), maxSitemapSize)
+		if err != nil {
+			return nil, err
+		}
+		nestedEntries, err := sitemapEntries(nestedBody, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, nestedEntries...)
+	}
+	return entries, nil
+}
+
+// fetchPage returns up to max bytes of the page at pageUrl.
+func fetchPage(pageUrl string, max int64) ([]byte, error) {
+	resp, err := pageClient.Get(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Sorry, %s couldn't be fetched: %s", pageUrl, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, max))
+}
+
+// pageMetadata picks a page's title, and the og:title, og:image and og:description it gives for link previews.
+func pageMetadata(page []byte) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	if match := titlePattern.FindSubmatch(page); match != nil {
+		metadata["title"] = strings.TrimSpace(html.UnescapeString(string(match[1])))
+	}
+
+	for _, tag := range metaTagPattern.FindAll(page, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range attrPattern.FindAllSubmatch(tag, -1) {
+			attrs[strings.ToLower(string(attr[1]))] = html.UnescapeString(strings.Trim(string(attr[2]), `"'`))
+		}
+		property := attrs["property"]
+		if property == "" {
+			property = attrs["name"]
+		}
+		switch strings.ToLower(property) {
+		case "og:title":
+			metadata["ogTitle"] = attrs["content"]
+		case "og:image":
+			metadata["image"] = attrs["content"]
+		case "og:description":
+			metadata["description"] = attrs["content"]
+		}
+	}
+
+	// og:title is usually the headline without the site's name tacked on
+	if ogTitle, ok := metadata["ogTitle"]; ok {
+		metadata["title"] = ogTitle
+		delete(metadata, "ogTitle")
+	}
+	return metadata
+}
+
+// ImportUrls creates an asset for each page in a sitemap or url list. With fetchPages, each page is fetched and its
+// title, og:image and og:description go in the asset's Metadata as title, image and description, and the title
+// becomes its Name. Pages that can't be fetched are still imported, without them.
+func (s *Server) ImportUrls(body []byte, fetchPages bool, batchSize int, dedup bool) (assetStreamResponse, error) {
+	entries, err := sitemapEntries(body, 0)
+	if err != nil {
+		return assetStreamResponse{}, err
+	}
+	if len(entries) == 0 {
+		return assetStreamResponse{}, errors.New("Sorry, there weren't any urls to import.")
+	}
+
+	return s.importAssetStream(batchSize, dedup, func() (*Asset, error) {
+		if len(entries) == 0 {
+			return nil, nil
+		}
+		entry := entries[0]
+		entries = entries[1:]
+
+		asset := &Asset{Url: strings.TrimSpace(entry.Loc), Name: strings.TrimSpace(entry.Loc)}
+		asset.Metadata = make(map[string]interface{})
+		if fetchPages && asset.Url != "" {
+			page, err := fetchPage(asset.Url, maxPageSize)
+			if err != nil {
+				s.logEvent("Failed fetching page to import", logFields{"url": asset.Url, "error": err.Error()})
+			} else {
+				asset.Metadata = pageMetadata(page)
+			}
+		}
+		if title, ok := asset.Metadata["title"].(string); ok && title != "" {
+			asset.Name = title
+		}
+		if entry.LastMod != "" {
+			asset.Metadata["lastModified"] = strings.TrimSpace(entry.LastMod)
+		}
+		return asset, nil
+	})
+}
+
+// @Title AdminImportUrlsHandler
+// @Description creates an asset for each page in a sitemap or list of urls, ex: published articles to crowdsource tasks over
+// @Accept  text/plain
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   urls        body   string     false        "A sitemap.xml, or one url per line"
+// @Param   sitemap        query   string     false        "The url of a sitemap to fetch, instead of sending one"
+// @Param   fetch        query   bool     false        "If true, fetches each page for its title, og:image and og:description"
+// @Param   batch        query   int     false        "How many assets to store per bulk request, defaults to 500 (max 5000)"
+// @Param   dedup        query   bool     false        "Unless false, pages whose url is already an asset in the project update it instead of creating another"
+// @Param   import        query   string     false        "The id of an import that stopped partway, to resume it: send the same urls and those it already got through are skipped"
+// @Success 200 {object}  assetStreamResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/import/urls [post]
+func (s *Server) AdminImportUrlsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	var body []byte
+	var err error
+	if sitemapUrl := queryParams.Get("sitemap"); sitemapUrl != "" {
+		body, err = fetchPage(sitemapUrl, maxSitemapSize)
+	} else {
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxSitemapSize))
+	}
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	scoped, err := s.startImport(queryParams.Get("import"), "urls")
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s = scoped
+	fetchPages := queryParams.Get("fetch") == "true"
+	imported, err := s.ImportUrls(body, fetchPages, importBatchSize(r), dedupImports(r))
+	err = s.finishImport(err)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	importedJson, err := json.Marshal(imported)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, importedJson)
+}
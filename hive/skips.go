@@ -0,0 +1,153 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// skipReasons are why a user can say they skipped an assignment, see Assignment.SkipReason.
+var skipReasons = []string{"unreadable", "not_relevant", "broken_image", "duplicate", "other"}
+
+// assetSkips is how often an asset was skipped, and why.
+type assetSkips struct {
+	Asset   string
+	Skips   int
+	Reasons map[string]int // by reason, with "unspecified" for skips without one
+}
+
+type skipsResponse struct {
+	Skips   int            // skipped assignments
+	Reasons map[string]int // by reason, with "unspecified" for skips without one
+	Assets  []assetSkips   // the most skipped assets, most first
+}
+
+type skipBucket struct {
+	Key     string `json:"key"`
+	Count   int    `json:"doc_count"`
+	Reasons struct {
+		Buckets []skipBucket `json:"buckets"`
+	} `json:"reasons"`
+}
+
+type skipAgg struct {
+	Reasons struct {
+		Buckets []skipBucket `json:"buckets"`
+	} `json:"reasons"`
+	Assets struct {
+		Buckets []skipBucket `json:"buckets"`
+	} `json:"assets"`
+}
+
+// skipReasonCounts turns reason buckets into counts, putting skips without a reason under "unspecified".
+func skipReasonCounts(total int, buckets []skipBucket) map[string]int {
+	reasons := make(map[string]int)
+	for _, bucket := range buckets {
+		reasons[bucket.Key] = bucket.Count
+		total -= bucket.Count
+	}
+	if total > 0 {
+		reasons["unspecified"] = total
+	}
+	return reasons
+}
+
+// FindSkips tallies the current project's skipped assignments by reason, and the size most skipped assets with
+// their reasons. taskId and assetId narrow it to a task or an asset, unless they're empty.
+func (s *Server) FindSkips(taskId string, assetId string, size int) (skips skipsResponse, err error) {
+	musts := []string{
+		fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId),
+		`{ "term": { "State": "skipped" } }`,
+	}
+	if taskId != "" {
+		musts = append(musts, fmt.Sprintf(`{ "term": { "Task": "%s" } }`, taskId))
+	}
+	if assetId != "" {
+		musts = append(musts, fmt.Sprintf(`{ "term": { "Asset.Id": "%s" } }`, assetId))
+	}
+
+	searchJson := fmt.Sprintf(`{
+		"query": {
+			"filtered": {
+				"filter": {
+					"bool": { "must": [ %s ] }
+				}
+			}
+		},
+		"size": 0,
+		"aggs": {
+			"reasons": { "terms": { "field": "SkipReason" } },
+			"assets": {
+				"terms": { "field": "Asset.Id", "size": %d, "order": { "_count": "desc" } },
+				"aggs": {
+					"reasons": { "terms": { "field": "SkipReason" } }
+				}
+			}
+		}
+	}`, strings.Join(musts, ", "), size)
+
+	results, err := s.Store.Search("assignments", searchJson)
+	if err != nil {
+		return
+	}
+	var agg skipAgg
+	err = json.Unmarshal(results.Aggregations, &agg)
+	if err != nil {
+		return
+	}
+
+	skips.Skips = results.Hits.Total
+	skips.Reasons = skipReasonCounts(skips.Skips, agg.Reasons.Buckets)
+	skips.Assets = []assetSkips{}
+	for _, bucket := range agg.Assets.Buckets {
+		skips.Assets = append(skips.Assets, assetSkips{
+			Asset:   bucket.Key,
+			Skips:   bucket.Count,
+			Reasons: skipReasonCounts(bucket.Count, bucket.Reasons.Buckets),
+		})
+	}
+	return
+}
+
+// @Title AdminSkipsHandler
+// @Description tallies why assignments were skipped, overall and for the most skipped assets, to spot corrupt assets
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   task        query   string     false        "If specified, only counts skips for this task"
+// @Param   asset        query   string     false        "If specified, only counts skips of this asset"
+// @Param   size        query   int     false        "How many of the most skipped assets to return, defaults to 10"
+// @Success 200 {object}  skipsResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/skips [get]
+func (s *Server) AdminSkipsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	queryParams := r.URL.Query()
+	taskId := queryParams.Get("task")
+	if taskId != "" && !strings.HasPrefix(taskId, s.ActiveProjectId+"-") {
+		taskId = s.ActiveProjectId + "-" + taskId
+	}
+	size, err := strconv.Atoi(defaultQuery(queryParams, "size", "10"))
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	skips, err := s.FindSkips(taskId, queryParams.Get("asset"), size)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	skipsJson, err := json.Marshal(skips)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, skipsJson)
+}
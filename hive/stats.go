@@ -0,0 +1,253 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// statsActivityDays is how many days of daily activity user stats report.
+const statsActivityDays = 30
+
+// dailyActivity is the number of assignments a user finished on a given day (YYYY-MM-DD).
+type dailyActivity struct {
+	Date  string
+	Count int
+}
+
+// UserStats summarizes a single user's contributions to a project.
+type UserStats struct {
+	User         string
+	Finished     int             // finished assignments, including those that went on to be verified
+	Verified     int             // assignments whose answers contributed to a verified asset
+	Skipped      int             // skipped assignments
+	GoldAnswered int             // finished assignments on gold standard assets
+	GoldCorrect  int             // of those, how many matched the known-correct answer
+	Accuracy     float64         // GoldCorrect / GoldAnswered, or 0 with no gold answers yet
+	Rank         int             // position among the project's users by finished assignments, starting at 1
+	Activity     []dailyActivity // finished assignments per day for the last 30 days, oldest first
+}
+
+type userStatsResponse struct {
+	Stats UserStats
+}
+
+// reportLocation returns the time zone named by the request's "tz" parameter (ex: "America/New_York"), or UTC without one.
+// Daily buckets in reports start at midnight in this zone.
+func reportLocation(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		invalid := &ValidationError{}
+		invalid.add("tz", "has to be a time zone name like America/New_York, not %q", tz)
+		return nil, invalid
+	}
+	return loc, nil
+}
+
+// FindGoldAssets returns the project's gold standard assets keyed by asset id.
+func (s *Server) FindGoldAssets() (goldAssets map[string]Asset, err error) {
+	goldAssets = make(map[string]Asset)
+
+	goldQuery := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "exists": { "field": "GoldData" } }, { "query": { "match": { "Project": "%s" } } } ] } } } } }`, s.ActiveProjectId)
+
+	count, err := s.Store.Count("assets", goldQuery)
+	if err != nil {
+		return
+	}
+	if count == 0 {
+		return
+	}
+
+	searchJson := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "exists": { "field": "GoldData" } }, { "query": { "match": { "Project": "%s" } } } ] } } } }, "from": 0, "size": %d }`, s.ActiveProjectId, count)
+	results, err := s.Store.Search("assets", searchJson)
+	if err != nil {
+		return
+	}
+	for _, hit := range results.Hits.Hits {
+		var asset Asset
+		err = json.Unmarshal(*hit.Source, &asset)
+		if err != nil {
+			return
+		}
+		goldAssets[asset.Id] = asset
+	}
+	return
+}
+
+// matchesGold reports whether an assignment's submitted data has a gold answer to compare against,
+// and if so whether it was correct.
+func matchesGold(assignment Assignment, goldAssets map[string]Asset, taskNames map[string]string) (graded bool, correct bool) {
+	gold, ok := goldAssets[assignment.Asset.Id]
+	if !ok {
+		return false, false
+	}
+	answer, ok := gold.GoldData[taskNames[assignment.Task]]
+	if !ok || answer == nil {
+		return false, false
+	}
+	return true, sameAnswer(answer, assignment.SubmittedData)
+}
+
+// sameAnswer reports whether submitted data matches an answer stored on an asset, ex: its GoldData or SubmittedData for a task.
+func sameAnswer(answer interface{}, submittedData SubmittedData) bool {
+	// round trip the answer so it compares like submitted data decoded from a request
+	var key SubmittedData
+	answerJson, err := json.Marshal(answer)
+	if err != nil {
+		return false
+	}
+	err = json.Unmarshal(answerJson, &key)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(key, submittedData)
+}
+
+// UserRank returns the user's 1-based position among the project's users by finished assignments.
+func (s *Server) UserRank(user User) (int, error) {
+	rankQuery := fmt.Sprintf(`{ "query": { "filtered": { "filter": { "bool": { "must": [ { "term": { "Project": "%s" } }, { "range": { "Counts.Assignments": { "gt": %d } } } ] } } } } }`, s.ActiveProjectId, user.Counts["Assignments"])
+
+	count, err := s.Store.Count("users", rankQuery)
+	if err != nil {
+		return 0, err
+	}
+	return count + 1, nil
+}
+
+// CalculateUserStats tallies a user's finished and verified work, gold accuracy, recent activity and rank.
+// Activity is bucketed into days in loc.
+func (s *Server) CalculateUserStats(user User, loc *time.Location) (stats UserStats, err error) {
+	stats.User = user.Id
+
+	assignments, err := s.FindUserAssignments(user.Id)
+	if err != nil {
+		return
+	}
+	goldAssets, err := s.FindGoldAssets()
+	if err != nil {
+		return
+	}
+
+	p := Params{
+		From:    "0",
+		Size:    "10",
+		SortBy:  "Name",
+		SortDir: "asc",
+	}
+	tasks, _, err := s.FindTasks(p)
+	if err != nil {
+		return
+	}
+	taskNames := make(map[string]string)
+	for _, task := range tasks {
+		taskNames[task.Id] = task.Name
+	}
+
+	// one bucket per day, oldest first
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	activity := make(map[string]int)
+	for i := statsActivityDays - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format("2006-01-02")
+		activity[day] = 0
+		stats.Activity = append(stats.Activity, dailyActivity{Date: day})
+	}
+
+	for _, assignment := range assignments {
+		switch assignment.State {
+		case "skipped":
+			stats.Skipped++
+			continue
+		case "verified":
+			stats.Verified++
+		case "finished":
+		default:
+			continue
+		}
+		stats.Finished++
+
+		graded, correct := matchesGold(assignment, goldAssets, taskNames)
+		if graded {
+			stats.GoldAnswered++
+			if correct {
+				stats.GoldCorrect++
+			}
+		}
+
+		day := assignment.Updated.In(loc).Format("2006-01-02")
+		if _, ok := activity[day]; ok {
+			activity[day]++
+		}
+	}
+	for i, day := range stats.Activity {
+		stats.Activity[i].Count = activity[day.Date]
+	}
+
+	if stats.GoldAnswered > 0 {
+		stats.Accuracy = float64(stats.GoldCorrect) / float64(stats.GoldAnswered)
+	}
+
+	stats.Rank, err = s.UserRank(user)
+	return
+}
+
+// @Title UserStatsHandler
+// @Description returns contribution stats for the current user: finished and verified counts, gold accuracy, daily activity and rank
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Param   tz        query   string     false        "Time zone for daily activity, ex: America/New_York; defaults to UTC"
+// @Success 200 {object}  userStatsResponse
+// @Failure 400 {object} errorResponse	tz isn't a time zone
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /projects/{project_id}/user/stats [get]
+func (s *Server) UserStatsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, user stats require a valid user."))
+		return
+	}
+	user, err := s.FindUser(userId)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if user == nil {
+		s.wrapFailure(w, r, notFound("Sorry, there isn't a user with that id."))
+		return
+	}
+
+	loc, err := reportLocation(r)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	stats, err := s.CalculateUserStats(*user, loc)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	statsJson, err := json.Marshal(userStatsResponse{
+		Stats: stats,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, statsJson)
+}
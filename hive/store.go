@@ -0,0 +1,765 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// Store keeps hive's documents, ex: projects, tasks, assets, users and assignments, each under its document type.
+// Searches and counts are written as Elasticsearch 1.x request bodies, so a store backed by something else
+// needs to understand the parts of that query language hive uses.
+type Store interface {
+	// Get decodes the document with id into doc, returning an error when there isn't one.
+	Get(docType string, id string, doc interface{}) error
+	// Exists reports whether there's a document with id.
+	Exists(docType string, id string) (bool, error)
+	// Put saves doc under id, replacing any document already there, and returns the id. The store picks one when id is empty.
+	Put(docType string, id string, doc interface{}) (string, error)
+	// PutMany saves several documents at once, keyed by id, ex: for large imports. When only some of them
+	// couldn't be saved, it returns a *BulkError saying which.
+	PutMany(docType string, docs map[string]interface{}) error
+	// Delete removes the document with id.
+	Delete(docType string, id string) error
+	// Search returns the documents matching query, ex: `{"query": {...}, "from": 0, "size": 10, "sort": [...]}`.
+	Search(docType string, query string) (*SearchResult, error)
+	// Count returns how many documents match query.
+	Count(docType string, query string) (int, error)
+	// Refresh makes everything saved so far visible to Search and Count.
+	Refresh() error
+
+	// IndexExists reports whether the store has been set up to hold hive's documents.
+	IndexExists() (bool, error)
+	// CreateIndex sets the store up to hold hive's documents.
+	CreateIndex() error
+	// DeleteIndex removes every document, of every project.
+	DeleteIndex() error
+	// PutMapping tells the store how docType's fields should be indexed, ex: which strings are matched exactly.
+	PutMapping(docType string, mapping string) error
+}
+
+// Scroller is a Store that can walk every document matching a query, however many there are, without paging
+// through them with from and size.
+type Scroller interface {
+	// Scroll calls fn with each document matching query, in the query's sort order, stopping at the first error.
+	Scroll(docType string, query string, fn func(SearchHit) error) error
+}
+
+// Versioner is a Store that can tell whether a document changed between reading and saving it, so concurrent
+// read-modify-write updates don't overwrite each other, see Server.updateDoc.
+type Versioner interface {
+	// GetVersioned is Get, also returning the version of the document read. It returns ErrNotFound when there
+	// isn't a document with id.
+	GetVersioned(docType string, id string, doc interface{}) (version string, err error)
+	// PutVersioned saves doc under id only if the stored document is still at version, or, when version is empty,
+	// if there isn't one yet. Otherwise it returns ErrConflict.
+	PutVersioned(docType string, id string, doc interface{}, version string) error
+}
+
+// BulkItemError is why one of the documents given to PutMany wasn't saved.
+type BulkItemError struct {
+	Id    string
+	Error string
+}
+
+// BulkError is returned by PutMany when some of the documents weren't saved. The others were.
+type BulkError struct {
+	DocType string
+	Items   []BulkItemError
+}
+
+func (err *BulkError) Error() string {
+	if len(err.Items) == 0 {
+		return fmt.Sprintf("Sorry, some %s couldn't be stored.", err.DocType)
+	}
+	return fmt.Sprintf("Sorry, %d %s couldn't be stored, ex: %s: %s", len(err.Items), err.DocType, err.Items[0].Id, err.Items[0].Error)
+}
+
+// failed reports whether the document with id is one that wasn't saved.
+func (err *BulkError) failed(id string) bool {
+	for _, item := range err.Items {
+		if item.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNotFound is returned by ElasticsearchStore's Get when there isn't a document with the id.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned by PutVersioned when the document was changed, or created, since it was read.
+var ErrConflict = errors.New("Sorry, the record was changed by someone else. Please try again.")
+
+// SearchResult is a page of documents returned by Store.Search.
+type SearchResult struct {
+	Hits         SearchHits
+	Aggregations json.RawMessage // results of any "aggs" in the query
+}
+
+// SearchHits are the documents matching a search.
+type SearchHits struct {
+	Total int // every matching document, not just those on this page
+	Hits  []SearchHit
+}
+
+// SearchHit is one document in a SearchResult.
+type SearchHit struct {
+	Id        string
+	Source    *json.RawMessage    // the document itself
+	Highlight map[string][]string // snippets of the fields that matched, by field, when the query asks for a "highlight"
+}
+
+// ElasticsearchStore is a Store that keeps documents in Elasticsearch. Up to 5.x, every document type shares a single
+// index. 6.x only allows one type per index, so from then on each type gets an index of its own, ex: "hive-assets",
+// and a template puts them all behind an alias named Index.
+type ElasticsearchStore struct {
+	Client *elasticsearch.Client
+	Index  string // ex: "hive"
+	// Version is Elasticsearch's major version, ex: 7, which decides how queries, mappings and indices are written
+	// for it, see esDialect. When it's 0, the cluster is asked the first time it's needed.
+	Version int
+	// Timeout is how long each request to Elasticsearch may take, or forever when it's 0.
+	Timeout time.Duration
+
+	versionLock sync.Mutex
+}
+
+// NewElasticsearchStore connects to Elasticsearch at hosts, ex: "es1.example.com" or "https://es.example.com:9243",
+// with port used for hosts that don't give a scheme.
+func NewElasticsearchStore(hosts []string, port string, index string) (*ElasticsearchStore, error) {
+	var addresses []string
+	for _, host := range hosts {
+		if !strings.Contains(host, "://") {
+			host = fmt.Sprintf("http://%s:%s", host, port)
+		}
+		addresses = append(addresses, host)
+	}
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: addresses})
+	if err != nil {
+		return nil, err
+	}
+	return &ElasticsearchStore{Client: client, Index: index}, nil
+}
+
+// esError is a response from Elasticsearch that wasn't a success.
+type esError struct {
+	Status int
+	Body   string
+}
+
+func (err *esError) Error() string {
+	return fmt.Sprintf("elasticsearch answered %d: %s", err.Status, err.Body)
+}
+
+// do sends req within the store's Timeout and returns the response body, or an esError for anything but a 2xx.
+func (e *ElasticsearchStore) do(req esapi.Request) ([]byte, error) {
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+	resp, err := req.Do(ctx, e.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return body, &esError{Status: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
+
+// isNotFound reports whether err is a 404 from Elasticsearch.
+func isNotFound(err error) bool {
+	esErr, ok := err.(*esError)
+	return ok && esErr.Status == 404
+}
+
+// esVersion returns the major version of Elasticsearch, asking the cluster when it isn't set.
+func (e *ElasticsearchStore) esVersion() (int, error) {
+	e.versionLock.Lock()
+	defer e.versionLock.Unlock()
+	if e.Version > 0 {
+		return e.Version, nil
+	}
+
+	body, err := e.do(esapi.InfoRequest{})
+	if err != nil {
+		return 0, err
+	}
+	var info struct {
+		Version struct {
+			Number       string
+			Distribution string
+		}
+	}
+	err = json.Unmarshal(body, &info)
+	if err != nil {
+		return 0, err
+	}
+	// OpenSearch forked from 7.10 and numbers its releases from 1.0
+	if info.Version.Distribution == "opensearch" {
+		e.Version = 7
+		return e.Version, nil
+	}
+	version, err := esMajorVersion(info.Version.Number)
+	if err != nil {
+		return 0, err
+	}
+	e.Version = version
+	return e.Version, nil
+}
+
+// esMajorVersion reads the major version from an Elasticsearch release, ex: 7 from "7.10.2".
+func esMajorVersion(release string) (int, error) {
+	major, err := strconv.Atoi(strings.SplitN(strings.TrimSpace(release), ".", 2)[0])
+	if err != nil || major < 1 {
+		return 0, fmt.Errorf("Sorry, %q isn't an Elasticsearch version, ex: 7 or 7.10.2", release)
+	}
+	return major, nil
+}
+
+// indexFor returns the index docType is kept in.
+func (e *ElasticsearchStore) indexFor(version int, docType string) string {
+	if version >= 6 {
+		return e.Index + "-" + docType
+	}
+	return e.Index
+}
+
+// typeFor returns the document type docType is stored as: _doc once each type has its own index, and none from
+// 7.x, where the client fills in _doc for the endpoints that still need it.
+func (e *ElasticsearchStore) typeFor(version int, docType string) string {
+	switch {
+	case version >= 7:
+		return ""
+	case version == 6:
+		return "_doc"
+	}
+	return docType
+}
+
+// typesFor is typeFor for the endpoints that take a list of types.
+func (e *ElasticsearchStore) typesFor(version int, docType string) []string {
+	if docType = e.typeFor(version, docType); docType != "" {
+		return []string{docType}
+	}
+	return nil
+}
+
+// Get decodes the document's source into doc, returning ErrNotFound when there isn't one.
+func (e *ElasticsearchStore) Get(docType string, id string, doc interface{}) error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	body, err := e.do(esapi.GetSourceRequest{
+		Index:        e.indexFor(version, docType),
+		DocumentType: e.typeFor(version, docType),
+		DocumentID:   id,
+	})
+	if isNotFound(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, doc)
+}
+
+// Exists checks for the document without fetching it.
+func (e *ElasticsearchStore) Exists(docType string, id string) (bool, error) {
+	version, err := e.esVersion()
+	if err != nil {
+		return false, err
+	}
+	_, err = e.do(esapi.ExistsRequest{
+		Index:        e.indexFor(version, docType),
+		DocumentType: e.typeFor(version, docType),
+		DocumentID:   id,
+	})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put indexes doc, letting elasticsearch generate an id when there isn't one.
+func (e *ElasticsearchStore) Put(docType string, id string, doc interface{}) (string, error) {
+	version, err := e.esVersion()
+	if err != nil {
+		return "", err
+	}
+	source, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	body, err := e.do(esapi.IndexRequest{
+		Index:        e.indexFor(version, docType),
+		DocumentType: e.typeFor(version, docType),
+		DocumentID:   id,
+		Body:         bytes.NewReader(source),
+	})
+	if err != nil {
+		return "", err
+	}
+	var indexed struct {
+		Id string `json:"_id"`
+	}
+	err = json.Unmarshal(body, &indexed)
+	return indexed.Id, err
+}
+
+// isConflict reports whether err is a 409 from Elasticsearch.
+func isConflict(err error) bool {
+	esErr, ok := err.(*esError)
+	return ok && esErr.Status == 409
+}
+
+// GetVersioned is Get, also returning the document's version: its sequence number and primary term from 7.x, and
+// its version number before that, when saves couldn't be made conditional on sequence numbers.
+func (e *ElasticsearchStore) GetVersioned(docType string, id string, doc interface{}) (string, error) {
+	version, err := e.esVersion()
+	if err != nil {
+		return "", err
+	}
+	body, err := e.do(esapi.GetRequest{
+		Index:        e.indexFor(version, docType),
+		DocumentType: e.typeFor(version, docType),
+		DocumentID:   id,
+	})
+	if isNotFound(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	var found struct {
+		Found       bool            `json:"found"`
+		Version     int             `json:"_version"`
+		SeqNo       int             `json:"_seq_no"`
+		PrimaryTerm int             `json:"_primary_term"`
+		Source      json.RawMessage `json:"_source"`
+	}
+	err = json.Unmarshal(body, &found)
+	if err != nil {
+		return "", err
+	}
+	if !found.Found {
+		return "", ErrNotFound
+	}
+	err = json.Unmarshal(found.Source, doc)
+	if err != nil {
+		return "", err
+	}
+	if version >= 7 {
+		return fmt.Sprintf("%d:%d", found.SeqNo, found.PrimaryTerm), nil
+	}
+	return strconv.Itoa(found.Version), nil
+}
+
+// PutVersioned indexes doc only if the stored document is still at version, as returned by GetVersioned, or only
+// if there isn't one when version is empty.
+func (e *ElasticsearchStore) PutVersioned(docType string, id string, doc interface{}, version string) error {
+	esVersion, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	source, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	req := esapi.IndexRequest{
+		Index:        e.indexFor(esVersion, docType),
+		DocumentType: e.typeFor(esVersion, docType),
+		DocumentID:   id,
+		Body:         bytes.NewReader(source),
+	}
+	switch {
+	case version == "":
+		req.OpType = "create"
+	case esVersion >= 7:
+		var seqNo, primaryTerm int
+		_, err = fmt.Sscanf(version, "%d:%d", &seqNo, &primaryTerm)
+		if err != nil {
+			return fmt.Errorf("Sorry, %q isn't a document version.", version)
+		}
+		req.IfSeqNo = &seqNo
+		req.IfPrimaryTerm = &primaryTerm
+	default:
+		number, err := strconv.Atoi(version)
+		if err != nil {
+			return fmt.Errorf("Sorry, %q isn't a document version.", version)
+		}
+		req.Version = &number
+	}
+	_, err = e.do(req)
+	if isConflict(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// bulkResponse is the outcome of each document in a bulk request, keyed by the action taken on it.
+type bulkResponse struct {
+	Errors bool
+	Items  []map[string]struct {
+		Id    string          `json:"_id"`
+		Error json.RawMessage // a string until 2.x, then {"type": "...", "reason": "..."}
+	}
+}
+
+// bulkItemReason reads why a document in a bulk request failed.
+func bulkItemReason(itemError json.RawMessage) string {
+	var reason string
+	if json.Unmarshal(itemError, &reason) == nil {
+		return reason
+	}
+	var cause struct {
+		Type   string
+		Reason string
+	}
+	if json.Unmarshal(itemError, &cause) == nil && cause.Reason != "" {
+		return cause.Type + ": " + cause.Reason
+	}
+	return string(itemError)
+}
+
+// PutMany sends the documents in a single bulk request, returning a *BulkError with every document that failed.
+func (e *ElasticsearchStore) PutMany(docType string, docs map[string]interface{}) error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	for id, doc := range docs {
+		target := map[string]string{"_index": e.indexFor(version, docType), "_id": id}
+		if docType := e.typeFor(version, docType); docType != "" {
+			target["_type"] = docType
+		}
+		action, err := json.Marshal(map[string]interface{}{"index": target})
+		if err != nil {
+			return err
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(source)
+		body.WriteByte('\n')
+	}
+
+	responseBody, err := e.do(esapi.BulkRequest{Body: &body})
+	if err != nil {
+		return err
+	}
+
+	// a bulk request succeeds as a whole even when some of its documents fail
+	var response bulkResponse
+	err = json.Unmarshal(responseBody, &response)
+	if err != nil || !response.Errors {
+		return err
+	}
+	bulkErr := &BulkError{DocType: docType}
+	for _, item := range response.Items {
+		for _, result := range item {
+			if len(result.Error) > 0 && string(result.Error) != "null" {
+				bulkErr.Items = append(bulkErr.Items, BulkItemError{Id: result.Id, Error: bulkItemReason(result.Error)})
+			}
+		}
+	}
+	return bulkErr
+}
+
+// Delete removes the document from the index.
+func (e *ElasticsearchStore) Delete(docType string, id string) error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	_, err = e.do(esapi.DeleteRequest{
+		Index:        e.indexFor(version, docType),
+		DocumentType: e.typeFor(version, docType),
+		DocumentID:   id,
+	})
+	return err
+}
+
+// searchResponse is a page of search results as Elasticsearch sends them.
+type searchResponse struct {
+	ScrollId string `json:"_scroll_id"`
+	Hits     struct {
+		Total json.RawMessage // a number until 7.x, then {"value": 123, "relation": "eq"}
+		Hits  []struct {
+			Id        string              `json:"_id"`
+			Source    *json.RawMessage    `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		}
+	}
+	Aggregations json.RawMessage
+}
+
+// result converts a response to a SearchResult.
+func (response searchResponse) result() (*SearchResult, error) {
+	result := &SearchResult{Aggregations: response.Aggregations}
+	if json.Unmarshal(response.Hits.Total, &result.Hits.Total) != nil {
+		var total struct {
+			Value int
+		}
+		err := json.Unmarshal(response.Hits.Total, &total)
+		if err != nil {
+			return nil, err
+		}
+		result.Hits.Total = total.Value
+	}
+	for _, hit := range response.Hits.Hits {
+		result.Hits.Hits = append(result.Hits.Hits, SearchHit{
+			Id:        hit.Id,
+			Source:    hit.Source,
+			Highlight: hit.Highlight,
+		})
+	}
+	return result, nil
+}
+
+// Search runs query against docType, rewritten for the cluster's version.
+func (e *ElasticsearchStore) Search(docType string, query string) (*SearchResult, error) {
+	version, err := e.esVersion()
+	if err != nil {
+		return nil, err
+	}
+	query, err = dialectFor(version).Search(query)
+	if err != nil {
+		return nil, err
+	}
+	body, err := e.do(esapi.SearchRequest{
+		Index:        []string{e.indexFor(version, docType)},
+		DocumentType: e.typesFor(version, docType),
+		Body:         strings.NewReader(query),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response searchResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.result()
+}
+
+// scrollKeepAlive is how long Elasticsearch holds a scroll open between pages.
+const scrollKeepAlive = time.Minute
+
+// Scroll walks every document matching query, a page of the query's size at a time. Paging with from stops at
+// 10,000 documents from 2.1 on, which a scroll doesn't.
+func (e *ElasticsearchStore) Scroll(docType string, query string, fn func(SearchHit) error) error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	query, err = dialectFor(version).Search(query)
+	if err != nil {
+		return err
+	}
+	body, err := e.do(esapi.SearchRequest{
+		Index:        []string{e.indexFor(version, docType)},
+		DocumentType: e.typesFor(version, docType),
+		Body:         strings.NewReader(query),
+		Scroll:       scrollKeepAlive,
+	})
+	for {
+		if err != nil {
+			return err
+		}
+		var response searchResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return err
+		}
+		page, err := response.result()
+		if err != nil {
+			return err
+		}
+		if len(page.Hits.Hits) == 0 {
+			// scrolls are dropped once they're idle for scrollKeepAlive, so a failure here only holds one open a while longer
+			e.do(esapi.ClearScrollRequest{ScrollID: []string{response.ScrollId}})
+			return nil
+		}
+		for _, hit := range page.Hits.Hits {
+			err = fn(hit)
+			if err != nil {
+				e.do(esapi.ClearScrollRequest{ScrollID: []string{response.ScrollId}})
+				return err
+			}
+		}
+		body, err = e.do(esapi.ScrollRequest{ScrollID: response.ScrollId, Scroll: scrollKeepAlive})
+	}
+}
+
+// Count runs query against docType as a count request, rewritten for the cluster's version.
+func (e *ElasticsearchStore) Count(docType string, query string) (int, error) {
+	version, err := e.esVersion()
+	if err != nil {
+		return 0, err
+	}
+	query, err = dialectFor(version).Count(query)
+	if err != nil {
+		return 0, err
+	}
+	body, err := e.do(esapi.CountRequest{
+		Index:        []string{e.indexFor(version, docType)},
+		DocumentType: e.typesFor(version, docType),
+		Body:         strings.NewReader(query),
+	})
+	if err != nil {
+		return 0, err
+	}
+	var countResponse struct {
+		Count int
+	}
+	err = json.Unmarshal(body, &countResponse)
+	return countResponse.Count, err
+}
+
+// Refresh refreshes the index, or every type's index behind the alias.
+func (e *ElasticsearchStore) Refresh() error {
+	_, err := e.do(esapi.IndicesRefreshRequest{Index: []string{e.Index}})
+	return err
+}
+
+// IndexExists checks for the index, or the template that sets up each type's index.
+func (e *ElasticsearchStore) IndexExists() (bool, error) {
+	version, err := e.esVersion()
+	if err != nil {
+		return false, err
+	}
+	if version >= 6 {
+		_, err = e.do(esapi.IndicesExistsTemplateRequest{Name: []string{e.Index}})
+	} else {
+		_, err = e.do(esapi.IndicesExistsRequest{Index: []string{e.Index}})
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sortableStrings maps strings that haven't been given a mapping the way 1.x did, as analyzed text that can still
+// be sorted on, ex: a user's Name. Since 5.x, sorting on text needs fielddata turned on.
+const sortableStrings = `{ "dynamic_templates": [ { "strings": { "match_mapping_type": "string", "mapping": { "type": "text", "fielddata": true, "fields": { "keyword": { "type": "keyword", "ignore_above": 256 } } } } } ] }`
+
+// CreateIndex creates the index or, from 6.x, the template each type's index is created from as it's needed.
+func (e *ElasticsearchStore) CreateIndex() error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	switch {
+	case version >= 7:
+		template := fmt.Sprintf(`{ "index_patterns": [ "%s-*" ], "aliases": { "%s": {} }, "mappings": %s }`, e.Index, e.Index, sortableStrings)
+		_, err = e.do(esapi.IndicesPutTemplateRequest{Name: e.Index, Body: strings.NewReader(template)})
+	case version == 6:
+		template := fmt.Sprintf(`{ "index_patterns": [ "%s-*" ], "aliases": { "%s": {} }, "mappings": { "_doc": %s } }`, e.Index, e.Index, sortableStrings)
+		_, err = e.do(esapi.IndicesPutTemplateRequest{Name: e.Index, Body: strings.NewReader(template)})
+	case version == 5:
+		// _default_ is copied into the mapping of each type as it's created
+		mappings := fmt.Sprintf(`{ "mappings": { "_default_": %s } }`, sortableStrings)
+		_, err = e.do(esapi.IndicesCreateRequest{Index: e.Index, Body: strings.NewReader(mappings)})
+	default:
+		_, err = e.do(esapi.IndicesCreateRequest{Index: e.Index})
+	}
+	return err
+}
+
+// DeleteIndex deletes the index or, from 6.x, every type's index behind the alias and the template.
+func (e *ElasticsearchStore) DeleteIndex() error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	if version < 6 {
+		_, err = e.do(esapi.IndicesDeleteRequest{Index: []string{e.Index}})
+		return err
+	}
+
+	body, err := e.do(esapi.CatAliasesRequest{Name: []string{e.Index}, Format: "json"})
+	if err != nil {
+		return err
+	}
+	var aliases []struct {
+		Index string
+	}
+	err = json.Unmarshal(body, &aliases)
+	if err != nil {
+		return err
+	}
+	var indices []string
+	for _, alias := range aliases {
+		indices = append(indices, alias.Index)
+	}
+	if len(indices) > 0 {
+		_, err = e.do(esapi.IndicesDeleteRequest{Index: indices})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = e.do(esapi.IndicesDeleteTemplateRequest{Name: e.Index})
+	return err
+}
+
+// PutMapping puts an elasticsearch mapping for docType, ex: `{"assets": {"properties": {...}}}`, rewritten for the
+// cluster's version. From 6.x, docType's index is created with the mapping if it doesn't exist yet.
+func (e *ElasticsearchStore) PutMapping(docType string, mapping string) error {
+	version, err := e.esVersion()
+	if err != nil {
+		return err
+	}
+	mapping, err = dialectFor(version).Mapping(docType, mapping)
+	if err != nil {
+		return err
+	}
+	index := e.indexFor(version, docType)
+	if version >= 6 {
+		_, err = e.do(esapi.IndicesExistsRequest{Index: []string{index}})
+		if isNotFound(err) {
+			if version < 7 {
+				mapping = fmt.Sprintf(`{ "_doc": %s }`, mapping)
+			}
+			_, err = e.do(esapi.IndicesCreateRequest{Index: index, Body: strings.NewReader(fmt.Sprintf(`{ "mappings": %s }`, mapping))})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = e.do(esapi.IndicesPutMappingRequest{
+		Index:        []string{index},
+		DocumentType: e.typeFor(version, docType),
+		Body:         strings.NewReader(mapping),
+	})
+	return err
+}
+
+// String describes where documents are kept, for logging.
+func (e *ElasticsearchStore) String() string {
+	return fmt.Sprintf("elasticsearch index %s", e.Index)
+}
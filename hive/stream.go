@@ -0,0 +1,243 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportBatchSize caps how many assets a streaming import stores per bulk request.
+const maxImportBatchSize = 5000
+
+// defaultImportBatchSize is how many assets an import stores per bulk request unless it asks for another size.
+const defaultImportBatchSize = 500
+
+// maxReportedFailures caps how many failed assets an import lists, so a broken mapping can't make a huge response.
+const maxReportedFailures = 100
+
+type assetStreamResponse struct {
+	Imported int // new assets stored
+	Updated  int // assets matched by Url or id to one already in the project, and updated in place
+	Existing int // assets skipped because their hashed id had already been imported
+	Batches  int // bulk requests made
+	Failed   int // assets elasticsearch refused to store, the rest of their batch was stored
+	// Failures says why assets weren't stored, for the first maxReportedFailures of them
+	Failures []BulkItemError `json:",omitempty"`
+	// Results says what happened to each asset, for imports small enough to list them, see importAssets
+	Results []assetImportResult `json:",omitempty"`
+	Import  string              `json:",omitempty"` // the import's id, to resume or roll it back, see ImportJob
+}
+
+// assetImportResult is what an import did with one asset.
+type assetImportResult struct {
+	Id     string
+	Url    string
+	Result string // "created", "updated" or "existing"
+}
+
+// wantsNdjson reports whether a request body is newline-delimited JSON, one asset per line.
+func wantsNdjson(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/x-ndjson") || defaultQuery(r.URL.Query(), "format", "") == "ndjson"
+}
+
+// importBatchSize reads the batch query param, defaulting to defaultImportBatchSize.
+func importBatchSize(r *http.Request) int {
+	n, err := strconv.Atoi(defaultQuery(r.URL.Query(), "batch", strconv.Itoa(defaultImportBatchSize)))
+	if err != nil || n <= 0 {
+		n = defaultImportBatchSize
+	}
+	if n > maxImportBatchSize {
+		n = maxImportBatchSize
+	}
+	return n
+}
+
+// StreamAssets imports assets from a stream of JSON objects, decoding them one at a time and storing them
+// batchSize at a time, so imports of any size run in constant memory.
+func (s *Server) StreamAssets(body io.Reader, batchSize int, dedup bool) (assetStreamResponse, error) {
+	decoder := json.NewDecoder(body)
+	line := 0
+	return s.importAssetStream(batchSize, dedup, func() (*Asset, error) {
+		line++
+		var asset Asset
+		err := decoder.Decode(&asset)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("asset %d isn't valid JSON: %v", line, err)
+		}
+		return &asset, nil
+	})
+}
+
+// importAssetStream stores the assets returned by next, batchSize at a time, until next returns none.
+// Assets without an id, in projects that don't hash them, are given a random one.
+func (s *Server) importAssetStream(batchSize int, dedup bool, next func() (*Asset, error)) (imported assetStreamResponse, err error) {
+	return s.importAssetBatches(batchSize, dedup, next, nil)
+}
+
+// importAssetBatches is importAssetStream, also calling stored, when it isn't nil, with each asset once it's
+// stored or found to be there already, and whether it was "created", "updated" or "existing". Assets elasticsearch
+// refuses are counted as failed rather than ending the import.
+//
+// When the request is recording an ImportJob, see startImport, the assets it creates are tagged with the job's id
+// and its progress is saved after every batch. A resumed job skips the assets its earlier runs got through.
+func (s *Server) importAssetBatches(batchSize int, dedup bool, next func() (*Asset, error), stored func(asset Asset, result string)) (imported assetStreamResponse, err error) {
+	imp, err := s.newAssetImport(dedup)
+	if err != nil {
+		return
+	}
+
+	job := s.activeImport
+	var before ImportJob // the job as earlier runs left it
+	handled := 0         // assets stored, in the batch or already there, so far
+	if job != nil {
+		before = *job
+		imported.Import = job.Id
+		for handled < job.Processed {
+			skipped, err := next()
+			if err != nil {
+				return imported, fmt.Errorf("Sorry, %v, while skipping the assets already imported.", err)
+			}
+			if skipped == nil {
+				break
+			}
+			handled++
+		}
+	}
+	// progress saves how far the job has got once everything handled so far is stored
+	progress := func() error {
+		if job == nil {
+			return nil
+		}
+		job.Processed = handled
+		job.Imported = before.Imported + imported.Imported
+		job.Updated = before.Updated + imported.Updated
+		job.Existing = before.Existing + imported.Existing
+		job.Failed = before.Failed + imported.Failed
+		_, err := s.Store.Put("imports", job.Id, job)
+		return err
+	}
+
+	batch := make(map[string]interface{})
+	var batchIds []string               // batch's ids in the order they were imported
+	updatedIds := make(map[string]bool) // the assets in batch that were already stored
+	flush := func() error {
+		if len(batch) == 0 {
+			return progress()
+		}
+		err := s.Store.PutMany("assets", batch)
+		bulkErr, partial := err.(*BulkError)
+		if err != nil && !partial {
+			return err
+		}
+		imported.Batches++
+		for _, id := range batchIds {
+			if partial && bulkErr.failed(id) {
+				continue
+			}
+			result := "created"
+			if updatedIds[id] {
+				imported.Updated++
+				result = "updated"
+			} else {
+				imported.Imported++
+			}
+			if stored != nil {
+				stored(batch[id].(Asset), result)
+			}
+		}
+		if partial {
+			imported.Failed += len(bulkErr.Items)
+			for _, item := range bulkErr.Items {
+				if len(imported.Failures) < maxReportedFailures {
+					imported.Failures = append(imported.Failures, item)
+				}
+			}
+		}
+		batch = make(map[string]interface{})
+		batchIds = nil
+		updatedIds = make(map[string]bool)
+		return progress()
+	}
+
+	for n := handled + 1; ; n++ {
+		newAsset, err := next()
+		if err != nil {
+			return imported, fmt.Errorf("Sorry, %v. %d assets were imported before it.", err, imported.Imported)
+		}
+		if newAsset == nil {
+			break
+		}
+
+		// an asset repeating a url or id earlier in this batch updates that one, so it has to be stored first
+		id, ok := imp.urlIds[newAsset.Url]
+		if stableId := stableAssetId(s.ActiveProjectId, *newAsset); stableId != "" {
+			id, ok = stableId, true
+		}
+		if ok && batch[id] != nil {
+			err = flush()
+			if err != nil {
+				return imported, err
+			}
+		}
+
+		asset, existing, updated, err := s.prepareAsset(imp, *newAsset)
+		if invalid, ok := err.(*ValidationError); ok {
+			// say which asset, as a JSON import would
+			prefixed := &ValidationError{Message: fmt.Sprintf("Sorry, asset %d couldn't be imported: %v %d assets were imported before it.", n, err, imported.Imported)}
+			for _, field := range invalid.Fields {
+				prefixed.add(fmt.Sprintf("Assets[%d].%s", n-1, field.Field), "%s", field.Error)
+			}
+			return imported, prefixed
+		}
+		if err != nil {
+			return imported, fmt.Errorf("Sorry, asset %d couldn't be imported: %v. %d assets were imported before it.", n, err, imported.Imported)
+		}
+		if existing != nil {
+			imported.Existing++
+			handled = n
+			if stored != nil {
+				stored(*existing, "existing")
+			}
+			continue
+		}
+		if asset.Id == "" {
+			asset.Id, err = randomId()
+			if err != nil {
+				return imported, err
+			}
+		}
+		if job != nil && !updated {
+			asset.ImportId = job.Id
+		}
+
+		// the batch is keyed by id, so an asset repeated within one batch is only stored once
+		if batch[asset.Id] == nil {
+			batchIds = append(batchIds, asset.Id)
+		}
+		batch[asset.Id] = asset
+		if updated {
+			updatedIds[asset.Id] = true
+		}
+		handled = n
+		if len(batch) >= batchSize {
+			err = flush()
+			if err != nil {
+				return imported, err
+			}
+		}
+	}
+
+	err = flush()
+	if err != nil {
+		return
+	}
+	err = s.Store.Refresh()
+	return
+}
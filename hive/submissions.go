@@ -0,0 +1,323 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Attachment references a file uploaded along with an assignment submission, ex: a cropped image or an audio clip.
+// It is stored in the assignment's SubmittedData under the name of the form field the file was uploaded in.
+type Attachment struct {
+	Key         string // where the file is kept in blob storage
+	Url         string // where the file can be fetched from
+	Name        string // the uploaded file's original name
+	ContentType string
+	Size        int64
+}
+
+// readAssignmentSubmission returns the assignment the session's user is submitting. Multipart submissions carry the
+// JSON in an "assignment" field; every uploaded file is stored and added to the assignment's SubmittedData as an
+// Attachment. The assignment has to be the user's own, see findOwnAssignment, before anything is stored, and
+// which assignment it is, whose and for what is taken from the stored copy rather than the body.
+func (s *Server) readAssignmentSubmission(w http.ResponseWriter, r *http.Request, userId string) (*Assignment, error) {
+	if userId == "" {
+		return nil, unauthorized("Sorry, submitting an assignment requires a valid user.")
+	}
+
+	multipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+	var assignment Assignment
+	if multipart {
+		if s.Blobs == nil {
+			return nil, errors.New("Sorry, file uploads aren't configured on this server.")
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		err := r.ParseMultipartForm(maxUploadSize)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(r.FormValue("assignment")), &assignment)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := json.NewDecoder(r.Body).Decode(&assignment)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stored, err := s.findOwnAssignment(assignment.Id, userId)
+	if err != nil {
+		return nil, err
+	}
+	assignment.Id = stored.Id
+	assignment.User = stored.User
+	assignment.Project = stored.Project
+	assignment.Task = stored.Task
+	assignment.Asset = stored.Asset
+	if !multipart {
+		return &assignment, nil
+	}
+
+	if assignment.SubmittedData == nil {
+		assignment.SubmittedData = make(SubmittedData)
+	}
+
+	// one file per field, stored under the field's name
+	for field, files := range r.MultipartForm.File {
+		header := files[0]
+		key, err := blobKey(s.ActiveProjectId, "attachments", assignment.Id, field, header.Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		contentType := header.Header.Get("Content-Type")
+		url, err := s.Blobs.Put(key, contentType, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		assignment.SubmittedData[field] = Attachment{
+			Key:         key,
+			Url:         url,
+			Name:        header.Filename,
+			ContentType: contentType,
+			Size:        header.Size,
+		}
+	}
+	return &assignment, nil
+}
+
+// mergeSubmittedData merges partial answers into previously saved ones, returning the combined data.
+// Nested objects are merged key by key, so each screen of a multi-step task only needs to send its own fields.
+func mergeSubmittedData(saved SubmittedData, partial SubmittedData) SubmittedData {
+	merged := make(SubmittedData)
+	for key, value := range saved {
+		merged[key] = value
+	}
+	for key, value := range partial {
+		merged[key] = mergeSubmittedValue(merged[key], value)
+	}
+	return merged
+}
+
+// mergeSubmittedValue merges two freeform json values, recursing into objects and otherwise preferring the newer value.
+func mergeSubmittedValue(saved interface{}, partial interface{}) interface{} {
+	savedMap, savedOk := saved.(map[string]interface{})
+	partialMap, partialOk := partial.(map[string]interface{})
+	if !savedOk || !partialOk {
+		return partial
+	}
+
+	merged := make(map[string]interface{})
+	for key, value := range savedMap {
+		merged[key] = value
+	}
+	for key, value := range partialMap {
+		merged[key] = mergeSubmittedValue(merged[key], value)
+	}
+	return merged
+}
+
+// findOwnAssignment looks up an assignment that belongs to the user in the current project. Someone else's is
+// answered as if it weren't there, and a request without a user as unauthorized.
+func (s *Server) findOwnAssignment(assignmentId string, userId string) (*Assignment, error) {
+	if userId == "" {
+		return nil, unauthorized("Sorry, that requires a valid user.")
+	}
+	if assignmentId == "" {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "Id", Error: "is required"}}}
+	}
+	assignment, err := s.FindAssignment(assignmentId)
+	if err != nil {
+		return nil, err
+	}
+	if assignment.Project != s.ActiveProjectId || assignment.User != userId {
+		return nil, notFound("Sorry, there isn't an assignment with that id for the current user.")
+	}
+	return assignment, nil
+}
+
+// findUnfinishedAssignment looks up an assignment that belongs to the user and hasn't been submitted or skipped yet.
+func (s *Server) findUnfinishedAssignment(assignmentId string, userId string) (*Assignment, error) {
+	assignment, err := s.findOwnAssignment(assignmentId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if assignment.State != "unfinished" {
+		return nil, invalidState("Sorry, only unfinished assignments can be saved partway.")
+	}
+	return assignment, nil
+}
+
+// SaveAssignmentProgress merges partially submitted data into an unfinished assignment without changing its state.
+func (s *Server) SaveAssignmentProgress(assignmentId string, userId string, requestBody io.Reader) (*Assignment, error) {
+	_, err := s.findUnfinishedAssignment(assignmentId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	var partial struct {
+		SubmittedData SubmittedData
+	}
+	err = json.Unmarshal(body, &partial)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignment Assignment
+	err = s.updateDoc("assignments", assignmentId, &assignment, func(found bool) error {
+		// it may have been submitted since it was looked up
+		if !found || assignment.State != "unfinished" {
+			return invalidState("Sorry, only unfinished assignments can be saved partway.")
+		}
+		assignment.SubmittedData = mergeSubmittedData(assignment.SubmittedData, partial.SubmittedData)
+		assignment.Updated = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// @Title PartialAssignmentHandler
+// @Description saves part of an assignment's submitted data, ex: one screen of a multi-step task, leaving it unfinished
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   assignment_id        path   string     true        "Assignment ID"
+// @Param   assignment        body   string     true        "JSON-formatted partial data, ex: {\"SubmittedData\": {\"transcribe\": {\"headline\": \"...\"}}}"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object} assignmentResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assignments
+// @Router /projects/{project_id}/assignments/{assignment_id} [patch]
+func (s *Server) PartialAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, saving an assignment requires a valid user."))
+		return
+	}
+
+	assignment, err := s.SaveAssignmentProgress(vars["assignment_id"], userId, r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.signAssetUrl(&assignment.Asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assignmentJson, err := json.Marshal(assignmentResponse{
+		Assignment: *assignment,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assignmentJson)
+}
+
+// SaveAssignmentDraft replaces an unfinished assignment's draft with the request body's Draft.
+// Drafts are saved often, so unlike submissions they don't refresh the index or touch Updated.
+func (s *Server) SaveAssignmentDraft(assignmentId string, userId string, requestBody io.Reader) (*Assignment, error) {
+	_, err := s.findUnfinishedAssignment(assignmentId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	var draft struct {
+		Draft SubmittedData
+	}
+	err = json.Unmarshal(body, &draft)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignment Assignment
+	err = s.updateDoc("assignments", assignmentId, &assignment, func(found bool) error {
+		if !found || assignment.State != "unfinished" {
+			return invalidState("Sorry, only unfinished assignments can be saved partway.")
+		}
+		assignment.Draft = draft.Draft
+		assignment.DraftSaved = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// @Title AssignmentDraftHandler
+// @Description autosaves the current user's work in progress on an assignment, leaving it unfinished
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   assignment_id        path   string     true        "Assignment ID"
+// @Param   draft        body   string     true        "JSON-formatted draft, ex: {\"Draft\": {\"transcribe\": {\"text\": \"Fine furs at ha\"}}}"
+// @Param   user_id        header   string     true        "User ID stored in a cookie named according to the project '{project_id}_user_id'"
+// @Success 200 {object} assignmentResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 401 {object} errorResponse	there's no session user
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assignments
+// @Router /projects/{project_id}/assignments/{assignment_id}/draft [post]
+func (s *Server) AssignmentDraftHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	userId := s.SessionUserId(r)
+	if userId == "" {
+		s.wrapFailure(w, r, unauthorized("Sorry, saving a draft requires a valid user."))
+		return
+	}
+
+	assignment, err := s.SaveAssignmentDraft(vars["assignment_id"], userId, r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	err = s.signAssetUrl(&assignment.Asset)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assignmentJson, err := json.Marshal(assignmentResponse{
+		Assignment: *assignment,
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assignmentJson)
+}
@@ -0,0 +1,59 @@
+package hive
+
+//go:generate go run ../cmd/hiveswagger -o swagger.json
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/url"
+)
+
+// docsPath is where the API's Swagger UI is served.
+const docsPath = "/docs/"
+
+// swaggerJson describes every annotated handler, generated from their annotations by go generate.
+//
+//go:embed swagger.json
+var swaggerJson []byte
+
+// docsFiles is the Swagger UI page, which reads swagger.json from the browser.
+//
+//go:embed docs
+var docsFiles embed.FS
+
+// @Title SwaggerHandler
+// @Description returns a Swagger 2.0 document of the API's routes, parameters and responses
+// @Success 200 {object}  string	the Swagger document
+// @Failure 500 {object} error	appropriate error message
+// @Resource /docs
+// @Router /swagger.json [get]
+func (s *Server) SwaggerHandler(w http.ResponseWriter, r *http.Request) {
+	var doc map[string]interface{}
+	err := json.Unmarshal(swaggerJson, &doc)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	// hive can be mounted under a path, which BaseUrl includes
+	if base, err := url.Parse(s.BaseUrl); err == nil && base.Path != "" {
+		doc["basePath"] = base.Path
+	}
+	docJson, err := json.Marshal(doc)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, docJson)
+}
+
+// docsHandler serves the Swagger UI's files.
+func docsHandler() http.Handler {
+	files, err := fs.Sub(docsFiles, "docs")
+	if err != nil {
+		// only if the embed directive above and the directory name disagree
+		panic(err)
+	}
+	return http.StripPrefix(docsPath, http.FileServer(http.FS(files)))
+}
@@ -0,0 +1,207 @@
+package hive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	_ "golang.org/x/image/tiff"
+)
+
+const (
+	defaultThumbWidth = 400
+	maxThumbWidth     = 2000
+	// maxThumbSourceSize caps how much of an asset is downloaded to make a thumbnail, in bytes.
+	// Archive scans can be hundreds of megabytes.
+	maxThumbSourceSize = 256 << 20
+	// thumbMaxAge is how long browsers and CDNs may keep a thumbnail. Its url changes with the asset's Url,
+	// so it can be long.
+	thumbMaxAge = 30 * 24 * time.Hour
+)
+
+// thumbClient fetches the images thumbnails are made from, with a timeout so a slow archive server can't tie up
+// requests forever.
+var thumbClient = &http.Client{Timeout: 2 * time.Minute}
+
+// thumbKey is where a thumbnail of asset at width is cached in blob storage. It includes a hash of the asset's Url,
+// so a corrected Url makes a new thumbnail instead of serving the old one.
+func thumbKey(asset *Asset, width int) string {
+	urlHash := sha256.Sum256([]byte(asset.Url))
+	return fmt.Sprintf("thumbs/%s/%s/%s-%d.jpg", asset.Project, asset.Id, hex.EncodeToString(urlHash[:8]), width)
+}
+
+// AssetThumb returns a JPEG of asset scaled to width pixels wide, or its own width if that's smaller.
+// Thumbnails are cached in blob storage when it's configured and the asset isn't private.
+func (s *Server) AssetThumb(asset *Asset, width int) ([]byte, error) {
+	reader, cacheable := s.Blobs.(BlobReader)
+	cacheable = cacheable && !asset.Private
+	key := thumbKey(asset, width)
+
+	if cacheable {
+		cached, err := reader.Get(key)
+		if err == nil {
+			defer cached.Close()
+			return ioutil.ReadAll(cached)
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	src, err := s.fetchAssetImage(asset)
+	if err != nil {
+		return nil, err
+	}
+	var thumb bytes.Buffer
+	err = jpeg.Encode(&thumb, scaleImage(src, width), &jpeg.Options{Quality: 85})
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		_, err = s.Blobs.Put(key, "image/jpeg", bytes.NewReader(thumb.Bytes()))
+		if err != nil {
+			// the thumbnail is still good, it'll just be made again next time
+			s.logEvent("Failed caching thumbnail", logFields{"key": key, "error": err.Error()})
+		}
+	}
+	return thumb.Bytes(), nil
+}
+
+// fetchAssetImage downloads and decodes an asset's image. JPEG, PNG, GIF and TIFF are understood.
+func (s *Server) fetchAssetImage(asset *Asset) (image.Image, error) {
+	fetched := *asset
+	err := s.signAssetUrl(&fetched)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := thumbClient.Get(fetched.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Sorry, the asset's image couldn't be fetched: %s", resp.Status)
+	}
+
+	src, _, err := image.Decode(io.LimitReader(resp.Body, maxThumbSourceSize))
+	if err == image.ErrFormat {
+		return nil, errors.New("Sorry, thumbnails can only be made of JPEG, PNG, GIF and TIFF images.")
+	}
+	return src, err
+}
+
+// scaleImage shrinks src to width pixels wide, keeping its aspect ratio, by averaging the block of source pixels
+// behind each one. Transparent areas come out white. Images narrower than width keep their size.
+func scaleImage(src image.Image, width int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if width > srcW {
+		width = srcW
+	}
+	height := srcH * width / srcW
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcH/height
+		y1 := bounds.Min.Y + (y+1)*srcH/height
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcW/width
+			x1 := bounds.Min.X + (x+1)*srcW/width
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+				}
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			// colors are premultiplied by alpha, so adding what's left of white composites onto it
+			white := 0xffff*n - a
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8((r + white) / n >> 8)
+			dst.Pix[i+1] = uint8((g + white) / n >> 8)
+			dst.Pix[i+2] = uint8((b + white) / n >> 8)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// @Title AssetThumbHandler
+// @Description returns a JPEG thumbnail of an asset's image, so frontends don't have to load full-size scans
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Param   w        query   int     false        "Width in pixels, defaults to 400 (max 2000). Images narrower than this keep their size"
+// @Success 200 {object}  string	the JPEG
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /projects/{project_id}/assets/{asset_id}/thumb [get]
+func (s *Server) AssetThumbHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	width, err := strconv.Atoi(defaultQuery(r.URL.Query(), "w", strconv.Itoa(defaultThumbWidth)))
+	if err != nil || width < 1 || width > maxThumbWidth {
+		s.wrapFailure(w, r, fmt.Errorf("Sorry, w has to be a width in pixels from 1 to %d.", maxThumbWidth))
+		return
+	}
+
+	asset, err := s.FindAsset(vars["asset_id"])
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	etag := `"` + thumbKey(asset, width)[len("thumbs/"):] + `"`
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(thumbMaxAge.Seconds()))
+	if asset.Private {
+		cacheControl = fmt.Sprintf("private, max-age=%d", int(signedUrlTTL.Seconds()))
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	thumb, err := s.AssetThumb(asset, width)
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("ETag")
+		s.wrapFailure(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumb)))
+	w.WriteHeader(200)
+	w.Write(thumb)
+}
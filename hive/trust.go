@@ -0,0 +1,133 @@
+package hive
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Trust is how often a user's finished assignments agree with the answers their assets were verified with.
+// Users with no assignments on verified assets yet have none.
+type Trust struct {
+	Compared int     // finished assignments on assets verified for their task
+	Agreed   int     // of those, how many matched the verified answer
+	Score    float64 // Agreed / Compared
+}
+
+type trustScoreResponse struct {
+	Users    int // users whose Trust was recalculated
+	Compared int // finished assignments compared with verified answers, across all users
+	Agreed   int
+}
+
+// ScoreTrust recalculates every user's Trust in the current project, comparing each of their finished assignments
+// on a verified asset with the answer the asset was verified with for the assignment's task.
+func (s *Server) ScoreTrust() (score trustScoreResponse, err error) {
+	tasks, err := s.FindLiveTasks()
+	if err != nil {
+		return
+	}
+	taskNames := make(map[string]string)
+	for _, task := range tasks {
+		taskNames[task.Id] = task.Name
+	}
+
+	// the final answers, by asset id then task name
+	answers := make(map[string]SubmittedData)
+	verifiedFilters := []string{`{ "term": { "Verified": true } }`}
+	err = s.forEachMatchingDoc("assets", verifiedFilters, func(source json.RawMessage) error {
+		var asset Asset
+		err := json.Unmarshal(source, &asset)
+		if err != nil {
+			return err
+		}
+		answers[asset.Id] = asset.SubmittedData
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// assignments that made it into a verified answer are marked "verified", the rest stay "finished"
+	trusts := make(map[string]*Trust)
+	assignmentFilters := []string{`{ "terms": { "State": [ "finished", "verified" ] } }`}
+	err = s.forEachMatchingDoc("assignments", assignmentFilters, func(source json.RawMessage) error {
+		var assignment Assignment
+		err := json.Unmarshal(source, &assignment)
+		if err != nil {
+			return err
+		}
+		answer := answers[assignment.Asset.Id][taskNames[assignment.Task]]
+		if answer == nil {
+			return nil
+		}
+		trust := trusts[assignment.User]
+		if trust == nil {
+			trust = &Trust{}
+			trusts[assignment.User] = trust
+		}
+		trust.Compared++
+		score.Compared++
+		if sameAnswer(answer, assignment.SubmittedData) {
+			trust.Agreed++
+			score.Agreed++
+		}
+		trust.Score = float64(trust.Agreed) / float64(trust.Compared)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = s.forEachProjectDoc("users", func(source json.RawMessage) error {
+		var user User
+		err := json.Unmarshal(source, &user)
+		if err != nil {
+			return err
+		}
+		_, err = s.updateUser(user.Id, func(user *User) error {
+			user.Trust = trusts[user.Id]
+			return nil
+		})
+		if err == ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		score.Users++
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	err = s.Store.Refresh()
+	return
+}
+
+// @Title AdminScoreTrustHandler
+// @Description recalculates every user's Trust, how often their answers agree with verified answers, to find unreliable contributors with /admin/projects/{project_id}/users?sortBy=trust
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Success 200 {object}  trustScoreResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /users
+// @Router /admin/projects/{project_id}/trust/score [post]
+func (s *Server) AdminScoreTrustHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	score, err := s.ScoreTrust()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	scoreJson, err := json.Marshal(score)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, scoreJson)
+}
@@ -0,0 +1,33 @@
+package hive
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// uiPath is where the admin dashboard is served.
+const uiPath = "/admin/ui/"
+
+// uiFiles are the admin dashboard's page, script and styles. It's a static page that reads the admin endpoints
+// from the browser, so every deployment has the same dashboard without building its own.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// isAdminUiPath reports whether a request is for one of the dashboard's files. They hold no data, so they're served
+// without an API key; the dashboard asks for one and sends it with the admin requests it makes.
+func isAdminUiPath(path string) bool {
+	return path == strings.TrimSuffix(uiPath, "/") || strings.HasPrefix(path, uiPath)
+}
+
+// adminUiHandler serves the dashboard's files.
+func adminUiHandler() http.Handler {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		// only if the embed directive above and the directory name disagree
+		panic(err)
+	}
+	return http.StripPrefix(uiPath, http.FileServer(http.FS(files)))
+}
@@ -0,0 +1,205 @@
+// The hive admin dashboard reads everything from the admin JSON endpoints. Signed in reviewers are recognized by
+// their session cookie; anyone else is asked for an admin API key, kept for the browser session.
+(function () {
+  'use strict';
+
+  var keyStorage = 'hiveAdminKey';
+  var refreshSeconds = 30;
+  var projectId = decodeURIComponent(location.hash.slice(1));
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  // api fetches an admin endpoint's JSON, rejecting with hive's error message when it fails.
+  function api(path) {
+    var headers = {};
+    var key = sessionStorage.getItem(keyStorage);
+    if (key) {
+      headers.Authorization = 'Bearer ' + key;
+    }
+    return fetch(path, { headers: headers, credentials: 'same-origin' }).then(function (resp) {
+      if (resp.status === 401 || resp.status === 403) {
+        showKeyForm();
+      }
+      return resp.json().then(function (body) {
+        if (!resp.ok) {
+          throw new Error(body.error || resp.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function projectPath(path) {
+    return '/admin/projects/' + encodeURIComponent(projectId) + (path || '');
+  }
+
+  function showError(err) {
+    $('error').textContent = err ? err.message : '';
+    $('error').hidden = !err;
+  }
+
+  function showKeyForm() {
+    $('key').hidden = false;
+    $('dashboard').hidden = true;
+  }
+
+  // cell adds a table cell holding text, or a node.
+  function cell(row, content, className) {
+    var td = row.insertCell();
+    if (content instanceof Node) {
+      td.appendChild(content);
+    } else {
+      td.textContent = content === undefined || content === null ? '' : content;
+    }
+    if (className) {
+      td.className = className;
+    }
+    return td;
+  }
+
+  function loadProjects() {
+    return api('/admin/projects?size=100').then(function (body) {
+      var select = $('projects');
+      select.textContent = '';
+      body.Projects.forEach(function (project) {
+        select.add(new Option(project.Name || project.Id, project.Id));
+      });
+      if (!projectId && body.Projects.length > 0) {
+        projectId = body.Projects[0].Id;
+      }
+      select.value = projectId;
+    });
+  }
+
+  function loadProject() {
+    return api(projectPath()).then(function (body) {
+      var project = body.Project;
+      $('project-name').textContent = (project.Name || project.Id) + ': ' + project.Progress + '% verified';
+      $('progress-bar').style.width = project.Progress + '%';
+
+      var counts = [
+        ['Assets', project.AssetCount],
+        ['Verified', project.VerifiedCount],
+        ['Excluded', project.ExcludedCount],
+        ['Tasks', project.TaskCount],
+        ['Users', project.UserCount]
+      ];
+      Object.keys(project.AssignmentCount || {}).sort().forEach(function (state) {
+        counts.push([state.charAt(0).toUpperCase() + state.slice(1) + ' assignments', project.AssignmentCount[state]]);
+      });
+
+      var list = $('project-counts');
+      list.textContent = '';
+      counts.forEach(function (count) {
+        var div = document.createElement('div');
+        var dt = document.createElement('dt');
+        var dd = document.createElement('dd');
+        dt.textContent = count[0];
+        dd.textContent = (count[1] || 0).toLocaleString();
+        div.appendChild(dt);
+        div.appendChild(dd);
+        list.appendChild(div);
+      });
+    });
+  }
+
+  function loadTasks() {
+    return api(projectPath('/tasks?size=100')).then(function (body) {
+      var tbody = $('tasks');
+      tbody.textContent = '';
+      body.Tasks.forEach(function (task) {
+        var row = tbody.insertRow();
+        cell(row, task.Name);
+        cell(row, task.Description);
+        cell(row, task.CurrentState, 'state-' + task.CurrentState);
+
+        var complete = document.createElement('button');
+        complete.type = 'button';
+        complete.textContent = 'Complete';
+        complete.title = 'Verify the assets that meet this task\'s completion criteria';
+        complete.addEventListener('click', function () {
+          completeTask(task, complete);
+        });
+        cell(row, complete);
+      });
+    });
+  }
+
+  function completeTask(task, button) {
+    button.disabled = true;
+    button.textContent = 'Completing...';
+    api(projectPath('/tasks/' + encodeURIComponent(task.Name) + '/complete')).then(function (body) {
+      var verified = (body.Assets || []).length;
+      button.textContent = verified + (verified === 1 ? ' asset' : ' assets') + ' verified';
+      return loadProject();
+    }).catch(function (err) {
+      button.textContent = 'Complete';
+      showError(err);
+    }).then(function () {
+      button.disabled = false;
+    });
+  }
+
+  function loadAssignments() {
+    return api(projectPath('/assignments?size=20&sortBy=Updated&sortDir=desc')).then(function (body) {
+      var tbody = $('assignments');
+      tbody.textContent = '';
+      body.Assignments.forEach(function (assignment) {
+        var row = tbody.insertRow();
+        cell(row, new Date(assignment.Updated).toLocaleString());
+        cell(row, assignment.Task.replace(projectId + '-', ''));
+        cell(row, assignment.Asset.Name || assignment.Asset.Id);
+        cell(row, assignment.User);
+        cell(row, assignment.State, 'state-' + assignment.State);
+      });
+    });
+  }
+
+  function load() {
+    showError(null);
+    if (!projectId) {
+      return;
+    }
+    Promise.all([loadProject(), loadTasks(), loadAssignments()]).then(function () {
+      $('dashboard').hidden = false;
+      $('key').hidden = true;
+    }).catch(showError);
+  }
+
+  function start() {
+    $('signout').hidden = !sessionStorage.getItem(keyStorage);
+    // reviewers signed in to one project can't list them all, but can still open theirs by its #id
+    loadProjects().catch(function (err) {
+      if (!projectId) {
+        throw err;
+      }
+    }).then(load).catch(showError);
+  }
+
+  $('projects').addEventListener('change', function () {
+    projectId = this.value;
+    location.hash = encodeURIComponent(projectId);
+    load();
+  });
+  $('refresh').addEventListener('click', load);
+  $('signout').addEventListener('click', function () {
+    sessionStorage.removeItem(keyStorage);
+    location.reload();
+  });
+  $('key').addEventListener('submit', function (e) {
+    e.preventDefault();
+    sessionStorage.setItem(keyStorage, $('key-value').value);
+    $('key-value').value = '';
+    $('key').hidden = true;
+    start();
+  });
+
+  setInterval(function () {
+    if (!document.hidden && !$('dashboard').hidden) {
+      load();
+    }
+  }, refreshSeconds * 1000);
+  start();
+})();
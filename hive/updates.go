@@ -0,0 +1,99 @@
+package hive
+
+import (
+	"errors"
+	"reflect"
+)
+
+// maxWriteAttempts is how many times updateDoc reads and saves a document that keeps being changed underneath it.
+const maxWriteAttempts = 5
+
+// errUnchanged is returned by an updateDoc change that finds the document doesn't need changing after all, ex: an
+// assignment that was submitted since it was found to be stale. The document isn't saved and updateDoc returns nil.
+var errUnchanged = errors.New("unchanged")
+
+// updateDoc reads the document with id into doc, calls change to modify it, and saves it. change is told whether
+// the document was found, and doc is reset to its zero value before each read.
+//
+// Two requests updating the same document at once, ex: two submissions for one asset, would otherwise both read it
+// and the second save would undo the first's change. So when the store is a Versioner the save only goes through
+// if nobody saved the document since it was read; otherwise it's read and changed again, up to maxWriteAttempts
+// times, before giving up with ErrConflict. change shouldn't have side effects beyond doc for that reason.
+func (s *Server) updateDoc(docType string, id string, doc interface{}, change func(found bool) error) error {
+	versioner, versioned := s.Store.(Versioner)
+	for attempt := 1; ; attempt++ {
+		reset := reflect.ValueOf(doc).Elem()
+		reset.Set(reflect.Zero(reset.Type()))
+
+		found := true
+		var version string
+		var err error
+		if versioned {
+			version, err = versioner.GetVersioned(docType, id, doc)
+			if err == ErrNotFound {
+				found, err = false, nil
+			}
+		} else {
+			found, err = s.Store.Exists(docType, id)
+			if found && err == nil {
+				err = s.Store.Get(docType, id, doc)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		err = change(found)
+		if err == errUnchanged {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !versioned {
+			_, err = s.Store.Put(docType, id, doc)
+			return err
+		}
+		err = versioner.PutVersioned(docType, id, doc, version)
+		if err != ErrConflict || attempt == maxWriteAttempts {
+			return err
+		}
+		s.logEvent("retrying conflicting write", logFields{"type": docType, "id": id, "attempt": attempt})
+	}
+}
+
+// updateAsset applies change to the stored asset with id, see updateDoc, returning ErrNotFound when there isn't one.
+func (s *Server) updateAsset(id string, change func(asset *Asset) error) (*Asset, error) {
+	var asset Asset
+	err := s.updateDoc("assets", id, &asset, func(found bool) error {
+		if !found {
+			return ErrNotFound
+		}
+		if asset.Counts == nil {
+			asset.Counts = Counts{}
+		}
+		return change(&asset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// updateUser applies change to the stored user with id, see updateDoc, returning ErrNotFound when there isn't one.
+func (s *Server) updateUser(id string, change func(user *User) error) (*User, error) {
+	var user User
+	err := s.updateDoc("users", id, &user, func(found bool) error {
+		if !found {
+			return ErrNotFound
+		}
+		if user.Counts == nil {
+			user.Counts = Counts{}
+		}
+		return change(&user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
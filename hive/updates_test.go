@@ -0,0 +1,153 @@
+package hive
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+// versionedStore keeps documents in memory as JSON, with a version each. beforePut, when set, runs before each
+// PutVersioned, ex: to save the document in between, as a concurrent request would.
+type versionedStore struct {
+	Store
+	docs      map[string][]byte
+	versions  map[string]int
+	beforePut func()
+}
+
+func newVersionedStore() *versionedStore {
+	return &versionedStore{docs: map[string][]byte{}, versions: map[string]int{}}
+}
+
+func (m *versionedStore) Put(docType string, id string, doc interface{}) (string, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	m.docs[docType+"/"+id] = data
+	m.versions[docType+"/"+id]++
+	return id, nil
+}
+
+func (m *versionedStore) GetVersioned(docType string, id string, doc interface{}) (string, error) {
+	data, ok := m.docs[docType+"/"+id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return strconv.Itoa(m.versions[docType+"/"+id]), json.Unmarshal(data, doc)
+}
+
+func (m *versionedStore) PutVersioned(docType string, id string, doc interface{}, version string) error {
+	if m.beforePut != nil {
+		m.beforePut()
+	}
+	current := ""
+	if _, ok := m.docs[docType+"/"+id]; ok {
+		current = strconv.Itoa(m.versions[docType+"/"+id])
+	}
+	if current != version {
+		return ErrConflict
+	}
+	_, err := m.Put(docType, id, doc)
+	return err
+}
+
+func TestUpdateAssetRetriesConflicts(t *testing.T) {
+	store := newVersionedStore()
+	store.Put("assets", "a1", Asset{Id: "a1", Counts: Counts{"finished": 1}})
+	s := &Server{Store: store}
+
+	// another submission saves the asset between the first read and save
+	conflicts := 1
+	store.beforePut = func() {
+		if conflicts > 0 {
+			conflicts--
+			store.Put("assets", "a1", Asset{Id: "a1", Counts: Counts{"finished": 2}})
+		}
+	}
+	attempts := 0
+	asset, err := s.updateAsset("a1", func(asset *Asset) error {
+		attempts++
+		asset.Counts["finished"]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("change ran %d times, want 2", attempts)
+	}
+	if asset.Counts["finished"] != 3 {
+		t.Errorf("finished = %d, want 3, counting the other submission too", asset.Counts["finished"])
+	}
+}
+
+func TestUpdateDocGivesUpOnConflicts(t *testing.T) {
+	store := newVersionedStore()
+	store.Put("assets", "a1", Asset{Id: "a1"})
+	store.beforePut = func() {
+		store.Put("assets", "a1", Asset{Id: "a1"})
+	}
+	s := &Server{Store: store}
+
+	attempts := 0
+	_, err := s.updateAsset("a1", func(asset *Asset) error {
+		attempts++
+		return nil
+	})
+	if err != ErrConflict {
+		t.Errorf("updateAsset = %v, want ErrConflict", err)
+	}
+	if attempts != maxWriteAttempts {
+		t.Errorf("change ran %d times, want %d", attempts, maxWriteAttempts)
+	}
+}
+
+func TestUpdateDocUnchanged(t *testing.T) {
+	store := newVersionedStore()
+	store.Put("assignments", "x1", Assignment{Id: "x1", State: "finished"})
+	s := &Server{Store: store}
+
+	var assignment Assignment
+	err := s.updateDoc("assignments", "x1", &assignment, func(found bool) error {
+		if assignment.State != "unfinished" {
+			return errUnchanged
+		}
+		assignment.State = "expired"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("updateDoc = %v, want nil", err)
+	}
+	if store.versions["assignments/x1"] != 1 {
+		t.Errorf("the assignment was saved again, want it left as it was")
+	}
+}
+
+func TestUpdateAssetNotFound(t *testing.T) {
+	s := &Server{Store: newVersionedStore()}
+	_, err := s.updateAsset("missing", func(asset *Asset) error {
+		t.Error("change ran without an asset")
+		return nil
+	})
+	if err != ErrNotFound {
+		t.Errorf("updateAsset = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSubmitAssignmentOnlyOnce(t *testing.T) {
+	store := newVersionedStore()
+	store.Put("assets", "a1", Asset{Id: "a1", Counts: Counts{"Assignments": 1, "finished": 1}})
+	for _, state := range []string{"finished", "skipped", "expired"} {
+		store.Put("assignments", "x1", Assignment{Id: "x1", State: state, Asset: Asset{Id: "a1"}})
+		s := &Server{Store: store}
+
+		_, err := s.SubmitAssignment(&Assignment{Id: "x1", State: "finished", Asset: Asset{Id: "a1"}}, "")
+		if errorStatus(err) != 422 {
+			t.Errorf("submitting a %s assignment = %v, want a 422", state, err)
+		}
+	}
+	if store.versions["assets/a1"] != 1 {
+		t.Errorf("the asset's counts were changed, want them left as they were")
+	}
+}
@@ -0,0 +1,122 @@
+package hive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// UploadAssets stores each file in a multipart request in blob storage and imports an asset pointing at it.
+// An optional "asset" form field holds JSON-formatted fields (ex: Metadata, Language) shared by every uploaded asset.
+func (s *Server) UploadAssets(w http.ResponseWriter, r *http.Request) (assets []Asset, err error) {
+	if s.Blobs == nil {
+		return nil, errors.New("Sorry, file uploads aren't configured on this server.")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err = r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.MultipartForm.File) == 0 {
+		return nil, errors.New("Sorry, no files were uploaded.")
+	}
+
+	var template Asset
+	if assetJson := r.FormValue("asset"); assetJson != "" {
+		err = json.Unmarshal([]byte(assetJson), &template)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hashIds := s.hashesAssetIds()
+
+	var newAssets []Asset
+	for _, files := range r.MultipartForm.File {
+		for _, header := range files {
+			file, err := header.Open()
+			if err != nil {
+				return nil, err
+			}
+			content, err := ioutil.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			asset := template
+			asset.Id = ""
+
+			// a prefix keeps uploads with the same file name apart: the content hash when ids are hashed, otherwise random
+			prefix := ""
+			if hashIds {
+				asset.Id = hashAssetId(s.ActiveProjectId, content)
+				prefix = asset.Id
+			} else {
+				prefix, err = randomId()
+				if err != nil {
+					return nil, err
+				}
+			}
+			key, err := blobKey(s.ActiveProjectId, "assets", prefix, header.Filename)
+			if err != nil {
+				return nil, err
+			}
+
+			url, err := s.Blobs.Put(key, header.Header.Get("Content-Type"), bytes.NewReader(content))
+			if err != nil {
+				return nil, err
+			}
+
+			asset.Url = url
+			if asset.Name == "" {
+				asset.Name = header.Filename
+			}
+			newAssets = append(newAssets, asset)
+		}
+	}
+	assets, _, err = s.importAssets(newAssets, dedupImports(r))
+	return assets, err
+}
+
+// @Title AdminUploadAssetsHandler
+// @Description stores uploaded files and creates an asset for each one, for projects without their own file hosting
+// @Accept  multipart/form-data
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   file        formData   file     true        "One or more files, each becomes an asset"
+// @Param   asset        formData   string     false        "JSON-formatted fields shared by the new assets, ex: {\"Metadata\": {\"issue\": \"1921-03-02\"}}"
+// @Param   dedup        query   bool     false        "Unless false, a file stored at the Url of an asset already in the project updates it instead of creating another"
+// @Success 200 {object}  assetsResponse
+// @Failure 400 {object} errorResponse	what's wrong with each field of the request body
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/upload [post]
+func (s *Server) AdminUploadAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	assets, err := s.UploadAssets(w, r)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	assetsJson, err := json.Marshal(&assetsResponse{
+		Assets: assets,
+		Meta: meta{
+			Total: len(assets),
+			From:  0,
+			Size:  len(assets),
+		},
+	})
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, assetsJson)
+}
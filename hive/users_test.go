@@ -0,0 +1,25 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeUserProfile(t *testing.T) {
+	target := User{Id: "registered", Name: "Jane", Role: RoleContributor, Languages: []string{"en"}}
+	source := User{Id: "cookie", Name: "anonymous", Email: "jane@example.com", Role: RoleOwner, Languages: []string{"es", "en"}, OnboardingSteps: []string{"intro"}}
+	mergeUserProfile(&target, source)
+
+	if target.Role != RoleContributor {
+		t.Errorf("Role = %q, want the target's own %q", target.Role, RoleContributor)
+	}
+	if target.Name != "Jane" || target.Email != "jane@example.com" {
+		t.Errorf("Name, Email = %q, %q, want the target's name and the source's email", target.Name, target.Email)
+	}
+	if !reflect.DeepEqual(target.Languages, []string{"en", "es"}) {
+		t.Errorf("Languages = %v, want [en es]", target.Languages)
+	}
+	if !reflect.DeepEqual(target.OnboardingSteps, []string{"intro"}) {
+		t.Errorf("OnboardingSteps = %v, want [intro]", target.OnboardingSteps)
+	}
+}
@@ -0,0 +1,143 @@
+package hive
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// validName matches the project ids and task names hive accepts. Both end up in urls, document ids and, for
+// tasks, elasticsearch field names, where spaces, slashes and dots would break things.
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// submittableStates are the states a contributor can submit an assignment in; the rest are set by hive.
+var submittableStates = []string{"unfinished", "finished", "skipped"}
+
+// FieldError is what's wrong with one field of a request body.
+type FieldError struct {
+	Field string // where in the body, ex: "Assets[3].Url"
+	Error string
+}
+
+// ValidationError is returned for a request body that can't be saved as it is, listing every problem found. Handlers
+// answer it with a 400 rather than passing the body on to elasticsearch, see wrapFailure.
+type ValidationError struct {
+	Message string // optional, says what couldn't be done, ex: which asset of a stream stopped the import
+	Fields  []FieldError
+}
+
+func (err *ValidationError) Error() string {
+	if err.Message != "" {
+		return err.Message
+	}
+	if len(err.Fields) == 0 {
+		return "Sorry, the request isn't valid."
+	}
+	message := fmt.Sprintf("Sorry, %s %s", err.Fields[0].Field, err.Fields[0].Error)
+	if len(err.Fields) > 1 {
+		message += fmt.Sprintf(", and %d more problems", len(err.Fields)-1)
+	}
+	return message + "."
+}
+
+// add records a problem with field.
+func (err *ValidationError) add(field string, format string, args ...interface{}) {
+	err.Fields = append(err.Fields, FieldError{Field: field, Error: fmt.Sprintf(format, args...)})
+}
+
+// orNil returns the error when there's anything wrong, so callers can return it as an error without a typed nil.
+func (err *ValidationError) orNil() error {
+	if len(err.Fields) == 0 {
+		return nil
+	}
+	return err
+}
+
+// validateAssetUrl says what's wrong with an asset's url, or "" when nothing is. Assets link to http(s) urls,
+// s3:// urls for private buckets, or paths on this server, ex: uploads kept on disk.
+func validateAssetUrl(rawUrl string) string {
+	if rawUrl == "" {
+		return "is required"
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "isn't a url"
+	}
+	switch {
+	case parsed.Scheme == "http" || parsed.Scheme == "https" || parsed.Scheme == "s3":
+		if parsed.Host == "" {
+			return "needs a host"
+		}
+	case parsed.Scheme == "" && strings.HasPrefix(parsed.Path, "/") && parsed.Host == "":
+	default:
+		return "needs to be an http, https or s3 url"
+	}
+	return ""
+}
+
+// validateOrigin says what's wrong with a site allowed to call hive from the browser, or "" when nothing is. Browsers
+// send origins as a scheme and host, with a port when it isn't the default, ex: https://crowd.example.com.
+func validateOrigin(origin string) string {
+	if origin == "*" {
+		return ""
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "needs to be an http or https origin, ex: https://crowd.example.com, or *"
+	}
+	if strings.TrimSuffix(parsed.Path, "/") != "" || parsed.RawQuery != "" {
+		return "can't have a path, just the scheme and host"
+	}
+	return ""
+}
+
+// validateAsset records what's wrong with an imported asset, its fields named after prefix, ex: "Assets[3]".
+func validateAsset(invalid *ValidationError, prefix string, asset Asset) {
+	if problem := validateAssetUrl(asset.Url); problem != "" {
+		invalid.add(prefix+"Url", "%s", problem)
+	}
+}
+
+// validateTask records what's wrong with a task being created or updated.
+func validateTask(invalid *ValidationError, prefix string, task Task) {
+	if task.Name == "" {
+		invalid.add(prefix+"Name", "is required")
+	} else if !validName.MatchString(task.Name) {
+		invalid.add(prefix+"Name", "can only have letters, numbers, - and _, not %q", task.Name)
+	}
+}
+
+// validateProject records what's wrong with a project being created or updated at projectId.
+func validateProject(invalid *ValidationError, project Project, projectId string) {
+	switch {
+	case project.Id == "":
+		invalid.add("Id", "is required")
+	case !validName.MatchString(project.Id):
+		invalid.add("Id", "can only have letters, numbers, - and _, not %q", project.Id)
+	case projectId != "" && project.Id != projectId:
+		invalid.add("Id", "has to match the project in the url, %q", projectId)
+	}
+	for i, origin := range project.CorsOrigins {
+		if problem := validateOrigin(origin); problem != "" {
+			invalid.add(fmt.Sprintf("CorsOrigins[%d]", i), "%s", problem)
+		}
+	}
+	err := validateOnboarding(project.Onboarding)
+	if err != nil {
+		invalid.add("Onboarding", "%s", strings.TrimSuffix(strings.TrimPrefix(err.Error(), "Sorry, "), "."))
+	}
+}
+
+// validateSubmission records what's wrong with an assignment a contributor submitted.
+func validateSubmission(invalid *ValidationError, assignment Assignment) {
+	if assignment.Id == "" {
+		invalid.add("Id", "is required")
+	}
+	if !containsString(submittableStates, assignment.State) {
+		invalid.add("State", "has to be one of %s, not %q", strings.Join(submittableStates, ", "), assignment.State)
+	}
+	if assignment.State == "skipped" && assignment.SkipReason != "" && !containsString(skipReasons, assignment.SkipReason) {
+		invalid.add("SkipReason", "has to be one of %s, not %q", strings.Join(skipReasons, ", "), assignment.SkipReason)
+	}
+}
@@ -0,0 +1,122 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type forceVerifyResponse struct {
+	Asset    Asset
+	Credited int // finished assignments whose answer matched and were marked verified
+}
+
+// ForceVerifyAsset stores an editor's answer as the verified SubmittedData for a task, ex: to break a tie consensus
+// will never settle. Finished assignments that gave the same answer are marked verified like CompleteTask would.
+func (s *Server) ForceVerifyAsset(assetId string, taskId string, submittedData SubmittedData, editor string) (response forceVerifyResponse, err error) {
+	if !strings.HasPrefix(taskId, s.ActiveProjectId+"-") {
+		taskId = s.ActiveProjectId + "-" + taskId
+	}
+	task, err := s.FindTask(taskId)
+	if err != nil {
+		return response, errors.New("Sorry, there's no task with that name in this project.")
+	}
+	if len(submittedData) == 0 {
+		return response, errors.New("Sorry, the SubmittedData to verify the asset with can't be empty.")
+	}
+	asset, err := s.FindAsset(assetId)
+	if err != nil {
+		return
+	}
+	if asset == nil || asset.Project != s.ActiveProjectId {
+		return response, notFound("Sorry, there isn't an asset with that id in this project.")
+	}
+
+	asset, err = s.CompleteAsset(asset.Id, *task, submittedData)
+	if err != nil {
+		return
+	}
+	s.logEvent("asset verified by an editor", logFields{"asset": asset.Id, "task": task.Name, "editor": editor})
+
+	assignments, err := s.FindAssetAssignments(task.Id, asset.Id, "finished")
+	if err != nil {
+		return
+	}
+	for _, found := range assignments {
+		if !sameAnswer(submittedData, found.SubmittedData) {
+			continue
+		}
+		credited := false
+		var assignment Assignment
+		err = s.updateDoc("assignments", found.Id, &assignment, func(exists bool) error {
+			credited = false
+			if !exists || assignment.State != "finished" {
+				return errUnchanged
+			}
+			assignment.State = "verified"
+			credited = true
+			return nil
+		})
+		if err != nil {
+			return
+		}
+		if credited {
+			response.Credited++
+		}
+	}
+
+	err = s.Store.Refresh()
+	if err != nil {
+		return
+	}
+	response.Asset = *asset
+	return
+}
+
+// @Title AdminForceVerifyAssetHandler
+// @Description verifies an asset for a task with the given answer, for when consensus never forms, ex: a 2-2 split
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   asset_id        path   string     true        "Asset ID"
+// @Param   answer       body    string     true        "JSON-formatted task and answer, ex: {\"Task\": \"vote\", \"SubmittedData\": {\"Category\": \"usable\"}, \"Editor\": \"editor@example.com\"}"
+// @Success 200 {object}  forceVerifyResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /assets
+// @Router /admin/projects/{project_id}/assets/{asset_id}/verify [post]
+func (s *Server) AdminForceVerifyAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var verifyData struct {
+		Task          string
+		SubmittedData SubmittedData
+		Editor        string
+	}
+	err = json.Unmarshal(body, &verifyData)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	response, err := s.ForceVerifyAsset(vars["asset_id"], verifyData.Task, verifyData.SubmittedData, verifyData.Editor)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, responseJson)
+}
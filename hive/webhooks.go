@@ -0,0 +1,302 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// webhookAttempts is how many times a delivery is tried before it's given up on, waiting twice as long after each failure.
+const webhookAttempts = 5
+
+// webhookClient sends deliveries, giving up on receivers that hang.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookSlots limits how many deliveries are sent at once, so completing a big task doesn't flood the receiver.
+var webhookSlots = make(chan struct{}, 10)
+
+// webhookDeliveries tracks deliveries still being tried, so shutting down can wait for them.
+var webhookDeliveries sync.WaitGroup
+
+// webhookEvents are the events a webhook can be sent.
+var webhookEvents = []string{"asset.verified", "assignment.created", "assignment.finished", "assignment.skipped", "assignment.expired"}
+
+// Webhook is where a project's events are sent, ex: verified assets. Webhooks are stored apart from their project,
+// under the project's id, so the secret never shows up in project responses.
+type Webhook struct {
+	Project string
+	Url     string   // receives a POST for each event
+	Secret  string   // signs each delivery's body, see X-Hive-Signature
+	Events  []string // which of webhookEvents to send, defaults to just "asset.verified"
+}
+
+// wants reports whether the webhook should be sent event.
+func (webhook *Webhook) wants(event string) bool {
+	if len(webhook.Events) == 0 {
+		return event == "asset.verified"
+	}
+	for _, wanted := range webhook.Events {
+		if wanted == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookPayload is the body of a delivery. Asset events carry the asset, and assignment events the assignment.
+type webhookPayload struct {
+	Event         string // ex: "asset.verified"
+	Project       string
+	Asset         *Asset        `json:",omitempty"`
+	SubmittedData SubmittedData `json:",omitempty"` // the asset's verified data, by task name
+	Assignment    *Assignment   `json:",omitempty"`
+	Sent          time.Time
+}
+
+// FindWebhook returns the current project's webhook, or nil if it doesn't have one.
+// Projects without one set up through the admin API use the server's config, see Config.Webhooks.
+func (s *Server) FindWebhook() (*Webhook, error) {
+	exists, err := s.Store.Exists("webhooks", s.ActiveProjectId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		webhook, ok := s.Config.Webhooks[s.ActiveProjectId]
+		if !ok {
+			return nil, nil
+		}
+		webhook.Project = s.ActiveProjectId
+		return &webhook, nil
+	}
+	var webhook Webhook
+	err = s.Store.Get("webhooks", s.ActiveProjectId, &webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// isWebhookEvent reports whether event is one of webhookEvents.
+func isWebhookEvent(event string) bool {
+	for _, webhookEvent := range webhookEvents {
+		if event == webhookEvent {
+			return true
+		}
+	}
+	return false
+}
+
+// signWebhook returns the hex HMAC-SHA256 of body, keyed with the webhook's secret.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AssetVerified sends a verified asset to the current project's webhook, if it has one.
+func (s *Server) AssetVerified(asset Asset) {
+	s.sendEvent(webhookPayload{
+		Event:         "asset.verified",
+		Asset:         &asset,
+		SubmittedData: asset.SubmittedData,
+	})
+}
+
+// AssignmentChanged sends an assignment to the current project's webhook, if it wants event,
+// ex: "assignment.finished".
+func (s *Server) AssignmentChanged(event string, assignment Assignment) {
+	s.sendEvent(webhookPayload{
+		Event:      event,
+		Assignment: &assignment,
+	})
+}
+
+// sendEvent delivers payload to the current project's webhook when it wants the event.
+// Delivery happens in the background, so failures are only logged.
+func (s *Server) sendEvent(payload webhookPayload) {
+	webhook, err := s.FindWebhook()
+	if err != nil {
+		s.logError("failed finding webhook", err, nil)
+		return
+	}
+	if webhook == nil || webhook.Url == "" || !webhook.wants(payload.Event) {
+		return
+	}
+
+	payload.Project = s.ActiveProjectId
+	payload.Sent = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.logError("failed encoding webhook", err, logFields{"event": payload.Event})
+		return
+	}
+	webhookDeliveries.Add(1)
+	go deliverWebhook(*webhook, payload.Event, body)
+}
+
+// deliverWebhook POSTs body to the webhook, trying again with backoff until it's accepted with a 2xx.
+func deliverWebhook(webhook Webhook, event string, body []byte) {
+	defer webhookDeliveries.Done()
+	webhookSlots <- struct{}{}
+	defer func() { <-webhookSlots }()
+
+	deliveryId, err := randomId()
+	if err != nil {
+		logJson("error", "failed delivering webhook", logFields{"project": webhook.Project, "url": webhook.Url, "error": err})
+		return
+	}
+
+	wait := time.Second
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err = sendWebhook(webhook, event, deliveryId, body)
+		if err == nil {
+			return
+		}
+		logJson("warn", "webhook delivery failed", logFields{"project": webhook.Project, "url": webhook.Url, "event": event, "delivery": deliveryId, "attempt": attempt, "attempts": webhookAttempts, "error": err})
+		if attempt < webhookAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+}
+
+// waitForWebhooks waits for deliveries still being tried to finish, or ctx to be done.
+func waitForWebhooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		webhookDeliveries.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendWebhook makes a single delivery attempt.
+func sendWebhook(webhook Webhook, event string, deliveryId string, body []byte) error {
+	req, err := http.NewRequest("POST", webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hive-Event", event)
+	req.Header.Set("X-Hive-Delivery", deliveryId) // the same on every attempt, so receivers can skip repeats
+	req.Header.Set("X-Hive-Signature", "sha256="+signWebhook(webhook.Secret, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("responded %s", resp.Status)
+	}
+	return nil
+}
+
+// @Title AdminWebhookHandler
+// @Description returns the project's webhook, without its secret
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Success 200 {object}  Webhook
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/webhook [get]
+func (s *Server) AdminWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	webhook, err := s.FindWebhook()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	if webhook == nil {
+		webhook = &Webhook{Project: s.ActiveProjectId}
+	}
+	webhook.Secret = ""
+
+	webhookJson, err := json.Marshal(webhook)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, webhookJson)
+}
+
+// @Title AdminCreateWebhookHandler
+// @Description sets where the project's events are sent, and which. An empty Url turns the webhook off. A secret is generated when none is given, and returned once here.
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Param   webhook        body    string     true        "JSON-formatted webhook, ex: {\"Url\": \"https://cms.example.com/hive\", \"Secret\": \"...\", \"Events\": [\"asset.verified\"]}"
+// @Success 200 {object}  Webhook
+// @Failure 500 {object} error	appropriate error message
+// @Resource /projects
+// @Router /admin/projects/{project_id}/webhook [post]
+func (s *Server) AdminCreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	var webhook Webhook
+	err = json.Unmarshal(body, &webhook)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	webhook.Project = s.ActiveProjectId
+
+	if webhook.Url != "" {
+		parsed, err := url.Parse(webhook.Url)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			s.wrapFailure(w, r, fmt.Errorf("Sorry, %q isn't an http or https url.", webhook.Url))
+			return
+		}
+	}
+	for _, event := range webhook.Events {
+		if !isWebhookEvent(event) {
+			s.wrapFailure(w, r, fmt.Errorf("Sorry, %q isn't a webhook event. Use one of %s.", event, strings.Join(webhookEvents, ", ")))
+			return
+		}
+	}
+	if webhook.Secret == "" {
+		webhook.Secret, err = randomId()
+		if err != nil {
+			s.wrapFailure(w, r, err)
+			return
+		}
+	}
+
+	_, err = s.Store.Put("webhooks", s.ActiveProjectId, webhook)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	webhookJson, err := json.Marshal(webhook)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, webhookJson)
+}
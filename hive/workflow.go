@@ -0,0 +1,166 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// workflowStep is a task in a project's workflow, with how many assets are at it.
+type workflowStep struct {
+	Task         string // the task's name
+	CurrentState string
+	After        string `json:",omitempty"` // the task whose verified assets move on to this one, if any
+	NextTask     string `json:",omitempty"`
+	Waiting      int    // assets ready for this task: verified for the one before it, if any, and not yet for this one
+	Verified     int    // assets verified for this task
+}
+
+type workflowResponse struct {
+	Pipelines [][]workflowStep // chains of tasks linked by NextTask, each in order; tasks on their own are a chain of one
+	Problems  []string         // NextTask links that can't work, ex: to a task that doesn't exist
+}
+
+// previousTasks returns the names of the tasks in the list whose NextTask is the task, by task name.
+func previousTasks(tasks []Task) map[string][]string {
+	previous := make(map[string][]string)
+	for _, task := range tasks {
+		if task.NextTask != "" {
+			previous[task.NextTask] = append(previous[task.NextTask], task.Name)
+		}
+	}
+	return previous
+}
+
+// workflowMusts returns the filters that hold a task's assets back until they're verified for the tasks before it,
+// those whose NextTask it is. Archived tasks don't hold anything back.
+func (s *Server) workflowMusts(task Task) ([]string, error) {
+	tasks, err := s.FindLiveTasks()
+	if err != nil {
+		return nil, err
+	}
+	var musts []string
+	for _, previous := range previousTasks(tasks)[task.Name] {
+		musts = append(musts, fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, previous))
+	}
+	return musts, nil
+}
+
+// FindWorkflow lays out the current project's live tasks as the chains their NextTask links make,
+// with how far assets have got along each.
+func (s *Server) FindWorkflow() (workflow workflowResponse, err error) {
+	tasks, err := s.FindLiveTasks()
+	if err != nil {
+		return
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+
+	byName := make(map[string]Task)
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	previous := previousTasks(tasks)
+	workflow.Pipelines = [][]workflowStep{}
+	workflow.Problems = []string{}
+	for _, task := range tasks {
+		if task.NextTask == "" {
+			continue
+		}
+		if _, ok := byName[task.NextTask]; !ok {
+			workflow.Problems = append(workflow.Problems, fmt.Sprintf("Task %q moves assets on to %q, but there's no live task named that.", task.Name, task.NextTask))
+		}
+		if len(previous[task.NextTask]) > 1 && previous[task.NextTask][0] == task.Name {
+			workflow.Problems = append(workflow.Problems, fmt.Sprintf("Tasks %q all move assets on to %q, which only takes assets verified for all of them.", previous[task.NextTask], task.NextTask))
+		}
+	}
+
+	// each chain starts at a task nothing leads to, or at whatever's left over when tasks loop
+	placed := make(map[string]bool)
+	var starts []Task
+	for _, task := range tasks {
+		if len(previous[task.Name]) == 0 {
+			starts = append(starts, task)
+		}
+	}
+	for _, task := range tasks {
+		starts = append(starts, task)
+	}
+	for _, start := range starts {
+		if placed[start.Name] {
+			continue
+		}
+		var pipeline []workflowStep
+		inPipeline := make(map[string]bool)
+		after := ""
+		task, ok := start, true
+		for ok && !placed[task.Name] {
+			placed[task.Name] = true
+			inPipeline[task.Name] = true
+			step, err := s.workflowStep(task, after)
+			if err != nil {
+				return workflow, err
+			}
+			pipeline = append(pipeline, step)
+			after = task.Name
+			task, ok = byName[task.NextTask]
+		}
+		if ok && inPipeline[task.Name] {
+			workflow.Problems = append(workflow.Problems, fmt.Sprintf("Task %q leads back around to itself, so its assets can never start it.", task.Name))
+		}
+		workflow.Pipelines = append(workflow.Pipelines, pipeline)
+	}
+	return
+}
+
+// workflowStep counts the assets waiting for and verified for a task, after is the task before it, if any.
+func (s *Server) workflowStep(task Task, after string) (step workflowStep, err error) {
+	step = workflowStep{
+		Task:         task.Name,
+		CurrentState: task.CurrentState,
+		After:        after,
+		NextTask:     task.NextTask,
+	}
+	project := fmt.Sprintf(`{ "term": { "Project": "%s" } }`, s.ActiveProjectId)
+	verified := fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, task.Name)
+
+	step.Verified, err = s.countFilteredAssets([]string{project, verified}, nil)
+	if err != nil {
+		return
+	}
+
+	waitingMusts := []string{project}
+	if after != "" {
+		waitingMusts = append(waitingMusts, fmt.Sprintf(`{ "exists": { "field": "SubmittedData.%s" } }`, after))
+	}
+	step.Waiting, err = s.countFilteredAssets(waitingMusts, []string{verified, `{ "term": { "Excluded": true } }`})
+	return
+}
+
+// @Title AdminWorkflowHandler
+// @Description returns the project's tasks as the pipelines their NextTask links make, with how many assets are waiting at and verified for each
+// @Accept  json
+// @Param   project_id     path    string     true        "Project ID"
+// @Success 200 {object}  workflowResponse
+// @Failure 500 {object} error	appropriate error message
+// @Resource /tasks
+// @Router /admin/projects/{project_id}/workflow [get]
+func (s *Server) AdminWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r) // params in URL
+	s = s.forProject(vars["project_id"])
+
+	workflow, err := s.FindWorkflow()
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+
+	workflowJson, err := json.Marshal(workflow)
+	if err != nil {
+		s.wrapFailure(w, r, err)
+		return
+	}
+	s.wrapResponse(w, r, 200, workflowJson)
+}
@@ -0,0 +1,288 @@
+// Package loadgen fills a hive instance with synthetic projects, assets and users, then has those users work
+// through assignments concurrently, so Elasticsearch sizing and the assignment engine can be checked before launch.
+package loadgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nytlabs/hive/hive"
+)
+
+// importBatchSize is how many assets are imported per request.
+const importBatchSize = 500
+
+// Config says how much synthetic data to generate and where to send it.
+type Config struct {
+	Target      string // base url of the hive instance, ex: "http://localhost:8080"
+	AdminKey    string // API key for the admin endpoints, when the instance requires one
+	Prefix      string // generated project ids are Prefix-1, Prefix-2, etc.
+	Projects    int
+	Assets      int // assets per project
+	Users       int // users per project
+	Assignments int // assignments each user submits
+	Concurrency int // how many users work at once
+}
+
+// Report tallies what a run created and how the assignment requests performed.
+type Report struct {
+	Projects    int
+	Assets      int
+	Users       int
+	Assignments int // assignments submitted
+	Errors      int // failed requests, including users who ran out of assets
+	Elapsed     time.Duration
+	Median      time.Duration // assignment request latency
+	P95         time.Duration
+	Max         time.Duration
+
+	latencies []time.Duration
+	mu        sync.Mutex
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%d projects, %d assets, %d users, %d assignments, %d errors in %s; assignment latency median %s, p95 %s, max %s",
+		r.Projects, r.Assets, r.Users, r.Assignments, r.Errors, r.Elapsed, r.Median, r.P95, r.Max)
+}
+
+// record adds an assignment request's outcome to the report.
+func (r *Report) record(latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.Errors++
+		return
+	}
+	r.Assignments++
+	r.latencies = append(r.latencies, latency)
+}
+
+// summarize fills in latency percentiles.
+func (r *Report) summarize() {
+	if len(r.latencies) == 0 {
+		return
+	}
+	sort.Sort(byDuration(r.latencies))
+	r.Median = r.latencies[len(r.latencies)/2]
+	r.P95 = r.latencies[len(r.latencies)*95/100]
+	r.Max = r.latencies[len(r.latencies)-1]
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+
+// generator sends requests to the target hive instance.
+type generator struct {
+	config Config
+	client *http.Client
+	report *Report
+}
+
+// Run generates the configured projects one after another, reporting on the whole run.
+func Run(config Config) (*Report, error) {
+	if config.Target == "" {
+		return nil, errors.New("Sorry, loadgen needs a target hive url.")
+	}
+	if config.Prefix == "" {
+		config.Prefix = "loadgen"
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+
+	g := &generator{
+		config: config,
+		client: &http.Client{Timeout: time.Minute},
+		report: &Report{},
+	}
+
+	start := time.Now()
+	for i := 1; i <= config.Projects; i++ {
+		err := g.runProject(fmt.Sprintf("%s-%d", config.Prefix, i))
+		if err != nil {
+			return g.report, err
+		}
+	}
+	g.report.Elapsed = time.Since(start)
+	g.report.summarize()
+	return g.report, nil
+}
+
+// runProject creates a project with a single voting task, imports its assets and users, then puts the users to work.
+func (g *generator) runProject(projectId string) error {
+	log.Println("loadgen: creating project", projectId)
+	project := hive.Project{
+		Id:          projectId,
+		Name:        "Load test " + projectId,
+		Description: "Synthetic data generated by hive loadgen",
+	}
+	err := g.send("POST", "/admin/projects/"+projectId, "", project, nil)
+	if err != nil {
+		return err
+	}
+	g.report.Projects++
+
+	task := hive.Task{
+		Name:         "vote",
+		Description:  "Is this a photo of a cat?",
+		CurrentState: "available",
+		CompletionCriteria: hive.CompletionCriteria{
+			Total:    3,
+			Matching: 2,
+		},
+	}
+	err = g.send("POST", "/admin/projects/"+projectId+"/tasks", "", map[string][]hive.Task{"Tasks": {task}}, nil)
+	if err != nil {
+		return err
+	}
+
+	for imported := 0; imported < g.config.Assets; imported += importBatchSize {
+		var assets []hive.Asset
+		for n := imported; n < imported+importBatchSize && n < g.config.Assets; n++ {
+			assets = append(assets, hive.Asset{
+				Url:  fmt.Sprintf("https://example.com/loadgen/%s/%d.jpg", projectId, n),
+				Name: fmt.Sprintf("Asset %d", n),
+			})
+		}
+		err = g.send("POST", "/admin/projects/"+projectId+"/assets", "", map[string][]hive.Asset{"Assets": assets}, nil)
+		if err != nil {
+			return err
+		}
+		g.report.Assets += len(assets)
+	}
+
+	log.Println("loadgen: creating", g.config.Users, "users in", projectId)
+	var sessions []string
+	for n := 0; n < g.config.Users; n++ {
+		var user hive.User
+		cookies, err := g.exchange("POST", "/projects/"+projectId+"/user", "", hive.User{
+			Name:  fmt.Sprintf("Load test user %d", n),
+			Email: fmt.Sprintf("loadgen+%s-%d@example.com", projectId, n),
+		}, &user)
+		if err != nil {
+			return err
+		}
+
+		// use the session hive started for the user, which is signed when the target has a secret
+		session := projectId + "_user_id=" + user.Id
+		for _, cookie := range cookies {
+			if cookie.Name == projectId+"_user_id" {
+				session = cookie.Name + "=" + cookie.Value
+			}
+		}
+		sessions = append(sessions, session)
+		g.report.Users++
+	}
+
+	log.Println("loadgen: submitting assignments in", projectId)
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < g.config.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for session := range work {
+				g.work(projectId, task.Name, session)
+			}
+		}()
+	}
+	for _, session := range sessions {
+		work <- session
+	}
+	close(work)
+	wg.Wait()
+	return nil
+}
+
+// work has a user take an assignment and submit answers until they've done their share or run out of assets.
+// cookie is the user's session, ex: "loadgen-1_user_id=...".
+func (g *generator) work(projectId string, taskName string, cookie string) {
+	path := "/projects/" + projectId + "/tasks/" + taskName + "/assignments"
+
+	var assignment hive.Assignment
+	start := time.Now()
+	err := g.send("GET", path, cookie, nil, &assignment)
+	if err != nil {
+		g.report.record(time.Since(start), err)
+		return
+	}
+
+	for n := 0; n < g.config.Assignments; n++ {
+		answer := "no"
+		if rand.Intn(3) > 0 {
+			answer = "yes"
+		}
+		assignment.State = "finished"
+		assignment.SubmittedData = hive.SubmittedData{"cat": answer}
+
+		var next hive.Assignment
+		start = time.Now()
+		err = g.send("POST", path, cookie, assignment, &next)
+		g.report.record(time.Since(start), err)
+		if err != nil {
+			return
+		}
+		assignment = next
+	}
+}
+
+// send makes a request to the target with body as JSON, decoding the response into result when it's given.
+func (g *generator) send(method string, path string, cookie string, body interface{}, result interface{}) error {
+	_, err := g.exchange(method, path, cookie, body, result)
+	return err
+}
+
+// exchange is send, also returning any cookies the response sets.
+func (g *generator) exchange(method string, path string, cookie string, body interface{}, result interface{}) ([]*http.Cookie, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(g.config.Target, "/")+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.config.AdminKey != "" && strings.HasPrefix(path, "/admin/") {
+		req.Header.Set("Authorization", "Bearer "+g.config.AdminKey)
+	}
+	if cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, resp.Status, respBody)
+	}
+	if result != nil {
+		err = json.Unmarshal(respBody, result)
+	}
+	return resp.Cookies(), err
+}
@@ -2,49 +2,126 @@ package main
 
 import (
 	"flag"
+	"log"
 	"os"
+	"time"
 
-	elastigo "github.com/jacqui/elastigo/lib"
 	"github.com/nytlabs/hive/hive"
+	"github.com/nytlabs/hive/loadgen"
 )
 
 var (
-	port     = flag.String("port", "8080", "hive port")
-	esDomain = flag.String("esDomain", "localhost", "elasticsearch domain")
-	esPort   = flag.String("esPort", "9200", "elasticsearch port")
-	index    = flag.String("index", "hive", "elasticsearch index name")
+	configPath = flag.String("config", os.Getenv("HIVE_CONFIG"), "YAML config file, see README")
+	port       = flag.String("port", "8080", "hive port")
+	esDomain   = flag.String("esDomain", "localhost", "comma-separated elasticsearch hosts")
+	esPort     = flag.String("esPort", "9200", "elasticsearch port")
+	index      = flag.String("index", "hive", "elasticsearch index name")
+	esVersion  = flag.String("esVersion", "", "elasticsearch release, ex: 7; asked of the cluster when empty")
+	secret     = flag.String("secret", "", "secret key used to sign login links and sessions")
+	adminKeys  = flag.String("adminKeys", "", "comma-separated API keys required by admin endpoints")
+	baseUrl    = flag.String("baseUrl", "http://localhost:8080", "public url of this hive server")
+	smtpAddr   = flag.String("smtpAddr", "", "smtp server (host:port) for sending login emails")
+	mailFrom   = flag.String("mailFrom", "", "address login emails are sent from")
+	blobDir    = flag.String("blobDir", "", "directory to store uploaded files in")
+	s3Bucket   = flag.String("s3Bucket", "", "s3 bucket to store uploaded files in, instead of blobDir")
+	s3Region   = flag.String("s3Region", "us-east-1", "region of the s3 bucket")
+	shutdown   = flag.Duration("shutdownTimeout", 30*time.Second, "how long to wait for in-flight requests when stopping")
+	reconcile  = flag.Duration("reconcileInterval", time.Hour, "how often to repair stored counts that have drifted, 0 for never")
 )
 
 func main() {
-	flag.Parse()
-
-	s := hive.NewServer()
+	// hive-server loadgen -target http://localhost:8080 ... generates synthetic traffic instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		runLoadgen(os.Args[2:])
+		return
+	}
 
-	// what port should the hive server run on
-	s.Port = *port
+	flag.Parse()
 
-	// allow overriding elasticsearch index name
-	// this is useful for testing
-	s.Index = *index
+	// settings come from the defaults, then the config file, then flags, then environment variables
+	config := hive.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		config, err = hive.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalln("failed reading config:", err)
+		}
+	}
 
-	conn := elastigo.NewConn()
+	// only flags given on the command line override the file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			config.Port = *port
+		case "esDomain":
+			config.EsHosts = hive.SplitList(*esDomain)
+		case "esPort":
+			config.EsPort = *esPort
+		case "index":
+			config.Index = *index
+		case "esVersion":
+			config.EsVersion = *esVersion
+		case "secret":
+			config.Secret = *secret
+		case "adminKeys":
+			config.AdminKeys = hive.SplitList(*adminKeys)
+		case "baseUrl":
+			config.BaseUrl = *baseUrl
+		case "smtpAddr":
+			config.SmtpAddr = *smtpAddr
+		case "mailFrom":
+			config.MailFrom = *mailFrom
+		case "blobDir":
+			config.BlobDir = *blobDir
+		case "s3Bucket":
+			config.S3Bucket = *s3Bucket
+		case "s3Region":
+			config.S3Region = *s3Region
+		case "shutdownTimeout":
+			config.ShutdownTimeout = *shutdown
+		case "reconcileInterval":
+			config.ReconcileInterval = *reconcile
+		}
+	})
 
 	// EnvVar set via etcd/fleet
-	esDomainEnv := os.Getenv("ELASTICSEARCH_DOMAIN")
-	esPortEnv := os.Getenv("ELASTICSEARCH_PORT")
-	if esDomainEnv != "" {
-		conn.Domain = esDomainEnv
-	} else {
-		conn.Domain = *esDomain
+	err := config.ApplyEnv()
+	if err != nil {
+		log.Fatalln("failed reading environment:", err)
 	}
 
-	if esPortEnv != "" {
-		conn.Port = esPortEnv
-	} else {
-		conn.Port = *esPort
-	}
+	s := hive.NewServer()
+	s.Configure(config)
+	s.Run()
+}
 
-	s.EsConn = *conn
+// runLoadgen fills a hive instance with synthetic projects, assets, users and assignment traffic.
+func runLoadgen(args []string) {
+	flags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	target := flags.String("target", "http://localhost:8080", "url of the hive instance to load")
+	adminKey := flags.String("adminKey", os.Getenv("HIVE_ADMIN_KEY"), "API key for the target's admin endpoints, if it requires one")
+	prefix := flags.String("prefix", "loadgen", "prefix for generated project ids")
+	projects := flags.Int("projects", 1, "number of projects to generate")
+	assets := flags.Int("assets", 1000, "assets per project")
+	users := flags.Int("users", 100, "users per project")
+	assignments := flags.Int("assignments", 10, "assignments each user submits")
+	concurrency := flags.Int("concurrency", 10, "users working at once")
+	flags.Parse(args)
 
-	s.Run()
+	report, err := loadgen.Run(loadgen.Config{
+		Target:      *target,
+		AdminKey:    *adminKey,
+		Prefix:      *prefix,
+		Projects:    *projects,
+		Assets:      *assets,
+		Users:       *users,
+		Assignments: *assignments,
+		Concurrency: *concurrency,
+	})
+	if report != nil {
+		log.Println("loadgen:", report)
+	}
+	if err != nil {
+		log.Fatalln("loadgen failed:", err)
+	}
 }